@@ -0,0 +1,242 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/licensing"
+)
+
+// host statuses by KLHST_WKS_STATUS_ID
+var hostStatuses = []string{"ok", "critical", "warning"}
+
+// hostStatusMaskOldBases bit 7 of KLHST_WKS_STATUS_MASK_0 set if anti-virus bases are outdated
+const hostStatusMaskOldBases = 1 << 7
+
+// real-time protection states of KLHST_WKS_RTP_STATE meaning protection is off
+var rtpOffStates = map[int64]bool{
+	1: true, // stopped
+	2: true, // suspended
+	9: true, // failure
+}
+
+var hostAttributes = []string{
+	"KLHST_WKS_GROUPID",
+	"KLHST_WKS_STATUS_ID",
+	"KLHST_WKS_STATUS_MASK_0",
+	"KLHST_WKS_RTP_STATE",
+	"KLHST_WKS_NAG_VERSION",
+	"KLHST_WKS_LAST_VISIBLE",
+}
+
+func paramTime(p kaspersky.Params, key string) time.Time {
+	if typed, ok := p[key].(kaspersky.TypedValue); ok && typed.Type == "datetime" {
+		if s, ok := typed.Value.(string); ok {
+			t, _ := time.Parse(time.RFC3339, s)
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// groupPaths resolves administration group paths for labels.
+type groupPaths struct {
+	client *kaspersky.Client
+	paths  map[int64]string
+}
+
+func (gp *groupPaths) path(ctx context.Context, id int64) (string, error) {
+	if path, ok := gp.paths[id]; ok {
+		return path, nil
+	}
+	path, err := gp.client.HostGroup.GroupPath(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("group %d: %w", id, err)
+	}
+	if gp.paths == nil {
+		gp.paths = map[int64]string{}
+	}
+	gp.paths[id] = path
+	return path, nil
+}
+
+// collectHosts returns metrics of hosts by administration group.
+// Hosts not visible on the network for longer than notSeen are counted as not seen.
+func collectHosts(notSeen time.Duration, now func() time.Time) collector {
+	return func(ctx context.Context, client *kaspersky.Client) ([]*family, error) {
+		hosts := gauge("ksc_hosts", "Number of hosts by status.")
+		outdated := gauge("ksc_hosts_bases_outdated", "Number of hosts with outdated anti-virus bases.")
+		stale := gauge("ksc_hosts_not_seen", fmt.Sprintf("Number of hosts not visible on the network for more than %s.", notSeen))
+		rtpOff := gauge("ksc_hosts_rtp_off", "Number of hosts with real-time protection stopped, suspended or failed.")
+		agents := gauge("ksc_agents", "Number of Network Agents by version.")
+
+		accessor, _, err := client.HostGroup.FindHosts(ctx, kaspersky.HGParams{
+			WstrFilter:        `(KLHST_WKS_DN="*")`,
+			VecFieldsToReturn: hostAttributes,
+			PParams:           kaspersky.PParams{KlgrpFindFromCurVsOnly: true},
+			LMaxLifeTime:      600,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer client.ChunkAccessor.Release(context.Background(), accessor.StrAccessor)
+
+		groups := &groupPaths{client: client}
+		threshold := now().Add(-notSeen)
+		err = client.ChunkAccessor.ForEachItem(ctx, accessor.StrAccessor, 0, func(item json.RawMessage) error {
+			var host kaspersky.Params
+			if err := json.Unmarshal(item, &host); err != nil {
+				return err
+			}
+
+			group, err := groups.path(ctx, host.Int("KLHST_WKS_GROUPID"))
+			if err != nil {
+				return err
+			}
+
+			// all series of the group are exported even if they are zero
+			status := host.Int("KLHST_WKS_STATUS_ID")
+			for i, name := range hostStatuses {
+				value := 0.0
+				if int64(i) == status {
+					value = 1
+				}
+				hosts.add(value, "group", group, "status", name)
+			}
+
+			value := func(b bool) float64 {
+				if b {
+					return 1
+				}
+				return 0
+			}
+			outdated.add(value(host.Int("KLHST_WKS_STATUS_MASK_0")&hostStatusMaskOldBases != 0), "group", group)
+			lastVisible := paramTime(host, "KLHST_WKS_LAST_VISIBLE")
+			stale.add(value(!lastVisible.IsZero() && lastVisible.Before(threshold)), "group", group)
+			rtpOff.add(value(rtpOffStates[host.Int("KLHST_WKS_RTP_STATE")]), "group", group)
+
+			if version := host.String("KLHST_WKS_NAG_VERSION"); version != "" {
+				agents.add(1, "group", group, "version", version)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*family{hosts, outdated, stale, rtpOff, agents}, nil
+	}
+}
+
+// collectLicenses returns metrics of license keys.
+func collectLicenses(ctx context.Context, client *kaspersky.Client) ([]*family, error) {
+	total := gauge("ksc_license_key_licenses", "Number of licenses of the license key.")
+	used := gauge("ksc_license_key_used", "Number of hosts using the license key.")
+	expiry := gauge("ksc_license_key_expiry_timestamp_seconds", "Expiration time of the license key.")
+
+	keys, err := licensing.Keys(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		labels := []string{"serial", key.Serial, "product", key.ProductName}
+		total.set(float64(key.LicenseCount), labels...)
+		used.set(float64(key.Used), labels...)
+		if t := key.LimitDate.Time(); !t.IsZero() {
+			expiry.set(float64(t.Unix()), labels...)
+		}
+	}
+	return []*family{total, used, expiry}, nil
+}
+
+// collectDatabase returns metrics of the Administration Server database.
+func collectDatabase(ctx context.Context, client *kaspersky.Client) ([]*family, error) {
+	size, _, err := client.DatabaseInfo.GetDBSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events, _, err := client.DatabaseInfo.GetDBEventsCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbSize := gauge("ksc_database_size_bytes", "Size of the Administration Server database.")
+	dbSize.set(float64(size.Int))
+	dbEvents := gauge("ksc_database_events", "Number of events in the Administration Server database.")
+	dbEvents.set(float64(events.Int))
+	return []*family{dbSize, dbEvents}, nil
+}
+
+// collectTasks returns metrics of failed tasks by administration group, global tasks have empty group label.
+func collectTasks(ctx context.Context, client *kaspersky.Client) ([]*family, error) {
+	failedTasks := gauge("ksc_tasks_failed", "Number of tasks failed on at least one host.")
+	failedHosts := gauge("ksc_task_failed_hosts", "Number of hosts the tasks failed on.")
+
+	type task struct {
+		id    string
+		group int64
+	}
+	var tasks []task
+	err := client.Tasks.ForEachTask(ctx, kaspersky.TasksIteratorParams{}, func(t kaspersky.Params) error {
+		group := int64(-1)
+		if info := t.Params("TASK_INFO_PARAMS"); info.Has("PRTS_TASK_GROUPID") {
+			group = info.Int("PRTS_TASK_GROUPID")
+		}
+		tasks = append(tasks, task{id: t.String("TASK_UNIQUE_ID"), group: group})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := &groupPaths{client: client}
+	for _, t := range tasks {
+		var group string
+		if t.group >= 0 {
+			if group, err = groups.path(ctx, t.group); err != nil {
+				return nil, err
+			}
+		}
+
+		statistics, _, err := client.Tasks.GetTaskStatistics(ctx, t.id)
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %w", t.id, err)
+		}
+		failed := statistics.TaskStatistic.The16
+
+		value := 0.0
+		if failed > 0 {
+			value = 1
+		}
+		failedTasks.add(value, "group", group)
+		failedHosts.add(float64(failed), "group", group)
+	}
+	return []*family{failedTasks, failedHosts}, nil
+}
@@ -0,0 +1,231 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// collector acquires metrics from the Administration Server
+type collector func(ctx context.Context, client *kaspersky.Client) ([]*family, error)
+
+// section group of metrics refreshed together
+type section struct {
+	name     string
+	interval time.Duration
+	collect  collector
+}
+
+// cached last metrics of section
+type cached struct {
+	families  []*family
+	refreshed time.Time
+	duration  time.Duration
+	success   bool
+	errors    int
+	next      time.Time
+}
+
+// Options of Exporter
+type Options struct {
+	// Interval of hosts and tasks metrics refresh
+	Interval time.Duration
+
+	// SlowInterval of license keys and database metrics refresh
+	SlowInterval time.Duration
+
+	// NotSeen hosts not visible on the network for longer are counted in ksc_hosts_not_seen
+	NotSeen time.Duration
+
+	// Timeout of refresh of one section
+	Timeout time.Duration
+}
+
+// Exporter refreshes metrics of the Administration Server in background and serves the last ones to scrapes,
+// scrapes never cause requests to the Administration Server.
+type Exporter struct {
+	connect  func(ctx context.Context) (*kaspersky.Client, error)
+	sections []section
+	timeout  time.Duration
+	logger   *log.Logger
+	now      func() time.Time
+
+	// client is used by the refresh loop only
+	client *kaspersky.Client
+
+	mu    sync.RWMutex
+	cache map[string]*cached
+}
+
+// NewExporter returns exporter logging on the Administration Server by connect.
+func NewExporter(opts Options, connect func(ctx context.Context) (*kaspersky.Client, error), logger *log.Logger) *Exporter {
+	e := &Exporter{connect: connect, timeout: opts.Timeout, logger: logger, now: time.Now, cache: map[string]*cached{}}
+	e.sections = []section{
+		{"hosts", opts.Interval, collectHosts(opts.NotSeen, func() time.Time { return e.now() })},
+		{"tasks", opts.Interval, collectTasks},
+		{"licenses", opts.SlowInterval, collectLicenses},
+		{"database", opts.SlowInterval, collectDatabase},
+	}
+	for _, s := range e.sections {
+		e.cache[s.name] = &cached{}
+	}
+	return e
+}
+
+// Run refreshes sections when their intervals elapse until ctx is done.
+func (e *Exporter) Run(ctx context.Context) {
+	for {
+		next := e.refreshDue(ctx)
+
+		timer := time.NewTimer(next.Sub(e.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshDue refreshes sections due for refresh and returns time of the next refresh.
+func (e *Exporter) refreshDue(ctx context.Context) time.Time {
+	var next time.Time
+	for _, s := range e.sections {
+		e.mu.RLock()
+		due := e.cache[s.name].next
+		e.mu.RUnlock()
+
+		if !e.now().Before(due) {
+			e.refresh(ctx, s)
+			due = e.now().Add(s.interval)
+			e.mu.Lock()
+			e.cache[s.name].next = due
+			e.mu.Unlock()
+		}
+		if next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+	return next
+}
+
+// refresh collects metrics of section s keeping the previous ones on failure.
+func (e *Exporter) refresh(ctx context.Context, s section) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	started := e.now()
+	families, err := e.collect(ctx, s.collect)
+	duration := e.now().Sub(started)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.cache[s.name]
+	c.duration = duration
+	c.success = err == nil
+	if err != nil {
+		c.errors++
+		e.logf("refresh %s: %v", s.name, err)
+		return
+	}
+	c.families = families
+	c.refreshed = started
+}
+
+// collect calls fn with client logged on the Administration Server. If the session has expired
+// the exporter logs on again and fn is retried once.
+func (e *Exporter) collect(ctx context.Context, fn collector) ([]*family, error) {
+	for attempt := 0; ; attempt++ {
+		if e.client == nil {
+			client, err := e.connect(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("log on: %w", err)
+			}
+			e.client = client
+		}
+
+		families, err := fn(ctx, e.client)
+		var se *kaspersky.StatusError
+		if attempt == 0 && errors.As(err, &se) &&
+			(se.StatusCode == http.StatusUnauthorized || se.StatusCode == http.StatusForbidden) {
+			e.client = nil
+			continue
+		}
+		return families, err
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+
+	refreshed := gauge("ksc_exporter_refresh_timestamp_seconds", "Time of the last successful refresh of metrics section.")
+	duration := gauge("ksc_exporter_refresh_duration_seconds", "Duration of the last refresh of metrics section.")
+	success := gauge("ksc_exporter_refresh_success", "Whether the last refresh of metrics section succeeded.")
+	failures := counter("ksc_exporter_refresh_errors_total", "Number of failed refreshes of metrics section.")
+
+	var families []*family
+	e.mu.RLock()
+	for _, s := range e.sections {
+		c := e.cache[s.name]
+		families = append(families, c.families...)
+		if !c.refreshed.IsZero() {
+			refreshed.set(float64(c.refreshed.Unix()), "section", s.name)
+		}
+		duration.set(c.duration.Seconds(), "section", s.name)
+		value := 0.0
+		if c.success {
+			value = 1
+		}
+		success.set(value, "section", s.name)
+		failures.set(float64(c.errors), "section", s.name)
+	}
+	e.mu.RUnlock()
+	families = append(families, refreshed, duration, success, failures)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := writeFamilies(w, families); err != nil {
+		e.logf("write metrics: %v", err)
+	}
+}
+
+func (e *Exporter) logf(format string, args ...interface{}) {
+	if e.logger != nil {
+		e.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+var testNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeKSC returns fake Administration Server.
+func fakeKSC(t *testing.T) *ksctest.Server {
+	srv := ksctest.NewServer(t)
+
+	// hosts, KLHST_WKS_STATUS_MASK_0 bit 0x80 is outdated bases, 0x20 and 0x01 are other statuses
+	srv.Reply("HostGroup.FindHosts", `{"strAccessor": "acc", "PxgRetVal": 3}`)
+	srv.Reply("ChunkAccessor.GetItemsCount", `{"PxgRetVal": 3}`)
+	srv.Reply("ChunkAccessor.GetItemsChunk", `{"pChunk": {"KLCSP_ITERATOR_ARRAY": [
+		{"type": "params", "value": {"KLHST_WKS_GROUPID": 1, "KLHST_WKS_STATUS_ID": 0,
+			"KLHST_WKS_STATUS_MASK_0": {"type": "long", "value": 32}, "KLHST_WKS_RTP_STATE": 4,
+			"KLHST_WKS_NAG_VERSION": "13.0.0.11247",
+			"KLHST_WKS_LAST_VISIBLE": {"type": "datetime", "value": "2020-06-01T11:00:00Z"}}},
+		{"type": "params", "value": {"KLHST_WKS_GROUPID": 2, "KLHST_WKS_STATUS_ID": 1,
+			"KLHST_WKS_STATUS_MASK_0": {"type": "long", "value": 128}, "KLHST_WKS_RTP_STATE": 1,
+			"KLHST_WKS_NAG_VERSION": "12.0.0.7734",
+			"KLHST_WKS_LAST_VISIBLE": {"type": "datetime", "value": "2020-05-01T11:00:00Z"}}},
+		{"type": "params", "value": {"KLHST_WKS_GROUPID": 2, "KLHST_WKS_STATUS_ID": 2,
+			"KLHST_WKS_STATUS_MASK_0": {"type": "long", "value": 129}, "KLHST_WKS_RTP_STATE": 9,
+			"KLHST_WKS_NAG_VERSION": "13.0.0.11247",
+			"KLHST_WKS_LAST_VISIBLE": {"type": "datetime", "value": "2020-06-01T11:00:00Z"}}}
+	]}, "PxgRetVal": 3}`)
+
+	// groups
+	srv.Reply("HostGroup.GroupIdGroups", `{"PxgRetVal": 1}`)
+	srv.Handle("HostGroup.GetGroupInfoEx", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NGroupID int64 `json:"nGroupId"`
+		}
+		ksctest.Params(r, &in)
+		switch in.NGroupID {
+		case 1:
+			w.Write([]byte(`{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`))
+		case 2:
+			w.Write([]byte(`{"PxgRetVal": {"name": "Branch", "parentId": 1}}`))
+		}
+	})
+
+	// tasks
+	var next int32
+	srv.Reply("Tasks.ResetTasksIterator", `{"strTaskIteratorId": "it"}`)
+	srv.Handle("Tasks.GetNextTask", func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&next, 1) {
+		case 1:
+			w.Write([]byte(`{"pTaskData": {"TASK_UNIQUE_ID": "1", "TASK_INFO_PARAMS": {"type": "params",
+				"value": {"PRTS_TASK_GROUPID": 2}}}, "PxgRetVal": true}`))
+		case 2:
+			w.Write([]byte(`{"pTaskData": {"TASK_UNIQUE_ID": "2"}, "PxgRetVal": true}`))
+		default:
+			atomic.StoreInt32(&next, 0)
+			w.Write([]byte(`{"PxgRetVal": false}`))
+		}
+	})
+	srv.Handle("Tasks.GetTaskStatistics", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			StrTask string `json:"strTask"`
+		}
+		ksctest.Params(r, &in)
+		if in.StrTask == "1" {
+			w.Write([]byte(`{"PxgRetVal": {"4": 10, "16": 3}}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": {"4": 5}}`))
+	})
+
+	// license keys
+	srv.Reply("SrvView.ResetIterator", `{"wstrIteratorId": "it"}`)
+	srv.Reply("SrvView.GetRecordCount", `{"PxgRetVal": 1}`)
+	srv.Reply("SrvView.GetRecordRange", `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+		{"type": "params", "value": {"KLLIC_SERIAL": "AAAA-0001", "KLLIC_PROD_NAME": "KES", "KLLIC_LIC_COUNT": 100,
+			"KLLIC_LIMIT_DATE": {"type": "datetime", "value": "2021-01-01T00:00:00Z"}}}
+	]}}`)
+	srv.Reply("LicenseKeys.GetKeyData", `{"pKeyData": {"KLLIC_SERIAL": "AAAA-0001",
+		"KLLIC_PROD_NAME": "KES", "KLLIC_LIC_COUNT": 100,
+		"KLLIC_LIMIT_DATE": {"type": "datetime", "value": "2021-01-01T00:00:00Z"}}}`)
+	srv.Reply("LicenseKeys.AcquireKeyHosts", `{"lKeyCount": 42, "wstrIterator": "hosts"}`)
+	t.Cleanup(func() {
+		acquired, released := srv.Calls("LicenseKeys.AcquireKeyHosts"), srv.Calls("HostsKeyIterator.ReleaseIterator")
+		if acquired != released {
+			t.Errorf("%d license key hosts iterators are not released", acquired-released)
+		}
+	})
+
+	// database
+	srv.Reply("DatabaseInfo.GetDBSize", `{"PxgRetVal": 1048576}`)
+	srv.Reply("DatabaseInfo.GetDBEventsCount", `{"PxgRetVal": 5000}`)
+
+	return srv
+}
+
+func newTestExporter(server string) *Exporter {
+	e := NewExporter(Options{Interval: time.Minute, SlowInterval: time.Hour, NotSeen: 7 * 24 * time.Hour},
+		func(ctx context.Context) (*kaspersky.Client, error) {
+			client := kaspersky.New(kaspersky.Config{Server: server})
+			return client, client.KSCAuth(ctx)
+		}, nil)
+	e.now = func() time.Time { return testNow }
+	return e
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestExporterMetrics(t *testing.T) {
+	ksc := fakeKSC(t)
+	e := newTestExporter(ksc.URL)
+
+	next := e.refreshDue(context.Background())
+	if !next.Equal(testNow.Add(time.Minute)) {
+		t.Fatalf("unexpected next refresh %v", next)
+	}
+
+	expected := `# HELP ksc_hosts Number of hosts by status.
+# TYPE ksc_hosts gauge
+ksc_hosts{group="Managed devices",status="critical"} 0
+ksc_hosts{group="Managed devices",status="ok"} 1
+ksc_hosts{group="Managed devices",status="warning"} 0
+ksc_hosts{group="Managed devices/Branch",status="critical"} 1
+ksc_hosts{group="Managed devices/Branch",status="ok"} 0
+ksc_hosts{group="Managed devices/Branch",status="warning"} 1
+# HELP ksc_hosts_bases_outdated Number of hosts with outdated anti-virus bases.
+# TYPE ksc_hosts_bases_outdated gauge
+ksc_hosts_bases_outdated{group="Managed devices"} 0
+ksc_hosts_bases_outdated{group="Managed devices/Branch"} 2
+# HELP ksc_hosts_not_seen Number of hosts not visible on the network for more than 168h0m0s.
+# TYPE ksc_hosts_not_seen gauge
+ksc_hosts_not_seen{group="Managed devices"} 0
+ksc_hosts_not_seen{group="Managed devices/Branch"} 1
+# HELP ksc_hosts_rtp_off Number of hosts with real-time protection stopped, suspended or failed.
+# TYPE ksc_hosts_rtp_off gauge
+ksc_hosts_rtp_off{group="Managed devices"} 0
+ksc_hosts_rtp_off{group="Managed devices/Branch"} 2
+# HELP ksc_agents Number of Network Agents by version.
+# TYPE ksc_agents gauge
+ksc_agents{group="Managed devices",version="13.0.0.11247"} 1
+ksc_agents{group="Managed devices/Branch",version="12.0.0.7734"} 1
+ksc_agents{group="Managed devices/Branch",version="13.0.0.11247"} 1
+# HELP ksc_tasks_failed Number of tasks failed on at least one host.
+# TYPE ksc_tasks_failed gauge
+ksc_tasks_failed{group=""} 0
+ksc_tasks_failed{group="Managed devices/Branch"} 1
+# HELP ksc_task_failed_hosts Number of hosts the tasks failed on.
+# TYPE ksc_task_failed_hosts gauge
+ksc_task_failed_hosts{group=""} 0
+ksc_task_failed_hosts{group="Managed devices/Branch"} 3
+# HELP ksc_license_key_licenses Number of licenses of the license key.
+# TYPE ksc_license_key_licenses gauge
+ksc_license_key_licenses{serial="AAAA-0001",product="KES"} 100
+# HELP ksc_license_key_used Number of hosts using the license key.
+# TYPE ksc_license_key_used gauge
+ksc_license_key_used{serial="AAAA-0001",product="KES"} 42
+# HELP ksc_license_key_expiry_timestamp_seconds Expiration time of the license key.
+# TYPE ksc_license_key_expiry_timestamp_seconds gauge
+ksc_license_key_expiry_timestamp_seconds{serial="AAAA-0001",product="KES"} 1609459200
+# HELP ksc_database_size_bytes Size of the Administration Server database.
+# TYPE ksc_database_size_bytes gauge
+ksc_database_size_bytes 1048576
+# HELP ksc_database_events Number of events in the Administration Server database.
+# TYPE ksc_database_events gauge
+ksc_database_events 5000
+# HELP ksc_exporter_refresh_timestamp_seconds Time of the last successful refresh of metrics section.
+# TYPE ksc_exporter_refresh_timestamp_seconds gauge
+ksc_exporter_refresh_timestamp_seconds{section="database"} 1591012800
+ksc_exporter_refresh_timestamp_seconds{section="hosts"} 1591012800
+ksc_exporter_refresh_timestamp_seconds{section="licenses"} 1591012800
+ksc_exporter_refresh_timestamp_seconds{section="tasks"} 1591012800
+# HELP ksc_exporter_refresh_duration_seconds Duration of the last refresh of metrics section.
+# TYPE ksc_exporter_refresh_duration_seconds gauge
+ksc_exporter_refresh_duration_seconds{section="database"} 0
+ksc_exporter_refresh_duration_seconds{section="hosts"} 0
+ksc_exporter_refresh_duration_seconds{section="licenses"} 0
+ksc_exporter_refresh_duration_seconds{section="tasks"} 0
+# HELP ksc_exporter_refresh_success Whether the last refresh of metrics section succeeded.
+# TYPE ksc_exporter_refresh_success gauge
+ksc_exporter_refresh_success{section="database"} 1
+ksc_exporter_refresh_success{section="hosts"} 1
+ksc_exporter_refresh_success{section="licenses"} 1
+ksc_exporter_refresh_success{section="tasks"} 1
+# HELP ksc_exporter_refresh_errors_total Number of failed refreshes of metrics section.
+# TYPE ksc_exporter_refresh_errors_total counter
+ksc_exporter_refresh_errors_total{section="database"} 0
+ksc_exporter_refresh_errors_total{section="hosts"} 0
+ksc_exporter_refresh_errors_total{section="licenses"} 0
+ksc_exporter_refresh_errors_total{section="tasks"} 0
+`
+	before := ksc.Calls("")
+	for i := 0; i < 3; i++ {
+		if actual := scrape(t, e); actual != expected {
+			t.Fatalf("unexpected metrics:\n%s", actual)
+		}
+	}
+	if after := ksc.Calls(""); after != before {
+		t.Fatalf("scrapes made %d requests to the Administration Server", after-before)
+	}
+
+	// sections are refreshed only when their intervals elapse
+	e.now = func() time.Time { return testNow.Add(time.Minute) }
+	before = ksc.Calls("")
+	next = e.refreshDue(context.Background())
+	if !next.Equal(testNow.Add(2 * time.Minute)) {
+		t.Fatalf("unexpected next refresh %v", next)
+	}
+	if ksc.Calls("") == before {
+		t.Fatal("hosts and tasks were not refreshed")
+	}
+	expectSection := func(section string, refreshed time.Time) {
+		t.Helper()
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		if !e.cache[section].refreshed.Equal(refreshed) {
+			t.Fatalf("section %s refreshed at %v", section, e.cache[section].refreshed)
+		}
+	}
+	expectSection("hosts", testNow.Add(time.Minute))
+	expectSection("licenses", testNow)
+}
+
+func TestExporterKeepsMetricsOnFailure(t *testing.T) {
+	ksc := fakeKSC(t)
+	e := newTestExporter(ksc.URL)
+	e.refreshDue(context.Background())
+
+	ksc.Close()
+	e.now = func() time.Time { return testNow.Add(time.Hour) }
+	e.refreshDue(context.Background())
+
+	metrics := scrape(t, e)
+	for _, line := range []string{
+		`ksc_database_events 5000`,
+		`ksc_exporter_refresh_success{section="database"} 0`,
+		`ksc_exporter_refresh_errors_total{section="database"} 1`,
+		`ksc_exporter_refresh_timestamp_seconds{section="database"} 1591012800`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Fatalf("metrics do not contain %q:\n%s", line, metrics)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Command ksc-exporter is a Prometheus exporter of fleet protection health metrics of the Administration Server.
+//
+// Metrics are refreshed in background every -interval (hosts and tasks) and -slow-interval (license keys and
+// database), scrapes of /metrics are served from memory. The Administration Server password is read from
+// $KSC_PASSWORD.
+//
+//	KSC_PASSWORD=secret ksc-exporter -server https://ksc.example.com:13299 -user exporter
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func main() {
+	insecure, _ := strconv.ParseBool(os.Getenv("KSC_INSECURE"))
+	server := flag.String("server", os.Getenv("KSC_SERVER"), "Administration Server `url`, $KSC_SERVER")
+	user := flag.String("user", os.Getenv("KSC_USER"), "user `name`, $KSC_USER")
+	vserver := flag.String("vserver", os.Getenv("KSC_VSERVER"), "virtual server `name`, $KSC_VSERVER")
+	flag.BoolVar(&insecure, "insecure", insecure, "skip server certificate verification, $KSC_INSECURE")
+	listen := flag.String("listen", ":9652", "listen `address`")
+	interval := flag.Duration("interval", 5*time.Minute, "hosts and tasks metrics refresh `interval`")
+	slowInterval := flag.Duration("slow-interval", time.Hour, "license keys and database metrics refresh `interval`")
+	notSeen := flag.Duration("not-seen", 7*24*time.Hour, "hosts not visible for longer `duration` are counted as not seen")
+	timeout := flag.Duration("timeout", 5*time.Minute, "`timeout` of one metrics section refresh")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "ksc-exporter: ", log.LstdFlags)
+	if *server == "" {
+		logger.Fatal("server is not set, use -server or $KSC_SERVER")
+	}
+	if *interval <= 0 || *slowInterval <= 0 {
+		logger.Fatal("-interval and -slow-interval must be positive")
+	}
+
+	cfg := kaspersky.Config{
+		Server:             strings.TrimRight(*server, "/"),
+		UserName:           *user,
+		Password:           os.Getenv("KSC_PASSWORD"),
+		VServerName:        *vserver,
+		InsecureSkipVerify: insecure,
+	}
+	connect := func(ctx context.Context) (*kaspersky.Client, error) {
+		client := kaspersky.New(cfg)
+		if err := client.KSCAuth(ctx); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	exporter := NewExporter(Options{
+		Interval:     *interval,
+		SlowInterval: *slowInterval,
+		NotSeen:      *notSeen,
+		Timeout:      *timeout,
+	}, connect, logger)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		stop()
+	}()
+	go exporter.Run(ctx)
+
+	srv := &http.Server{Addr: *listen, Handler: exporter, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	logger.Printf("listening on %s", *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+}
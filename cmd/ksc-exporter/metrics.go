@@ -0,0 +1,128 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// family metric family in Prometheus text exposition format
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples map[string]*sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func newFamily(name, typ, help string) *family {
+	return &family{name: name, typ: typ, help: help, samples: map[string]*sample{}}
+}
+
+func gauge(name, help string) *family {
+	return newFamily(name, "gauge", help)
+}
+
+func counter(name, help string) *family {
+	return newFamily(name, "counter", help)
+}
+
+// formatLabels formats label name-value pairs as {name="value",...}.
+func formatLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// set sets value of the sample with label name-value pairs.
+func (f *family) set(value float64, pairs ...string) {
+	labels := formatLabels(pairs)
+	if s, ok := f.samples[labels]; ok {
+		s.value = value
+		return
+	}
+	f.samples[labels] = &sample{labels: labels, value: value}
+}
+
+// add adds delta to value of the sample with label name-value pairs.
+func (f *family) add(delta float64, pairs ...string) {
+	labels := formatLabels(pairs)
+	if s, ok := f.samples[labels]; ok {
+		s.value += delta
+		return
+	}
+	f.samples[labels] = &sample{labels: labels, value: delta}
+}
+
+// formatValue formats integral values without exponent, e.g. 1048576 rather than 1.048576e+06.
+func formatValue(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// writeFamilies writes families in Prometheus text exposition format, samples are sorted by labels.
+func writeFamilies(w io.Writer, families []*family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		bw.WriteString("# HELP " + f.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+
+		keys := make([]string, 0, len(f.samples))
+		for labels := range f.samples {
+			keys = append(keys, labels)
+		}
+		sort.Strings(keys)
+		for _, labels := range keys {
+			bw.WriteString(f.name + labels + " " + formatValue(f.samples[labels].value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
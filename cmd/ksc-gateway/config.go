@@ -0,0 +1,233 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Operations callers can be allowed to perform
+const (
+	OpHostsRead  = "hosts:read"
+	OpGroupsRead = "groups:read"
+	OpTasksRun   = "tasks:run"
+	OpEventsRead = "events:read"
+
+	// OpAll allows all operations
+	OpAll = "*"
+)
+
+var operations = []string{OpHostsRead, OpGroupsRead, OpTasksRun, OpEventsRead}
+
+// Config ksc-gateway config file, e.g.
+//
+//	listen: :8443
+//	tls:
+//	  cert: /etc/ksc-gateway/cert.pem
+//	  key: /etc/ksc-gateway/key.pem
+//	ksc:
+//	  server: https://ksc.example.com:13299
+//	  user: gateway
+//	  passwordEnv: KSC_PASSWORD
+//	tokens:
+//	  - name: inventory
+//	    tokenEnv: GATEWAY_INVENTORY_TOKEN
+//	    allow: [hosts:read, groups:read]
+//	  - name: helpdesk
+//	    token: 3f6c9a0e...
+//	    allow: ["*"]
+type Config struct {
+	// Listen address of HTTP server, :8080 by default. Without TLS only loopback addresses are allowed,
+	// localhost:8080 by default
+	Listen string `yaml:"listen,omitempty"`
+
+	// TLS certificate of HTTPS server
+	TLS TLSConfig `yaml:"tls,omitempty"`
+
+	// KSC connection to the Administration Server
+	KSC KSCConfig `yaml:"ksc"`
+
+	// Tokens API tokens of callers
+	Tokens []Token `yaml:"tokens"`
+
+	// CursorTTL lifetime of idle pagination cursors, 10 minutes by default
+	CursorTTL time.Duration `yaml:"cursorTTL,omitempty"`
+
+	// PageSize default number of items per page, 100 by default
+	PageSize int64 `yaml:"pageSize,omitempty"`
+}
+
+// TLSConfig certificate and private key files of HTTPS server in PEM format
+type TLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+// enabled reports whether HTTPS is configured.
+func (c TLSConfig) enabled() bool {
+	return c.Cert != ""
+}
+
+// KSCConfig settings of connection to the Administration Server
+type KSCConfig struct {
+	Server   string `yaml:"server"`
+	User     string `yaml:"user,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	// PasswordEnv name of environment variable holding the password, used if Password is empty
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+
+	// VServer name of virtual server to log on
+	VServer string `yaml:"vserver,omitempty"`
+
+	// Insecure skips server certificate verification
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+func (c KSCConfig) config() kaspersky.Config {
+	password := c.Password
+	if password == "" && c.PasswordEnv != "" {
+		password = os.Getenv(c.PasswordEnv)
+	}
+	return kaspersky.Config{
+		Server:             strings.TrimRight(c.Server, "/"),
+		UserName:           c.User,
+		Password:           password,
+		VServerName:        c.VServer,
+		InsecureSkipVerify: c.Insecure,
+	}
+}
+
+// Token API token of a caller
+type Token struct {
+	// Name of the caller used in logs
+	Name string `yaml:"name"`
+
+	// Token value passed by the caller as "Authorization: Bearer <token>"
+	Token string `yaml:"token,omitempty"`
+
+	// TokenEnv name of environment variable holding the token, used if Token is empty
+	TokenEnv string `yaml:"tokenEnv,omitempty"`
+
+	// Allow operations the caller may perform, e.g. hosts:read, "*" allows all operations
+	Allow []string `yaml:"allow"`
+}
+
+// LoadConfig reads and validates config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(Config)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range cfg.Tokens {
+		if t := &cfg.Tokens[i]; t.Token == "" && t.TokenEnv != "" {
+			t.Token = os.Getenv(t.TokenEnv)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.KSC.Server == "" {
+		return errors.New("ksc.server is not set")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return errors.New("both tls.cert and tls.key must be set")
+	}
+	if len(c.Tokens) == 0 {
+		return errors.New("no tokens configured")
+	}
+
+	known := map[string]bool{OpAll: true}
+	for _, op := range operations {
+		known[op] = true
+	}
+
+	seen := map[string]string{}
+	for i, t := range c.Tokens {
+		if t.Name == "" {
+			return fmt.Errorf("tokens[%d]: name is not set", i)
+		}
+		if t.Token == "" {
+			return fmt.Errorf("token %q: token is empty", t.Name)
+		}
+		if other, ok := seen[t.Token]; ok {
+			return fmt.Errorf("token %q: same token as %q", t.Name, other)
+		}
+		seen[t.Token] = t.Name
+		for _, op := range t.Allow {
+			if !known[op] {
+				return fmt.Errorf("token %q: unknown operation %q, known operations: %s, %s",
+					t.Name, op, strings.Join(operations, ", "), OpAll)
+			}
+		}
+	}
+	return nil
+}
+
+// checkListen sets the default listen address and refuses to pass API tokens over plain HTTP
+// on addresses reachable from other hosts.
+func (c *Config) checkListen() error {
+	if c.Listen == "" {
+		if c.TLS.enabled() {
+			c.Listen = ":8080"
+		} else {
+			c.Listen = "localhost:8080"
+		}
+	}
+	if c.TLS.enabled() {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(c.Listen)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("listen %s: tokens would be sent in cleartext, configure tls or listen on a loopback address",
+			c.Listen)
+	}
+	return nil
+}
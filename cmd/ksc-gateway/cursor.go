@@ -0,0 +1,349 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// resultSet server-side result-set of the Administration Server read page by page
+type resultSet struct {
+	caller   string
+	resource string
+	total    int64
+
+	// fetch acquires count items starting from start
+	fetch func(ctx context.Context, start, count int64) ([]json.RawMessage, error)
+
+	// item converts raw item to item of response
+	item func(raw json.RawMessage) (interface{}, error)
+
+	// release releases the result-set on the server
+	release func()
+
+	expires time.Time
+
+	// mu serializes reading and releasing of the result-set by concurrent requests with the same cursor
+	mu       sync.Mutex
+	released bool
+}
+
+var errCursorGone = &httpError{status: http.StatusGone, message: "cursor expired or unknown"}
+
+// read acquires items from start to start+count and converts them to items of response.
+func (rs *resultSet) read(ctx context.Context, start, count int64) ([]interface{}, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.released {
+		return nil, errCursorGone
+	}
+
+	values, err := rs.fetch(ctx, start, count)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		item, err := rs.item(value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// close releases the result-set on the server once, waiting for reading in progress.
+func (rs *resultSet) close() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !rs.released {
+		rs.released = true
+		rs.release()
+	}
+}
+
+// chunkResultSet returns result-set of ChunkAccessor accessor.
+func chunkResultSet(ctx context.Context, client *kaspersky.Client, accessor string) (*resultSet, error) {
+	count, _, err := client.ChunkAccessor.GetItemsCount(ctx, accessor)
+	if err != nil {
+		client.ChunkAccessor.Release(context.Background(), accessor)
+		return nil, err
+	}
+
+	return &resultSet{
+		total: count.Int,
+		fetch: func(ctx context.Context, start, count int64) ([]json.RawMessage, error) {
+			chunk := new(kaspersky.ItemsChunk)
+			_, err := client.ChunkAccessor.GetItemsChunk(ctx, kaspersky.ItemsChunkParams{
+				StrAccessor: accessor,
+				NStart:      start,
+				NCount:      count,
+			}, chunk)
+			if err != nil || chunk.PChunk == nil {
+				return nil, err
+			}
+			return chunkValues(chunk.PChunk.Items), nil
+		},
+		release: func() {
+			client.ChunkAccessor.Release(context.Background(), accessor)
+		},
+	}, nil
+}
+
+// srvViewResultSet returns result-set of SrvView iterator.
+func srvViewResultSet(ctx context.Context, client *kaspersky.Client, params *kaspersky.SrvViewParams) (*resultSet, error) {
+	iterator, _, err := client.SrvView.ResetIterator(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	release := func() {
+		_, _ = client.SrvView.ReleaseIterator(context.Background(), iterator.WstrIteratorID)
+	}
+
+	count, _, err := client.SrvView.GetRecordCount(ctx, iterator.WstrIteratorID)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	return &resultSet{
+		total: count.Int,
+		fetch: func(ctx context.Context, start, count int64) ([]json.RawMessage, error) {
+			records := new(struct {
+				PRecords *kaspersky.ItemsChunkArray `json:"pRecords"`
+			})
+			_, err := client.SrvView.GetRecordRange(ctx, &kaspersky.RecordRangeParams{
+				WstrIteratorID: iterator.WstrIteratorID,
+				NStart:         start,
+				NEnd:           start + count,
+			}, records)
+			if err != nil || records.PRecords == nil {
+				return nil, err
+			}
+			return chunkValues(records.PRecords.Items), nil
+		},
+		release: release,
+	}, nil
+}
+
+func chunkValues(items []kaspersky.ItemsChunkValue) []json.RawMessage {
+	values := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values
+}
+
+// cursorStore keeps result-sets being paged by callers until they are read to the end or expire.
+type cursorStore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	now  func() time.Time
+	sets map[string]*resultSet
+}
+
+func newCursorStore(ttl time.Duration) *cursorStore {
+	return &cursorStore{ttl: ttl, now: time.Now, sets: map[string]*resultSet{}}
+}
+
+// put stores result-set and returns its id.
+func (cs *cursorStore) put(rs *resultSet) string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	id := hex.EncodeToString(b[:])
+
+	cs.expire()
+	cs.mu.Lock()
+	rs.expires = cs.now().Add(cs.ttl)
+	cs.sets[id] = rs
+	cs.mu.Unlock()
+	return id
+}
+
+// get returns result-set id opened by caller for resource and prolongs its lifetime.
+func (cs *cursorStore) get(id, caller, resource string) (*resultSet, bool) {
+	cs.expire()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	rs, ok := cs.sets[id]
+	if !ok || rs.caller != caller || rs.resource != resource {
+		return nil, false
+	}
+	rs.expires = cs.now().Add(cs.ttl)
+	return rs, true
+}
+
+// drop removes result-set id and releases it.
+func (cs *cursorStore) drop(id string) {
+	cs.mu.Lock()
+	rs, ok := cs.sets[id]
+	delete(cs.sets, id)
+	cs.mu.Unlock()
+	if ok {
+		rs.close()
+	}
+}
+
+// expire releases result-sets which were not read during ttl.
+func (cs *cursorStore) expire() {
+	now := cs.now()
+	var expired []*resultSet
+	cs.mu.Lock()
+	for id, rs := range cs.sets {
+		if now.After(rs.expires) {
+			expired = append(expired, rs)
+			delete(cs.sets, id)
+		}
+	}
+	cs.mu.Unlock()
+	for _, rs := range expired {
+		rs.close()
+	}
+}
+
+// close releases all result-sets.
+func (cs *cursorStore) close() {
+	cs.mu.Lock()
+	sets := cs.sets
+	cs.sets = map[string]*resultSet{}
+	cs.mu.Unlock()
+	for _, rs := range sets {
+		rs.close()
+	}
+}
+
+func encodeCursor(id string, offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.FormatInt(offset, 10)))
+}
+
+var errBadCursor = errors.New("malformed cursor")
+
+func decodeCursor(cursor string) (string, int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errBadCursor
+	}
+	parts := strings.SplitN(string(data), ":", 2)
+	if len(parts) != 2 {
+		return "", 0, errBadCursor
+	}
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || offset < 0 {
+		return "", 0, errBadCursor
+	}
+	return parts[0], offset, nil
+}
+
+// page response of collection resources
+type page struct {
+	Items []interface{} `json:"items"`
+	Total int64         `json:"total"`
+
+	// Next cursor of the next page, absent on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// maxPageSize upper limit of "limit" query parameter
+const maxPageSize = 1000
+
+// page returns page of resource collection. The collection is opened by open unless "cursor" query parameter
+// continues paging of previously opened one. Result-set is kept on the server until its last page is read,
+// concurrent requests with the same cursor read it one after another.
+func (g *Gateway) page(ctx context.Context, r *http.Request, caller, resource string,
+	open func(ctx context.Context) (*resultSet, error)) (*page, error) {
+	query := r.URL.Query()
+
+	limit := g.pageSize
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 || n > maxPageSize {
+			return nil, badRequest("limit must be from 1 to %d", maxPageSize)
+		}
+		limit = n
+	}
+
+	var rs *resultSet
+	var id string
+	var start int64
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		if id, start, err = decodeCursor(cursor); err != nil {
+			return nil, badRequest("%v", err)
+		}
+		var ok bool
+		if rs, ok = g.cursors.get(id, caller, resource); !ok {
+			return nil, errCursorGone
+		}
+	} else {
+		var err error
+		if rs, err = open(ctx); err != nil {
+			return nil, err
+		}
+		rs.caller, rs.resource = caller, resource
+	}
+
+	done := func() {
+		if id != "" {
+			g.cursors.drop(id)
+		} else {
+			rs.close()
+		}
+	}
+
+	items, err := rs.read(ctx, start, limit)
+	if err != nil {
+		if err != errCursorGone {
+			done()
+		}
+		return nil, err
+	}
+
+	result := &page{Items: items, Total: rs.total}
+	next := start + int64(len(items))
+	if len(items) == 0 || next >= rs.total {
+		done()
+		return result, nil
+	}
+	if id == "" {
+		id = g.cursors.put(rs)
+	}
+	result.Next = encodeCursor(id, next)
+	return result, nil
+}
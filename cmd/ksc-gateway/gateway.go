@@ -0,0 +1,256 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Gateway HTTP handler exposing REST resources backed by the Administration Server:
+//
+//	GET  /hosts?filter=PC-*&group=Managed devices/Branch   hosts
+//	GET  /groups/{path}                                   administration group and its subgroups
+//	POST /tasks/{id}/run                                  start task
+//	GET  /events?since=24h                                events
+//
+// Callers authenticate with "Authorization: Bearer <token>". Collections are paged with "limit" and
+// "cursor" query parameters, cursor of the next page is returned in "next" field of the response.
+type Gateway struct {
+	connect  func(ctx context.Context) (*kaspersky.Client, error)
+	tokens   []Token
+	pageSize int64
+	cursors  *cursorStore
+	logger   *log.Logger
+
+	mu     sync.Mutex
+	client *kaspersky.Client
+}
+
+// NewGateway returns gateway of config, connect is called to log on the Administration Server
+// at the first request and after the session has expired.
+func NewGateway(cfg *Config, connect func(ctx context.Context) (*kaspersky.Client, error), logger *log.Logger) *Gateway {
+	ttl := cfg.CursorTTL
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	pageSize := cfg.PageSize
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = 100
+	}
+	return &Gateway{
+		connect:  connect,
+		tokens:   cfg.Tokens,
+		pageSize: pageSize,
+		cursors:  newCursorStore(ttl),
+		logger:   logger,
+	}
+}
+
+// Close releases result-sets kept for pagination.
+func (g *Gateway) Close() {
+	g.cursors.close()
+}
+
+// httpError error reported to the caller with HTTP status
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// route resource handler
+type route struct {
+	method    string
+	operation string
+	handle    func(ctx context.Context, r *http.Request, caller string, args []string) (int, interface{}, error)
+}
+
+// match returns route of path and path arguments.
+func (g *Gateway) match(path string) (*route, []string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "hosts":
+		return &route{http.MethodGet, OpHostsRead, g.hosts}, nil
+	case parts[0] == "groups":
+		return &route{http.MethodGet, OpGroupsRead, g.group}, []string{strings.Join(parts[1:], kaspersky.GroupPathSeparator)}
+	case len(parts) == 3 && parts[0] == "tasks" && parts[2] == "run":
+		return &route{http.MethodPost, OpTasksRun, g.runTask}, parts[1:2]
+	case len(parts) == 1 && parts[0] == "events":
+		return &route{http.MethodGet, OpEventsRead, g.events}, nil
+	}
+	return nil, nil
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	caller := "-"
+	status, body := g.serve(r, &caller)
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ksc-gateway"`)
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		g.logf("%s %s %s: write response: %v", caller, r.Method, r.URL.Path, err)
+	}
+	g.logf("%s %s %s %d %s", caller, r.Method, r.URL.RequestURI(), status, time.Since(started).Round(time.Millisecond))
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func (g *Gateway) serve(r *http.Request, caller *string) (int, interface{}) {
+	token := g.authenticate(r)
+	if token == nil {
+		return http.StatusUnauthorized, errorBody{"missing or invalid API token"}
+	}
+	*caller = token.Name
+
+	rt, args := g.match(r.URL.Path)
+	if rt == nil {
+		return http.StatusNotFound, errorBody{"no such resource"}
+	}
+	if rt.method != r.Method {
+		return http.StatusMethodNotAllowed, errorBody{fmt.Sprintf("method %s is not allowed, use %s", r.Method, rt.method)}
+	}
+	if !allowed(token, rt.operation) {
+		return http.StatusForbidden, errorBody{fmt.Sprintf("operation %s is not allowed", rt.operation)}
+	}
+
+	status, body, err := rt.handle(r.Context(), r, token.Name, args)
+	if err != nil {
+		return g.failure(r, token.Name, err)
+	}
+	return status, body
+}
+
+// failure returns HTTP status and body of err.
+func (g *Gateway) failure(r *http.Request, caller string, err error) (int, interface{}) {
+	var he *httpError
+	switch {
+	case errors.As(err, &he):
+		return he.status, errorBody{he.message}
+	case errors.Is(err, kaspersky.ErrGroupNotFound):
+		return http.StatusNotFound, errorBody{err.Error()}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, errorBody{"request to Administration Server was canceled"}
+	}
+	g.logf("%s %s %s: %v", caller, r.Method, r.URL.Path, err)
+	return http.StatusBadGateway, errorBody{fmt.Sprintf("Administration Server: %v", err)}
+}
+
+// authenticate returns token of the caller or nil.
+func (g *Gateway) authenticate(r *http.Request) *Token {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil
+	}
+	value := []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+
+	var found *Token
+	for i := range g.tokens {
+		if subtle.ConstantTimeCompare(value, []byte(g.tokens[i].Token)) == 1 {
+			found = &g.tokens[i]
+		}
+	}
+	return found
+}
+
+func allowed(token *Token, operation string) bool {
+	for _, op := range token.Allow {
+		if op == OpAll || op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// do calls fn with client logged on the Administration Server. If the session has expired
+// the gateway logs on again and fn is retried once.
+func (g *Gateway) do(ctx context.Context, fn func(client *kaspersky.Client) error) error {
+	client, err := g.ksc(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(client)
+	var se *kaspersky.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusUnauthorized && se.StatusCode != http.StatusForbidden {
+		return err
+	}
+
+	g.logf("Administration Server session has expired, logging on again")
+	g.mu.Lock()
+	if g.client == client {
+		g.client = nil
+	}
+	g.mu.Unlock()
+
+	if client, err = g.ksc(ctx); err != nil {
+		return err
+	}
+	return fn(client)
+}
+
+func (g *Gateway) ksc(ctx context.Context) (*kaspersky.Client, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.client != nil {
+		return g.client, nil
+	}
+
+	client, err := g.connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("log on: %w", err)
+	}
+	g.client = client
+	return client, nil
+}
+
+func (g *Gateway) logf(format string, args ...interface{}) {
+	if g.logger != nil {
+		g.logger.Printf(format, args...)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func newTestGateway(t *testing.T, server string) *httptest.Server {
+	cfg := &Config{
+		KSC: KSCConfig{Server: server},
+		Tokens: []Token{
+			{Name: "inventory", Token: "inventory-token", Allow: []string{OpHostsRead, OpGroupsRead}},
+			{Name: "reports", Token: "reports-token", Allow: []string{OpHostsRead}},
+			{Name: "admin", Token: "admin-token", Allow: []string{OpAll}},
+		},
+	}
+	gateway := NewGateway(cfg, connector(cfg.KSC), nil)
+	srv := httptest.NewServer(gateway)
+	t.Cleanup(func() {
+		srv.Close()
+		gateway.Close()
+	})
+	return srv
+}
+
+func request(t *testing.T, method, url, token string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestGatewayAccess(t *testing.T) {
+	ksc := ksctest.NewServer(t)
+	srv := newTestGateway(t, ksc.URL)
+
+	var body errorBody
+	ksctest.ExpectEqual(t, http.StatusUnauthorized, request(t, "GET", srv.URL+"/hosts", "", &body))
+	ksctest.ExpectEqual(t, http.StatusUnauthorized, request(t, "GET", srv.URL+"/hosts", "wrong", nil))
+	ksctest.ExpectEqual(t, http.StatusForbidden, request(t, "POST", srv.URL+"/tasks/1/run", "inventory-token", &body))
+	ksctest.ExpectEqual(t, "operation tasks:run is not allowed", body.Error)
+	ksctest.ExpectEqual(t, http.StatusNotFound, request(t, "GET", srv.URL+"/tasks", "admin-token", nil))
+	ksctest.ExpectEqual(t, http.StatusMethodNotAllowed, request(t, "GET", srv.URL+"/tasks/1/run", "admin-token", nil))
+	ksctest.ExpectEqual(t, http.StatusBadRequest, request(t, "POST", srv.URL+`/tasks/1"/run`, "admin-token", nil))
+}
+
+func TestGatewayHosts(t *testing.T) {
+	ksc := ksctest.NewServer(t)
+	hosts := []string{"PC-001", "PC-002", "PC-003"}
+	var filter string
+	ksc.Handle("HostGroup.FindHosts", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.HGParams
+		ksctest.Params(r, &in)
+		filter = in.WstrFilter
+		w.Write([]byte(`{"strAccessor": "acc", "PxgRetVal": 3}`))
+	})
+	ksc.Reply("ChunkAccessor.GetItemsCount", `{"PxgRetVal": 3}`)
+	ksc.Handle("ChunkAccessor.GetItemsChunk", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.ItemsChunkParams
+		ksctest.Params(r, &in)
+		var items []string
+		for i := in.NStart; i < int64(len(hosts)) && i < in.NStart+in.NCount; i++ {
+			items = append(items, `{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "id-`+strconv.FormatInt(i, 10)+
+				`", "KLHST_WKS_DN": "`+hosts[i]+`", "KLHST_WKS_GROUPID": 1, `+
+				`"KLHST_WKS_LAST_VISIBLE": {"type": "datetime", "value": "2020-05-01T10:00:00Z"}}}`)
+		}
+		w.Write([]byte(`{"pChunk": {"KLCSP_ITERATOR_ARRAY": [` + strings.Join(items, ",") + `]}, "PxgRetVal": ` +
+			strconv.Itoa(len(items)) + `}`))
+	})
+	srv := newTestGateway(t, ksc.URL)
+
+	var first page
+	ksctest.ExpectEqual(t, http.StatusOK, request(t, "GET", srv.URL+`/hosts?filter=PC-"0*&limit=2`, "inventory-token", &first))
+	ksctest.ExpectEqual(t, `(KLHST_WKS_DN="PC-\"0*")`, filter)
+	ksctest.ExpectEqual(t, int64(3), first.Total)
+	ksctest.ExpectEqual(t, 2, len(first.Items))
+	ksctest.ExpectEqual(t, map[string]interface{}{
+		"id":          "id-0",
+		"name":        "PC-001",
+		"groupId":     float64(1),
+		"lastVisible": "2020-05-01T10:00:00Z",
+	}, first.Items[0])
+	if first.Next == "" {
+		t.Fatal("expected cursor of the next page")
+	}
+
+	// cursors are bound to the caller
+	ksctest.ExpectEqual(t, http.StatusGone, request(t, "GET", srv.URL+"/hosts?cursor="+first.Next, "reports-token", nil))
+	ksctest.ExpectEqual(t, http.StatusBadRequest, request(t, "GET", srv.URL+"/hosts?cursor=bad", "inventory-token", nil))
+
+	var second page
+	ksctest.ExpectEqual(t, http.StatusOK, request(t, "GET", srv.URL+"/hosts?limit=2&cursor="+first.Next, "inventory-token", &second))
+	ksctest.ExpectEqual(t, 1, len(second.Items))
+	ksctest.ExpectEqual(t, "PC-003", second.Items[0].(map[string]interface{})["name"])
+	ksctest.ExpectEqual(t, "", second.Next)
+	ksctest.ExpectEqual(t, 1, ksc.Calls("ChunkAccessor.Release"))
+
+	// the result-set is released after the last page
+	ksctest.ExpectEqual(t, http.StatusGone, request(t, "GET", srv.URL+"/hosts?cursor="+first.Next, "inventory-token", nil))
+}
+
+func TestGatewayGroup(t *testing.T) {
+	ksc := ksctest.NewServer(t)
+	ksc.Reply("HostGroup.GroupIdGroups", `{"PxgRetVal": 1}`)
+	ksc.Reply("HostGroup.GetGroupInfoEx", `{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`)
+	ksc.Handle("HostGroup.GetSubgroups", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NParent int64 `json:"nParent"`
+		}
+		ksctest.Params(r, &in)
+		if in.NParent == 1 {
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Branch"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 3, "name": "Kiosks"}}]}`))
+	})
+	srv := newTestGateway(t, ksc.URL)
+
+	var root group
+	ksctest.ExpectEqual(t, http.StatusOK, request(t, "GET", srv.URL+"/groups", "inventory-token", &root))
+	ksctest.ExpectEqual(t, groupRef{ID: 1, Name: "Managed devices", Path: "Managed devices"}, root.groupRef)
+	ksctest.ExpectEqual(t, []groupRef{{ID: 2, Name: "Branch", Path: "Managed devices/Branch"}}, root.Subgroups)
+
+	var branch group
+	ksctest.ExpectEqual(t, http.StatusOK, request(t, "GET", srv.URL+"/groups/Managed%20devices/Branch", "inventory-token", &branch))
+	ksctest.ExpectEqual(t, groupRef{ID: 2, Name: "Branch", Path: "Managed devices/Branch"}, branch.groupRef)
+	ksctest.ExpectEqual(t, []groupRef{{ID: 3, Name: "Kiosks", Path: "Managed devices/Branch/Kiosks"}}, branch.Subgroups)
+
+	var body errorBody
+	ksctest.ExpectEqual(t, http.StatusNotFound, request(t, "GET", srv.URL+"/groups/Managed%20devices/Missing", "inventory-token", &body))
+}
+
+func TestGatewayRunTask(t *testing.T) {
+	ksc := ksctest.NewServer(t)
+	var task string
+	ksc.Handle("Tasks.RunTask", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			StrTask string `json:"strTask"`
+		}
+		ksctest.Params(r, &in)
+		task = in.StrTask
+		if task == "404" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"PxgError": {"code": 1183, "message": "Object not found"}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	srv := newTestGateway(t, ksc.URL)
+
+	var run taskRun
+	ksctest.ExpectEqual(t, http.StatusAccepted, request(t, "POST", srv.URL+"/tasks/1234/run", "admin-token", &run))
+	ksctest.ExpectEqual(t, taskRun{Task: "1234", Status: "started"}, run)
+	ksctest.ExpectEqual(t, "1234", task)
+
+	var body errorBody
+	ksctest.ExpectEqual(t, http.StatusBadGateway, request(t, "POST", srv.URL+"/tasks/404/run", "admin-token", &body))
+	ksctest.ExpectEqual(t, "Administration Server: Code: 1183, Message: Object not found", body.Error)
+}
+
+func TestGatewayEvents(t *testing.T) {
+	ksc := ksctest.NewServer(t)
+	var filter string
+	resets := 0
+	ksc.Handle("SrvView.ResetIterator", func(w http.ResponseWriter, r *http.Request) {
+		// the first session expires
+		resets++
+		if resets == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var in kaspersky.SrvViewParams
+		ksctest.Params(r, &in)
+		filter = in.WstrFilter
+		w.Write([]byte(`{"wstrIteratorId": "it"}`))
+	})
+	ksc.Reply("SrvView.GetRecordCount", `{"PxgRetVal": 1}`)
+	ksc.Reply("SrvView.GetRecordRange", `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [{"type": "params", "value": {"event_db_id": 7,
+			"event_type": "GNRL_EV_VIRUS_FOUND", "severity": 4, "hostdn": "PC-001",
+			"rise_time": {"type": "datetime", "value": "2020-05-01T10:00:00Z"}}}]}}`)
+	srv := newTestGateway(t, ksc.URL)
+
+	var result page
+	ksctest.ExpectEqual(t, http.StatusOK, request(t, "GET", srv.URL+"/events?since=2020-05-01T00:00:00Z", "admin-token", &result))
+	ksctest.ExpectEqual(t, `(rise_time >= T"2020-05-01 00:00:00")`, filter)
+	ksctest.ExpectEqual(t, 2, ksc.Calls("login"))
+	ksctest.ExpectEqual(t, 1, ksc.Calls("SrvView.ReleaseIterator"))
+	ksctest.ExpectEqual(t, []interface{}{map[string]interface{}{
+		"id":       float64(7),
+		"time":     "2020-05-01T10:00:00Z",
+		"type":     "GNRL_EV_VIRUS_FOUND",
+		"severity": "critical",
+		"host":     "PC-001",
+	}}, result.Items)
+	ksctest.ExpectEqual(t, "", result.Next)
+
+	ksctest.ExpectEqual(t, http.StatusBadRequest, request(t, "GET", srv.URL+"/events?since=yesterday", "admin-token", nil))
+	ksctest.ExpectEqual(t, http.StatusForbidden, request(t, "GET", srv.URL+"/events", "inventory-token", nil))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ksc-gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("KSC_GATEWAY_TEST_TOKEN", "secret")
+	defer os.Unsetenv("KSC_GATEWAY_TEST_TOKEN")
+	write(`
+ksc:
+  server: https://ksc.example.com:13299/
+tokens:
+  - name: inventory
+    tokenEnv: KSC_GATEWAY_TEST_TOKEN
+    allow: [hosts:read]
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ksctest.ExpectEqual(t, "secret", cfg.Tokens[0].Token)
+	ksctest.ExpectEqual(t, "https://ksc.example.com:13299", cfg.KSC.config().Server)
+
+	write(`
+ksc:
+  server: https://ksc.example.com:13299
+tokens:
+  - name: inventory
+    token: secret
+    allow: [hosts:write]
+`)
+	_, err = LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), `unknown operation "hosts:write"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestConfigCheckListen(t *testing.T) {
+	for _, tc := range []struct {
+		listen, expected string
+		tls, ok          bool
+	}{
+		{"", "localhost:8080", false, true},
+		{"", ":8080", true, true},
+		{"127.0.0.1:8080", "127.0.0.1:8080", false, true},
+		{"[::1]:8080", "[::1]:8080", false, true},
+		{":8080", ":8080", false, false},
+		{"10.0.0.1:8080", "10.0.0.1:8080", false, false},
+		{"10.0.0.1:8443", "10.0.0.1:8443", true, true},
+	} {
+		cfg := &Config{Listen: tc.listen}
+		if tc.tls {
+			cfg.TLS = TLSConfig{Cert: "cert.pem", Key: "key.pem"}
+		}
+		err := cfg.checkListen()
+		ksctest.ExpectEqual(t, tc.ok, err == nil)
+		ksctest.ExpectEqual(t, tc.expected, cfg.Listen)
+	}
+
+	cfg := &Config{KSC: KSCConfig{Server: "https://ksc"}, Tokens: []Token{{Name: "a", Token: "a"}},
+		TLS: TLSConfig{Cert: "cert.pem"}}
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "tls.key") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCursorConcurrentRead(t *testing.T) {
+	var released int32
+	fetching, proceed := make(chan struct{}), make(chan struct{})
+	rs := &resultSet{
+		total: 10,
+		fetch: func(ctx context.Context, start, count int64) ([]json.RawMessage, error) {
+			close(fetching)
+			<-proceed
+			if atomic.LoadInt32(&released) != 0 {
+				t.Error("result-set released while being read")
+			}
+			return []json.RawMessage{json.RawMessage(`"PC-001"`)}, nil
+		},
+		item: func(raw json.RawMessage) (interface{}, error) {
+			return string(raw), nil
+		},
+		release: func() {
+			atomic.AddInt32(&released, 1)
+		},
+	}
+	cursors := newCursorStore(time.Minute)
+	id := cursors.put(rs)
+
+	read := make(chan error)
+	go func() {
+		_, err := rs.read(context.Background(), 0, 1)
+		read <- err
+	}()
+	<-fetching
+
+	// another request with the same cursor reaches the last page and drops the result-set
+	dropped := make(chan struct{})
+	go func() {
+		cursors.drop(id)
+		close(dropped)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(proceed)
+
+	ksctest.ExpectSucceeded(t, <-read)
+	<-dropped
+	ksctest.ExpectEqual(t, int32(1), atomic.LoadInt32(&released))
+
+	_, err := rs.read(context.Background(), 1, 1)
+	ksctest.ExpectEqual(t, errCursorGone, err)
+	cursors.close()
+	ksctest.ExpectEqual(t, int32(1), atomic.LoadInt32(&released))
+}
@@ -0,0 +1,119 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Command ksc-gateway is an HTTP server exposing simplified REST resources of the Administration Server
+// to callers holding gateway API tokens instead of KSC credentials.
+//
+//	ksc-gateway -config gateway.yaml
+//
+// Without tls in the config the gateway listens only on loopback addresses.
+//
+//	curl -H "Authorization: Bearer $TOKEN" 'http://localhost:8080/hosts?filter=PC-*&limit=50'
+//	curl -H "Authorization: Bearer $TOKEN" 'http://localhost:8080/hosts?cursor=<next>'
+//	curl -H "Authorization: Bearer $TOKEN" 'http://localhost:8080/groups/Managed devices/Branch'
+//	curl -H "Authorization: Bearer $TOKEN" -X POST http://localhost:8080/tasks/1234/run
+//	curl -H "Authorization: Bearer $TOKEN" 'http://localhost:8080/events?since=24h'
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// connector returns function logging on the Administration Server of cfg.
+func connector(cfg KSCConfig) func(ctx context.Context) (*kaspersky.Client, error) {
+	return func(ctx context.Context) (*kaspersky.Client, error) {
+		client := kaspersky.New(cfg.config())
+		if err := client.KSCAuth(ctx); err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "ksc-gateway.yaml", "config `file`")
+	listen := flag.String("listen", "", "listen `address`, overrides listen of the config")
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "ksc-gateway: ", log.LstdFlags)
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if *listen != "" {
+		cfg.Listen = *listen
+	}
+	if err := cfg.checkListen(); err != nil {
+		logger.Fatal(err)
+	}
+
+	gateway := NewGateway(cfg, connector(cfg.KSC), logger)
+	defer gateway.Close()
+
+	srv := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           gateway,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		stop()
+	}()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	logger.Printf("listening on %s", cfg.Listen)
+	if cfg.TLS.enabled() {
+		err = srv.ListenAndServeTLS(cfg.TLS.Cert, cfg.TLS.Key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+	// wait for requests in progress
+	<-done
+}
@@ -0,0 +1,302 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// host item of GET /hosts
+type host struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	GroupID     int64      `json:"groupId"`
+	OS          string     `json:"os,omitempty"`
+	LastVisible *time.Time `json:"lastVisible,omitempty"`
+}
+
+// hostRecord host attributes returned by HostGroup.FindHosts
+type hostRecord struct {
+	ID          string              `json:"KLHST_WKS_HOSTNAME"`
+	Name        string              `json:"KLHST_WKS_DN"`
+	GroupID     int64               `json:"KLHST_WKS_GROUPID"`
+	OS          string              `json:"KLHST_WKS_OS_NAME,omitempty"`
+	LastVisible *kaspersky.DateTime `json:"KLHST_WKS_LAST_VISIBLE,omitempty"`
+}
+
+var hostAttributes = []string{"KLHST_WKS_HOSTNAME", "KLHST_WKS_DN", "KLHST_WKS_GROUPID", "KLHST_WKS_OS_NAME",
+	"KLHST_WKS_LAST_VISIBLE"}
+
+func timeOf(d *kaspersky.DateTime) *time.Time {
+	if t := d.Time(); !t.IsZero() {
+		return &t
+	}
+	return nil
+}
+
+// hosts GET /hosts, query parameters:
+//   - filter: host name, "*" matches any characters
+//   - group: path of administration group the hosts are direct members of
+func (g *Gateway) hosts(ctx context.Context, r *http.Request, caller string, _ []string) (int, interface{}, error) {
+	query := r.URL.Query()
+	var terms []string
+	if filter := query.Get("filter"); filter != "" {
+		terms = append(terms, "(KLHST_WKS_DN="+kaspersky.QuoteFilterValue(filter)+")")
+	}
+
+	result, err := g.page(ctx, r, caller, "hosts", func(ctx context.Context) (rs *resultSet, err error) {
+		err = g.do(ctx, func(client *kaspersky.Client) error {
+			filter := append([]string(nil), terms...)
+			if path := query.Get("group"); path != "" {
+				id, err := client.HostGroup.ResolveGroupPath(ctx, path)
+				if err != nil {
+					return err
+				}
+				filter = append(filter, fmt.Sprintf("(KLHST_WKS_GROUPID = %d)", id))
+			}
+
+			var wstrFilter string
+			switch len(filter) {
+			case 0:
+				wstrFilter = `(KLHST_WKS_DN="*")`
+			case 1:
+				wstrFilter = filter[0]
+			default:
+				wstrFilter = "(&" + strings.Join(filter, "") + ")"
+			}
+
+			accessor, _, err := client.HostGroup.FindHosts(ctx, kaspersky.HGParams{
+				WstrFilter:        wstrFilter,
+				VecFieldsToReturn: hostAttributes,
+				PParams:           kaspersky.PParams{KlgrpFindFromCurVsOnly: true},
+				LMaxLifeTime:      int64(g.cursors.ttl / time.Second),
+			})
+			if err != nil {
+				return err
+			}
+			rs, err = chunkResultSet(ctx, client, accessor.StrAccessor)
+			return err
+		})
+		if rs != nil {
+			rs.item = func(raw json.RawMessage) (interface{}, error) {
+				var record hostRecord
+				if err := json.Unmarshal(raw, &record); err != nil {
+					return nil, err
+				}
+				return host{
+					ID:          record.ID,
+					Name:        record.Name,
+					GroupID:     record.GroupID,
+					OS:          record.OS,
+					LastVisible: timeOf(record.LastVisible),
+				}, nil
+			}
+		}
+		return rs, err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, result, nil
+}
+
+// groupRef subgroup of GET /groups/{path}
+type groupRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// group response of GET /groups/{path}
+type group struct {
+	groupRef
+	Subgroups []groupRef `json:"subgroups"`
+}
+
+// group GET /groups/{path}, path is full path of administration group, e.g. /groups/Managed devices/Branch.
+// The predefined "Managed devices" group is returned for /groups.
+func (g *Gateway) group(ctx context.Context, _ *http.Request, _ string, args []string) (int, interface{}, error) {
+	var result *group
+	err := g.do(ctx, func(client *kaspersky.Client) error {
+		var id int64
+		var err error
+		if path := args[0]; path == "" {
+			root, _, err := client.HostGroup.GroupIdGroups(ctx)
+			if err != nil {
+				return err
+			}
+			id = root.Int
+		} else if id, err = client.HostGroup.ResolveGroupPath(ctx, path); err != nil {
+			return err
+		}
+
+		path, err := client.HostGroup.GroupPath(ctx, id)
+		if err != nil {
+			return err
+		}
+		names := kaspersky.SplitGroupPath(path)
+
+		children, _, err := client.HostGroup.GetSubgroupsList(ctx, id, 1)
+		if err != nil {
+			return err
+		}
+
+		result = &group{groupRef: groupRef{ID: id, Name: names[len(names)-1], Path: path}, Subgroups: []groupRef{}}
+		for _, child := range children {
+			result.Subgroups = append(result.Subgroups, groupRef{
+				ID:   child.ID,
+				Name: child.Name,
+				Path: path + kaspersky.GroupPathSeparator + child.Name,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, result, nil
+}
+
+var taskID = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// taskRun response of POST /tasks/{id}/run
+type taskRun struct {
+	Task   string `json:"task"`
+	Status string `json:"status"`
+}
+
+// runTask POST /tasks/{id}/run starts task id, the task runs asynchronously.
+func (g *Gateway) runTask(ctx context.Context, _ *http.Request, _ string, args []string) (int, interface{}, error) {
+	id := args[0]
+	if !taskID.MatchString(id) {
+		return 0, nil, badRequest("invalid task id %q", id)
+	}
+
+	err := g.do(ctx, func(client *kaspersky.Client) error {
+		_, err := client.Tasks.RunTask(ctx, id)
+		return err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusAccepted, taskRun{Task: id, Status: "started"}, nil
+}
+
+// event item of GET /events
+type event struct {
+	ID          int64      `json:"id"`
+	Time        *time.Time `json:"time,omitempty"`
+	Type        string     `json:"type"`
+	Name        string     `json:"name,omitempty"`
+	Severity    string     `json:"severity"`
+	Host        string     `json:"host,omitempty"`
+	Task        string     `json:"task,omitempty"`
+	Product     string     `json:"product,omitempty"`
+	Description string     `json:"description,omitempty"`
+}
+
+var eventSeverities = map[int64]string{
+	1: "info",
+	2: "warning",
+	3: "error",
+	4: "critical",
+}
+
+// parseSince parses RFC 3339 time or duration before now, e.g. "24h".
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, badRequest("since must be RFC 3339 time or duration, e.g. 24h")
+	}
+	return t, nil
+}
+
+// events GET /events, events are ordered by registration, query parameters:
+//   - since: RFC 3339 time or duration before now, e.g. 24h
+func (g *Gateway) events(ctx context.Context, r *http.Request, caller string, _ []string) (int, interface{}, error) {
+	var filter string
+	if s := r.URL.Query().Get("since"); s != "" {
+		since, err := parseSince(s, time.Now())
+		if err != nil {
+			return 0, nil, err
+		}
+		filter = fmt.Sprintf(`(rise_time >= T"%s")`, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	params, err := kaspersky.SrvViewQuery{
+		Filter:   filter,
+		Order:    []kaspersky.OrderValue{{Name: "event_db_id", Asc: true}},
+		Lifetime: g.cursors.ttl,
+	}.Params(kaspersky.EventRecord{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result, err := g.page(ctx, r, caller, "events", func(ctx context.Context) (rs *resultSet, err error) {
+		err = g.do(ctx, func(client *kaspersky.Client) error {
+			rs, err = srvViewResultSet(ctx, client, params)
+			return err
+		})
+		if rs != nil {
+			rs.item = func(raw json.RawMessage) (interface{}, error) {
+				var record kaspersky.EventRecord
+				if err := json.Unmarshal(raw, &record); err != nil {
+					return nil, err
+				}
+				severity, ok := eventSeverities[record.Severity]
+				if !ok {
+					severity = fmt.Sprint(record.Severity)
+				}
+				return event{
+					ID:          record.ID,
+					Time:        timeOf(record.RiseTime),
+					Type:        record.Type,
+					Name:        record.TypeDisplayName,
+					Severity:    severity,
+					Host:        record.HostDisplayName,
+					Task:        record.TaskDisplayName,
+					Product:     record.ProductName,
+					Description: record.Description,
+				}, nil
+			}
+		}
+		return rs, err
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, result, nil
+}
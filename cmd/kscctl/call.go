@@ -0,0 +1,98 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+func callCommand() *command {
+	return &command{
+		name:    "call",
+		args:    "Service.Method [params|@file|-]",
+		summary: "invoke any Open API method with JSON params",
+		run:     call,
+	}
+}
+
+// callParams returns JSON params of call command from argument, @file or - for stdin.
+func (a *app) callParams(arg string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case arg == "-":
+		data, err = ioutil.ReadAll(a.stdin)
+	case strings.HasPrefix(arg, "@"):
+		data, err = ioutil.ReadFile(arg[1:])
+	default:
+		data = []byte(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		return nil, errors.New("params are not valid JSON")
+	}
+	return data, nil
+}
+
+func call(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl call", "Service.Method [params|@file|-]")
+	if err := parse(fs, args, 1, 2); err != nil {
+		return err
+	}
+
+	var params interface{}
+	if fs.NArg() == 2 {
+		data, err := a.callParams(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		params = data
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	raw, err := client.Call(ctx, fs.Arg(0), params, nil)
+	if len(bytes.TrimSpace(raw)) != 0 {
+		var out bytes.Buffer
+		if json.Indent(&out, raw, "", "  ") == nil {
+			raw = out.Bytes()
+		}
+		fmt.Fprintf(a.stdout, "%s\n", bytes.TrimSpace(raw))
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Config kscctl config file, e.g.
+//
+//	default: prod
+//	profiles:
+//	  prod:
+//	    server: https://ksc.example.com:13299
+//	    user: api
+//	    passwordEnv: KSC_PROD_PASSWORD
+//	  lab:
+//	    server: https://10.0.0.5:13299
+//	    user: admin
+//	    password: secret
+//	    insecure: true
+type Config struct {
+	// Default name of the profile used if no profile is selected
+	Default string `yaml:"default,omitempty"`
+
+	// Profiles connection profiles by name
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile settings of connection to the Administration Server
+type Profile struct {
+	Server   string `yaml:"server,omitempty"`
+	User     string `yaml:"user,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	// PasswordEnv name of environment variable holding the password, used if Password is empty
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+
+	// VServer name of virtual server to log on
+	VServer string `yaml:"vserver,omitempty"`
+
+	// Session uses X-KSC-Session token authentication
+	Session bool `yaml:"session,omitempty"`
+
+	// Insecure skips server certificate verification
+	Insecure bool `yaml:"insecure,omitempty"`
+}
+
+func (p Profile) config() kaspersky.Config {
+	return kaspersky.Config{
+		Server:             strings.TrimRight(p.Server, "/"),
+		UserName:           p.User,
+		Password:           p.Password,
+		VServerName:        p.VServer,
+		XKscSession:        p.Session,
+		InsecureSkipVerify: p.Insecure,
+	}
+}
+
+// defaultConfigPath returns path of the config file used if it is not set by -config or $KSCCTL_CONFIG.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".kscctl", "config.yaml")
+	}
+	return filepath.Join(dir, "kscctl", "config.yaml")
+}
+
+// LoadConfig reads config file. If path is empty $KSCCTL_CONFIG or the default config path is used,
+// missing default config file is not an error.
+func LoadConfig(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv("KSCCTL_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	cfg := new(Config)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Profile returns connection profile name with environment overrides applied.
+//
+// If name is empty the profile is selected by $KSC_PROFILE, then by Config.Default.
+// If the config has the only profile it is used by default. Settings of the profile are overridden by
+// $KSC_SERVER, $KSC_USER, $KSC_PASSWORD, $KSC_VSERVER, $KSC_SESSION and $KSC_INSECURE if they are set.
+func (c *Config) Profile(name string, getenv func(string) string) (Profile, error) {
+	if name == "" {
+		name = getenv("KSC_PROFILE")
+	}
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" && len(c.Profiles) == 1 {
+		for name = range c.Profiles {
+		}
+	}
+
+	var profile Profile
+	if name != "" {
+		p, ok := c.Profiles[name]
+		if !ok {
+			names := make([]string, 0, len(c.Profiles))
+			for n := range c.Profiles {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return Profile{}, fmt.Errorf("unknown profile %q, known profiles: %s", name, strings.Join(names, ", "))
+		}
+		profile = p
+	}
+
+	if profile.Password == "" && profile.PasswordEnv != "" {
+		profile.Password = getenv(profile.PasswordEnv)
+	}
+
+	for env, value := range map[string]*string{
+		"KSC_SERVER":   &profile.Server,
+		"KSC_USER":     &profile.User,
+		"KSC_PASSWORD": &profile.Password,
+		"KSC_VSERVER":  &profile.VServer,
+	} {
+		if v := getenv(env); v != "" {
+			*value = v
+		}
+	}
+
+	for env, value := range map[string]*bool{
+		"KSC_SESSION":  &profile.Session,
+		"KSC_INSECURE": &profile.Insecure,
+	} {
+		if v := getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return Profile{}, fmt.Errorf("%s: %w", env, err)
+			}
+			*value = b
+		}
+	}
+	return profile, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func eventsCommand() *command {
+	return &command{name: "events", summary: "follow and export events", subcommands: []*command{
+		{name: "tail", args: "", summary: "print the last events and optionally follow new ones", run: eventsTail},
+		{name: "export", args: "-out file", summary: "export events to CSV, JSON Lines or Parquet file", run: eventsExport},
+	}}
+}
+
+// eventFields maps event attributes of events srvview to output columns
+var eventFields = []field{
+	{"id", "event_db_id"},
+	{"time", "rise_time"},
+	{"host", "hostdn"},
+	{"severity", "severity"},
+	{"event", "event_type_display_name"},
+	{"task", "task_display_name"},
+	{"description", "descr"},
+}
+
+var eventSeverities = map[string]string{
+	"1": "info",
+	"2": "warning",
+	"3": "error",
+	"4": "critical",
+}
+
+// eventsFilter joins search filter of events with conditions on event id and rise time.
+func eventsFilter(filter string, after int64, since time.Time) string {
+	terms := filter
+	if after > 0 {
+		terms += fmt.Sprintf("(event_db_id > %d)", after)
+	}
+	if !since.IsZero() {
+		terms += fmt.Sprintf(`(rise_time >= T"%s")`, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if terms == filter {
+		return filter
+	}
+	return "(&" + terms + ")"
+}
+
+// queryEvents returns rows of events matching filter ordered by id.
+func queryEvents(ctx context.Context, client *kaspersky.Client, filter string, asc bool, topN int64) ([]export.Row, error) {
+	fields := make([]string, len(eventFields))
+	for i, f := range eventFields {
+		fields[i] = f.name
+	}
+
+	params, err := kaspersky.SrvViewQuery{
+		Filter: filter,
+		Fields: fields,
+		Order:  []kaspersky.OrderValue{{Name: "event_db_id", Asc: asc}},
+		TopN:   topN,
+	}.Params(kaspersky.EventRecord{})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []export.Row
+	err = export.SrvView(client.SrvView, params, 0)(ctx, func(record kaspersky.Params) error {
+		event := export.Flatten(record)
+		row := make(export.Row, len(eventFields))
+		for _, f := range eventFields {
+			row[f.column] = event[f.name]
+		}
+		if severity, ok := eventSeverities[fmt.Sprint(row["severity"])]; ok {
+			row["severity"] = severity
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+func eventID(row export.Row) int64 {
+	if id, ok := row["id"].(json.Number); ok {
+		n, _ := id.Int64()
+		return n
+	}
+	return 0
+}
+
+func eventsTail(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl events tail", "")
+	filter := fs.String("filter", "", "search `filter` of events, e.g. (severity >= 3)")
+	n := fs.Int64("n", 10, "print the last `n` events")
+	follow := fs.Bool("f", false, "follow new events")
+	interval := fs.Duration("interval", 10*time.Second, "new events polling `interval`")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	columns := make([]string, len(eventFields))
+	for i, f := range eventFields {
+		columns[i] = f.column
+	}
+	w := a.streamWriter(columns)
+
+	topN := *n
+	if topN <= 0 {
+		topN = 1
+	}
+	rows, err := queryEvents(ctx, client, *filter, false, topN)
+	if err != nil {
+		return err
+	}
+
+	var last int64
+	if len(rows) != 0 {
+		last = eventID(rows[0])
+	}
+	if *n > 0 {
+		for i := len(rows) - 1; i >= 0; i-- {
+			if err := w.Write(rows[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Close(); err != nil || !*follow {
+		return err
+	}
+
+	for {
+		if err := sleep(ctx, *interval); err != nil {
+			return nil
+		}
+
+		rows, err := queryEvents(ctx, client, eventsFilter(*filter, last, time.Time{}), true, 0)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if id := eventID(row); id > last {
+				last = id
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+func eventsExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl events export", "-out file")
+	filter := fs.String("filter", "", "search `filter` of events")
+	since := fs.Duration("since", 0, "export events registered during the last `duration` only")
+	format := fs.String("format", string(export.FormatCSV), "file `format`: csv, jsonl or parquet")
+	out := fs.String("out", "", "output `file`, - for stdout")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *out == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	params, err := kaspersky.SrvViewQuery{
+		Filter: eventsFilter(*filter, 0, from),
+		Order:  []kaspersky.OrderValue{{Name: "event_db_id", Asc: true}},
+	}.Params(kaspersky.EventRecord{})
+	if err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var file io.Writer = a.stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file = f
+	}
+
+	w, err := export.NewWriter(export.Format(*format), file, export.Options{Columns: params.VecFieldsToReturn})
+	if err != nil {
+		return err
+	}
+
+	stats, err := export.Export(ctx, export.SrvView(client.SrvView, params, 0), w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "%d events exported\n", stats.Rows)
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func groupsCommand() *command {
+	return &command{name: "groups", summary: "show and create administration groups", subcommands: []*command{
+		{name: "tree", args: "[path]", summary: "print administration groups tree", run: groupsTree},
+		{name: "create", args: "path...", summary: "create administration groups with missing parents", run: groupsCreate},
+	}}
+}
+
+// resolveGroup returns id of administration group by path, or id of the "Managed devices" group if path is empty.
+func resolveGroup(ctx context.Context, client *kaspersky.Client, path string) (int64, error) {
+	if path != "" {
+		return client.HostGroup.ResolveGroupPath(ctx, path)
+	}
+	root, _, err := client.HostGroup.GroupIdGroups(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return root.Int, nil
+}
+
+func groupsTree(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl groups tree", "[path]")
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	group, err := resolveGroup(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := a.writer([]string{"id", "path"})
+	err = client.HostGroup.WalkGroups(ctx, group, func(id int64, path string) error {
+		return w.Write(export.Row{"id": id, "path": path})
+	})
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func groupsCreate(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl groups create", "path...")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, fs.NArg())
+	for _, path := range fs.Args() {
+		id, err := client.HostGroup.EnsureGroupPath(ctx, path)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, export.Row{"id": id, "path": path})
+	}
+	return a.print([]string{"id", "path"}, rows)
+}
@@ -0,0 +1,340 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func hostsCommand() *command {
+	return &command{name: "hosts", summary: "find, inspect, move and remove hosts", subcommands: []*command{
+		{name: "find", args: "[host...]", summary: "find hosts by name or filter", run: hostsFind},
+		{name: "info", args: "host...", summary: "show host attributes", run: hostsInfo},
+		{name: "move", args: "-to group [host...]", summary: "move hosts into administration group", run: hostsMove},
+		{name: "remove", args: "[host...]", summary: "remove hosts from administration groups", run: hostsRemove},
+	}}
+}
+
+// field maps host attribute to output column
+type field struct {
+	column string
+	name   string
+}
+
+var hostFields = []field{
+	{"name", "KLHST_WKS_DN"},
+	{"id", "KLHST_WKS_HOSTNAME"},
+	{"group", "KLHST_WKS_GROUPID"},
+	{"os", "KLHST_WKS_OS_NAME"},
+	{"last_visible", "KLHST_WKS_LAST_VISIBLE"},
+}
+
+var hostInfoFields = []string{
+	"KLHST_WKS_DN",
+	"KLHST_WKS_HOSTNAME",
+	"KLHST_WKS_FQDN",
+	"KLHST_WKS_WIN_DOMAIN",
+	"KLHST_WKS_GROUPID",
+	"KLHST_WKS_OS_NAME",
+	"KLHST_WKS_IP_LONG",
+	"KLHST_WKS_STATUS",
+	"KLHST_WKS_STATUS_ID",
+	"KLHST_WKS_RTP_STATE",
+	"KLHST_WKS_LAST_VISIBLE",
+	"KLHST_WKS_LAST_INFOUDATE",
+	"KLHST_WKS_LAST_UPDATE",
+	"KLHST_WKS_CREATED",
+}
+
+// hostFilter returns filter of hosts matching filter and any of display names or host ids.
+func hostFilter(filter string, hosts []string) string {
+	var terms []string
+	for _, host := range hosts {
+		terms = append(terms, fmt.Sprintf("(KLHST_WKS_DN=%s)(KLHST_WKS_HOSTNAME=%s)", kaspersky.QuoteFilterValue(host), kaspersky.QuoteFilterValue(host)))
+	}
+
+	var names string
+	if len(terms) != 0 {
+		names = "(|" + strings.Join(terms, "") + ")"
+	}
+
+	switch {
+	case filter != "" && names != "":
+		return "(&" + filter + names + ")"
+	case names != "":
+		return names
+	}
+	return filter
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func hostsFind(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl hosts find", "[host...]")
+	filter := fs.String("filter", "", "search `filter`, e.g. (KLHST_WKS_OS_NAME=\"*Server*\")")
+	fields := fs.String("fields", "", "comma separated host `attributes` to print instead of the default columns")
+	limit := fs.Int("limit", 0, "print at most `n` hosts")
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	wstrFilter := hostFilter(*filter, fs.Args())
+	if wstrFilter == "" {
+		wstrFilter = `(KLHST_WKS_DN="*")`
+	}
+
+	columns := splitList(*fields)
+	names := columns
+	if len(columns) == 0 {
+		for _, f := range hostFields {
+			columns = append(columns, f.column)
+			names = append(names, f.name)
+		}
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	accessor, _, err := client.HostGroup.FindHosts(ctx, kaspersky.HGParams{
+		WstrFilter:        wstrFilter,
+		VecFieldsToReturn: names,
+		PParams:           kaspersky.PParams{KlgrpFindFromCurVsOnly: true},
+		LMaxLifeTime:      600,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.ChunkAccessor.Release(context.Background(), accessor.StrAccessor)
+
+	w := a.writer(columns)
+	n := 0
+	errLimit := errors.New("limit reached")
+	err = export.ChunkAccessor(client.ChunkAccessor, accessor.StrAccessor, 0)(ctx, func(record kaspersky.Params) error {
+		if *limit > 0 && n >= *limit {
+			return errLimit
+		}
+		n++
+		row := export.Flatten(record)
+		out := make(export.Row, len(columns))
+		for i, column := range columns {
+			out[column] = row[names[i]]
+		}
+		return w.Write(out)
+	})
+	if cerr := w.Close(); err == nil || err == errLimit {
+		err = cerr
+	}
+	return err
+}
+
+func hostsInfo(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl hosts info", "host...")
+	fields := fs.String("fields", strings.Join(hostInfoFields, ","), "comma separated host `attributes` to print")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := client.HostGroup.FindBulkHosts(ctx, hostFilter("", fs.Args()))
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("no hosts found: %s", strings.Join(fs.Args(), ", "))
+	}
+
+	columns := splitList(*fields)
+	rows := make([]export.Row, 0, len(hosts))
+	for _, host := range hosts {
+		raw, err := client.HostGroup.GetHostInfo(ctx, struct {
+			StrHostName    string   `json:"strHostName"`
+			PFields2Return []string `json:"pFields2Return"`
+		}{host.HostName, columns})
+		if err != nil {
+			return fmt.Errorf("%s: %w", host.DisplayName, err)
+		}
+
+		out := new(struct {
+			Info kaspersky.Params `json:"PxgRetVal"`
+		})
+		if err := json.Unmarshal(raw, out); err != nil {
+			return err
+		}
+		rows = append(rows, export.Flatten(out.Info))
+	}
+
+	if len(rows) == 1 {
+		return a.printFields(columns, rows[0])
+	}
+	return a.print(columns, rows)
+}
+
+// defaultBulkMax default limit of hosts changed by one bulk command
+const defaultBulkMax = 100
+
+// bulkFlags flags of bulk host commands.
+// Bulk commands only print the matched hosts unless -yes is given.
+type bulkFlags struct {
+	filter  *string
+	yes     *bool
+	max     *int
+	batch   *int
+	workers *int
+}
+
+func newBulkFlags(fs *flag.FlagSet) bulkFlags {
+	return bulkFlags{
+		filter:  fs.String("filter", "", "search `filter` of hosts"),
+		yes:     fs.Bool("yes", false, "apply changes, otherwise only print hosts that would be changed"),
+		max:     fs.Int("max", defaultBulkMax, "refuse to run if more than `n` hosts match, 0 for no limit"),
+		batch:   fs.Int("batch", 0, "`number` of hosts changed by one call, 100 by default"),
+		workers: fs.Int("concurrency", 0, "`number` of batches processed in parallel, 4 by default"),
+	}
+}
+
+func (f bulkFlags) options() kaspersky.BulkOptions {
+	return kaspersky.BulkOptions{BatchSize: *f.batch, Concurrency: *f.workers, DryRun: !*f.yes, MaxHosts: *f.max}
+}
+
+// printBulkResult prints changed, skipped and failed hosts of bulk operation.
+func (a *app) printBulkResult(result *kaspersky.BulkResult, changed string) error {
+	if result == nil {
+		return nil
+	}
+	if result.DryRun {
+		changed = "would be " + changed
+		defer fmt.Fprintln(a.stderr, "dry run, pass -yes to apply changes")
+	}
+
+	var rows []export.Row
+	add := func(host kaspersky.BulkHost, action string, err error) {
+		row := export.Row{"name": host.DisplayName, "id": host.HostName, "group": host.GroupID, "result": action}
+		if err != nil {
+			row["error"] = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	for _, host := range result.Changed {
+		add(host, changed, nil)
+	}
+	for _, host := range result.Skipped {
+		add(host, "skipped", nil)
+	}
+	for _, failure := range result.Failed {
+		add(failure.Host, "failed", failure.Err)
+	}
+	return a.print([]string{"name", "id", "group", "result", "error"}, rows)
+}
+
+func hostsMove(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl hosts move", "-to group [host...]")
+	to := fs.String("to", "", "destination administration group `path`, e.g. \"Managed devices/Branch\"")
+	create := fs.Bool("create", false, "create destination group if it does not exist")
+	bulk := newBulkFlags(fs)
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	filter := hostFilter(*bulk.filter, fs.Args())
+	if *to == "" || filter == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	var group int64
+	if *create && *bulk.yes {
+		group, err = client.HostGroup.EnsureGroupPath(ctx, *to)
+	} else {
+		group, err = client.HostGroup.ResolveGroupPath(ctx, *to)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := client.HostGroup.BulkMoveHostsToGroup(ctx, filter, group, bulk.options())
+	if perr := a.printBulkResult(result, "moved"); err == nil {
+		err = perr
+	}
+	if err == nil {
+		err = result.Err()
+	}
+	return err
+}
+
+func hostsRemove(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl hosts remove", "[host...]")
+	force := fs.Bool("force", false, "force deleting hosts records")
+	bulk := newBulkFlags(fs)
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	filter := hostFilter(*bulk.filter, fs.Args())
+	if filter == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.HostGroup.BulkRemoveHosts(ctx, filter, *force, bulk.options())
+	if perr := a.printBulkResult(result, "removed"); err == nil {
+		err = perr
+	}
+	if err == nil {
+		err = result.Err()
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/inventory"
+)
+
+func inventoryCommand() *command {
+	return &command{name: "inventory", summary: "export hardware and software inventory", subcommands: []*command{
+		{name: "export", args: "", summary: "export inventory of hosts changed since the previous export", run: inventoryExport},
+		{name: "track", args: "", summary: "snapshot applications of hosts and print changes since the previous snapshot", run: inventoryTrack},
+		{name: "history", args: "host-id", summary: "print application changes of host", run: inventoryHistory},
+	}}
+}
+
+// inventoryFiles exported files by name without extension
+var inventoryFiles = []struct {
+	name    string
+	columns []string
+}{
+	{"hosts", inventory.HostColumns},
+	{"applications", inventory.ApplicationColumns},
+	{"patches", inventory.PatchColumns},
+	{"hardware", inventory.HardwareColumns},
+}
+
+func inventoryExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl inventory export", "")
+	dir := fs.String("dir", ".", "output `directory`")
+	format := fs.String("format", "csv", "file `format`: csv or jsonl")
+	statePath := fs.String("state", "", "export state `file`, inventory-state.json in the output directory by default")
+	full := fs.Bool("full", false, "export all hosts regardless of the state")
+	filter := fs.String("filter", "", "host search `filter`, hosts missing from a filtered export are not reported as removed")
+	concurrency := fs.Int("concurrency", 4, "number of hosts inventory is acquired for concurrently")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	if *format != string(export.FormatCSV) && *format != string(export.FormatJSONL) {
+		return fmt.Errorf("unknown inventory format %q, expected csv or jsonl", *format)
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(*dir, "inventory-state.json")
+	}
+
+	state, err := inventory.LoadState(*statePath)
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	writers := make([]export.Writer, len(inventoryFiles))
+	for i, file := range inventoryFiles {
+		f, err := os.Create(filepath.Join(*dir, file.name+"."+*format))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		writers[i], err = export.NewWriter(export.Format(*format), f, export.Options{Columns: file.columns})
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := inventory.Export(ctx, client, state, inventory.ExportOptions{
+		Options: inventory.Options{Filter: *filter, Concurrency: *concurrency},
+		Full:    *full,
+	}, inventory.Sinks{
+		Hosts:        writers[0],
+		Applications: writers[1],
+		Patches:      writers[2],
+		Hardware:     writers[3],
+	})
+	// hosts whose inventory failed keep their previous state, the state of the other hosts is saved
+	var failed inventory.HostErrors
+	if errors.As(err, &failed) {
+		err = nil
+	}
+	for _, w := range writers {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// the state is saved only when all files are written, so hosts of a failed export are exported again
+	if err := state.Save(*statePath); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+
+	err = a.printFields([]string{"hosts", "new", "changed", "removed", "unchanged", "failed"}, export.Row{
+		"hosts":     int64(result.Hosts),
+		"new":       int64(result.New),
+		"changed":   int64(result.Changed),
+		"removed":   int64(result.Removed),
+		"unchanged": int64(result.Unchanged),
+		"failed":    int64(result.Failed),
+	})
+	if err == nil && failed != nil {
+		err = failed
+	}
+	return err
+}
+
+func inventoryTrack(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl inventory track", "")
+	storeDir := fs.String("store", "inventory-snapshots", "snapshot store `directory`")
+	publishers := fs.String("publisher", "", "comma separated publisher `patterns` of tracked applications, e.g. Microsoft*")
+	names := fs.String("name", "", "comma separated name `patterns` of tracked applications")
+	filter := fs.String("filter", "", "host search `filter`")
+	concurrency := fs.Int("concurrency", 4, "number of hosts applications are acquired for concurrently")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	store, err := inventory.OpenStore(*storeDir)
+	if err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := a.streamWriter(inventory.ChangeColumns)
+	_, err = inventory.Track(ctx, client, store, inventory.TrackOptions{
+		Options: inventory.Options{Filter: *filter, Concurrency: *concurrency},
+		Apps:    inventory.AppFilter{Publishers: splitList(*publishers), Names: splitList(*names)},
+	}, inventory.WriterSink(w))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func inventoryHistory(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl inventory history", "host-id")
+	storeDir := fs.String("store", "inventory-snapshots", "snapshot store `directory`")
+	since := fs.Duration("since", 0, "print changes found during the last `duration` only")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	store, err := inventory.OpenStore(*storeDir)
+	if err != nil {
+		return err
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+	changes, err := store.History(inventory.HostKey(fs.Arg(0)), from)
+	if err != nil {
+		return err
+	}
+
+	w := a.writer(inventory.ChangeColumns)
+	sink := inventory.WriterSink(w)
+	for _, change := range changes {
+		if err := sink.Emit(ctx, change); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+)
+
+func expectEqual(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\n expected: \n %#v \n actual: \n %#v", expected, actual)
+	}
+}
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "kscctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigProfile(t *testing.T) {
+	path := writeConfig(t, `
+default: prod
+profiles:
+  prod:
+    server: https://ksc.example.com:13299
+    user: api
+    passwordEnv: PROD_PASSWORD
+  lab:
+    server: https://10.0.0.5:13299
+    user: admin
+    password: secret
+    insecure: true
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"PROD_PASSWORD": "p@ss"}
+	getenv := func(name string) string { return env[name] }
+
+	profile, err := cfg.Profile("", getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, Profile{Server: "https://ksc.example.com:13299", User: "api", Password: "p@ss", PasswordEnv: "PROD_PASSWORD"}, profile)
+
+	env["KSC_PROFILE"] = "lab"
+	env["KSC_USER"] = "operator"
+	env["KSC_INSECURE"] = "false"
+	profile, err = cfg.Profile("", getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, Profile{Server: "https://10.0.0.5:13299", User: "operator", Password: "secret"}, profile)
+
+	if _, err := cfg.Profile("missing", getenv); err == nil || !strings.Contains(err.Error(), "lab, prod") {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+
+	env["KSC_INSECURE"] = "maybe"
+	if _, err := cfg.Profile("lab", getenv); err == nil {
+		t.Fatal("expected invalid KSC_INSECURE error")
+	}
+
+	if _, err := LoadConfig(path + ".missing"); err == nil {
+		t.Fatal("expected error of missing explicit config file")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	rows := []export.Row{
+		{"id": int64(1), "name": "WKS-1", "seen": time.Date(2020, 5, 28, 7, 22, 14, 0, time.UTC)},
+		{"id": int64(2), "name": "multi\nline"},
+	}
+	columns := []string{"id", "name"}
+
+	var out bytes.Buffer
+	a := &app{format: FormatTable, stdout: &out}
+	if err := a.print(columns, rows); err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "ID  NAME\n1   WKS-1\n2   multi line\n", out.String())
+
+	out.Reset()
+	a.format = FormatCSV
+	if err := a.print(columns, rows); err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "id,name\n1,WKS-1\n2,\"multi\nline\"\n", out.String())
+
+	out.Reset()
+	a.format = FormatJSON
+	if err := a.print([]string{"id", "seen"}, rows); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, []map[string]interface{}{
+		{"id": float64(1), "seen": "2020-05-28T07:22:14Z"},
+		{"id": float64(2), "seen": nil},
+	}, decoded)
+
+	out.Reset()
+	a.format = FormatTable
+	if err := a.print(columns, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "ID  NAME\n", out.String())
+}
+
+func TestHostFilter(t *testing.T) {
+	expectEqual(t, `(|(KLHST_WKS_DN="WKS-1")(KLHST_WKS_HOSTNAME="WKS-1")(KLHST_WKS_DN="a\"b")(KLHST_WKS_HOSTNAME="a\"b"))`,
+		hostFilter("", []string{"WKS-1", `a"b`}))
+	expectEqual(t, `(&(KLHST_WKS_STATUS_ID=1)(|(KLHST_WKS_DN="x")(KLHST_WKS_HOSTNAME="x")))`,
+		hostFilter("(KLHST_WKS_STATUS_ID=1)", []string{"x"}))
+	expectEqual(t, "(KLHST_WKS_STATUS_ID=1)", hostFilter("(KLHST_WKS_STATUS_ID=1)", nil))
+}
+
+func TestRunGroupsTree(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1.0/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v1.0/HostGroup.GroupIdGroups", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"PxgRetVal": 1}`))
+	})
+	mux.HandleFunc("/api/v1.0/HostGroup.GetGroupInfoEx", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`))
+	})
+	mux.HandleFunc("/api/v1.0/HostGroup.GetSubgroups", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NParent int64 `json:"nParent"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NParent == 1 {
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Branch"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": []}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config := writeConfig(t, "")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-config", config, "-server", srv.URL, "-o", "csv", "groups", "tree"},
+		&stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	expectEqual(t, "id,path\n1,Managed devices\n2,Managed devices/Branch\n", stdout.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"-config", config, "-server", srv.URL, "groups", "unknown"}, &stdout, &stderr)
+	expectEqual(t, 2, code)
+	if !strings.Contains(stderr.String(), `unknown command "unknown"`) {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestRunCall(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1.0/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v1.0/HostGroup.GetGroupInfo", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NGroupID int64 `json:"nGroupId"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NGroupID != 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"PxgError": {"code": 1183, "message": "Object not found"}}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": {"name": "Managed devices"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config := writeConfig(t, "")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-config", config, "-server", srv.URL, "call", "HostGroup.GetGroupInfo", `{"nGroupId": 1}`},
+		&stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	expectEqual(t, "{\n  \"PxgRetVal\": {\n    \"name\": \"Managed devices\"\n  }\n}\n", stdout.String())
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"-config", config, "-server", srv.URL, "call", "HostGroup.GetGroupInfo", `{"nGroupId": 2}`},
+		&stdout, &stderr)
+	expectEqual(t, 1, code)
+	expectEqual(t, "kscctl: Code: 1183, Message: Object not found\n", stderr.String())
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"-config", config, "-server", srv.URL, "call", "HostGroup.GetGroupInfo", `{`},
+		&stdout, &stderr)
+	expectEqual(t, 1, code)
+	expectEqual(t, "kscctl: params are not valid JSON\n", stderr.String())
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`hosts find "(KLHST_WKS_DN=\"PC-*\")" 'a b' c\ d`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, []string{"hosts", "find", `(KLHST_WKS_DN="PC-*")`, "a b", "c d"}, args)
+
+	if _, err := splitArgs(`hosts find "PC`); err == nil {
+		t.Fatal("expected unterminated quote error")
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	s := &shell{root: root(), groups: []string{"Managed devices", "Managed devices/Branch", "Managed devices/Branch Office",
+		"Managed devices/Отдел", "Managed devices/Офис"}}
+
+	complete := func(line string) (string, []string) {
+		completed, pos, matches := s.complete([]rune(line), len([]rune(line)))
+		expectEqual(t, len(completed), pos)
+		return string(completed), matches
+	}
+
+	line, _ := complete("tas")
+	expectEqual(t, "task", line)
+	line, matches := complete("task")
+	expectEqual(t, "task", line)
+	expectEqual(t, []string{"task", "tasks"}, matches)
+	line, _ = complete("tasks ru")
+	expectEqual(t, "tasks run ", line)
+	line, _ = complete("hosts move -to Managed")
+	expectEqual(t, `hosts move -to "Managed devices`, line)
+	line, matches = complete(`hosts move -to "Managed devices/Branch `)
+	expectEqual(t, `hosts move -to "Managed devices/Branch Office" `, line)
+	expectEqual(t, []string{"Managed devices/Branch Office"}, matches)
+	line, _ = complete("groups tree Man")
+	expectEqual(t, `groups tree "Managed devices`, line)
+	line, _ = complete("policies import -group Man")
+	expectEqual(t, `policies import -group "Managed devices`, line)
+
+	// host names and files are not administration groups
+	line, matches = complete("hosts move -to Managed Man")
+	expectEqual(t, "hosts move -to Managed Man", line)
+	expectEqual(t, []string(nil), matches)
+	_, matches = complete("policies import -group Managed Man")
+	expectEqual(t, []string(nil), matches)
+
+	// common prefix is cut on rune boundaries
+	line, _ = complete(`groups tree "managed devices/о`)
+	expectEqual(t, `groups tree "Managed devices/О`, line)
+
+	// only Open API methods are completed
+	_, matches = complete("call HostGroup.FindHosts")
+	expectEqual(t, []string{"HostGroup.FindHosts", "HostGroup.FindHostsAsync", "HostGroup.FindHostsAsyncCancel",
+		"HostGroup.FindHostsAsyncGetAccessor"}, matches)
+	_, matches = complete("call HostGroup.Ensure")
+	expectEqual(t, []string(nil), matches)
+}
+
+func TestKscMethods(t *testing.T) {
+	files, err := filepath.Glob("../../kaspersky/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]bool{}
+	method := regexp.MustCompile(`/api/v1\.0/(\w+\.\w+)`)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range method.FindAllStringSubmatch(string(data), -1) {
+			names[m[1]] = true
+		}
+	}
+
+	var expected []string
+	for name := range names {
+		expected = append(expected, name)
+	}
+	sort.Strings(expected)
+	expectEqual(t, expected, kscMethods)
+}
+
+func TestLineEditor(t *testing.T) {
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader("ta\tst\x1b[D\x1b[Da\r\x1b[A\x1b[A\r\x03\x04"), &out, func(line []rune, pos int) ([]rune, int, []string) {
+		return []rune("tasks "), 6, []string{"tasks"}
+	})
+	e.addHistory("help")
+
+	line, err := e.edit("> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "tasks ast", line)
+	e.addHistory(line)
+
+	line, err = e.edit("> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "help", line)
+
+	_, err = e.edit("> ")
+	expectEqual(t, errInterrupted, err)
+	_, err = e.edit("> ")
+	expectEqual(t, io.EOF, err)
+}
+
+func TestRunShell(t *testing.T) {
+	hosts := []string{"PC-001", "PC-002", "PC-003"}
+	released := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1.0/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v1.0/HostGroup.FindHosts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"strAccessor": "acc", "PxgRetVal": 3}`))
+	})
+	mux.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsCount", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"PxgRetVal": 3}`))
+	})
+	mux.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsChunk", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NStart int `json:"nStart"`
+			NCount int `json:"nCount"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		var items []string
+		for i := in.NStart; i < len(hosts) && i < in.NStart+in.NCount; i++ {
+			items = append(items, `{"type": "params", "value": {"KLHST_WKS_DN": "`+hosts[i]+`", "KLHST_WKS_GROUPID": 1}}`)
+		}
+		w.Write([]byte(`{"pChunk": {"KLCSP_ITERATOR_ARRAY": [` + strings.Join(items, ",") + `]}, "PxgRetVal": ` +
+			strconv.Itoa(len(items)) + `}`))
+	})
+	mux.HandleFunc("/api/v1.0/ChunkAccessor.Release", func(w http.ResponseWriter, r *http.Request) {
+		released = true
+		w.Write([]byte(`{}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	a := &app{
+		profile: Profile{Server: srv.URL},
+		format:  FormatCSV,
+		stdin:   strings.NewReader("hosts find \"(KLHST_WKS_DN=\\\"PC-*\\\")\"\nnext\nnext\nprev\nclose\nnext\n"),
+		stdout:  &stdout,
+		stderr:  &stderr,
+	}
+	if err := runShell(context.Background(), a, []string{"-history", "", "-page", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	header := "name,id,group,os,last_visible\n"
+	prompt := "127.0.0.1> "
+	expectEqual(t, prompt+header+"PC-001,,1,,\nPC-002,,1,,\n"+
+		prompt+header+"PC-003,,1,,\n"+
+		prompt+
+		prompt+header+"PC-001,,1,,\nPC-002,,1,,\n"+
+		prompt+prompt+prompt, stdout.String())
+	expectEqual(t, "rows 1-2 of 3\nrows 3-3 of 3\nerror: no more rows, 3 rows total\nrows 1-2 of 3\n"+
+		"error: no open result-set, run \"hosts find\" or \"view\" first\n", stderr.String())
+	expectEqual(t, true, released)
+}
+
+func TestRunReportsDownload(t *testing.T) {
+	var calls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1.0/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v1.0/ReportManager.ExecuteReportAsyncGetData", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			// not generated yet
+			w.Write([]byte(`{"pXmlData": ""}`))
+			return
+		}
+		// generated empty report
+		w.Write([]byte(`{"pXmlData": "", "nDataSizeRest": 0}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	config := writeConfig(t, "")
+	out := filepath.Join(t.TempDir(), "report.html")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-config", config, "-server", srv.URL,
+		"reports", "download", "-poll", "1ms", "-out", out, "req-1"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	expectEqual(t, 2, calls)
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEqual(t, "", string(data))
+	expectEqual(t, "report written to "+out+" (0 bytes)\n", stderr.String())
+}
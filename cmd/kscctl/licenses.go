@@ -0,0 +1,213 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/licensing"
+)
+
+func licensesCommand() *command {
+	return &command{name: "licenses", summary: "list license keys and their usage", subcommands: []*command{
+		{name: "list", args: "", summary: "list license keys", run: licensesList},
+		{name: "usage", args: "[serial...]", summary: "show number of hosts using license keys", run: licensesUsage},
+		{name: "hosts", args: "serial", summary: "list hosts using license key", run: licensesHosts},
+		{name: "check", args: "", summary: "report expiring and overused license keys", run: licensesCheck},
+	}}
+}
+
+func licenseKeys(ctx context.Context, client *kaspersky.Client) ([]kaspersky.LicenseKeyRecord, error) {
+	var keys []kaspersky.LicenseKeyRecord
+	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{
+		Order: []kaspersky.OrderValue{{Name: "KLLIC_SERIAL", Asc: true}},
+	}, &keys)
+	return keys, err
+}
+
+// expires returns key expiration time, zero time if the key does not expire.
+func expires(key kaspersky.LicenseKeyRecord) time.Time {
+	return key.LimitDate.Time()
+}
+
+func licensesList(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl licenses list", "")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := licenseKeys(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, export.Row{
+			"serial":   key.Serial,
+			"product":  key.ProductName,
+			"type":     key.KeyType,
+			"licenses": key.LicenseCount,
+			"created":  key.CreationDate.Time(),
+			"expires":  expires(key),
+		})
+	}
+	return a.print([]string{"serial", "product", "type", "licenses", "created", "expires"}, rows)
+}
+
+func licensesUsage(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl licenses usage", "[serial...]")
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := licenseKeys(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		wanted := map[string]bool{}
+		for _, serial := range fs.Args() {
+			wanted[serial] = true
+		}
+		selected := keys[:0]
+		for _, key := range keys {
+			if wanted[key.Serial] {
+				selected = append(selected, key)
+				delete(wanted, key.Serial)
+			}
+		}
+		for serial := range wanted {
+			return fmt.Errorf("license key %s not found", serial)
+		}
+		keys = selected
+	}
+
+	rows := make([]export.Row, 0, len(keys))
+	for _, key := range keys {
+		// the host iterator is not needed, it expires on the server after lTimeoutSec
+		hosts, _, err := client.LicenseKeys.AcquireKeyHosts(ctx, kaspersky.AcquireKeyHostsParams{
+			PInData:     kaspersky.PInData{KllicSerial: key.Serial},
+			PFields:     []string{"KLHST_WKS_HOSTNAME"},
+			LTimeoutSEC: 60,
+		})
+		if err != nil {
+			return fmt.Errorf("license key %s: %w", key.Serial, err)
+		}
+
+		row := export.Row{
+			"serial":   key.Serial,
+			"product":  key.ProductName,
+			"licenses": key.LicenseCount,
+			"used":     hosts.LKeyCount,
+			"expires":  expires(key),
+		}
+		if key.LicenseCount > 0 {
+			row["free"] = key.LicenseCount - hosts.LKeyCount
+		}
+		rows = append(rows, row)
+	}
+	return a.print([]string{"serial", "product", "licenses", "used", "free", "expires"}, rows)
+}
+
+func licensesHosts(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl licenses hosts", "serial")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := licensing.KeyHosts(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(hosts))
+	for _, host := range hosts {
+		rows = append(rows, export.Row{"id": host.ID, "name": host.Name, "group": host.GroupID})
+	}
+	return a.print([]string{"id", "name", "group"}, rows)
+}
+
+func licensesCheck(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl licenses check", "")
+	days := fs.Int("days", 30, "warn about keys expiring within `n` days")
+	fail := fs.Bool("fail", false, "exit with non-zero status if there are alerts")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := licensing.Keys(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	alerts := licensing.Check(keys, time.Now(), *days)
+	rows := make([]export.Row, 0, len(alerts))
+	for _, alert := range alerts {
+		row := export.Row{
+			"alert":   alert.Kind,
+			"serial":  alert.Serial,
+			"product": alert.Product,
+			"message": alert.Message,
+		}
+		if !alert.Expires.IsZero() {
+			row["expires"] = alert.Expires
+		}
+		rows = append(rows, row)
+	}
+	if err := a.print([]string{"alert", "serial", "product", "expires", "message"}, rows); err != nil {
+		return err
+	}
+
+	if *fail && len(alerts) != 0 {
+		return fmt.Errorf("%d license alerts", len(alerts))
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by lineEditor.ReadLine if the line is discarded by Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// completer returns line with the word before pos completed and the new cursor position.
+// If the word has several completions, they are returned as candidates.
+type completer func(line []rune, pos int) (completed []rune, newPos int, candidates []string)
+
+// lineEditor reads command lines with history and completion from a terminal.
+// If input is not a terminal, lines are read as is.
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int
+	terminal bool
+	complete completer
+
+	history []string
+	// maxHistory number of lines kept in history
+	maxHistory int
+}
+
+func newLineEditor(in io.Reader, out io.Writer, complete completer) *lineEditor {
+	e := &lineEditor{in: bufio.NewReader(in), out: out, fd: -1, complete: complete, maxHistory: 1000}
+	if f, ok := in.(*os.File); ok {
+		if restore, err := makeRaw(int(f.Fd())); err == nil {
+			_ = restore()
+			e.fd, e.terminal = int(f.Fd()), true
+		}
+	}
+	return e
+}
+
+// addHistory appends line to history skipping empty lines and repeats.
+func (e *lineEditor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) != 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > e.maxHistory {
+		e.history = e.history[len(e.history)-e.maxHistory:]
+	}
+}
+
+// ReadLine prints prompt and reads the next line. io.EOF is returned on end of input or Ctrl-D on empty line.
+func (e *lineEditor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	if !e.terminal {
+		line, err := e.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// edit implements line editing of the terminal in raw mode.
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	historyPos := len(e.history)
+	var pending []rune // line being edited while browsing history
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(l []rune) {
+		line = append([]rune(nil), l...)
+		pos = len(line)
+		redraw()
+	}
+	showHistory := func(i int) {
+		if i < 0 || i > len(e.history) || i == historyPos {
+			return
+		}
+		if historyPos == len(e.history) {
+			pending = append([]rune(nil), line...)
+		}
+		historyPos = i
+		if i == len(e.history) {
+			setLine(pending)
+			return
+		}
+		setLine([]rune(e.history[i]))
+	}
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+				redraw()
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(line)
+			redraw()
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+				redraw()
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+				redraw()
+			}
+		case 11: // Ctrl-K
+			line = line[:pos]
+			redraw()
+		case 21: // Ctrl-U
+			line = append([]rune(nil), line[pos:]...)
+			pos = 0
+			redraw()
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+			redraw()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			redraw()
+		case 16: // Ctrl-P
+			showHistory(historyPos - 1)
+		case 14: // Ctrl-N
+			showHistory(historyPos + 1)
+		case 8, 127: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+				redraw()
+			}
+		case '\t':
+			if e.complete == nil {
+				continue
+			}
+			completed, newPos, candidates := e.complete(line, pos)
+			if len(candidates) > 1 {
+				fmt.Fprintf(e.out, "\n%s\n", columnize(candidates, 80))
+			}
+			line, pos = completed, newPos
+			redraw()
+		case 27: // escape sequence
+			switch e.escape() {
+			case "[A", "OA":
+				showHistory(historyPos - 1)
+			case "[B", "OB":
+				showHistory(historyPos + 1)
+			case "[C", "OC":
+				if pos < len(line) {
+					pos++
+					redraw()
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			case "[H", "OH", "[1~", "[7~":
+				pos = 0
+				redraw()
+			case "[F", "OF", "[4~", "[8~":
+				pos = len(line)
+				redraw()
+			case "[3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+				redraw()
+			}
+		}
+	}
+}
+
+// escape reads the rest of escape sequence after ESC, e.g. "[A" of the up arrow key.
+func (e *lineEditor) escape() string {
+	first, _, err := e.in.ReadRune()
+	if err != nil || first != '[' && first != 'O' {
+		return ""
+	}
+	seq := []rune{first}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if r >= 0x40 && r <= 0x7e {
+			return string(seq)
+		}
+	}
+}
+
+// columnize lays out words in columns fitting width.
+func columnize(words []string, width int) string {
+	longest := 0
+	for _, word := range words {
+		if len(word) > longest {
+			longest = len(word)
+		}
+	}
+	perLine := width / (longest + 2)
+	if perLine < 1 {
+		perLine = 1
+	}
+
+	var b strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			if i%perLine == 0 {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		if (i+1)%perLine != 0 && i != len(words)-1 {
+			word += strings.Repeat(" ", longest-len(word))
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
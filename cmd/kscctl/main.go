@@ -0,0 +1,312 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Command kscctl runs everyday Kaspersky Security Center operations from the command line.
+//
+// Usage:
+//
+//	kscctl [global flags] <command> <subcommand> [flags] [args]
+//
+// Connection settings are read from the profile of the config file, see Config,
+// and may be overridden by KSC_* environment variables and global flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// errUsage is returned by commands on invalid arguments, usage is already printed.
+var errUsage = errors.New("invalid usage")
+
+// command is a node of the command tree. Leaf commands have run, group commands have subcommands.
+type command struct {
+	name        string
+	args        string
+	summary     string
+	subcommands []*command
+	run         func(ctx context.Context, a *app, args []string) error
+
+	// interactive commands handle interrupts themselves, other commands are canceled by SIGINT or SIGTERM
+	interactive bool
+}
+
+func (c *command) find(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// root returns the command tree of kscctl.
+func root() *command {
+	return &command{name: "kscctl", subcommands: []*command{
+		callCommand(),
+		shellCommand(),
+		hostsCommand(),
+		groupsCommand(),
+		tasksCommand(),
+		policiesCommand(),
+		reportsCommand(),
+		eventsCommand(),
+		licensesCommand(),
+		inventoryCommand(),
+		vapmCommand(),
+		rbacCommand(),
+	}}
+}
+
+// app state shared by commands
+type app struct {
+	profile Profile
+	format  string
+	timeout time.Duration
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+
+	client *kaspersky.Client
+}
+
+// connect returns client authenticated on the Administration Server of the profile,
+// the connection is established once and reused by following calls.
+func (a *app) connect(ctx context.Context) (*kaspersky.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	if a.profile.Server == "" {
+		return nil, errors.New("server is not configured, set it in the config profile, KSC_SERVER or -server")
+	}
+
+	client := kaspersky.New(a.profile.config())
+	if err := client.KSCAuth(ctx); err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", a.profile.Server, err)
+	}
+	a.client = client
+	return client, nil
+}
+
+// close ends X-KSC-Session session of the client if any.
+func (a *app) close() {
+	if a.client != nil && a.client.XKscSession && a.client.SessionToken() != "" {
+		_, _ = a.client.Session.EndSession(context.Background())
+		a.client = nil
+	}
+}
+
+// flagSet returns flag set of command path printing errors and usage to stderr.
+func (a *app) flagSet(path, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: %s [flags] %s\n", path, args)
+		var hasFlags bool
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(a.stderr, "\nFlags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parse parses command flags and checks the number of positional arguments is in [min, max], max < 0 means no limit.
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if n := fs.NArg(); n < min || max >= 0 && n > max {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// dispatch runs command of the tree selected by args.
+func dispatch(ctx context.Context, a *app, cmd *command, path string, args []string) error {
+	if cmd.run != nil {
+		if !cmd.interactive {
+			var stop func()
+			ctx, stop = withInterrupt(ctx)
+			defer stop()
+		}
+		return cmd.run(ctx, a, args)
+	}
+
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		printCommands(a.stderr, cmd, path)
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+
+	sub := cmd.find(args[0])
+	if sub == nil {
+		fmt.Fprintf(a.stderr, "%s: unknown command %q\n\n", path, args[0])
+		printCommands(a.stderr, cmd, path)
+		return errUsage
+	}
+	return dispatch(ctx, a, sub, path+" "+sub.name, args[1:])
+}
+
+func printCommands(w io.Writer, cmd *command, path string) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [args]\n\nCommands:\n", path)
+	subs := append([]*command(nil), cmd.subcommands...)
+	sort.Slice(subs, func(i, j int) bool { return subs[i].name < subs[j].name })
+	for _, sub := range subs {
+		usage := sub.name
+		if sub.args != "" {
+			usage += " " + sub.args
+		}
+		fmt.Fprintf(w, "  %-40s %s\n", usage, sub.summary)
+	}
+}
+
+const globalUsage = `Usage: kscctl [global flags] <command> <subcommand> [flags] [args]
+
+Connection profiles are read from the config file (-config, $KSCCTL_CONFIG or %s).
+The profile is selected by -profile, $KSC_PROFILE or the "default" key of the config file,
+its settings are overridden by $KSC_SERVER, $KSC_USER, $KSC_PASSWORD, $KSC_VSERVER, $KSC_SESSION, $KSC_INSECURE
+and by the global flags.
+
+Global flags:
+`
+
+// run runs kscctl with command line args and returns the process exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("kscctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	configPath := fs.String("config", "", "config file `path`")
+	profileName := fs.String("profile", "", "connection profile `name`")
+	server := fs.String("server", "", "Administration Server `url`, e.g. https://ksc.example.com:13299")
+	user := fs.String("user", "", "user `name`")
+	vserver := fs.String("vserver", "", "virtual server `name`")
+	insecure := fs.Bool("insecure", false, "skip server certificate verification")
+	format := fs.String("o", FormatTable, "output `format`: table, json or csv")
+	timeout := fs.Duration("timeout", 0, "abort the command after `duration`")
+
+	cmd := root()
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, globalUsage, defaultConfigPath())
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr)
+		printCommands(stderr, cmd, "kscctl")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if !validFormat(*format) {
+		fmt.Fprintf(stderr, "kscctl: unknown output format %q\n", *format)
+		return 2
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "kscctl: %v\n", err)
+		return 1
+	}
+
+	profile, err := cfg.Profile(*profileName, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "kscctl: %v\n", err)
+		return 1
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "server":
+			profile.Server = *server
+		case "user":
+			profile.User = *user
+		case "vserver":
+			profile.VServer = *vserver
+		case "insecure":
+			profile.Insecure = *insecure
+		}
+	})
+
+	a := &app{profile: profile, format: *format, timeout: *timeout, stdin: os.Stdin, stdout: stdout, stderr: stderr}
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	err = dispatch(ctx, a, cmd, "kscctl", fs.Args())
+	a.close()
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "kscctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// withInterrupt returns context canceled by SIGINT or SIGTERM until stop is called.
+func withInterrupt(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,278 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+// kscMethods Open API methods completed after call, the "Service.Method" names called by package kaspersky
+var kscMethods = []string{
+	"AKPatches.ApprovePatch", "AKPatches.ForbidPatch",
+	"AdHosts.FindAdGroups", "AdHosts.GetChildComputer", "AdHosts.GetChildComputers", "AdHosts.GetChildOUs",
+	"AdHosts.GetOU", "AdHosts.UpdateOU",
+	"AdSecManager.ApproveDetect", "AdSecManager.DisproveDetect",
+	"AdfsSso.GetSettings", "AdfsSso.SetSettings",
+	"AdmServerSettings.ChangeSharedFolder", "AdmServerSettings.GetSharedFolder",
+	"AppCtrlApi.GetExeFileInfo",
+	"AsyncActionStateChecker.CheckActionState",
+	"CertPoolCtrl.GetCertificateInfo", "CertPoolCtrl.SetCertificate",
+	"CertPoolCtrl2.GetCertificateInfoDetails",
+	"CgwHelper.GetNagentLocation", "CgwHelper.GetSlaveServerLocation",
+	"ChunkAccessor.GetItemsChunk", "ChunkAccessor.GetItemsCount", "ChunkAccessor.Release",
+	"CloudAccess.AcquireAccessForKeyPair", "CloudAccess.VerifyCredentials",
+	"ConEvents.Retrieve", "ConEvents.Subscribe", "ConEvents.UnSubscribe",
+	"DataProtectionApi.CheckPasswordSplPpc", "DataProtectionApi.ProtectDataForHost",
+	"DataProtectionApi.ProtectDataGlobally", "DataProtectionApi.ProtectUtf16StringForHost",
+	"DataProtectionApi.ProtectUtf16StringGlobally", "DataProtectionApi.ProtectUtf8StringForHost",
+	"DataProtectionApi.ProtectUtf8StringGlobally",
+	"DatabaseInfo.CheckBackupPath", "DatabaseInfo.CheckBackupPath2", "DatabaseInfo.GetDBDataSize",
+	"DatabaseInfo.GetDBEventsCount", "DatabaseInfo.GetDBSize", "DatabaseInfo.IsCloudSQL", "DatabaseInfo.IsLinuxSQL",
+	"DpeKeyService.GetDeviceKeys3",
+	"EventNotificationProperties.GetDefaultSettings", "EventNotificationProperties.GetNotificationLimits",
+	"EventNotificationProperties.SetDefaultSettings", "EventNotificationProperties.SetNotificationLimits",
+	"EventNotificationProperties.TestNotification",
+	"EventNotificationsApi.PublishEvent",
+	"EventProcessing.CancelDelete", "EventProcessing.GetRecordCount", "EventProcessing.GetRecordRange",
+	"EventProcessing.InitiateDelete", "EventProcessing.ReleaseIterator",
+	"EventProcessingFactory.CreateEventProcessing", "EventProcessingFactory.CreateEventProcessing2",
+	"EventProcessingFactory.CreateEventProcessingForHost", "EventProcessingFactory.CreateEventProcessingForHost2",
+	"ExtAud.FinalDelete", "ExtAud.GetRevision", "ExtAud.UpdateRevisionDesc",
+	"FileCategorizer2.AddExpressions", "FileCategorizer2.CancelFileMetadataOperations",
+	"FileCategorizer2.CancelFileUpload", "FileCategorizer2.CreateCategory", "FileCategorizer2.DeleteCategory",
+	"FileCategorizer2.DeleteExpression", "FileCategorizer2.DoStaticAnalysisAsync",
+	"FileCategorizer2.DoStaticAnalysisAsync2", "FileCategorizer2.DoTestStaticAnalysisAsync",
+	"FileCategorizer2.DoTestStaticAnalysisAsync2", "FileCategorizer2.FinishStaticAnalysis",
+	"FileCategorizer2.ForceCategoryUpdate", "FileCategorizer2.GetCategoriesModificationCounter",
+	"FileCategorizer2.GetCategory", "FileCategorizer2.GetCategoryByUUID", "FileCategorizer2.GetFileMetadata",
+	"FileCategorizer2.GetFilesMetadata", "FileCategorizer2.GetFilesMetadataFromMSI", "FileCategorizer2.GetRefPolicies",
+	"FileCategorizer2.GetSerializedCategoryBody", "FileCategorizer2.GetSerializedCategoryBody2",
+	"FileCategorizer2.GetSyncId", "FileCategorizer2.InitFileUpload", "FileCategorizer2.UpdateCategory",
+	"FileCategorizer2.UpdateExpressions",
+	"FilesAcceptor.CancelFileUpload", "FilesAcceptor.InitiateFileUpload",
+	"GatewayConnection.PrepareGatewayConnection", "GatewayConnection.PrepareTunnelConnection",
+	"GroupSync.GetSyncDeliveryTime", "GroupSync.GetSyncHostsInfo", "GroupSync.GetSyncInfo",
+	"GroupSyncIterator.GetNextItems", "GroupSyncIterator.ReleaseIterator",
+	"GroupTaskControlApi.CommitImportedTask", "GroupTaskControlApi.ExportTask", "GroupTaskControlApi.GetTaskByRevision",
+	"GroupTaskControlApi.ImportTask", "GroupTaskControlApi.RequestStatistics",
+	"GroupTaskControlApi.ResetTasksIteratorForCluster", "GroupTaskControlApi.RestoreTaskFromRevision",
+	"HWInvStorage.AddDynColumn", "HWInvStorage.AddHWInvObject", "HWInvStorage.DelDynColumn",
+	"HWInvStorage.DelHWInvObject", "HWInvStorage.DelHWInvObject2", "HWInvStorage.EnumDynColumns",
+	"HWInvStorage.ExportHWInvStorage2", "HWInvStorage.ExportHWInvStorageCancel",
+	"HWInvStorage.ExportHWInvStorageGetData", "HWInvStorage.GetHWInvObject", "HWInvStorage.GetProcessingRules",
+	"HWInvStorage.ImportHWInvStorage2", "HWInvStorage.ImportHWInvStorageCancel",
+	"HWInvStorage.ImportHWInvStorageSetData", "HWInvStorage.SetCorpFlag2", "HWInvStorage.SetHWInvObject",
+	"HWInvStorage.SetProcessingRules", "HWInvStorage.SetWriteOffFlag", "HWInvStorage.SetWriteOffFlag2",
+	"HostGroup.AddDomain", "HostGroup.AddGroup", "HostGroup.AddGroupHostsForSync", "HostGroup.AddHost",
+	"HostGroup.AddHostsForSync", "HostGroup.AddIncident", "HostGroup.DelDomain", "HostGroup.DeleteIncident",
+	"HostGroup.FindGroups", "HostGroup.FindHosts", "HostGroup.FindHostsAsync", "HostGroup.FindHostsAsyncCancel",
+	"HostGroup.FindHostsAsyncGetAccessor", "HostGroup.FindIncidents", "HostGroup.FindUsers",
+	"HostGroup.GetAllHostfixes", "HostGroup.GetComponentsForProductOnHost", "HostGroup.GetDomainHosts",
+	"HostGroup.GetDomains", "HostGroup.GetGroupId", "HostGroup.GetGroupInfo", "HostGroup.GetGroupInfoEx",
+	"HostGroup.GetHostInfo", "HostGroup.GetHostProducts", "HostGroup.GetHostTasks",
+	"HostGroup.GetHostfixesForProductOnHost", "HostGroup.GetInstanceStatistics", "HostGroup.GetRunTimeInfo",
+	"HostGroup.GetStaticInfo", "HostGroup.GetSubgroups", "HostGroup.GroupIdGroups", "HostGroup.GroupIdSuper",
+	"HostGroup.GroupIdUnassigned", "HostGroup.MoveHostsFromGroupToGroup", "HostGroup.MoveHostsToGroup",
+	"HostGroup.RemoveGroup", "HostGroup.RemoveHost", "HostGroup.RemoveHosts", "HostGroup.ResolveAndMoveToGroup",
+	"HostGroup.RestartNetworkScanning", "HostGroup.SS_CreateSection", "HostGroup.SS_DeleteSection",
+	"HostGroup.SS_GetNames", "HostGroup.SS_Read", "HostGroup.SS_Write", "HostGroup.SetLocInfo", "HostGroup.UpdateGroup",
+	"HostGroup.UpdateHost", "HostGroup.UpdateHostsMultiple", "HostGroup.UpdateIncident",
+	"HostGroup.ZeroVirusCountForGroup", "HostGroup.ZeroVirusCountForHosts",
+	"HostMoveRules.AddRule", "HostMoveRules.DeleteRule", "HostMoveRules.ExecuteRulesNow", "HostMoveRules.GetRule",
+	"HostMoveRules.GetRules", "HostMoveRules.SetRulesOrder", "HostMoveRules.UpdateRule",
+	"HostTagsApi.GetHostTags",
+	"HostTagsRulesApi.CancelAsyncAction", "HostTagsRulesApi.DeleteRule", "HostTagsRulesApi.ExecuteRule",
+	"HostTagsRulesApi.GetRule", "HostTagsRulesApi.GetRules", "HostTagsRulesApi.UpdateRule",
+	"HostTasks.GetNextTask", "HostTasks.ResetTasksIterator",
+	"HostsKeyIterator.GetNextItemsChunk", "HostsKeyIterator.ReleaseIterator",
+	"HstAccessControl.AccessCheckToAdmGroup", "HstAccessControl.AddRole", "HstAccessControl.DeleteRole",
+	"HstAccessControl.DeleteScObjectAcl", "HstAccessControl.DeleteScVServerAcl", "HstAccessControl.FindRoles",
+	"HstAccessControl.FindTrustees", "HstAccessControl.GetAccessibleFuncAreas",
+	"HstAccessControl.GetMappingFuncAreaToPolicies", "HstAccessControl.GetMappingFuncAreaToReports",
+	"HstAccessControl.GetMappingFuncAreaToSettings", "HstAccessControl.GetMappingFuncAreaToTasks",
+	"HstAccessControl.GetPolicyReadonlyNodes", "HstAccessControl.GetRole", "HstAccessControl.GetScObjectAcl",
+	"HstAccessControl.GetScVServerAcl", "HstAccessControl.GetSettingsReadonlyNodes", "HstAccessControl.GetTrustee",
+	"HstAccessControl.GetVisualViewForAccessRights", "HstAccessControl.IsTaskTypeReadonly",
+	"HstAccessControl.ModifyScObjectAcl", "HstAccessControl.SetScObjectAcl", "HstAccessControl.SetScVServerAcl",
+	"HstAccessControl.UpdateRole",
+	"IWebSrvSettings.GetCertificateInfo", "IWebSrvSettings.GetCustomPkgHttpFqdn",
+	"IWebSrvSettings.SetCustomCertificate", "IWebSrvSettings.SetCustomPkgHttpFqdn",
+	"IWebUsersSrv.SendEmail",
+	"IWebUsersSrv2.SendEmailAsync",
+	"InvLicenseProducts.AddLicenseKey", "InvLicenseProducts.AddLicenseProduct", "InvLicenseProducts.DeleteLicenseKey",
+	"InvLicenseProducts.DeleteLicenseProduct", "InvLicenseProducts.GetLicenseProducts",
+	"InvLicenseProducts.UpdateLicenseKey", "InvLicenseProducts.UpdateLicenseProduct",
+	"InventoryApi.DeleteUninstalledApps", "InventoryApi.GetHostInvPatches", "InventoryApi.GetHostInvProducts",
+	"InventoryApi.GetInvPatchesList", "InventoryApi.GetInvProductsList", "InventoryApi.GetObservedApps",
+	"InventoryApi.GetSrvCompetitorIniFileInfoList", "InventoryApi.SetObservedApps",
+	"KLEVerControl.CancelDownloadDistributive", "KLEVerControl.ChangeCreatePackage",
+	"KLEVerControl.DownloadDistributiveAsync", "KLEVerControl.GetDownloadDistributiveResult",
+	"KeyService.DecryptData", "KeyService.EncryptData", "KeyService.EncryptDataForHost",
+	"KeyService.GenerateTransportCertificate",
+	"KeyService2.ExportDpeKeys", "KeyService2.ImportDpeKeys",
+	"KillChain.GetByIDs",
+	"KsnInternal.CheckKsnConnection", "KsnInternal.GetNKsnEula", "KsnInternal.GetNKsnEulas", "KsnInternal.GetSettings",
+	"KsnInternal.NeedToSendStatistics",
+	"LicenseInfoSync.AcquireKeysForProductOnHost", "LicenseInfoSync.GetKeyDataForHost",
+	"LicenseInfoSync.IsLicForSaasValid2", "LicenseInfoSync.IsPCloudKey", "LicenseInfoSync.SynchronizeLicInfo2",
+	"LicenseInfoSync.TryToInstallLicForSaas2", "LicenseInfoSync.TryToUnistallLicense",
+	"LicenseKeys.AcquireKeyHosts", "LicenseKeys.AdjustKey", "LicenseKeys.CheckIfSaasLicenseIsValid",
+	"LicenseKeys.DownloadKeyFiles", "LicenseKeys.EnumKeys", "LicenseKeys.GetKeyData", "LicenseKeys.InstallKey",
+	"LicenseKeys.SaasTryToInstall", "LicenseKeys.SaasTryToUninstall", "LicenseKeys.UninstallKey",
+	"LicensePolicy.GetFreeLicenseCount", "LicensePolicy.GetTotalLicenseCount", "LicensePolicy.IsLimitedMode",
+	"LicensePolicy.SetLimitedModeTest", "LicensePolicy.SetTotalLicenseCountTest",
+	"LicensePolicy.SetUsedLicenseCountTest",
+	"Limits.GetLimits",
+	"ListTags.AddTag", "ListTags.DeleteTags2", "ListTags.GetAllTags", "ListTags.GetTags", "ListTags.RenameTag",
+	"ListTags.SetTags",
+	"MigrationData.AcquireKnownProducts", "MigrationData.CancelExport", "MigrationData.Export", "MigrationData.Import",
+	"MigrationData.InitFileUpload",
+	"Multitenancy.CheckAuthToken", "Multitenancy.GetAuthToken", "Multitenancy.GetProducts", "Multitenancy.GetTenantId",
+	"NagCgwHelper.GetProductComponentLocation",
+	"NagGuiCalls.CallConnectorAsync",
+	"NagHstCtl.GetHostRuntimeInfo", "NagHstCtl.SendProductAction", "NagHstCtl.SendTaskAction",
+	"NagNetworkListApi.GetListItemFileChunk", "NagNetworkListApi.GetListItemFileInfo",
+	"NagRdu.ChangeTraceParams", "NagRdu.ChangeTraceRotatedParams", "NagRdu.ChangeXperfBaseParams",
+	"NagRdu.ChangeXperfRotatedParams", "NagRdu.CreateAndDownloadDumpAsync", "NagRdu.DeleteFile", "NagRdu.DeleteFiles",
+	"NagRdu.DownloadCommonDataAsync", "NagRdu.DownloadEventlogAsync", "NagRdu.ExecuteFileAsync",
+	"NagRdu.ExecuteGsiAsync", "NagRdu.GetCurrentHostState", "NagRdu.GetUrlToDownloadFileFromHost",
+	"NagRdu.GetUrlToUploadFileToHost", "NagRdu.RunKlnagchkAsync", "NagRdu.SetProductStateAsync",
+	"NagRemoteScreen.CloseSession", "NagRemoteScreen.GetDataForTunnel", "NagRemoteScreen.GetExistingSessions",
+	"NagRemoteScreen.GetWdsData", "NagRemoteScreen.OpenSession",
+	"NlaDefinedNetworks.AddNetwork", "NlaDefinedNetworks.DeleteNetwork", "NlaDefinedNetworks.GetNetworkInfo",
+	"NlaDefinedNetworks.GetNetworksList", "NlaDefinedNetworks.SetNetworkInfo",
+	"OsVersion.GetAttributesByOs", "OsVersion.GetOsByAttributes",
+	"PLCDevApi.DeletePLC", "PLCDevApi.GetPLC", "PLCDevApi.UpdatePLC",
+	"PackagesApi.AcceptEulas", "PackagesApi.AddExtendedSign", "PackagesApi.AddExtendedSignAsync",
+	"PackagesApi.AllowSharedPrerequisitesInstallation", "PackagesApi.CancelCreateExecutablePkg",
+	"PackagesApi.CancelGetExecutablePkgFile", "PackagesApi.CancelRecordNewPackage",
+	"PackagesApi.CancelUpdateBasesInPackages", "PackagesApi.CreateExecutablePkgAsync",
+	"PackagesApi.DeleteExecutablePkg", "PackagesApi.GetEulaText", "PackagesApi.GetExecutablePackages",
+	"PackagesApi.GetExecutablePkgFileAsync", "PackagesApi.GetIncompatibleAppsInfo",
+	"PackagesApi.GetIntranetFolderForNewPackage", "PackagesApi.GetIntranetFolderForPackage",
+	"PackagesApi.GetKpdProfileString", "PackagesApi.GetLicenseKey", "PackagesApi.GetLoginScript",
+	"PackagesApi.GetMoveRuleInfo", "PackagesApi.GetPackageInfo", "PackagesApi.GetPackageInfo2",
+	"PackagesApi.GetPackageInfoFromArchive", "PackagesApi.GetPackagePlugin", "PackagesApi.GetPackages",
+	"PackagesApi.GetPackages2", "PackagesApi.GetRebootOptionsEx", "PackagesApi.GetUserAgreements",
+	"PackagesApi.IsPackagePublished", "PackagesApi.PrePublishMobilePackage", "PackagesApi.PublishMobileManifest",
+	"PackagesApi.PublishMobilePackage", "PackagesApi.PublishStandalonePackage", "PackagesApi.ReadKpdFile",
+	"PackagesApi.ReadPkgCfgFile", "PackagesApi.RecordNewPackage", "PackagesApi.RecordNewPackage2",
+	"PackagesApi.RecordNewPackage3", "PackagesApi.RecordNewPackage3Async", "PackagesApi.RecordNewPackageAsync",
+	"PackagesApi.RecordVapmPackageAsync", "PackagesApi.RemovePackage", "PackagesApi.RemovePackage2",
+	"PackagesApi.RenamePackage", "PackagesApi.ResetDefaultServerSpecificSettings", "PackagesApi.ResolvePackageLcid",
+	"PackagesApi.RetranslateToVServerAsync", "PackagesApi.SS_GetNames", "PackagesApi.SS_Read",
+	"PackagesApi.SS_SectionOperation", "PackagesApi.SS_Write", "PackagesApi.SetLicenseKey",
+	"PackagesApi.SetRemoveIncompatibleApps", "PackagesApi.UnpublishMobilePackage",
+	"PackagesApi.UpdateBasesInPackagesAsync", "PackagesApi.WriteKpdProfileString", "PackagesApi.WritePkgCfgFile",
+	"PatchParameters.GetTemplate", "PatchParameters.GetValues", "PatchParameters.GetValuesByPkg",
+	"PatchParameters.SetValues", "PatchParameters.SetValuesByPkg",
+	"Policy.AddPolicy", "Policy.CopyOrMovePolicy", "Policy.DeletePolicy", "Policy.ExportPolicy",
+	"Policy.GetEffectivePoliciesForGroup", "Policy.GetOutbreakPolicies", "Policy.GetPoliciesForGroup",
+	"Policy.GetPolicyContents", "Policy.GetPolicyData", "Policy.ImportPolicy", "Policy.MakePolicyActive",
+	"Policy.MakePolicyRoaming", "Policy.RevertPolicyToRevision", "Policy.SetOutbreakPolicies",
+	"Policy.UpdatePolicyData",
+	"PolicyProfiles.AddProfile", "PolicyProfiles.DeleteProfile", "PolicyProfiles.EnumProfiles",
+	"PolicyProfiles.ExportProfile", "PolicyProfiles.GetEffectivePolicyContents", "PolicyProfiles.GetPriorities",
+	"PolicyProfiles.GetProfile", "PolicyProfiles.GetProfileSettings", "PolicyProfiles.ImportProfile",
+	"PolicyProfiles.PutPriorities", "PolicyProfiles.RenameProfile", "PolicyProfiles.UpdateProfile",
+	"QBTNetworkListApi.AddListItemTask", "QBTNetworkListApi.AddListItemsTask", "QBTNetworkListApi.GetListItemInfo",
+	"QueriesStorage.AddQuery", "QueriesStorage.DeleteQuery", "QueriesStorage.GetQueries", "QueriesStorage.GetQuery",
+	"QueriesStorage.GetQueryIds", "QueriesStorage.UpdateQuery",
+	"ReportManager.AddReport", "ReportManager.CancelStatisticsRequest", "ReportManager.CreateChartPNG",
+	"ReportManager.EnumReportTypes", "ReportManager.EnumReports", "ReportManager.ExecuteReportAsync",
+	"ReportManager.ExecuteReportAsyncCancel", "ReportManager.ExecuteReportAsyncCancelWaitingForSlaves",
+	"ReportManager.ExecuteReportAsyncGetData", "ReportManager.GetAvailableDashboards",
+	"ReportManager.GetConstantOutputForReportType", "ReportManager.GetDefaultReportInfo",
+	"ReportManager.GetFilterSettings", "ReportManager.GetReportCommonData", "ReportManager.GetReportIds",
+	"ReportManager.GetReportInfo", "ReportManager.GetReportTypeDetailedInfo", "ReportManager.GetStatisticsData",
+	"ReportManager.RemoveReport", "ReportManager.RequestStatisticsData", "ReportManager.ResetStatisticsData",
+	"ReportManager.UpdateReport",
+	"RetrFiles.GetInfo",
+	"ScanDiapasons.AddDiapason", "ScanDiapasons.GetDiapason", "ScanDiapasons.GetDiapasons",
+	"ScanDiapasons.NotifyDpnsTask", "ScanDiapasons.RemoveDiapason", "ScanDiapasons.UpdateDiapason",
+	"SecurityPolicy.AddUser", "SecurityPolicy.GetCurrentUserId", "SecurityPolicy.GetCurrentUserId2",
+	"SecurityPolicy.GetUsers", "SecurityPolicy.LoadPerUserData", "SecurityPolicy.SavePerUserData",
+	"SecurityPolicy.UpdateTrustee", "SecurityPolicy.UpdateUser",
+	"SecurityPolicy3.AddSecurityGroup", "SecurityPolicy3.AddUserIntoSecurityGroup",
+	"SecurityPolicy3.CloseUserConnections", "SecurityPolicy3.DeleteSecurityGroup",
+	"SecurityPolicy3.DeleteUserFromSecurityGroup", "SecurityPolicy3.MoveUserIntoOtherSecurityGroup",
+	"SecurityPolicy3.UpdateSecurityGroup",
+	"ServerHierarchy.DelServer", "ServerHierarchy.FindSlaveServers", "ServerHierarchy.GetChildServers",
+	"ServerHierarchy.GetServerInfo",
+	"ServerTransportSettings.CheckDefaultCertificateExists", "ServerTransportSettings.GetCurrentConnectionSettings",
+	"ServerTransportSettings.GetCustomSrvCertificateInfo", "ServerTransportSettings.GetDefaultConnectionSettings",
+	"ServerTransportSettings.GetNumberOfManagedDevicesAgentless",
+	"ServerTransportSettings.GetNumberOfManagedDevicesKSM", "ServerTransportSettings.IsFeatureActive",
+	"ServerTransportSettings.ResetCstmReserveCertificate", "ServerTransportSettings.ResetDefaultReserveCertificate",
+	"ServerTransportSettings.SetCustomSrvCertificate", "ServerTransportSettings.SetFeatureActive",
+	"ServerTransportSettings.SetOrCreateDefaultCertificate",
+	"Session.CreateBlob", "Session.CreateToken", "Session.EndSession", "Session.Ping", "Session.StartSession",
+	"SmsQueue.Cancel", "SmsQueue.Clear", "SmsQueue.Enqueue",
+	"SmsSenders.AllowSenders", "SmsSenders.HasAllowedSenders",
+	"SrvCloud.GetCloudHostInfo", "SrvCloud.GetCloudsInfo",
+	"SrvSsRevision.SsRevision_Close", "SrvSsRevision.SsRevision_Open",
+	"SrvView.GetRecordCount", "SrvView.GetRecordRange", "SrvView.ReleaseIterator", "SrvView.ResetIterator",
+	"SsContents.SS_GetNames", "SsContents.Ss_Add", "SsContents.Ss_Apply", "SsContents.Ss_Clear",
+	"SsContents.Ss_CreateSection", "SsContents.Ss_Delete", "SsContents.Ss_DeleteSection", "SsContents.Ss_Read",
+	"SsContents.Ss_Release", "SsContents.Ss_Replace", "SsContents.Ss_Update",
+	"SubnetMasks.CreateSubnet", "SubnetMasks.DeleteSubnet", "SubnetMasks.ModifySubnet",
+	"Tasks.AddTask", "Tasks.CancelTask", "Tasks.DeleteTask", "Tasks.GetAllTasksOfHost",
+	"Tasks.GetHostStatusRecordRange", "Tasks.GetHostStatusRecordsCount", "Tasks.GetNextHostStatus", "Tasks.GetNextTask",
+	"Tasks.GetTask", "Tasks.GetTaskData", "Tasks.GetTaskGroup", "Tasks.GetTaskHistory", "Tasks.GetTaskStartEvent",
+	"Tasks.GetTaskStatistics", "Tasks.ProtectPassword", "Tasks.ReleaseHostStatusIterator", "Tasks.ReleaseTasksIterator",
+	"Tasks.ResetHostIteratorForTaskStatus", "Tasks.ResetHostIteratorForTaskStatusEx", "Tasks.ResetTasksIterator",
+	"Tasks.ResolveTaskId", "Tasks.ResumeTask", "Tasks.RunTask", "Tasks.SuspendTask",
+	"TrafficManager.AddRestriction", "TrafficManager.DeleteRestriction", "TrafficManager.GetRestrictions",
+	"TrafficManager.UpdateRestriction",
+	"UaControl.GetAssignUasAutomatically", "UaControl.GetDefaultUpdateAgentRegistrationInfo",
+	"UaControl.GetUpdateAgentInfo", "UaControl.GetUpdateAgentsDisplayInfoForHost", "UaControl.GetUpdateAgentsList",
+	"UaControl.ModifyUpdateAgent", "UaControl.RegisterDmzGateway", "UaControl.RegisterUpdateAgent",
+	"UaControl.UnregisterUpdateAgent",
+	"UpdComps.AsyncUpdate", "UpdComps.Stop", "UpdComps.UpdateAsync",
+	"Updates.GetAvailableUpdatesInfo", "Updates.GetUpdatesInfo", "Updates.RemoveUpdates", "Updates.RemoveUpdatesCancel",
+	"UserDevicesApi.DeleteCommand", "UserDevicesApi.DeleteDevice", "UserDevicesApi.DeleteEnrollmentPackage",
+	"UserDevicesApi.GenerateQRCode", "UserDevicesApi.GetCommands", "UserDevicesApi.GetCommandsLibrary",
+	"UserDevicesApi.GetDecipheredCommandList", "UserDevicesApi.GetDevice", "UserDevicesApi.GetDevices",
+	"UserDevicesApi.GetDevicesExtraData", "UserDevicesApi.GetEnrollmentPackage",
+	"UserDevicesApi.GetEnrollmentPackageFileData", "UserDevicesApi.GetEnrollmentPackageFileInfo",
+	"UserDevicesApi.GetEnrollmentPackages", "UserDevicesApi.GetJournalCommandResult",
+	"UserDevicesApi.GetJournalRecords", "UserDevicesApi.GetJournalRecords2",
+	"UserDevicesApi.GetLatestDeviceActivityDate", "UserDevicesApi.GetMobileAgentSettingStorageData",
+	"UserDevicesApi.GetMultitenancyServerSettings", "UserDevicesApi.GetMultitenancyServersInfo",
+	"UserDevicesApi.GetSafeBrowserAutoinstallFlag", "UserDevicesApi.GetSyncInfo", "UserDevicesApi.GlueDevices",
+	"UserDevicesApi.PostCommand", "UserDevicesApi.RecallCommand", "UserDevicesApi.SetMultitenancyServerSettings",
+	"UserDevicesApi.SetSafeBrowserAutoinstallFlag", "UserDevicesApi.SspLoginAllowed", "UserDevicesApi.UpdateDevice",
+	"VServers.AddVServerInfo", "VServers.DelVServer", "VServers.GetPermissions", "VServers.GetVServerInfo",
+	"VServers.GetVServers", "VServers.MoveVServer", "VServers.RecallCertAndCloseConnections", "VServers.SetPermissions",
+	"VServers.UpdateVServerInfo",
+	"VServers2.GetVServerStatistic",
+	"VapmControlApi.AcceptEulas", "VapmControlApi.CancelDeleteFilesForUpdates", "VapmControlApi.CancelDownloadPatch",
+	"VapmControlApi.ChangeApproval", "VapmControlApi.ChangeVulnerabilityIgnorance", "VapmControlApi.DeclineEulas",
+	"VapmControlApi.DeleteFilesForUpdates", "VapmControlApi.DownloadPatchAsync",
+	"VapmControlApi.GetAttributesSetVersionNum", "VapmControlApi.GetDownloadPatchDataChunk",
+	"VapmControlApi.GetDownloadPatchResult", "VapmControlApi.GetEulaParams",
+	"VapmControlApi.GetEulasIdsForPatchPrerequisites", "VapmControlApi.GetEulasIdsForUpdates",
+	"VapmControlApi.GetEulasIdsForVulnerabilitiesPatches", "VapmControlApi.GetEulasInfo",
+	"VapmControlApi.GetPendingRulesTasks", "VapmControlApi.GetSupportedLcidsForPatchPrerequisites",
+	"VapmControlApi.GetUpdateSupportedLanguagesFilter", "VapmControlApi.InitiateDownload",
+	"VapmControlApi.SetPackagesToFixVulnerability",
+	"WolSender.SendWolSignal",
+}
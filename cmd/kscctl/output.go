@@ -0,0 +1,193 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+)
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+func validFormat(format string) bool {
+	return format == FormatTable || format == FormatJSON || format == FormatCSV
+}
+
+// writer returns writer of rows with columns in the output format. JSON is written as an array of objects on Close.
+func (a *app) writer(columns []string) export.Writer {
+	switch a.format {
+	case FormatJSON:
+		return &jsonWriter{w: a.stdout, columns: columns}
+	case FormatCSV:
+		return export.NewCSVWriter(a.stdout, export.Options{Columns: columns})
+	}
+	return newTableWriter(a.stdout, columns)
+}
+
+// streamWriter returns writer of rows which is flushed by every Close call, e.g. to follow events.
+// JSON is written as JSON Lines.
+func (a *app) streamWriter(columns []string) export.Writer {
+	if a.format == FormatJSON {
+		return export.NewJSONLWriter(a.stdout, export.Options{Columns: columns})
+	}
+	return a.writer(columns)
+}
+
+// print writes rows with columns in the output format.
+func (a *app) print(columns []string, rows []export.Row) error {
+	w := a.writer(columns)
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// printFields writes single record as field/value pairs in table format and as a row otherwise.
+func (a *app) printFields(columns []string, row export.Row) error {
+	if a.format != FormatTable {
+		return a.print(columns, []export.Row{row})
+	}
+	rows := make([]export.Row, len(columns))
+	for i, column := range columns {
+		rows[i] = export.Row{"field": column, "value": row[column]}
+	}
+	return a.print([]string{"field", "value"}, rows)
+}
+
+// tableWriter writes rows aligned into columns with upper-cased header.
+type tableWriter struct {
+	tw      *tabwriter.Writer
+	columns []string
+	header  bool
+	rows    int64
+}
+
+func newTableWriter(w io.Writer, columns []string) *tableWriter {
+	return &tableWriter{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0), columns: columns}
+}
+
+func (w *tableWriter) writeHeader() error {
+	w.header = true
+	names := make([]string, len(w.columns))
+	for i, name := range w.columns {
+		names[i] = strings.ToUpper(name)
+	}
+	_, err := fmt.Fprintln(w.tw, strings.Join(names, "\t"))
+	return err
+}
+
+func (w *tableWriter) Write(row export.Row) error {
+	if !w.header {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	w.rows++
+
+	values := make([]string, len(w.columns))
+	for i, name := range w.columns {
+		values[i] = tableValue(row[name])
+	}
+	_, err := fmt.Fprintln(w.tw, strings.Join(values, "\t"))
+	return err
+}
+
+func (w *tableWriter) Close() error {
+	if !w.header {
+		if err := w.writeHeader(); err != nil {
+			return err
+		}
+	}
+	return w.tw.Flush()
+}
+
+func (w *tableWriter) Stats() export.Stats {
+	return export.Stats{Rows: w.rows, Columns: w.columns}
+}
+
+var tableReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+func tableValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return tableReplacer.Replace(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Local().Format("2006-01-02 15:04:05")
+	case time.Duration:
+		return v.Round(time.Second).String()
+	}
+	return tableReplacer.Replace(fmt.Sprint(value))
+}
+
+// jsonWriter writes rows as indented JSON array of objects with columns.
+type jsonWriter struct {
+	w       io.Writer
+	columns []string
+	rows    []map[string]interface{}
+}
+
+func (w *jsonWriter) Write(row export.Row) error {
+	out := make(map[string]interface{}, len(w.columns))
+	for _, name := range w.columns {
+		out[name] = row[name]
+	}
+	w.rows = append(w.rows, out)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	rows := w.rows
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	data, err := json.MarshalIndent(rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w.w, "%s\n", data)
+	return err
+}
+
+func (w *jsonWriter) Stats() export.Stats {
+	return export.Stats{Rows: int64(len(w.rows)), Columns: w.columns}
+}
@@ -0,0 +1,199 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func policiesCommand() *command {
+	return &command{name: "policies", summary: "list, export and import policies", subcommands: []*command{
+		{name: "list", args: "[group]", summary: "list policies of administration group", run: policiesList},
+		{name: "export", args: "policy-id...", summary: "export policies to files", run: policiesExport},
+		{name: "import", args: "-group path file...", summary: "import policies from files", run: policiesImport},
+	}}
+}
+
+var policyColumns = []string{"id", "name", "product", "version", "group", "active", "inherited"}
+
+func policyRow(p *kaspersky.PListValue) export.Row {
+	return export.Row{
+		"id":        deref(p.KlpolID),
+		"name":      deref(p.KlpolDN),
+		"product":   deref(p.KlpolProduct),
+		"version":   deref(p.KlpolVersion),
+		"group":     deref(p.KlpolGroupName),
+		"active":    deref(p.KlpolActive),
+		"inherited": deref(p.KlpolInherited),
+	}
+}
+
+// deref returns value pointed by p of *string, *int64 or *bool type, nil if p is nil.
+func deref(p interface{}) interface{} {
+	switch v := p.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *int64:
+		if v != nil {
+			return *v
+		}
+	case *bool:
+		if v != nil {
+			return *v
+		}
+	}
+	return nil
+}
+
+func policiesList(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl policies list", "[group]")
+	recursive := fs.Bool("r", false, "list policies of subgroups too")
+	inherited := fs.Bool("inherited", false, "list policies inherited from parent groups too")
+	if err := parse(fs, args, 0, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	group, err := resolveGroup(ctx, client, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	w := a.writer(policyColumns)
+	list := func(id int64) error {
+		policies, err := client.Policy.GetPoliciesForGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, item := range policies.PList {
+			p := item.PListValue
+			if p == nil || !*inherited && p.KlpolInherited != nil && *p.KlpolInherited {
+				continue
+			}
+			if err := w.Write(policyRow(p)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if *recursive {
+		err = client.HostGroup.WalkGroups(ctx, group, func(id int64, _ string) error { return list(id) })
+	} else {
+		err = list(group)
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func policiesExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl policies export", "policy-id...")
+	dir := fs.String("dir", ".", "output `directory`, policies are written as <policy-id>.klp")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	ids := make([]int64, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid policy id %q", arg)
+		}
+		ids[i] = id
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(ids))
+	for _, id := range ids {
+		blob, _, err := client.Policy.ExportPolicyBlob(ctx, id)
+		if err != nil {
+			return fmt.Errorf("policy %d: %w", id, err)
+		}
+
+		file := filepath.Join(*dir, fmt.Sprintf("%d.klp", id))
+		if err := ioutil.WriteFile(file, blob.Binary, 0644); err != nil {
+			return err
+		}
+		rows = append(rows, export.Row{"id": id, "file": file, "size": len(blob.Binary)})
+	}
+	return a.print([]string{"id", "file", "size"}, rows)
+}
+
+func policiesImport(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl policies import", "-group path file...")
+	group := fs.String("group", "", "administration group `path` to import policies into, \"Managed devices\" by default")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := resolveGroup(ctx, client, *group)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, fs.NArg())
+	for _, file := range fs.Args() {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		policy, _, err := client.Policy.ImportPolicyBlob(ctx, kaspersky.PolicyBinaryBlob{LGroup: groupID, PData: blob})
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		rows = append(rows, export.Row{"file": file, "id": policy.Int})
+	}
+	return a.print([]string{"file", "id"}, rows)
+}
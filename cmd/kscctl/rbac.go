@@ -0,0 +1,259 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/rbac"
+	"github.com/pixfid/go-ksc/reconcile"
+)
+
+func rbacCommand() *command {
+	return &command{name: "rbac", summary: "audit trustees and effective access rights", subcommands: []*command{
+		{name: "trustees", args: "", summary: "list accounts and roles", run: rbacTrustees},
+		{name: "audit", args: "", summary: "print effective permissions of trustees on groups", run: rbacAudit},
+		{name: "funcareas", args: "", summary: "list functional areas accessible to the current user", run: rbacFuncAreas},
+		{name: "grant", args: "trustee-id", summary: "allow access rights on a group to a trustee or role", run: rbacGrant},
+		{name: "revoke", args: "trustee-id", summary: "remove access rights on a group of a trustee or role", run: rbacRevoke},
+		{name: "sync", args: "roles-file", summary: "create, update and remove custom roles to match a file", run: rbacSync},
+	}}
+}
+
+func rbacTrustees(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl rbac trustees", "")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	trustees, err := rbac.Trustees(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(trustees))
+	for _, t := range trustees {
+		rows = append(rows, export.Row{"id": t.ID, "kind": t.Kind, "name": t.Name, "sid": t.SID, "builtin": t.BuiltIn})
+	}
+	return a.print([]string{"id", "kind", "name", "sid", "builtin"}, rows)
+}
+
+// parseRights returns access mask of comma separated access rights names, e.g. "read,execute".
+func parseRights(names string) (int64, error) {
+	if names == "" {
+		return 0, nil
+	}
+	return kaspersky.ParseAccessRights(splitList(names)...)
+}
+
+func rbacAudit(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl rbac audit", "")
+	group := fs.String("group", "", "audit administration group `path` and its subgroups, \"Managed devices\" by default")
+	vservers := fs.Bool("vservers", false, "audit virtual servers too")
+	rights := fs.String("right", "", "print trustees having all access `rights` only, e.g. execute")
+	object := fs.String("object", "", "print objects matching path `pattern` only, e.g. \"Managed devices/Servers*\"")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	mask, err := parseRights(*rights)
+	if err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := resolveGroup(ctx, client, *group)
+	if err != nil {
+		return err
+	}
+
+	report, err := rbac.Audit(ctx, client, rbac.Options{GroupID: groupID, VServers: *vservers})
+	if err != nil {
+		return err
+	}
+
+	permissions := report.Who(mask, *object)
+	rows := make([]export.Row, 0, len(permissions))
+	for _, p := range permissions {
+		rows = append(rows, export.Row{
+			"type":      p.Object.Type,
+			"object":    p.Object.Path,
+			"trustee":   p.Trustee,
+			"kind":      p.TrusteeKind,
+			"area":      p.FuncArea,
+			"effective": strings.Join(kaspersky.AccessRightNames(p.Effective), ","),
+			"deny":      strings.Join(kaspersky.AccessRightNames(p.Deny), ","),
+			"inherited": p.Inherited,
+			"source":    p.Source,
+			"roles":     strings.Join(p.Roles, ","),
+		})
+	}
+	return a.print([]string{"type", "object", "trustee", "kind", "area", "effective", "deny", "inherited", "source", "roles"}, rows)
+}
+
+func rbacFuncAreas(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl rbac funcareas", "")
+	group := fs.String("group", "", "administration group `path`, \"Managed devices\" by default")
+	rights := fs.String("right", "read,write,execute", "access `rights` to list functional areas for")
+	product := fs.String("product", kaspersky.ProductAdmServer, "`product` of functional areas")
+	version := fs.String("version", kaspersky.ProductVersionAdmServer, "`version` of the product")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	mask, err := parseRights(*rights)
+	if err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := resolveGroup(ctx, client, *group)
+	if err != nil {
+		return err
+	}
+
+	var rows []export.Row
+	for _, right := range kaspersky.AccessRightNames(mask) {
+		bit, _ := kaspersky.ParseAccessRights(right)
+		areas, _, err := client.HstAccessControl.GetAccessibleFuncAreas(ctx, groupID, bit, *product, *version, false)
+		if err != nil {
+			return err
+		}
+		for _, area := range areas.PFuncAreasArray {
+			rows = append(rows, export.Row{"right": right, "area": area})
+		}
+	}
+	return a.print([]string{"right", "area"}, rows)
+}
+
+func rbacGrant(ctx context.Context, a *app, args []string) error {
+	return rbacChange(ctx, a, "grant", args)
+}
+
+func rbacRevoke(ctx context.Context, a *app, args []string) error {
+	return rbacChange(ctx, a, "revoke", args)
+}
+
+// rbacChange grants or revokes access rights of a trustee on administration group.
+func rbacChange(ctx context.Context, a *app, action string, args []string) error {
+	fs := a.flagSet("kscctl rbac "+action, "-right rights trustee-id")
+	group := fs.String("group", "", "administration group `path`, \"Managed devices\" by default")
+	rights := fs.String("right", "", "access `rights`, e.g. read,execute")
+	area := fs.String("area", "", "functional `area` \"product|version|area\", the whole group by default")
+	deny := fs.Bool("deny", false, "deny the rights instead of allowing them")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	trustee, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid trustee id %q", fs.Arg(0))
+	}
+
+	mask, err := parseRights(*rights)
+	if err != nil {
+		return err
+	}
+	if mask == 0 {
+		return fmt.Errorf("-right is required")
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := resolveGroup(ctx, client, *group)
+	if err != nil {
+		return err
+	}
+
+	return client.HstAccessControl.UpdateScObjectAcl(ctx, groupID, kaspersky.ScObjectTypeAdmGroup, func(acl *kaspersky.ACL) error {
+		switch {
+		case action == "revoke":
+			acl.Revoke(kaspersky.TrusteeByID(trustee), *area, mask)
+		case *deny:
+			acl.Deny(kaspersky.TrusteeByID(trustee), *area, mask)
+		default:
+			acl.Grant(kaspersky.TrusteeByID(trustee), *area, mask)
+		}
+		return acl.Err()
+	})
+}
+
+func rbacSync(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl rbac sync", "roles-file")
+	apply := fs.Bool("apply", false, "apply the changes, otherwise only print them")
+	prune := fs.Bool("prune", false, "remove custom roles missing in the file")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	doc, err := reconcile.LoadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Only roles are synchronized here, other sections of the document are left to their owners.
+	doc = &reconcile.Document{Roles: doc.Roles, Prune: reconcile.Prune{Roles: doc.Prune.Roles || *prune}}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	engine := reconcile.New(client)
+	plan, err := engine.Plan(ctx, doc)
+	if err != nil {
+		return err
+	}
+	if err := plan.WriteText(a.stdout); err != nil {
+		return err
+	}
+	if !*apply || plan.Empty() {
+		return nil
+	}
+
+	applied, err := engine.Apply(ctx, plan)
+	fmt.Fprintf(a.stderr, "applied %d of %d change(s)\n", len(applied), len(plan.Changes))
+	return err
+}
@@ -0,0 +1,242 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func reportsCommand() *command {
+	return &command{name: "reports", summary: "list, run and download reports", subcommands: []*command{
+		{name: "list", args: "", summary: "list reports", run: reportsList},
+		{name: "run", args: "report-id", summary: "start report generation, download it if -out is set", run: reportsRun},
+		{name: "download", args: "request-id", summary: "download generated report", run: reportsDownload},
+	}}
+}
+
+// reportFormats maps report format to KLRPT_TARGET_TYPE and KLRPT_XML_TARGET_TYPE values.
+var reportFormats = map[string][2]int64{
+	"xml":  {0, -1},
+	"html": {0, 0},
+	"xls":  {0, 1},
+	"pdf":  {0, 2},
+	"csv":  {1, -1},
+	"json": {2, -1},
+}
+
+func reportFormatNames() string {
+	names := make([]string, 0, len(reportFormats))
+	for name := range reportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// reportChunkSize size of report data chunk acquired by one ReportManager.ExecuteReportAsyncGetData call
+const reportChunkSize = 1 << 20
+
+func reportsList(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl reports list", "")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	raw, err := client.ReportManager.EnumReports(ctx)
+	if err != nil {
+		return err
+	}
+
+	reports := new(struct {
+		Reports []kaspersky.Params `json:"PxgRetVal"`
+	})
+	if err := json.Unmarshal(raw, reports); err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(reports.Reports))
+	for _, report := range reports.Reports {
+		rows = append(rows, export.Row{
+			"id":   report.Int("RPT_ID"),
+			"name": report.String("RPT_DN"),
+			"type": report.Int("RPT_TYPE"),
+		})
+	}
+	return a.print([]string{"id", "name", "type"}, rows)
+}
+
+func reportsRun(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl reports run", "report-id")
+	format := fs.String("format", "html", "report `format`: "+reportFormatNames())
+	out := fs.String("out", "", "wait for the report and write it to `file`, - for stdout")
+	poll := fs.Duration("poll", 2*time.Second, "report readiness polling `interval`")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid report id %q", fs.Arg(0))
+	}
+
+	target, ok := reportFormats[*format]
+	if !ok {
+		return fmt.Errorf("unknown report format %q, expected one of %s", *format, reportFormatNames())
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := kaspersky.Params{
+		"lReportId": id,
+		"pOptions": kaspersky.Params{
+			"KLRPT_USE_UTC": true,
+			"KLRPT_OUTPUT_FORMAT": kaspersky.Params{
+				"KLRPT_TARGET_TYPE":     target[0],
+				"KLRPT_XML_TARGET_TYPE": target[1],
+			},
+		},
+	}
+
+	request := new(kaspersky.RequestID)
+	if _, err := client.PostInOut(ctx, "/api/v1.0/ReportManager.ExecuteReportAsync", params, request); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		fmt.Fprintln(a.stdout, request.StrRequestID)
+		return nil
+	}
+	return a.downloadReport(ctx, client, request.StrRequestID, *out, *poll)
+}
+
+func reportsDownload(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl reports download", "request-id")
+	out := fs.String("out", "-", "output `file`, - for stdout")
+	poll := fs.Duration("poll", 2*time.Second, "report readiness polling `interval`")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+	return a.downloadReport(ctx, client, fs.Arg(0), *out, *poll)
+}
+
+// downloadReport waits for report of ReportManager.ExecuteReportAsync request and writes it to file.
+// The report is ready once ReportManager.ExecuteReportAsyncGetData returns nDataSizeRest,
+// the file is created only then. If the context is done the report generation is canceled.
+func (a *app) downloadReport(ctx context.Context, client *kaspersky.Client, strRequestId, file string, poll time.Duration) (err error) {
+	var (
+		w       io.Writer
+		written int64
+	)
+	for {
+		data, raw, gerr := client.ReportManager.ExecuteReportAsyncGetData(ctx, strRequestId, reportChunkSize)
+		if gerr != nil {
+			if ctx.Err() != nil {
+				_, _ = client.ReportManager.ExecuteReportAsyncCancel(context.Background(), strRequestId)
+			}
+			return gerr
+		}
+
+		if w == nil {
+			if data.PXMLData == "" && !reportDataReady(raw) {
+				if err := sleep(ctx, poll); err != nil {
+					_, _ = client.ReportManager.ExecuteReportAsyncCancel(context.Background(), strRequestId)
+					return err
+				}
+				continue
+			}
+
+			w = a.stdout
+			if file != "-" {
+				f, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer func() {
+					if cerr := f.Close(); err == nil {
+						err = cerr
+					}
+					if err == nil {
+						fmt.Fprintf(a.stderr, "report written to %s (%d bytes)\n", file, written)
+					}
+				}()
+				w = f
+			}
+		}
+
+		n, err := io.WriteString(w, data.PXMLData)
+		written += int64(n)
+		if err != nil {
+			return err
+		}
+		if data.NDataSizeREST <= 0 {
+			return nil
+		}
+	}
+}
+
+// reportDataReady reports whether ReportManager.ExecuteReportAsyncGetData response has nDataSizeRest,
+// which is returned only for a generated report, possibly empty.
+func reportDataReady(raw []byte) bool {
+	rest := new(struct {
+		NDataSizeREST *int64 `json:"nDataSizeRest"`
+	})
+	return json.Unmarshal(raw, rest) == nil && rest.NDataSizeREST != nil
+}
+
+// sleep waits for duration d or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
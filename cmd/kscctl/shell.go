@@ -0,0 +1,787 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func shellCommand() *command {
+	return &command{
+		name:        "shell",
+		summary:     "interactive shell keeping one connection",
+		run:         runShell,
+		interactive: true,
+	}
+}
+
+// shellAliases maps shell command names to kscctl commands
+var shellAliases = map[string]string{
+	"task":    "tasks",
+	"group":   "groups",
+	"policy":  "policies",
+	"report":  "reports",
+	"event":   "events",
+	"license": "licenses",
+}
+
+const shellHelp = `Shell commands:
+  hosts find [filter|host...]     find hosts, the result-set stays open for paging
+  view <srvview> [filter]         query srvview, the result-set stays open for paging
+  next, prev, page                show the next, previous or current page of the result-set
+  close                           release the result-set
+  host <host> [info|products]     show host attributes or installed products
+  output table|json|csv           set output format
+  pagesize <n>                    set number of rows per page
+  refresh                         reload group paths used by completion
+  history                         print command history
+  help                            print this help
+  exit, quit                      leave the shell
+
+Other commands are run as kscctl commands, e.g. "tasks run 1234", "task status 1234",
+"call HostGroup.GetDomains". Use Tab to complete commands, method names and group paths.
+`
+
+// shellViews srvviews available to view command
+var shellViews = map[string]kaspersky.SrvViewRecord{
+	kaspersky.SrvViewHWInventory:     kaspersky.HWInvRecord{},
+	kaspersky.SrvViewApplications:    kaspersky.InvApplicationRecord{},
+	kaspersky.SrvViewVulnerabilities: kaspersky.VulnerabilityRecord{},
+	kaspersky.SrvViewUsers:           kaspersky.UserRecord{},
+	kaspersky.SrvViewEvents:          kaspersky.EventRecord{},
+	kaspersky.SrvViewLicenseKeys:     kaspersky.LicenseKeyRecord{},
+	kaspersky.SrvViewHostTags:        kaspersky.HostTagRecord{},
+}
+
+// resultSet server-side result-set which can be read page by page
+type resultSet interface {
+	Columns() []string
+	Count(ctx context.Context) (int64, error)
+	Rows(ctx context.Context, start, count int64) ([]export.Row, error)
+	Release()
+}
+
+// hostsResultSet hosts found by HostGroup.FindHosts
+type hostsResultSet struct {
+	client   *kaspersky.Client
+	accessor string
+}
+
+func (rs *hostsResultSet) Columns() []string {
+	columns := make([]string, len(hostFields))
+	for i, f := range hostFields {
+		columns[i] = f.column
+	}
+	return columns
+}
+
+func (rs *hostsResultSet) Count(ctx context.Context) (int64, error) {
+	count, _, err := rs.client.ChunkAccessor.GetItemsCount(ctx, rs.accessor)
+	if err != nil {
+		return 0, err
+	}
+	return count.Int, nil
+}
+
+func (rs *hostsResultSet) Rows(ctx context.Context, start, count int64) ([]export.Row, error) {
+	chunk := new(kaspersky.ItemsChunk)
+	_, err := rs.client.ChunkAccessor.GetItemsChunk(ctx, kaspersky.ItemsChunkParams{
+		StrAccessor: rs.accessor,
+		NStart:      start,
+		NCount:      count,
+	}, chunk)
+	if err != nil || chunk.PChunk == nil {
+		return nil, err
+	}
+	return chunkRows(chunk.PChunk.Items, func(row export.Row) export.Row {
+		out := make(export.Row, len(hostFields))
+		for _, f := range hostFields {
+			out[f.column] = row[f.name]
+		}
+		return out
+	})
+}
+
+func (rs *hostsResultSet) Release() {
+	rs.client.ChunkAccessor.Release(context.Background(), rs.accessor)
+}
+
+// srvViewResultSet records found by SrvView.ResetIterator
+type srvViewResultSet struct {
+	client   *kaspersky.Client
+	iterator string
+	columns  []string
+}
+
+func (rs *srvViewResultSet) Columns() []string {
+	return rs.columns
+}
+
+func (rs *srvViewResultSet) Count(ctx context.Context) (int64, error) {
+	count, _, err := rs.client.SrvView.GetRecordCount(ctx, rs.iterator)
+	if err != nil {
+		return 0, err
+	}
+	return count.Int, nil
+}
+
+func (rs *srvViewResultSet) Rows(ctx context.Context, start, count int64) ([]export.Row, error) {
+	records := new(struct {
+		PRecords *kaspersky.ItemsChunkArray `json:"pRecords"`
+	})
+	_, err := rs.client.SrvView.GetRecordRange(ctx, &kaspersky.RecordRangeParams{
+		WstrIteratorID: rs.iterator,
+		NStart:         start,
+		NEnd:           start + count,
+	}, records)
+	if err != nil || records.PRecords == nil {
+		return nil, err
+	}
+	return chunkRows(records.PRecords.Items, nil)
+}
+
+func (rs *srvViewResultSet) Release() {
+	_, _ = rs.client.SrvView.ReleaseIterator(context.Background(), rs.iterator)
+}
+
+func chunkRows(items []kaspersky.ItemsChunkValue, convert func(export.Row) export.Row) ([]export.Row, error) {
+	rows := make([]export.Row, 0, len(items))
+	for _, item := range items {
+		var record kaspersky.Params
+		if err := record.UnmarshalJSON(item.Value); err != nil {
+			return nil, err
+		}
+		row := export.Flatten(record)
+		if convert != nil {
+			row = convert(row)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// shell interactive session
+type shell struct {
+	a        *app
+	client   *kaspersky.Client
+	editor   *lineEditor
+	root     *command
+	history  string
+	pageSize int64
+
+	results resultSet
+	total   int64
+	start   int64
+
+	groups []string
+}
+
+func runShell(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl shell", "")
+	history := fs.String("history", filepath.Join(filepath.Dir(defaultConfigPath()), "history"),
+		"command history `file`, empty to keep history in memory only")
+	pageSize := fs.Int64("page", 20, "`number` of rows per page")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// interrupts cancel the running command only
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		for range signals {
+		}
+	}()
+
+	s := &shell{a: a, client: client, root: root(), history: *history, pageSize: *pageSize}
+	s.editor = newLineEditor(a.stdin, a.stdout, s.complete)
+	s.loadHistory()
+	defer s.closeResults()
+
+	prompt := "ksc> "
+	if u, err := url.Parse(a.profile.Server); err == nil && u.Hostname() != "" {
+		prompt = u.Hostname() + "> "
+	}
+
+	for {
+		line, err := s.editor.ReadLine(prompt)
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.editor.addHistory(line)
+		s.saveHistory(line)
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintf(a.stderr, "%v\n", err)
+			continue
+		}
+
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+		if err := s.exec(ctx, args); err != nil && !errors.Is(err, errUsage) {
+			fmt.Fprintf(a.stderr, "error: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// exec runs shell command args.
+func (s *shell) exec(ctx context.Context, args []string) error {
+	ctx, stop := withInterrupt(ctx)
+	defer stop()
+
+	switch args[0] {
+	case "help", "?":
+		fmt.Fprint(s.a.stdout, shellHelp)
+		return nil
+	case "next":
+		return s.page(ctx, s.start+s.pageSize)
+	case "prev":
+		return s.page(ctx, s.start-s.pageSize)
+	case "page":
+		return s.page(ctx, s.start)
+	case "close":
+		s.closeResults()
+		return nil
+	case "output":
+		if len(args) != 2 || !validFormat(args[1]) {
+			return errors.New("usage: output table|json|csv")
+		}
+		s.a.format = args[1]
+		return nil
+	case "pagesize":
+		n, err := strconv.ParseInt(argOrEmpty(args, 1), 10, 64)
+		if err != nil || n <= 0 {
+			return errors.New("usage: pagesize <n>")
+		}
+		s.pageSize = n
+		return nil
+	case "refresh":
+		s.groups = nil
+		return s.loadGroups(ctx)
+	case "history":
+		for i, line := range s.editor.history {
+			fmt.Fprintf(s.a.stdout, "%5d  %s\n", i+1, line)
+		}
+		return nil
+	case "view":
+		return s.view(ctx, args[1:])
+	case "host":
+		return s.host(ctx, args[1:])
+	}
+
+	if alias, ok := shellAliases[args[0]]; ok {
+		args = append([]string{alias}, args[1:]...)
+	}
+	if len(args) >= 2 && args[0] == "hosts" && args[1] == "find" {
+		return s.findHosts(ctx, args[2:])
+	}
+	if args[0] == "shell" {
+		return errors.New("already in shell")
+	}
+	return dispatch(ctx, s.a, s.root, "kscctl", args)
+}
+
+func argOrEmpty(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// open makes rs the current result-set and prints its first page.
+func (s *shell) open(ctx context.Context, rs resultSet) error {
+	s.closeResults()
+	total, err := rs.Count(ctx)
+	if err != nil {
+		rs.Release()
+		return err
+	}
+	s.results, s.total, s.start = rs, total, 0
+	return s.page(ctx, 0)
+}
+
+func (s *shell) closeResults() {
+	if s.results != nil {
+		s.results.Release()
+		s.results = nil
+	}
+}
+
+// page prints rows of the current result-set starting from start.
+func (s *shell) page(ctx context.Context, start int64) error {
+	if s.results == nil {
+		return errors.New("no open result-set, run \"hosts find\" or \"view\" first")
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= s.total && s.total > 0 {
+		return fmt.Errorf("no more rows, %d rows total", s.total)
+	}
+
+	rows, err := s.results.Rows(ctx, start, s.pageSize)
+	if err != nil {
+		return err
+	}
+	s.start = start
+	if err := s.a.print(s.results.Columns(), rows); err != nil {
+		return err
+	}
+
+	if s.total == 0 {
+		fmt.Fprintln(s.a.stderr, "no rows")
+	} else {
+		fmt.Fprintf(s.a.stderr, "rows %d-%d of %d\n", start+1, start+int64(len(rows)), s.total)
+	}
+	return nil
+}
+
+func (s *shell) findHosts(ctx context.Context, args []string) error {
+	var filter string
+	var names []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "(") {
+			filter = arg
+		} else {
+			names = append(names, arg)
+		}
+	}
+	wstrFilter := hostFilter(filter, names)
+	if wstrFilter == "" {
+		wstrFilter = `(KLHST_WKS_DN="*")`
+	}
+
+	fields := make([]string, len(hostFields))
+	for i, f := range hostFields {
+		fields[i] = f.name
+	}
+
+	accessor, _, err := s.client.HostGroup.FindHosts(ctx, kaspersky.HGParams{
+		WstrFilter:        wstrFilter,
+		VecFieldsToReturn: fields,
+		PParams:           kaspersky.PParams{KlgrpFindFromCurVsOnly: true},
+		LMaxLifeTime:      3600,
+	})
+	if err != nil {
+		return err
+	}
+	return s.open(ctx, &hostsResultSet{client: s.client, accessor: accessor.StrAccessor})
+}
+
+func viewNames() []string {
+	names := make([]string, 0, len(shellViews))
+	for name := range shellViews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *shell) view(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: view <srvview> [filter], srvviews: " + strings.Join(viewNames(), ", "))
+	}
+	record, ok := shellViews[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown srvview %q, known srvviews: %s", args[0], strings.Join(viewNames(), ", "))
+	}
+
+	params, err := kaspersky.SrvViewQuery{Filter: argOrEmpty(args, 1)}.Params(record)
+	if err != nil {
+		return err
+	}
+
+	iterator, _, err := s.client.SrvView.ResetIterator(ctx, params)
+	if err != nil {
+		return err
+	}
+	return s.open(ctx, &srvViewResultSet{client: s.client, iterator: iterator.WstrIteratorID, columns: params.VecFieldsToReturn})
+}
+
+func (s *shell) host(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New("usage: host <host> [info|products]")
+	}
+
+	switch argOrEmpty(args, 1) {
+	case "", "info":
+		return dispatch(ctx, s.a, s.root, "kscctl", []string{"hosts", "info", args[0]})
+	case "products":
+	default:
+		return fmt.Errorf("unknown host command %q, expected info or products", args[1])
+	}
+
+	hosts, err := s.client.HostGroup.FindBulkHosts(ctx, hostFilter("", args[:1]))
+	if err != nil {
+		return err
+	}
+	if len(hosts) != 1 {
+		return fmt.Errorf("%d hosts match %q", len(hosts), args[0])
+	}
+
+	products, _, err := s.client.HostGroup.GetHostProducts(ctx, hosts[0].HostName)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(products))
+	for _, product := range products {
+		rows = append(rows, export.Row{
+			"product": product.Name,
+			"version": product.Version,
+			"name":    product.DisplayName,
+			"build":   product.ProdVersion,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["product"].(string) < rows[j]["product"].(string) })
+	return s.a.print([]string{"product", "version", "name", "build"}, rows)
+}
+
+func (s *shell) loadHistory() {
+	if s.history == "" {
+		return
+	}
+	f, err := os.Open(s.history)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s.editor.addHistory(scanner.Text())
+	}
+}
+
+func (s *shell) saveHistory(line string) {
+	if s.history == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.history), 0700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(s.history, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// loadGroups loads paths of all administration groups for completion.
+func (s *shell) loadGroups(ctx context.Context) error {
+	if s.groups != nil {
+		return nil
+	}
+	group, err := resolveGroup(ctx, s.client, "")
+	if err != nil {
+		return err
+	}
+	groups := []string{}
+	err = s.client.HostGroup.WalkGroups(ctx, group, func(_ int64, path string) error {
+		groups = append(groups, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(groups)
+	s.groups = groups
+	return nil
+}
+
+var shellBuiltins = []string{"help", "next", "prev", "page", "close", "output", "pagesize", "refresh", "history",
+	"view", "host", "exit", "quit"}
+
+// candidates returns completions of the word following words.
+func (s *shell) candidates(words []string, word string) []string {
+	if len(words) == 0 {
+		names := append([]string(nil), shellBuiltins...)
+		for _, cmd := range s.root.subcommands {
+			if cmd.name != "shell" {
+				names = append(names, cmd.name)
+			}
+		}
+		for alias := range shellAliases {
+			names = append(names, alias)
+		}
+		return names
+	}
+
+	first := words[0]
+	if alias, ok := shellAliases[first]; ok {
+		first = alias
+	}
+	switch {
+	case first == "call" && len(words) == 1:
+		return kscMethods
+	case first == "view" && len(words) == 1:
+		return viewNames()
+	case first == "host" && len(words) == 2:
+		return []string{"info", "products"}
+	case first == "output" && len(words) == 1:
+		return []string{FormatTable, FormatJSON, FormatCSV}
+	}
+
+	if cmd := s.root.find(first); cmd != nil && cmd.run == nil && len(words) == 1 {
+		var names []string
+		for _, sub := range cmd.subcommands {
+			names = append(names, sub.name)
+		}
+		return names
+	}
+
+	if groupArgument(first, words, word) {
+		ctx, cancel := context.WithTimeout(context.Background(), groupsLoadTimeout)
+		defer cancel()
+		if err := s.loadGroups(ctx); err == nil {
+			return s.groups
+		}
+	}
+	return nil
+}
+
+// groupsLoadTimeout limits loading of administration groups on completion
+const groupsLoadTimeout = 3 * time.Second
+
+// groupCommands commands taking administration group paths as arguments
+var groupCommands = map[string]bool{"groups tree": true, "groups create": true, "policies list": true}
+
+// groupArgument reports whether the word completed after words is an administration group path:
+// a value of -to or -group flag or an argument of a command taking groups.
+func groupArgument(first string, words []string, word string) bool {
+	prev := words[len(words)-1]
+	if prev == "-to" || prev == "-group" {
+		return true
+	}
+	if len(words) < 2 || !groupCommands[first+" "+words[1]] || strings.HasPrefix(word, "-") {
+		return false
+	}
+	return len(words) == 2 || !strings.HasPrefix(prev, "-")
+}
+
+// complete completes the word before cursor position pos of line.
+func (s *shell) complete(line []rune, pos int) ([]rune, int, []string) {
+	words, word, start := completionWords(string(line[:pos]))
+
+	var matches []string
+	for _, candidate := range s.candidates(words, word) {
+		if len(candidate) >= len(word) && strings.EqualFold(candidate[:len(word)], word) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos, nil
+	}
+	sort.Strings(matches)
+
+	replacement := matches[0]
+	if len(matches) == 1 {
+		replacement = quoteWord(replacement, true) + " "
+	} else {
+		for _, match := range matches[1:] {
+			replacement = commonPrefix(replacement, match)
+		}
+		if len(replacement) < len(word) {
+			replacement = word
+		}
+		replacement = quoteWord(replacement, false)
+	}
+
+	completed := append([]rune(nil), line[:start]...)
+	completed = append(completed, []rune(replacement)...)
+	newPos := len(completed)
+	completed = append(completed, line[pos:]...)
+	return completed, newPos, matches
+}
+
+func commonPrefix(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && equalFoldRune(ra[n], rb[n]) {
+		n++
+	}
+	return string(ra[:n])
+}
+
+// equalFoldRune reports whether runes are equal under simple Unicode case folding.
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteWord quotes word if it contains spaces or quotes, closed is false for partial words.
+func quoteWord(word string, closed bool) string {
+	if !strings.ContainsAny(word, " \t\"'\\") {
+		return word
+	}
+	quoted := `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word)
+	if closed {
+		quoted += `"`
+	}
+	return quoted
+}
+
+// completionWords splits text before cursor into complete words and the partial word being typed,
+// start is rune offset of the partial word in text.
+func completionWords(text string) (words []string, word string, start int) {
+	runes := []rune(text)
+	var current []rune
+	inWord := false
+	var quote rune
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current = append(current, runes[i])
+			} else {
+				current = append(current, r)
+			}
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, string(current))
+				current, inWord = nil, false
+			}
+		default:
+			if !inWord {
+				inWord, start = true, i
+			}
+			if r == '"' || r == '\'' {
+				quote = r
+			} else if r == '\\' && i+1 < len(runes) {
+				i++
+				current = append(current, runes[i])
+			} else {
+				current = append(current, r)
+			}
+		}
+	}
+	if !inWord {
+		start = len(runes)
+	}
+	return words, string(current), start
+}
+
+// splitArgs splits command line into arguments honoring double and single quotes and backslash escapes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				current.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			switch r {
+			case '"', '\'':
+				quote = r
+			case '\\':
+				escaped = true
+			default:
+				current.WriteRune(r)
+			}
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,194 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func tasksCommand() *command {
+	return &command{name: "tasks", summary: "list, run and watch tasks", subcommands: []*command{
+		{name: "list", args: "", summary: "list tasks", run: tasksList},
+		{name: "run", args: "task-id", summary: "start task and optionally wait for completion", run: tasksRun},
+		{name: "status", args: "task-id", summary: "show task statistics", run: tasksStatus},
+	}}
+}
+
+func tasksList(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl tasks list", "")
+	group := fs.String("group", "", "list tasks of administration group `path` only")
+	product := fs.String("product", "", "list tasks of product `name` only, e.g. KES")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	params := kaspersky.TasksIteratorParams{StrProductName: *product}
+	if *group != "" {
+		id, err := client.HostGroup.ResolveGroupPath(ctx, *group)
+		if err != nil {
+			return err
+		}
+		params.NGroupID, params.BGroupIDSignificant = id, true
+	}
+
+	w := a.writer([]string{"id", "name", "type", "product", "group"})
+	err = client.Tasks.ForEachTask(ctx, params, func(task kaspersky.Params) error {
+		info := task.Params("TASK_INFO_PARAMS")
+		row := export.Row{
+			"id":      task.String("TASK_UNIQUE_ID"),
+			"name":    info.String("DisplayName"),
+			"type":    task.String("TASK_NAME"),
+			"product": task.String("TASKID_PRODUCT_NAME"),
+		}
+		if info.Has("PRTS_TASK_GROUPID") {
+			row["group"] = info.Int("PRTS_TASK_GROUPID")
+		}
+		return w.Write(row)
+	})
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+var hostStateColumns = []string{"host", "id", "state", "description"}
+
+func hostStateRow(state kaspersky.HostTaskState) export.Row {
+	return export.Row{
+		"host":        state.DisplayName,
+		"id":          state.HostName,
+		"state":       state.State.String(),
+		"description": state.Description,
+	}
+}
+
+func tasksRun(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl tasks run", "task-id")
+	wait := fs.Bool("wait", false, "wait for task completion printing host state changes")
+	poll := fs.Duration("poll", 5*time.Second, "task state polling `interval`")
+	timeout := fs.Duration("wait-timeout", kaspersky.DefaultTaskWaitTimeout, "stop waiting after `duration`, the task keeps running, no timeout if negative")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	task := fs.Arg(0)
+	if !*wait {
+		if _, err := client.Tasks.RunTask(ctx, task); err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stderr, "task %s started\n", task)
+		return nil
+	}
+
+	updates := make(chan kaspersky.HostTaskStateChange)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for change := range updates {
+			fmt.Fprintf(a.stderr, "%s: %s\n", change.DisplayName, change.State)
+		}
+	}()
+
+	summary, err := client.Tasks.RunTaskAndWait(ctx, task, kaspersky.TaskRunOptions{
+		PollInterval: *poll,
+		Timeout:      *timeout,
+		Updates:      updates,
+	})
+	<-done
+	if summary != nil {
+		rows := make([]export.Row, 0, len(summary.Hosts))
+		for _, host := range summary.Hosts {
+			rows = append(rows, hostStateRow(host))
+		}
+		if perr := a.print(hostStateColumns, rows); err == nil {
+			err = perr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if failed := summary.Failed(); len(failed) != 0 {
+		return fmt.Errorf("task failed on %d of %d hosts", len(failed), len(summary.Hosts))
+	}
+	return nil
+}
+
+func tasksStatus(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl tasks status", "task-id")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	stats, _, err := client.Tasks.GetTaskStatistics(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s := stats.TaskStatistic
+	var rows []export.Row
+	for _, state := range []struct {
+		state kaspersky.TaskState
+		hosts int64
+	}{
+		{kaspersky.TaskStatePending, s.The1},
+		{kaspersky.TaskStateRunning, s.The2},
+		{kaspersky.TaskStateCompleted, s.The4},
+		{kaspersky.TaskStateWarning, s.The8},
+		{kaspersky.TaskStateFailed, s.The16},
+		{kaspersky.TaskStateScheduled, s.The32},
+		{kaspersky.TaskStatePaused, s.The64},
+	} {
+		rows = append(rows, export.Row{"state": state.state.String(), "hosts": state.hosts})
+	}
+	if err := a.print([]string{"state", "hosts"}, rows); err != nil {
+		return err
+	}
+
+	if a.format == FormatTable {
+		fmt.Fprintf(a.stdout, "\ncompleted: %d%%, need reboot: %d\n", s.GnrlCompletedPercent, s.KltskNeedRbtCnt)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/vapm"
+)
+
+func vapmCommand() *command {
+	return &command{name: "vapm", summary: "list vulnerabilities and approve updates by policy", subcommands: []*command{
+		{name: "vulnerabilities", args: "", summary: "list vulnerabilities with host counts", run: vapmVulnerabilities},
+		{name: "updates", args: "", summary: "list software updates", run: vapmUpdates},
+		{name: "apply", args: "policy-file", summary: "approve or decline updates by policy rules", run: vapmApply},
+		{name: "download", args: "patch-global-id", summary: "download 3-party patch, resuming a partial file", run: vapmDownload},
+	}}
+}
+
+func vapmVulnerabilities(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl vapm vulnerabilities", "")
+	filter := fs.String("filter", "", "srvview `filter`, e.g. (KLVULNR_FIX_AVAILABLE = 1)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	list, err := vapm.Vulnerabilities(ctx, client, *filter)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(list))
+	for _, v := range list {
+		rows = append(rows, export.Row{
+			"id":       v.ID,
+			"name":     v.Name,
+			"severity": vapm.SeverityName(v.Severity),
+			"product":  strings.TrimSpace(v.ProductName + " " + v.ProductVersion),
+			"fix":      v.FixAvailable,
+			"hosts":    int64(len(v.Hosts)),
+			"cve":      strings.Join(v.CVE, " "),
+		})
+	}
+	return a.print([]string{"id", "name", "severity", "product", "fix", "hosts", "cve"}, rows)
+}
+
+var approvalStates = []string{"undefined", "approved", "declined"}
+
+func vapmUpdates(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl vapm updates", "")
+	filter := fs.String("filter", "", "srvview `filter`, e.g. (nApprovementState = 0)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	updates, err := vapm.Updates(ctx, client, *filter)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]export.Row, 0, len(updates))
+	for _, u := range updates {
+		state := fmt.Sprint(u.ApprovalState)
+		if u.ApprovalState >= 0 && u.ApprovalState < int64(len(approvalStates)) {
+			state = approvalStates[u.ApprovalState]
+		}
+		rows = append(rows, export.Row{
+			"source":   u.Source,
+			"id":       u.DbID(),
+			"name":     u.DisplayName,
+			"vendor":   u.Vendor,
+			"kb":       u.KB,
+			"severity": vapm.SeverityName(u.Severity),
+			"approval": state,
+		})
+	}
+	return a.print([]string{"source", "id", "name", "vendor", "kb", "severity", "approval"}, rows)
+}
+
+func vapmApply(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl vapm apply", "policy-file")
+	filter := fs.String("filter", "", "srvview `filter` of updates to decide on")
+	yes := fs.Bool("yes", false, "apply decisions, otherwise only record them in the audit log")
+	auditFile := fs.String("audit", "-", "audit log `file` decisions are appended to as JSON Lines, - for stdout")
+	lcid := fs.Int64("lcid", 0, "preferred `LCID` of EULAs")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	policy, err := vapm.LoadPolicy(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = a.stdout
+	if *auditFile != "-" {
+		f, err := os.OpenFile(*auditFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := vapm.Apply(ctx, client, policy, vapm.NewJSONAudit(w), vapm.Options{
+		Filter: *filter,
+		DryRun: !*yes,
+		Lcid:   *lcid,
+	})
+	if !*yes {
+		defer fmt.Fprintln(a.stderr, "dry run, pass -yes to apply changes")
+	}
+	if result != nil {
+		fmt.Fprintf(a.stderr, "approved %d, declined %d, unchanged %d, unmatched %d, EULA required %d, EULAs accepted %d\n",
+			result.Approved, result.Declined, result.Unchanged, result.Unmatched, result.EulaRequired, result.EulasAccepted)
+	}
+	return err
+}
+
+func vapmDownload(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("kscctl vapm download", "patch-global-id")
+	out := fs.String("out", "", "output `file`, downloading resumes if it exists")
+	lcid := fs.Int64("lcid", 1033, "`LCID` of the patch")
+	chunk := fs.Int64("chunk", kaspersky.DefaultPatchChunkSize, "chunk `size` in bytes")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid patch global id %q", fs.Arg(0))
+	}
+	if *out == "" {
+		return errors.New("output file is not set, use -out")
+	}
+
+	client, err := a.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	download, err := client.VapmControlApi.DownloadPatchFile(ctx, id, *lcid, *out, kaspersky.DownloadPatchOptions{ChunkSize: *chunk})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "%s: %d bytes, %d downloaded\n", download.FileName, download.Size, download.Written)
+	return nil
+}
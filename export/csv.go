@@ -0,0 +1,77 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+type csvWriter struct {
+	w       *csv.Writer
+	opts    Options
+	columns *columns
+	record  []string
+}
+
+// NewCSVWriter returns writer of CSV with header line of column names.
+func NewCSVWriter(w io.Writer, opts Options) Writer {
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	return &csvWriter{w: cw, opts: opts, columns: newColumns(opts)}
+}
+
+func (w *csvWriter) Write(row Row) error {
+	if w.record == nil {
+		w.columns.init(row)
+		if err := w.w.Write(w.columns.names); err != nil {
+			return err
+		}
+		w.record = make([]string, len(w.columns.names))
+	}
+	w.columns.track(row)
+
+	for i, name := range w.columns.names {
+		w.record[i] = formatValue(row[name], w.opts)
+	}
+	return w.w.Write(w.record)
+}
+
+func (w *csvWriter) Close() error {
+	if w.record == nil && w.columns.index != nil {
+		// no rows, write header of the given columns
+		if err := w.w.Write(w.columns.names); err != nil {
+			return err
+		}
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) Stats() Stats {
+	return w.columns.stats()
+}
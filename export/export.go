@@ -0,0 +1,274 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package export streams KSC result-sets into tabular files: CSV, JSON Lines and Parquet.
+//
+// Rows are read from a Source, e.g. a SrvView query, a ChunkAccessor result-set or events of EventProcessing,
+// flattened by Flatten and written by a Writer one at a time, so memory use does not depend on the number of rows.
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Source calls fn for every record of a result-set until fn returns an error.
+type Source func(ctx context.Context, fn func(record kaspersky.Params) error) error
+
+// SrvView returns source of records found by params, see kaspersky.SrvView.ForEachRecord.
+func SrvView(sv *kaspersky.SrvView, params *kaspersky.SrvViewParams, nPageSize int64) Source {
+	return func(ctx context.Context, fn func(kaspersky.Params) error) error {
+		return sv.ForEachRecord(ctx, params, nPageSize, func(raw json.RawMessage) error {
+			var record kaspersky.Params
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			return fn(record)
+		})
+	}
+}
+
+// ChunkAccessor returns source of hosts of result-set accessor, see kaspersky.ChunkAccessor.ForEachItem.
+// The result-set is not released.
+func ChunkAccessor(ca *kaspersky.ChunkAccessor, accessor string, nChunkSize int64) Source {
+	return func(ctx context.Context, fn func(kaspersky.Params) error) error {
+		return ca.ForEachItem(ctx, accessor, nChunkSize, func(raw json.RawMessage) error {
+			var record kaspersky.Params
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			return fn(record)
+		})
+	}
+}
+
+// Events returns source of events of result-set strIteratorId, see kaspersky.EventProcessing.ForEachEvent.
+// The result-set is not released.
+func Events(ep *kaspersky.EventProcessing, strIteratorId string, nPageSize int64) Source {
+	return func(ctx context.Context, fn func(kaspersky.Params) error) error {
+		return ep.ForEachEvent(ctx, strIteratorId, nPageSize, fn)
+	}
+}
+
+// Row flattened record. Values are nil, string, bool, json.Number or time.Time.
+type Row map[string]interface{}
+
+// Flatten flattens nested params into row, see kaspersky.Params.Leaves. Datetime values are converted to time.Time,
+// other typed values are replaced with their values.
+func Flatten(record kaspersky.Params) Row {
+	row := Row{}
+	record.Leaves(func(key string, value interface{}) {
+		row[key] = rowValue(value)
+	})
+	return row
+}
+
+func rowValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case kaspersky.Params:
+		return "{}"
+	case []interface{}:
+		return "[]"
+	case kaspersky.TypedValue:
+		if s, ok := v.Value.(string); ok && v.Type == "datetime" {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t
+			}
+		}
+		return rowValue(v.Value)
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case nil, string, bool, json.Number:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// Options of writers
+type Options struct {
+	// Columns columns to write in order. If empty, columns of the first row are written sorted by name.
+	// Columns missing from the column list are dropped and reported by Writer.Stats.
+	// JSON Lines writer writes all columns of every row if Columns is empty.
+	Columns []string
+
+	// DateFormat layout of datetime values, time.RFC3339 by default
+	DateFormat string
+
+	// Location of datetime values, UTC by default
+	Location *time.Location
+
+	// Comma CSV field delimiter, ',' by default
+	Comma rune
+
+	// RowGroupSize number of rows buffered into one Parquet row group, 10000 by default
+	RowGroupSize int
+}
+
+// Stats statistics of written rows
+type Stats struct {
+	Rows    int64    `json:"rows"`
+	Columns []string `json:"columns"`
+
+	// Dropped columns present in rows but not written
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// Writer writes rows into a tabular file
+type Writer interface {
+	// Write writes row
+	Write(row Row) error
+
+	// Close flushes buffered rows and writes file trailer if any, underlying io.Writer is not closed
+	Close() error
+
+	// Stats returns statistics of rows written so far
+	Stats() Stats
+}
+
+// Format of exported file
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// NewWriter returns writer of format
+func NewWriter(format Format, w io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w, opts), nil
+	case FormatJSONL:
+		return NewJSONLWriter(w, opts), nil
+	case FormatParquet:
+		return NewParquetWriter(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Export writes all records of src into w and closes w.
+func Export(ctx context.Context, src Source, w Writer) (Stats, error) {
+	err := src(ctx, func(record kaspersky.Params) error {
+		return w.Write(Flatten(record))
+	})
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return w.Stats(), err
+}
+
+// columns tracks columns of written rows.
+type columns struct {
+	names   []string
+	index   map[string]int
+	dropped map[string]bool
+	rows    int64
+}
+
+func newColumns(opts Options) *columns {
+	c := &columns{dropped: map[string]bool{}}
+	if len(opts.Columns) != 0 {
+		c.set(opts.Columns)
+	}
+	return c
+}
+
+func (c *columns) set(names []string) {
+	c.names = names
+	c.index = make(map[string]int, len(names))
+	for i, name := range names {
+		c.index[name] = i
+	}
+}
+
+// init sets columns from the first row unless they are given by options.
+func (c *columns) init(row Row) {
+	if c.index != nil {
+		return
+	}
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.set(names)
+}
+
+// track counts row and records its columns which are not written.
+func (c *columns) track(row Row) {
+	c.rows++
+	for name := range row {
+		if _, ok := c.index[name]; !ok {
+			c.dropped[name] = true
+		}
+	}
+}
+
+func (c *columns) stats() Stats {
+	stats := Stats{Rows: c.rows, Columns: c.names}
+	for name := range c.dropped {
+		stats.Dropped = append(stats.Dropped, name)
+	}
+	sort.Strings(stats.Dropped)
+	return stats
+}
+
+// formatValue renders row value as text.
+func formatValue(value interface{}, opts Options) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case time.Time:
+		return formatTime(v, opts)
+	}
+	return fmt.Sprint(value)
+}
+
+func formatTime(t time.Time, opts Options) string {
+	layout := opts.DateFormat
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+	return t.In(location).Format(layout)
+}
@@ -0,0 +1,117 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func expectEqual(t *testing.T, expected, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("\n expected: \n %#v \n actual: \n %#v", expected, actual)
+	}
+}
+
+func newServer(t *testing.T) (*kaspersky.Client, func()) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1.0/SrvView.ResetIterator", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"wstrIteratorId": "it"}`))
+	})
+	mux.HandleFunc("/api/v1.0/SrvView.ReleaseIterator", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v1.0/SrvView.GetRecordCount", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"PxgRetVal": 2}`))
+	})
+	mux.HandleFunc("/api/v1.0/SrvView.GetRecordRange", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+			{"type": "params", "value": {"KLHST_WKS_DN": "WKS-1", "KLHST_WKS_LAST_VISIBLE": {"type": "datetime", "value": "2020-05-28T07:22:14Z"},
+				"KLHST_WKS_STATUS": {"type": "params", "value": {"Ok": true, "Ids": [1, 2]}}}},
+			{"type": "params", "value": {"KLHST_WKS_DN": "WKS, 2", "Extra": "dropped"}}]}}`))
+	})
+	srv := httptest.NewServer(mux)
+	return kaspersky.New(kaspersky.Config{Server: srv.URL}), srv.Close
+}
+
+func TestFlatten(t *testing.T) {
+	var record kaspersky.Params
+	if err := json.Unmarshal([]byte(`{"a": {"type": "params", "value": {"b": [1, {"type": "params", "value": {"c": "x"}}], "e": []}},
+		"d": {"type": "datetime", "value": "2020-05-28T07:22:14Z"}, "n": null}`), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, export.Row{
+		"a.b[0]":   json.Number("1"),
+		"a.b[1].c": "x",
+		"a.e":      "[]",
+		"d":        time.Date(2020, 5, 28, 7, 22, 14, 0, time.UTC),
+		"n":        nil,
+	}, export.Flatten(record))
+}
+
+func TestExportCSV(t *testing.T) {
+	client, stop := newServer(t)
+	defer stop()
+
+	var buf bytes.Buffer
+	src := export.SrvView(client.SrvView, &kaspersky.SrvViewParams{WstrViewName: "HostsView"}, 0)
+	stats, err := export.Export(context.Background(), src, export.NewCSVWriter(&buf, export.Options{
+		Columns:    []string{"KLHST_WKS_DN", "KLHST_WKS_LAST_VISIBLE", "KLHST_WKS_STATUS.Ok", "KLHST_WKS_STATUS.Ids[1]"},
+		DateFormat: "2006-01-02",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEqual(t, "KLHST_WKS_DN,KLHST_WKS_LAST_VISIBLE,KLHST_WKS_STATUS.Ok,KLHST_WKS_STATUS.Ids[1]\n"+
+		"WKS-1,2020-05-28,true,2\n"+
+		"\"WKS, 2\",,,\n", buf.String())
+	expectEqual(t, int64(2), stats.Rows)
+	expectEqual(t, []string{"Extra", "KLHST_WKS_STATUS.Ids[0]"}, stats.Dropped)
+}
+
+func TestExportJSONL(t *testing.T) {
+	client, stop := newServer(t)
+	defer stop()
+
+	var buf bytes.Buffer
+	src := export.SrvView(client.SrvView, &kaspersky.SrvViewParams{WstrViewName: "HostsView"}, 0)
+	stats, err := export.Export(context.Background(), src, export.NewJSONLWriter(&buf, export.Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expectEqual(t, 2, len(lines))
+	expectEqual(t, `{"KLHST_WKS_DN":"WKS-1","KLHST_WKS_LAST_VISIBLE":"2020-05-28T07:22:14Z","KLHST_WKS_STATUS.Ids[0]":1,`+
+		`"KLHST_WKS_STATUS.Ids[1]":2,"KLHST_WKS_STATUS.Ok":true}`, lines[0])
+	expectEqual(t, 6, len(stats.Columns))
+}
+
+func TestExportParquet(t *testing.T) {
+	client, stop := newServer(t)
+	defer stop()
+
+	var buf bytes.Buffer
+	src := export.SrvView(client.SrvView, &kaspersky.SrvViewParams{WstrViewName: "HostsView"}, 0)
+	stats, err := export.Export(context.Background(), src, export.NewParquetWriter(&buf, export.Options{RowGroupSize: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	expectEqual(t, "PAR1", string(data[:4]))
+	expectEqual(t, "PAR1", string(data[len(data)-4:]))
+	expectEqual(t, int64(2), stats.Rows)
+	expectEqual(t, true, bytes.Contains(data, []byte("WKS, 2")))
+}
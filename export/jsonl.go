@@ -0,0 +1,90 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+type jsonlWriter struct {
+	w       *bufio.Writer
+	enc     *json.Encoder
+	opts    Options
+	columns *columns
+	all     map[string]bool
+}
+
+// NewJSONLWriter returns writer of JSON Lines, one flattened JSON object per row.
+func NewJSONLWriter(w io.Writer, opts Options) Writer {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	return &jsonlWriter{w: bw, enc: enc, opts: opts, columns: newColumns(opts), all: map[string]bool{}}
+}
+
+func (w *jsonlWriter) Write(row Row) error {
+	out := make(map[string]interface{}, len(row))
+	if w.columns.index != nil {
+		w.columns.track(row)
+		for _, name := range w.columns.names {
+			out[name] = w.value(row[name])
+		}
+	} else {
+		w.columns.rows++
+		for name, value := range row {
+			w.all[name] = true
+			out[name] = w.value(value)
+		}
+	}
+	return w.enc.Encode(out)
+}
+
+func (w *jsonlWriter) value(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return formatTime(t, w.opts)
+	}
+	return value
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.w.Flush()
+}
+
+func (w *jsonlWriter) Stats() Stats {
+	if w.columns.index != nil {
+		return w.columns.stats()
+	}
+
+	stats := Stats{Rows: w.columns.rows, Columns: make([]string, 0, len(w.all))}
+	for name := range w.all {
+		stats.Columns = append(stats.Columns, name)
+	}
+	sort.Strings(stats.Columns)
+	return stats
+}
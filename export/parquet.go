@@ -0,0 +1,378 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// Parquet file layout constants, see https://github.com/apache/parquet-format
+const (
+	parquetMagic = "PAR1"
+
+	parquetTypeByteArray      = 6
+	parquetRepetitionOptional = 1
+	parquetConvertedTypeUTF8  = 0
+	parquetEncodingPlain      = 0
+	parquetEncodingRLE        = 3
+	parquetCodecUncompressed  = 0
+	parquetPageTypeData       = 0
+
+	defaultRowGroupSize = 10000
+)
+
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type parquetRowGroup struct {
+	rows    int64
+	size    int64
+	columns []parquetColumnChunk
+}
+
+type parquetWriter struct {
+	w       *bufio.Writer
+	opts    Options
+	columns *columns
+	offset  int64
+	started bool
+
+	// buffered values of the current row group by column, nil is null
+	values    [][]*string
+	buffered  int
+	rowGroups []parquetRowGroup
+}
+
+// NewParquetWriter returns writer of Parquet file with optional UTF-8 string columns.
+//
+// Values are rendered as text like in CSV. Rows are buffered into row groups of opts.RowGroupSize rows,
+// which bounds memory use. Pages are written with PLAIN encoding and no compression.
+func NewParquetWriter(w io.Writer, opts Options) Writer {
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = defaultRowGroupSize
+	}
+	return &parquetWriter{w: bufio.NewWriter(w), opts: opts, columns: newColumns(opts)}
+}
+
+func (w *parquetWriter) Write(row Row) error {
+	if !w.started {
+		if err := w.start(row); err != nil {
+			return err
+		}
+	}
+	w.columns.track(row)
+
+	for i, name := range w.columns.names {
+		var value *string
+		if v, ok := row[name]; ok && v != nil {
+			s := formatValue(v, w.opts)
+			value = &s
+		}
+		w.values[i] = append(w.values[i], value)
+	}
+
+	w.buffered++
+	if w.buffered >= w.opts.RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *parquetWriter) start(row Row) error {
+	w.started = true
+	if row != nil {
+		w.columns.init(row)
+	}
+	w.values = make([][]*string, len(w.columns.names))
+	return w.write([]byte(parquetMagic))
+}
+
+func (w *parquetWriter) write(data []byte) error {
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	return err
+}
+
+// flush writes buffered rows as a row group, one data page per column.
+func (w *parquetWriter) flush() error {
+	if w.buffered == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(w.buffered)}
+	for i, values := range w.values {
+		page := encodeParquetPage(values)
+
+		header := newThriftWriter()
+		header.fieldI32(1, parquetPageTypeData)
+		header.fieldI32(2, int32(len(page)))
+		header.fieldI32(3, int32(len(page)))
+		header.fieldStruct(5)
+		header.fieldI32(1, int32(len(values)))
+		header.fieldI32(2, parquetEncodingPlain)
+		header.fieldI32(3, parquetEncodingRLE)
+		header.fieldI32(4, parquetEncodingRLE)
+		header.end()
+		header.end()
+
+		chunk := parquetColumnChunk{offset: w.offset, values: int64(len(values))}
+		if err := w.write(header.bytes()); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		chunk.size = w.offset - chunk.offset
+		group.size += chunk.size
+		group.columns = append(group.columns, chunk)
+
+		w.values[i] = values[:0]
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.buffered = 0
+	return nil
+}
+
+// encodeParquetPage encodes definition levels and PLAIN values of non-null values.
+func encodeParquetPage(values []*string) []byte {
+	var levels []byte
+	for i := 0; i < len(values); {
+		defined := values[i] != nil
+		run := 1
+		for i+run < len(values) && (values[i+run] != nil) == defined {
+			run++
+		}
+		levels = appendUvarint(levels, uint64(run)<<1)
+		if defined {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		i += run
+	}
+
+	page := make([]byte, 4, 4+len(levels))
+	binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+	page = append(page, levels...)
+
+	var size [4]byte
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		binary.LittleEndian.PutUint32(size[:], uint32(len(*value)))
+		page = append(page, size[:]...)
+		page = append(page, *value...)
+	}
+	return page
+}
+
+func (w *parquetWriter) Close() error {
+	if !w.started {
+		// no rows, write file with the given columns
+		if err := w.start(nil); err != nil {
+			return err
+		}
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	meta := newThriftWriter()
+	meta.fieldI32(1, 1)
+
+	meta.fieldList(2, thriftStruct, len(w.columns.names)+1)
+	meta.begin()
+	meta.fieldString(4, "schema")
+	meta.fieldI32(5, int32(len(w.columns.names)))
+	meta.end()
+	for _, name := range w.columns.names {
+		meta.begin()
+		meta.fieldI32(1, parquetTypeByteArray)
+		meta.fieldI32(3, parquetRepetitionOptional)
+		meta.fieldString(4, name)
+		meta.fieldI32(6, parquetConvertedTypeUTF8)
+		meta.end()
+	}
+
+	var rows int64
+	for _, group := range w.rowGroups {
+		rows += group.rows
+	}
+	meta.fieldI64(3, rows)
+
+	meta.fieldList(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		meta.begin()
+		meta.fieldList(1, thriftStruct, len(group.columns))
+		for i, chunk := range group.columns {
+			meta.begin()
+			meta.fieldI64(2, chunk.offset)
+			meta.fieldStruct(3)
+			meta.fieldI32(1, parquetTypeByteArray)
+			meta.fieldList(2, thriftI32, 2)
+			meta.i32(parquetEncodingPlain)
+			meta.i32(parquetEncodingRLE)
+			meta.fieldList(3, thriftBinary, 1)
+			meta.string(w.columns.names[i])
+			meta.fieldI32(4, parquetCodecUncompressed)
+			meta.fieldI64(5, chunk.values)
+			meta.fieldI64(6, chunk.size)
+			meta.fieldI64(7, chunk.size)
+			meta.fieldI64(9, chunk.offset)
+			meta.end()
+			meta.end()
+		}
+		meta.fieldI64(2, group.size)
+		meta.fieldI64(3, group.rows)
+		meta.end()
+	}
+	meta.fieldString(6, "go-ksc")
+	meta.end()
+
+	footer := meta.bytes()
+	if err := w.write(footer); err != nil {
+		return err
+	}
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(footer)))
+	if err := w.write(size[:]); err != nil {
+		return err
+	}
+	if err := w.write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+func (w *parquetWriter) Stats() Stats {
+	return w.columns.stats()
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes Thrift compact protocol structs, as used in Parquet metadata.
+type thriftWriter struct {
+	buf []byte
+
+	// last field id of every open struct
+	last []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) bytes() []byte {
+	return t.buf
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = appendUvarint(t.buf, zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) fieldI32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.i32(v)
+}
+
+func (t *thriftWriter) fieldI64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = appendUvarint(t.buf, zigzag(v))
+}
+
+func (t *thriftWriter) fieldString(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.string(s)
+}
+
+// fieldStruct starts nested struct field, closed by end.
+func (t *thriftWriter) fieldStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.last = append(t.last, 0)
+}
+
+// fieldList starts list field of size elements of typ. Struct elements are started by begin and closed by end.
+func (t *thriftWriter) fieldList(id int16, typ byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|typ)
+	} else {
+		t.buf = append(t.buf, 0xF0|typ)
+		t.buf = appendUvarint(t.buf, uint64(size))
+	}
+}
+
+// begin starts struct element of a list, closed by end.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) i32(v int32) {
+	t.buf = appendUvarint(t.buf, zigzag(int64(v)))
+}
+
+func (t *thriftWriter) string(s string) {
+	t.buf = appendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// end closes the current struct.
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,191 @@
+package export_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps by field id.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.varint()
+	case 8:
+		size := int(r.uvarint())
+		r.pos += size
+		return string(r.data[r.pos-size : r.pos])
+	case 9:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			if elem == 1 || elem == 2 {
+				list[i] = r.byte() == 1
+				continue
+			}
+			list[i] = r.value(elem)
+		}
+		return list
+	case 12:
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d at %d", typ, r.pos))
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+// decodeParquet reads the footer and the data pages of every column chunk
+// and returns rows by column name, nil is null.
+func decodeParquet(t *testing.T, data []byte) (int64, []string, []map[string]interface{}) {
+	t.Helper()
+	expectEqual(t, "PAR1", string(data[:4]))
+	expectEqual(t, "PAR1", string(data[len(data)-4:]))
+
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-size : len(data)-8]}
+	meta := footer.structure()
+	expectEqual(t, size, footer.pos)
+
+	var names []string
+	for _, element := range meta[2].([]interface{})[1:] {
+		names = append(names, element.(map[int16]interface{})[4].(string))
+	}
+
+	var rows []map[string]interface{}
+	for _, group := range meta[4].([]interface{}) {
+		group := group.(map[int16]interface{})
+		count := int(group[3].(int64))
+		groupRows := make([]map[string]interface{}, count)
+		for i := range groupRows {
+			groupRows[i] = map[string]interface{}{}
+		}
+
+		for _, chunk := range group[1].([]interface{}) {
+			chunkMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			name := chunkMeta[3].([]interface{})[0].(string)
+			expectEqual(t, int64(count), chunkMeta[5].(int64))
+
+			page := &thriftReader{data: data, pos: int(chunkMeta[9].(int64))}
+			header := page.structure()
+			expectEqual(t, int64(0), header[1])
+			expectEqual(t, int64(count), header[5].(map[int16]interface{})[1])
+			body := &thriftReader{data: data[page.pos : page.pos+int(header[2].(int64))]}
+
+			levelsSize := int(binary.LittleEndian.Uint32(body.data))
+			body.pos = 4
+			var defined []bool
+			for body.pos < 4+levelsSize {
+				run := body.uvarint()
+				if run&1 != 0 {
+					t.Fatalf("unexpected bit-packed run in column %s", name)
+				}
+				level := body.byte() == 1
+				for i := uint64(0); i < run>>1; i++ {
+					defined = append(defined, level)
+				}
+			}
+			expectEqual(t, count, len(defined))
+
+			for i, ok := range defined {
+				if !ok {
+					groupRows[i][name] = nil
+					continue
+				}
+				n := int(binary.LittleEndian.Uint32(body.data[body.pos:]))
+				body.pos += 4 + n
+				groupRows[i][name] = string(body.data[body.pos-n : body.pos])
+			}
+			expectEqual(t, len(body.data), body.pos)
+		}
+		rows = append(rows, groupRows...)
+	}
+	return meta[3].(int64), names, rows
+}
+
+func TestExportParquetDecode(t *testing.T) {
+	client, stop := newServer(t)
+	defer stop()
+
+	// one row per group and both rows in one page with a null run
+	for _, groupSize := range []int{1, 2} {
+		var buf bytes.Buffer
+		src := export.SrvView(client.SrvView, &kaspersky.SrvViewParams{WstrViewName: "HostsView"}, 0)
+		if _, err := export.Export(context.Background(), src, export.NewParquetWriter(&buf, export.Options{RowGroupSize: groupSize})); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, names, records := decodeParquet(t, buf.Bytes())
+		expectEqual(t, int64(2), rows)
+		expectEqual(t, []string{"KLHST_WKS_DN", "KLHST_WKS_LAST_VISIBLE", "KLHST_WKS_STATUS.Ids[0]",
+			"KLHST_WKS_STATUS.Ids[1]", "KLHST_WKS_STATUS.Ok"}, names)
+		expectEqual(t, []map[string]interface{}{
+			{
+				"KLHST_WKS_DN":            "WKS-1",
+				"KLHST_WKS_LAST_VISIBLE":  "2020-05-28T07:22:14Z",
+				"KLHST_WKS_STATUS.Ids[0]": "1",
+				"KLHST_WKS_STATUS.Ids[1]": "2",
+				"KLHST_WKS_STATUS.Ok":     "true",
+			},
+			{
+				"KLHST_WKS_DN":            "WKS, 2",
+				"KLHST_WKS_LAST_VISIBLE":  nil,
+				"KLHST_WKS_STATUS.Ids[0]": nil,
+				"KLHST_WKS_STATUS.Ids[1]": nil,
+				"KLHST_WKS_STATUS.Ok":     nil,
+			},
+		}, records)
+	}
+}
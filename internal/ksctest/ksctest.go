@@ -0,0 +1,131 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package ksctest provides a fake Administration Server and assertions for tests of packages built on the client.
+package ksctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Server fake Administration Server. Calls of Open API methods, e.g. "HostGroup.FindHosts", are routed
+// to handlers registered by Handle and Reply, methods without handlers respond with an empty object.
+// Handlers are called one at a time, so they may share state without locking.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]http.HandlerFunc
+	calls    map[string]int
+}
+
+// NewServer starts fake Administration Server which is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{handlers: map[string]http.HandlerFunc{}, calls: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/v1.0/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	if handler, ok := s.handlers[method]; ok {
+		handler(w, r)
+		return
+	}
+	w.Write([]byte(`{}`))
+}
+
+// Handle registers handler of method, e.g. "HostGroup.FindHosts" or "login".
+func (s *Server) Handle(method string, handler http.HandlerFunc) {
+	s.mu.Lock()
+	s.handlers[method] = handler
+	s.mu.Unlock()
+}
+
+// Reply registers handler of method responding with body.
+func (s *Server) Reply(method, body string) {
+	s.Handle(method, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+}
+
+// Calls returns number of calls of method, of all methods if method is empty. It must not be called by handlers.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if method != "" {
+		return s.calls[method]
+	}
+	n := 0
+	for _, calls := range s.calls {
+		n += calls
+	}
+	return n
+}
+
+// NewClient returns client of the server, not logged on.
+func (s *Server) NewClient() *kaspersky.Client {
+	return kaspersky.New(kaspersky.Config{Server: s.URL})
+}
+
+// Params decodes JSON body of request r into params, if it is not nil, and returns the body.
+func Params(r *http.Request, params interface{}) []byte {
+	body, _ := ioutil.ReadAll(r.Body)
+	if params != nil {
+		_ = json.Unmarshal(body, params)
+	}
+	return body
+}
+
+// ExpectEqual fails the test if JSON encodings of expected and actual differ.
+func ExpectEqual(t testing.TB, expected, actual interface{}) {
+	t.Helper()
+	e, _ := json.Marshal(expected)
+	a, _ := json.Marshal(actual)
+	if !bytes.Equal(e, a) {
+		t.Fatalf("expected %s, got %s", e, a)
+	}
+}
+
+// ExpectSucceeded fails the test if err is not nil.
+func ExpectSucceeded(t testing.TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/internal/pattern"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Kinds of application changes
+const (
+	ChangeInstalled      = "installed"
+	ChangeRemoved        = "removed"
+	ChangeVersionChanged = "version_changed"
+)
+
+// Change application change of a host found by comparing two snapshots of the host
+type Change struct {
+	// Time time of the snapshot the change was found in
+	Time time.Time `json:"time"`
+
+	// Kind change kind, e.g. ChangeInstalled
+	Kind string `json:"kind"`
+
+	HostKey  string `json:"hostKey"`
+	HostName string `json:"hostName"`
+
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	Publisher string `json:"publisher,omitempty"`
+	Version   string `json:"version,omitempty"`
+
+	// PreviousVersion version before ChangeVersionChanged
+	PreviousVersion string `json:"previousVersion,omitempty"`
+}
+
+// AppFilter filter of tracked applications, patterns are matched by pattern.MatchAny.
+// Application matches the filter if its publisher matches any of Publishers and its name
+// matches any of Names, empty lists match any application.
+type AppFilter struct {
+	Publishers []string `json:"publishers,omitempty"`
+	Names      []string `json:"names,omitempty"`
+}
+
+// Match reports whether application matches the filter.
+func (f AppFilter) Match(app kaspersky.InvProduct) bool {
+	return pattern.MatchAny(f.Publishers, app.Publisher) && pattern.MatchAny(f.Names, app.DisplayName)
+}
+
+// appKey identifies application regardless of its version
+func appKey(app kaspersky.InvProduct) string {
+	return strings.ToLower(app.Publisher) + "\x00" + strings.ToLower(app.DisplayName)
+}
+
+// Diff returns changes of applications matching filter between previous and current snapshots of a host.
+//
+// Applications are compared by publisher and name. If a single version of an application is replaced by another
+// single version, the change is ChangeVersionChanged, otherwise every added or removed version is reported as
+// ChangeInstalled or ChangeRemoved, e.g. for side-by-side runtime libraries.
+func Diff(previous, current *Snapshot, filter AppFilter) []Change {
+	versions := func(s *Snapshot) map[string]map[string]kaspersky.InvProduct {
+		result := map[string]map[string]kaspersky.InvProduct{}
+		if s == nil {
+			return result
+		}
+		for _, app := range s.Applications {
+			if !filter.Match(app) {
+				continue
+			}
+			key := appKey(app)
+			if result[key] == nil {
+				result[key] = map[string]kaspersky.InvProduct{}
+			}
+			result[key][app.DisplayVersion] = app
+		}
+		return result
+	}
+	before, after := versions(previous), versions(current)
+
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []Change
+	change := func(kind string, app kaspersky.InvProduct) Change {
+		return Change{
+			Time:      current.Taken,
+			Kind:      kind,
+			HostKey:   current.Host.Key,
+			HostName:  current.Host.Name,
+			ProductID: app.ProductID,
+			Name:      app.DisplayName,
+			Publisher: app.Publisher,
+			Version:   app.DisplayVersion,
+		}
+	}
+
+	for _, key := range sorted {
+		removed := difference(before[key], after[key])
+		installed := difference(after[key], before[key])
+
+		if len(removed) == 1 && len(installed) == 1 {
+			c := change(ChangeVersionChanged, installed[0])
+			c.PreviousVersion = removed[0].DisplayVersion
+			changes = append(changes, c)
+			continue
+		}
+		for _, app := range removed {
+			changes = append(changes, change(ChangeRemoved, app))
+		}
+		for _, app := range installed {
+			changes = append(changes, change(ChangeInstalled, app))
+		}
+	}
+	return changes
+}
+
+// difference returns applications of a which versions are not in b ordered by version.
+func difference(a, b map[string]kaspersky.InvProduct) []kaspersky.InvProduct {
+	var result []kaspersky.InvProduct
+	for version, app := range a {
+		if _, ok := b[version]; !ok {
+			result = append(result, app)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].DisplayVersion < result[j].DisplayVersion
+	})
+	return result
+}
+
+// Sink receives application changes found by Track
+type Sink interface {
+	Emit(ctx context.Context, change Change) error
+}
+
+// SinkFunc function implementing Sink
+type SinkFunc func(ctx context.Context, change Change) error
+
+// Emit calls f(ctx, change).
+func (f SinkFunc) Emit(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// ChangeColumns columns of rows written by WriterSink
+var ChangeColumns = []string{"time", "kind", "host_key", "host_name", "product_id", "name", "publisher", "version",
+	"previous_version"}
+
+// WriterSink returns sink writing changes as rows with ChangeColumns to w, e.g. export.NewJSONLWriter.
+func WriterSink(w export.Writer) Sink {
+	return SinkFunc(func(ctx context.Context, change Change) error {
+		return w.Write(export.Row{
+			"time":             change.Time,
+			"kind":             change.Kind,
+			"host_key":         change.HostKey,
+			"host_name":        change.HostName,
+			"product_id":       change.ProductID,
+			"name":             change.Name,
+			"publisher":        change.Publisher,
+			"version":          change.Version,
+			"previous_version": change.PreviousVersion,
+		})
+	})
+}
+
+// TrackOptions options of Track
+type TrackOptions struct {
+	Options
+
+	// Apps filter of tracked applications, all applications by default
+	Apps AppFilter
+}
+
+// TrackResult result of Track
+type TrackResult struct {
+	// Hosts number of hosts snapshots were taken of
+	Hosts int `json:"hosts"`
+
+	// Baseline number of hosts without previous snapshots, their applications are not reported as installed
+	Baseline int `json:"baseline"`
+
+	// Changed number of hosts with application changes
+	Changed int `json:"changed"`
+
+	// Changes number of application changes
+	Changes int `json:"changes"`
+
+	// Failed number of hosts whose applications could not be acquired, their snapshots are kept
+	Failed int `json:"failed"`
+}
+
+// Track takes application snapshots of hosts matching opts.Filter, emits changes since the latest snapshots in store
+// to sink, if it is not nil, and records the snapshots and changes in store.
+//
+// The first snapshot of a host is a baseline, no changes are reported for it. Snapshots store all applications
+// regardless of opts.Apps, so the filter may be changed between runs. A snapshot is recorded only after all its
+// changes were emitted, so changes rejected by sink are reported again by the next run.
+// Hosts whose applications cannot be acquired are skipped and their errors are returned as HostErrors.
+func Track(ctx context.Context, client *kaspersky.Client, store *Store, opts TrackOptions, sink Sink) (*TrackResult, error) {
+	result := &TrackResult{}
+	taken := time.Now().UTC()
+
+	collect := opts.Options
+	collect.SkipPatches, collect.SkipHardware = true, true
+	err := Collect(ctx, client, collect, func(inv *HostInventory) error {
+		result.Hosts++
+		current := &Snapshot{Host: inv.Host, Taken: taken, Applications: inv.Applications}
+
+		previous, err := store.Latest(inv.Host.Key)
+		if err != nil {
+			return err
+		}
+
+		var changes []Change
+		if previous == nil {
+			result.Baseline++
+		} else {
+			changes = Diff(previous, current, opts.Apps)
+		}
+
+		if len(changes) != 0 {
+			result.Changed++
+			result.Changes += len(changes)
+		}
+		for _, change := range changes {
+			if sink == nil {
+				break
+			}
+			if err := sink.Emit(ctx, change); err != nil {
+				return err
+			}
+		}
+		return store.Record(current, changes)
+	})
+	var failed HostErrors
+	if errors.As(err, &failed) {
+		result.Failed = len(failed)
+	}
+	return result, err
+}
@@ -0,0 +1,267 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Host statuses of exported host rows
+const (
+	StatusNew     = "new"
+	StatusChanged = "changed"
+	StatusRemoved = "removed"
+)
+
+// Columns of exported rows, to be passed as export.Options.Columns
+var (
+	HostColumns = []string{"host_key", "host_id", "name", "dns_name", "dns_domain", "netbios_name", "group", "os",
+		"status", "digest", "collected"}
+	ApplicationColumns = []string{"host_key", "host_name", "product_id", "name", "version", "publisher",
+		"install_date", "install_dir", "msi"}
+	PatchColumns = []string{"host_key", "host_name", "patch_id", "product_id", "name", "version", "publisher",
+		"install_date", "classification"}
+	HardwareColumns = []string{"host_key", "host_name", "object_id", "component", "name", "manufacturer",
+		"serial_number", "value"}
+)
+
+// Sinks writers of exported rows, nil writers are skipped
+type Sinks struct {
+	// Hosts one row per exported host, see HostColumns
+	Hosts export.Writer
+
+	// Applications one row per host application, see ApplicationColumns
+	Applications export.Writer
+
+	// Patches one row per host application update, see PatchColumns
+	Patches export.Writer
+
+	// Hardware one row per host hardware component, see HardwareColumns
+	Hardware export.Writer
+}
+
+// ExportOptions options of Export
+type ExportOptions struct {
+	Options
+
+	// Full export all hosts regardless of the state
+	Full bool
+}
+
+// Result of Export
+type Result struct {
+	// Hosts number of collected hosts
+	Hosts int `json:"hosts"`
+
+	// New, Changed, Removed numbers of exported hosts by status
+	New     int `json:"new"`
+	Changed int `json:"changed"`
+	Removed int `json:"removed"`
+
+	// Unchanged number of collected hosts which were not exported
+	Unchanged int `json:"unchanged"`
+
+	// Failed number of hosts whose inventory could not be acquired, they keep their previous state
+	Failed int `json:"failed"`
+}
+
+// Export writes inventory of hosts which are new or changed since the export recorded in state and updates state.
+//
+// Hosts recorded in state which are no longer managed are exported with StatusRemoved unless opts.Filter is set,
+// since a filtered run does not see all hosts. Callers should save state only after sinks were closed successfully,
+// otherwise hosts of a failed run would not be exported again.
+//
+// Hosts whose inventory cannot be acquired keep their previous state and are not reported as removed,
+// the other hosts are exported and their errors are returned as HostErrors, so state may still be saved.
+func Export(ctx context.Context, client *kaspersky.Client, state *State, opts ExportOptions, sinks Sinks) (*Result, error) {
+	result := &Result{}
+	seen := map[string]bool{}
+	collected := time.Now().UTC()
+
+	err := Collect(ctx, client, opts.Options, func(inv *HostInventory) error {
+		key := inv.Host.Key
+		seen[key] = true
+		result.Hosts++
+
+		digest := inv.Digest()
+		previous, ok := state.Hosts[key]
+		status := StatusNew
+		switch {
+		case ok && previous.Digest == digest && !opts.Full:
+			result.Unchanged++
+			return nil
+		case ok:
+			status = StatusChanged
+			result.Changed++
+		default:
+			result.New++
+		}
+
+		if err := writeHost(inv, status, digest, collected, sinks); err != nil {
+			return err
+		}
+		state.Hosts[key] = HostState{Name: inv.Host.Name, Digest: digest, Exported: collected}
+		return nil
+	})
+	var failed HostErrors
+	if errors.As(err, &failed) {
+		for _, hostErr := range failed {
+			seen[hostErr.Host.Key] = true
+		}
+		result.Failed = len(failed)
+	} else if err != nil {
+		return result, err
+	}
+
+	if opts.Filter == "" {
+		var removed []string
+		for key := range state.Hosts {
+			if !seen[key] {
+				removed = append(removed, key)
+			}
+		}
+		sort.Strings(removed)
+
+		for _, key := range removed {
+			if sinks.Hosts != nil {
+				err := sinks.Hosts.Write(export.Row{
+					"host_key":  key,
+					"name":      state.Hosts[key].Name,
+					"status":    StatusRemoved,
+					"collected": collected,
+				})
+				if err != nil {
+					return result, err
+				}
+			}
+			delete(state.Hosts, key)
+			result.Removed++
+		}
+	}
+
+	state.Updated = collected
+	if failed != nil {
+		return result, failed
+	}
+	return result, nil
+}
+
+func writeHost(inv *HostInventory, status, digest string, collected time.Time, sinks Sinks) error {
+	host := inv.Host
+	if sinks.Hosts != nil {
+		err := sinks.Hosts.Write(export.Row{
+			"host_key":     host.Key,
+			"host_id":      host.ID,
+			"name":         host.Name,
+			"dns_name":     host.DNSName,
+			"dns_domain":   host.DNSDomain,
+			"netbios_name": host.NetBIOS,
+			"group":        host.Group,
+			"os":           host.OS,
+			"status":       status,
+			"digest":       digest,
+			"collected":    collected,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if sinks.Applications != nil {
+		for _, app := range inv.Applications {
+			err := sinks.Applications.Write(export.Row{
+				"host_key":     host.Key,
+				"host_name":    host.Name,
+				"product_id":   app.ProductID,
+				"name":         app.DisplayName,
+				"version":      app.DisplayVersion,
+				"publisher":    app.Publisher,
+				"install_date": date(app.InstallDate),
+				"install_dir":  app.InstallDir,
+				"msi":          app.IsMsi,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if sinks.Patches != nil {
+		for _, patch := range inv.Patches {
+			err := sinks.Patches.Write(export.Row{
+				"host_key":       host.Key,
+				"host_name":      host.Name,
+				"patch_id":       patch.PatchID,
+				"product_id":     patch.ParentID,
+				"name":           patch.DisplayName,
+				"version":        patch.DisplayVersion,
+				"publisher":      patch.Publisher,
+				"install_date":   date(patch.InstallDate),
+				"classification": patch.Classification,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if sinks.Hardware != nil {
+		for _, component := range inv.Hardware {
+			err := sinks.Hardware.Write(export.Row{
+				"host_key":      host.Key,
+				"host_name":     host.Name,
+				"object_id":     json.Number(strconv.FormatInt(component.ObjectID, 10)),
+				"component":     component.Kind,
+				"name":          component.Name,
+				"manufacturer":  component.Manufacturer,
+				"serial_number": component.SerialNumber,
+				"value":         component.Value,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// date returns row value of optional datetime.
+func date(d *kaspersky.DateTime) interface{} {
+	if d == nil {
+		return nil
+	}
+	if t := d.Time(); !t.IsZero() {
+		return t
+	}
+	return nil
+}
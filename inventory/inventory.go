@@ -0,0 +1,418 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package inventory collects hardware and software inventory of managed hosts for CMDB ingestion.
+//
+// Collect acquires inventory of every host: applications and updates from InventoryApi and hardware from
+// the hardware inventory storage. Export writes hosts whose inventory changed since the previous run
+// into tabular files, one row per host, host application, host update and hardware component.
+// Track keeps application snapshots of hosts in a Store and reports applications installed, removed
+// or updated between runs.
+package inventory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Host managed host
+type Host struct {
+	// Key stable host identity: lower-case host id (KLHST_WKS_HOSTNAME), which is kept by the
+	// Administration Server when the host is renamed or moved to another group
+	Key string `json:"key"`
+
+	// ID host id (KLHST_WKS_HOSTNAME)
+	ID string `json:"id"`
+
+	// Name host display name (KLHST_WKS_DN)
+	Name      string `json:"name"`
+	DNSName   string `json:"dnsName,omitempty"`
+	DNSDomain string `json:"dnsDomain,omitempty"`
+	NetBIOS   string `json:"netbios,omitempty"`
+	OS        string `json:"os,omitempty"`
+
+	// Group full path of the administration group of the host
+	Group string `json:"group"`
+}
+
+// HostKey returns stable identity key of host id.
+func HostKey(id string) string {
+	return strings.ToLower(id)
+}
+
+// Component kinds of hardware components
+const (
+	ComponentDevice         = "device"
+	ComponentCPU            = "cpu"
+	ComponentMotherBoard    = "motherboard"
+	ComponentMemory         = "memory"
+	ComponentDisk           = "disk"
+	ComponentNetworkAdapter = "network_adapter"
+	ComponentOS             = "os"
+)
+
+// Component hardware component of a host
+type Component struct {
+	// ObjectID id of the hardware inventory storage object
+	ObjectID int64 `json:"objectId"`
+
+	// Kind component kind, e.g. ComponentCPU
+	Kind string `json:"kind"`
+
+	Name         string `json:"name,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// Value component value as reported by the Administration Server, e.g. CPU model or MAC address
+	Value string `json:"value,omitempty"`
+}
+
+// components returns hardware components of hardware inventory storage object.
+func components(object kaspersky.HWInvRecord) []Component {
+	result := []Component{{
+		ObjectID:     object.ID,
+		Kind:         ComponentDevice,
+		Name:         object.Name,
+		Manufacturer: object.Manufacturer,
+		SerialNumber: object.SerialNumber,
+		Value:        object.Description,
+	}}
+
+	values := []struct {
+		kind  string
+		value string
+	}{
+		{ComponentCPU, object.CPU},
+		{ComponentMotherBoard, object.MotherBoard},
+		{ComponentMemory, size(object.MemorySize)},
+		{ComponentDisk, size(object.DiskSize)},
+		{ComponentNetworkAdapter, object.MAC},
+		{ComponentOS, object.OS},
+	}
+	for _, v := range values {
+		if v.value != "" {
+			result = append(result, Component{ObjectID: object.ID, Kind: v.kind, Value: v.value})
+		}
+	}
+	return result
+}
+
+func size(n int64) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// HostInventory hardware and software inventory of a host
+type HostInventory struct {
+	Host         Host                   `json:"host"`
+	Applications []kaspersky.InvProduct `json:"applications"`
+	Patches      []kaspersky.InvPatch   `json:"patches"`
+	Hardware     []Component            `json:"hardware"`
+}
+
+// sort orders inventory items by their ids.
+func (hi *HostInventory) sort() {
+	sort.Slice(hi.Applications, func(i, j int) bool {
+		return hi.Applications[i].ProductID < hi.Applications[j].ProductID
+	})
+	sort.Slice(hi.Patches, func(i, j int) bool {
+		return hi.Patches[i].PatchID < hi.Patches[j].PatchID
+	})
+	sort.Slice(hi.Hardware, func(i, j int) bool {
+		a, b := hi.Hardware[i], hi.Hardware[j]
+		if a.ObjectID != b.ObjectID {
+			return a.ObjectID < b.ObjectID
+		}
+		return a.Kind < b.Kind
+	})
+}
+
+// Digest returns digest of the inventory, which changes whenever the host attributes or any inventory item change.
+func (hi *HostInventory) Digest() string {
+	hi.sort()
+	data, err := json.Marshal(hi)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Options of inventory collection
+type Options struct {
+	// Filter search filter of hosts, e.g. (KLHST_WKS_GROUPID = 5), all hosts by default
+	Filter string
+
+	// Concurrency number of hosts inventory is acquired for concurrently, 4 by default
+	Concurrency int
+
+	// SkipPatches, SkipHardware do not acquire application updates and hardware of hosts
+	SkipPatches  bool
+	SkipHardware bool
+}
+
+var hostAttributes = []string{
+	"KLHST_WKS_HOSTNAME",
+	"KLHST_WKS_DN",
+	"KLHST_WKS_DNSNAME",
+	"KLHST_WKS_DNSDOMAIN",
+	"KLHST_WKS_WINHOSTNAME",
+	"KLHST_WKS_OS_NAME",
+	"KLHST_WKS_GROUPID",
+}
+
+type hostRecord struct {
+	ID        string `json:"KLHST_WKS_HOSTNAME"`
+	Name      string `json:"KLHST_WKS_DN"`
+	DNSName   string `json:"KLHST_WKS_DNSNAME"`
+	DNSDomain string `json:"KLHST_WKS_DNSDOMAIN"`
+	NetBIOS   string `json:"KLHST_WKS_WINHOSTNAME"`
+	OS        string `json:"KLHST_WKS_OS_NAME"`
+	GroupID   int64  `json:"KLHST_WKS_GROUPID"`
+}
+
+// hosts returns hosts matching filter.
+func hosts(ctx context.Context, client *kaspersky.Client, filter string) ([]Host, error) {
+	if filter == "" {
+		filter = `(KLHST_WKS_DN="*")`
+	}
+
+	accessor, _, err := client.HostGroup.FindHosts(ctx, kaspersky.HGParams{
+		WstrFilter:        filter,
+		VecFieldsToReturn: hostAttributes,
+		PParams:           kaspersky.PParams{KlgrpFindFromCurVsOnly: true},
+		LMaxLifeTime:      3600,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.ChunkAccessor.Release(context.Background(), accessor.StrAccessor)
+
+	var result []Host
+	err = client.ChunkAccessor.ForEachItem(ctx, accessor.StrAccessor, 0, func(item json.RawMessage) error {
+		var record hostRecord
+		if err := json.Unmarshal(item, &record); err != nil {
+			return err
+		}
+
+		group, err := client.HostGroup.GroupPath(ctx, record.GroupID)
+		if err != nil {
+			return err
+		}
+
+		result = append(result, Host{
+			Key:       HostKey(record.ID),
+			ID:        record.ID,
+			Name:      record.Name,
+			DNSName:   record.DNSName,
+			DNSDomain: record.DNSDomain,
+			NetBIOS:   record.NetBIOS,
+			OS:        record.OS,
+			Group:     group,
+		})
+		return nil
+	})
+	return result, err
+}
+
+// hardwareIndex hardware inventory storage objects by lower-case name
+type hardwareIndex map[string][]kaspersky.HWInvRecord
+
+func loadHardware(ctx context.Context, client *kaspersky.Client) (hardwareIndex, error) {
+	var objects []kaspersky.HWInvRecord
+	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{
+		Order: []kaspersky.OrderValue{{Name: "Id", Asc: true}},
+	}, &objects)
+	if err != nil {
+		return nil, err
+	}
+
+	index := hardwareIndex{}
+	for _, object := range objects {
+		if name := strings.ToLower(object.Name); name != "" {
+			index[name] = append(index[name], object)
+		}
+	}
+	return index, nil
+}
+
+// components returns hardware components of host. Hardware inventory storage objects are matched to the host
+// by name, which is compared to host display name, NetBIOS name, DNS name and FQDN ignoring case.
+func (index hardwareIndex) components(host Host) []Component {
+	names := map[string]bool{}
+	for _, name := range []string{host.Name, host.NetBIOS, host.DNSName} {
+		if name != "" {
+			names[strings.ToLower(name)] = true
+		}
+	}
+	if host.DNSName != "" && host.DNSDomain != "" {
+		names[strings.ToLower(host.DNSName+"."+host.DNSDomain)] = true
+	}
+
+	var result []Component
+	seen := map[int64]bool{}
+	for name := range names {
+		for _, object := range index[name] {
+			if !seen[object.ID] {
+				seen[object.ID] = true
+				result = append(result, components(object)...)
+			}
+		}
+	}
+	return result
+}
+
+// Collect acquires inventory of hosts matching opts.Filter and calls fn for each host in the order of hosts.
+// Collection stops on the first error returned by fn.
+//
+// Hosts whose inventory cannot be acquired are skipped, fn is not called for them, and their errors
+// are returned together as HostErrors after all other hosts were passed to fn.
+func Collect(ctx context.Context, client *kaspersky.Client, opts Options, fn func(inv *HostInventory) error) error {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	list, err := hosts(ctx, client, opts.Filter)
+	if err != nil {
+		return err
+	}
+
+	hardware := hardwareIndex{}
+	if !opts.SkipHardware {
+		if hardware, err = loadHardware(ctx, client); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failed HostErrors
+	// hosts are processed in batches, inventory of a batch is acquired concurrently and passed to fn in order
+	batch := concurrency * 4
+	for start := 0; start < len(list); start += batch {
+		end := start + batch
+		if end > len(list) {
+			end = len(list)
+		}
+
+		inventories := make([]*HostInventory, end-start)
+		errs := make([]error, end-start)
+		next := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range next {
+					inventories[i], errs[i] = collectHost(ctx, client, list[start+i], hardware, opts.SkipPatches)
+				}
+			}()
+		}
+		for i := range inventories {
+			next <- i
+		}
+		close(next)
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for i, inv := range inventories {
+			if errs[i] != nil {
+				failed = append(failed, errs[i].(*HostError))
+				continue
+			}
+			if err := fn(inv); err != nil {
+				return err
+			}
+		}
+	}
+	if len(failed) != 0 {
+		return failed
+	}
+	return nil
+}
+
+func collectHost(ctx context.Context, client *kaspersky.Client, host Host, hardware hardwareIndex, skipPatches bool) (*HostInventory, error) {
+	applications, _, err := client.InventoryApi.HostInvProducts(ctx, host.ID)
+	if err != nil {
+		return nil, &HostError{Host: host, Err: err}
+	}
+
+	var patches []kaspersky.InvPatch
+	if !skipPatches {
+		if patches, _, err = client.InventoryApi.HostInvPatches(ctx, host.ID); err != nil {
+			return nil, &HostError{Host: host, Err: err}
+		}
+	}
+
+	inv := &HostInventory{
+		Host:         host,
+		Applications: applications,
+		Patches:      patches,
+		Hardware:     hardware.components(host),
+	}
+	inv.sort()
+	return inv, nil
+}
+
+// HostError error of inventory acquisition of a host
+type HostError struct {
+	Host Host
+	Err  error
+}
+
+func (e *HostError) Error() string {
+	return "host " + e.Host.Name + " (" + e.Host.ID + "): " + e.Err.Error()
+}
+
+func (e *HostError) Unwrap() error {
+	return e.Err
+}
+
+// HostErrors errors of hosts skipped by Collect
+type HostErrors []*HostError
+
+func (e HostErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strconv.Itoa(len(e)) + " hosts failed: " + strings.Join(msgs, "; ")
+}
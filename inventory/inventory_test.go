@@ -0,0 +1,305 @@
+package inventory_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/export"
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/inventory"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// fakeKSC returns fake Administration Server with hosts HOST-1 and HOST-2, products maps host id to its
+// GetHostInvProducts response.
+func fakeKSC(t *testing.T, products map[string]string, mu *sync.Mutex) *ksctest.Server {
+	srv := ksctest.NewServer(t)
+	srv.Reply("HostGroup.FindHosts", `{"strAccessor": "acc", "PxgRetVal": 2}`)
+	srv.Reply("ChunkAccessor.GetItemsCount", `{"PxgRetVal": 2}`)
+	srv.Reply("ChunkAccessor.GetItemsChunk", `{"pChunk": {"KLCSP_ITERATOR_ARRAY": [
+		{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "A1B2-GUID", "KLHST_WKS_DN": "HOST-1",
+			"KLHST_WKS_DNSNAME": "host-1", "KLHST_WKS_DNSDOMAIN": "corp.local", "KLHST_WKS_GROUPID": 1,
+			"KLHST_WKS_OS_NAME": "Windows 10"}},
+		{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "c3d4-guid", "KLHST_WKS_DN": "HOST-2",
+			"KLHST_WKS_GROUPID": 1}}
+	]}, "PxgRetVal": 2}`)
+	srv.Reply("HostGroup.GroupIdGroups", `{"PxgRetVal": 1}`)
+	srv.Reply("HostGroup.GetGroupInfoEx", `{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`)
+
+	srv.Reply("SrvView.ResetIterator", `{"wstrIteratorId": "it"}`)
+	srv.Reply("SrvView.GetRecordCount", `{"PxgRetVal": 1}`)
+	srv.Reply("SrvView.GetRecordRange", `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+		{"type": "params", "value": {"Id": 10, "Type": 1, "Name": "host-1.corp.local", "Manufacturer": "Dell",
+			"SerialNumber": "SN-1", "CPU": "Intel Core i5", "MemorySize": 8192, "StrMac": "00-11-22-33-44-55"}}
+	]}}`)
+
+	srv.Handle("InventoryApi.GetHostInvProducts", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			SzwHostID string `json:"szwHostId"`
+		}
+		ksctest.Params(r, &in)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write([]byte(products[in.SzwHostID]))
+	})
+	srv.Reply("InventoryApi.GetHostInvPatches", `{"PxgRetVal": {"GNRL_EA_PARAM_1": []}}`)
+	return srv
+}
+
+func product(id, name, version string) string {
+	return `{"type": "params", "value": {"ProductID": "` + id + `", "DisplayName": "` + name +
+		`", "DisplayVersion": "` + version + `", "Publisher": "Vendor"}}`
+}
+
+func products(items ...string) string {
+	return `{"PxgRetVal": {"GNRL_EA_PARAM_1": [` + strings.Join(items, ",") + `]}}`
+}
+
+type sinks struct {
+	hosts, applications, hardware bytes.Buffer
+	inventory.Sinks
+}
+
+func newSinks(t *testing.T) *sinks {
+	s := &sinks{}
+	var err error
+	newWriter := func(buf *bytes.Buffer, columns []string) export.Writer {
+		w, e := export.NewWriter(export.FormatCSV, buf, export.Options{Columns: columns})
+		if e != nil {
+			err = e
+		}
+		return w
+	}
+	s.Hosts = newWriter(&s.hosts, []string{"host_key", "name", "group", "status"})
+	s.Applications = newWriter(&s.applications, []string{"host_key", "product_id", "name", "version"})
+	s.Hardware = newWriter(&s.hardware, []string{"host_key", "object_id", "component", "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (s *sinks) close(t *testing.T) {
+	for _, w := range []export.Writer{s.Hosts, s.Applications, s.Hardware} {
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	inv := map[string]string{
+		"A1B2-GUID": products(product("p1", "Browser", "1.0"), product("p2", "Office", "2019")),
+		"c3d4-guid": products(product("p1", "Browser", "1.0")),
+	}
+	srv := fakeKSC(t, inv, &mu)
+	client := srv.NewClient()
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	run := func(opts inventory.ExportOptions) (*inventory.Result, *sinks) {
+		t.Helper()
+		state, err := inventory.LoadState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newSinks(t)
+		result, err := inventory.Export(ctx, client, state, opts, s.Sinks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.close(t)
+		if err := state.Save(statePath); err != nil {
+			t.Fatal(err)
+		}
+		return result, s
+	}
+
+	// the first run exports all hosts
+	result, s := run(inventory.ExportOptions{})
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 2, New: 2}, result)
+	ksctest.ExpectEqual(t, "host_key,name,group,status\n"+
+		"a1b2-guid,HOST-1,Managed devices,new\n"+
+		"c3d4-guid,HOST-2,Managed devices,new\n", s.hosts.String())
+	ksctest.ExpectEqual(t, "host_key,product_id,name,version\n"+
+		"a1b2-guid,p1,Browser,1.0\n"+
+		"a1b2-guid,p2,Office,2019\n"+
+		"c3d4-guid,p1,Browser,1.0\n", s.applications.String())
+	ksctest.ExpectEqual(t, "host_key,object_id,component,value\n"+
+		"a1b2-guid,10,cpu,Intel Core i5\n"+
+		"a1b2-guid,10,device,\n"+
+		"a1b2-guid,10,memory,8192\n"+
+		"a1b2-guid,10,network_adapter,00-11-22-33-44-55\n", s.hardware.String())
+
+	// nothing changed
+	result, s = run(inventory.ExportOptions{})
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 2, Unchanged: 2}, result)
+	ksctest.ExpectEqual(t, "host_key,name,group,status\n", s.hosts.String())
+
+	// application updated on HOST-2
+	mu.Lock()
+	inv["c3d4-guid"] = products(product("p1", "Browser", "1.1"))
+	mu.Unlock()
+	result, s = run(inventory.ExportOptions{})
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 2, Changed: 1, Unchanged: 1}, result)
+	ksctest.ExpectEqual(t, "host_key,name,group,status\nc3d4-guid,HOST-2,Managed devices,changed\n", s.hosts.String())
+	ksctest.ExpectEqual(t, "host_key,product_id,name,version\nc3d4-guid,p1,Browser,1.1\n", s.applications.String())
+
+	// full export ignores the state
+	result, _ = run(inventory.ExportOptions{Full: true})
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 2, Changed: 2}, result)
+
+	// host exported earlier which is no longer managed is reported as removed
+	state, err := inventory.LoadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Hosts["e5f6-guid"] = inventory.HostState{Name: "HOST-3", Digest: "x"}
+	if err := state.Save(statePath); err != nil {
+		t.Fatal(err)
+	}
+	result, s = run(inventory.ExportOptions{})
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 2, Removed: 1, Unchanged: 2}, result)
+	ksctest.ExpectEqual(t, "host_key,name,group,status\ne5f6-guid,HOST-3,,removed\n", s.hosts.String())
+
+	// host whose inventory fails keeps its state, the other hosts are exported
+	mu.Lock()
+	inv["A1B2-GUID"] = products(product("p1", "Browser", "1.1"))
+	inv["c3d4-guid"] = `{"PxgError": {"code": 1183, "message": "Object not found"}}`
+	mu.Unlock()
+	if state, err = inventory.LoadState(statePath); err != nil {
+		t.Fatal(err)
+	}
+	kept := state.Hosts["c3d4-guid"]
+	s = newSinks(t)
+	result, err = inventory.Export(ctx, client, state, inventory.ExportOptions{}, s.Sinks)
+	var failed inventory.HostErrors
+	if !errors.As(err, &failed) || len(failed) != 1 || failed[0].Host.Name != "HOST-2" {
+		t.Fatalf("expected HOST-2 error, got %v", err)
+	}
+	s.close(t)
+	ksctest.ExpectEqual(t, &inventory.Result{Hosts: 1, Changed: 1, Failed: 1}, result)
+	ksctest.ExpectEqual(t, "host_key,name,group,status\na1b2-guid,HOST-1,Managed devices,changed\n", s.hosts.String())
+	ksctest.ExpectEqual(t, kept, state.Hosts["c3d4-guid"])
+}
+
+func TestDiff(t *testing.T) {
+	app := func(id, publisher, name, version string) kaspersky.InvProduct {
+		return kaspersky.InvProduct{ProductID: id, Publisher: publisher, DisplayName: name, DisplayVersion: version}
+	}
+	host := inventory.Host{Key: "k", Name: "HOST-1"}
+	previous := &inventory.Snapshot{Host: host, Applications: []kaspersky.InvProduct{
+		app("p1", "Mozilla", "Firefox", "77.0"),
+		app("p2", "Microsoft", "Visual C++ Runtime", "2015"),
+		app("p3", "Vendor", "Tool", "1.0"),
+	}}
+	current := &inventory.Snapshot{Host: host, Applications: []kaspersky.InvProduct{
+		app("p4", "Mozilla", "Firefox", "78.0"),
+		app("p2", "Microsoft", "Visual C++ Runtime", "2015"),
+		app("p5", "Microsoft", "Visual C++ Runtime", "2019"),
+		app("p6", "Unknown", "Miner", "0.1"),
+	}}
+
+	ksctest.ExpectEqual(t, []inventory.Change{
+		{Kind: inventory.ChangeInstalled, HostKey: "k", HostName: "HOST-1", ProductID: "p5", Name: "Visual C++ Runtime",
+			Publisher: "Microsoft", Version: "2019"},
+		{Kind: inventory.ChangeVersionChanged, HostKey: "k", HostName: "HOST-1", ProductID: "p4", Name: "Firefox",
+			Publisher: "Mozilla", Version: "78.0", PreviousVersion: "77.0"},
+		{Kind: inventory.ChangeInstalled, HostKey: "k", HostName: "HOST-1", ProductID: "p6", Name: "Miner",
+			Publisher: "Unknown", Version: "0.1"},
+		{Kind: inventory.ChangeRemoved, HostKey: "k", HostName: "HOST-1", ProductID: "p3", Name: "Tool",
+			Publisher: "Vendor", Version: "1.0"},
+	}, inventory.Diff(previous, current, inventory.AppFilter{}))
+
+	changes := inventory.Diff(previous, current, inventory.AppFilter{Publishers: []string{"mozilla"}, Names: []string{"fire*"}})
+	ksctest.ExpectEqual(t, 1, len(changes))
+	ksctest.ExpectEqual(t, "Firefox", changes[0].Name)
+}
+
+func TestTrack(t *testing.T) {
+	var mu sync.Mutex
+	inv := map[string]string{
+		"A1B2-GUID": products(product("p1", "Browser", "1.0")),
+		"c3d4-guid": products(product("p1", "Browser", "1.0")),
+	}
+	srv := fakeKSC(t, inv, &mu)
+	client := srv.NewClient()
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := inventory.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []inventory.Change
+	sink := inventory.SinkFunc(func(ctx context.Context, change inventory.Change) error {
+		change.Time = time.Time{}
+		changes = append(changes, change)
+		return nil
+	})
+
+	// the first snapshots are baselines
+	result, err := inventory.Track(ctx, client, store, inventory.TrackOptions{}, sink)
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, &inventory.TrackResult{Hosts: 2, Baseline: 2}, result)
+	ksctest.ExpectEqual(t, 0, len(changes))
+
+	mu.Lock()
+	inv["A1B2-GUID"] = products(product("p2", "Browser", "1.1"), product("p3", "Miner", "0.1"))
+	mu.Unlock()
+
+	// the sink failure keeps the previous snapshot, so the changes are reported again
+	failed := inventory.SinkFunc(func(ctx context.Context, change inventory.Change) error {
+		return errors.New("sink is down")
+	})
+	_, err = inventory.Track(ctx, client, store, inventory.TrackOptions{}, failed)
+	ksctest.ExpectEqual(t, "sink is down", err.Error())
+
+	result, err = inventory.Track(ctx, client, store, inventory.TrackOptions{}, sink)
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, &inventory.TrackResult{Hosts: 2, Changed: 1, Changes: 2}, result)
+	ksctest.ExpectEqual(t, []inventory.Change{
+		{Kind: inventory.ChangeVersionChanged, HostKey: "a1b2-guid", HostName: "HOST-1", ProductID: "p2",
+			Name: "Browser", Publisher: "Vendor", Version: "1.1", PreviousVersion: "1.0"},
+		{Kind: inventory.ChangeInstalled, HostKey: "a1b2-guid", HostName: "HOST-1", ProductID: "p3",
+			Name: "Miner", Publisher: "Vendor", Version: "0.1"},
+	}, changes)
+
+	history, err := store.History("a1b2-guid", time.Time{})
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, 2, len(history))
+	ksctest.ExpectEqual(t, "Miner", history[1].Name)
+
+	history, err = store.History("c3d4-guid", time.Time{})
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, 0, len(history))
+
+	latest, err := store.Latest("a1b2-guid")
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, 2, len(latest.Applications))
+
+	hosts, err := store.Hosts()
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, []string{"a1b2-guid", "c3d4-guid"}, hosts)
+}
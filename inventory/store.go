@@ -0,0 +1,195 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Snapshot applications installed on a host at some time
+type Snapshot struct {
+	Host         Host                   `json:"host"`
+	Taken        time.Time              `json:"taken"`
+	Applications []kaspersky.InvProduct `json:"applications"`
+}
+
+// Store keeps the latest application snapshot and history of application changes of every host in a directory.
+//
+// Every host has its own subdirectory named by the host key with files snapshot.json and changes.jsonl.
+// Store is safe for concurrent use within a process.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// OpenStore opens store in directory dir, creating it if needed.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) hostDir(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key))
+}
+
+// Latest returns the latest snapshot of host, nil if the host has no snapshots.
+func (s *Store) Latest(key string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest(key)
+}
+
+func (s *Store) latest(key string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.hostDir(key), "snapshot.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Record appends changes to the host history and stores snapshot as the latest snapshot of its host.
+func (s *Store) Record(snapshot *Snapshot, changes []Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.hostDir(snapshot.Host.Key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if len(changes) != 0 {
+		f, err := os.OpenFile(filepath.Join(dir, "changes.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		enc := json.NewEncoder(w)
+		for _, change := range changes {
+			if err := enc.Encode(change); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, "snapshot.json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, "snapshot.json"))
+}
+
+// History returns application changes of host made at or after since, oldest first.
+func (s *Store) History(key string, since time.Time) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(s.hostDir(key), "changes.jsonl"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var result []Change
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var change Change
+		if err := dec.Decode(&change); err != nil {
+			return nil, err
+		}
+		if !change.Time.Before(since) {
+			result = append(result, change)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+// Hosts returns keys of hosts having snapshots.
+func (s *Store) Hosts() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if key, err := url.PathUnescape(entry.Name()); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,389 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrACLConflict is returned by ACL updates if the ACL has been changed by someone else after it was read.
+var ErrACLConflict = errors.New("ACL has been changed concurrently")
+
+// AceTrustee trustee of ACL entry.
+//
+// Use TrusteeByID, KscUserTrustee or AccountTrustee to make one.
+type AceTrustee struct {
+	// ID id of trustee or role, see HstAccessControl.FindTrustees and HstAccessControl.FindRoles
+	ID int64
+
+	// KscUserID id of internal Administration Server user
+	KscUserID int64
+
+	// SID, Name and IsGroup security id, name and kind of OS account
+	SID     string
+	Name    string
+	IsGroup bool
+}
+
+// TrusteeByID trustee or role by its id (KLHST_ACL_TRUSTEE_ID)
+func TrusteeByID(id int64) AceTrustee {
+	return AceTrustee{ID: id}
+}
+
+// KscUserTrustee internal Administration Server user by its id
+func KscUserTrustee(id int64) AceTrustee {
+	return AceTrustee{KscUserID: id}
+}
+
+// AccountTrustee OS account or group of accounts by its security id and name
+func AccountTrustee(sid, name string, isGroup bool) AceTrustee {
+	return AceTrustee{SID: sid, Name: name, IsGroup: isGroup}
+}
+
+func (t AceTrustee) valid() bool {
+	return t.ID != 0 || t.KscUserID != 0 || t.SID != "" || t.Name != ""
+}
+
+// is reports whether t and other identify the same trustee.
+func (t AceTrustee) is(other AceTrustee) bool {
+	switch {
+	case t.ID != 0 && other.ID != 0:
+		return t.ID == other.ID
+	case t.KscUserID != 0 && other.KscUserID != 0:
+		return t.KscUserID == other.KscUserID
+	case t.SID != "" && other.SID != "":
+		return strings.EqualFold(t.SID, other.SID)
+	case t.Name != "" && other.Name != "":
+		return strings.EqualFold(t.Name, other.Name)
+	}
+	return false
+}
+
+func (t AceTrustee) String() string {
+	switch {
+	case t.ID != 0:
+		return fmt.Sprintf("trustee %d", t.ID)
+	case t.KscUserID != 0:
+		return fmt.Sprintf("user %d", t.KscUserID)
+	case t.Name != "":
+		return fmt.Sprintf("account %q", t.Name)
+	default:
+		return fmt.Sprintf("account %s", t.SID)
+	}
+}
+
+// Ace access control entry
+type Ace struct {
+	Trustee AceTrustee
+
+	// FuncArea functional area "<product>|<version>|<functional area>" the entry applies to, the whole object if empty,
+	// see HstAccessControl.GetAccessibleFuncAreas
+	FuncArea string
+
+	// Allow, Deny allowed and denied access rights, see AccessRead and others
+	Allow int64
+	Deny  int64
+
+	// MayWrite, MayRemove the entry may be modified or removed, as reported by the server
+	MayWrite  bool
+	MayRemove bool
+}
+
+// Effective returns access rights allowed and not denied by the entry.
+func (a *Ace) Effective() int64 {
+	return a.Allow &^ a.Deny
+}
+
+// ACL access control list of administration group or virtual server.
+//
+// ACL is read by HstAccessControl.GetScObjectAcl and others and modified by Grant, Deny, Revoke and Remove,
+// use HstAccessControl.UpdateScObjectAcl to change ACL of an object safely, e.g. to grant a role access to a group:
+//
+//	err := client.HstAccessControl.UpdateScObjectAcl(ctx, groupID, kaspersky.ScObjectTypeAdmGroup, func(acl *kaspersky.ACL) error {
+//		return acl.Grant(kaspersky.TrusteeByID(helpdeskID), "", kaspersky.AccessRead|kaspersky.AccessExecute).Err()
+//	})
+type ACL struct {
+	// Inherited the object inherits ACL of its parent
+	Inherited bool
+
+	Aces []Ace
+
+	// PossibleRights access rights available for editing, as reported by the server
+	PossibleRights int64
+
+	err error
+}
+
+// NewACL makes an empty ACL, which inherits ACL of the parent object.
+func NewACL() *ACL {
+	return &ACL{Inherited: true}
+}
+
+// NewACLFromPermissions makes ACL from permissions read from the server.
+func NewACLFromPermissions(p *Permissions) *ACL {
+	acl := &ACL{Inherited: p.KlsplInherited, PossibleRights: p.KlsplPossibleRights}
+	for _, entry := range p.Entries() {
+		acl.Aces = append(acl.Aces, Ace{
+			Trustee: AceTrustee{
+				ID:        entry.TrusteeID,
+				KscUserID: entry.KlspluAkuserID,
+				SID:       entry.KlspluSid,
+				Name:      entry.KlspluName,
+				IsGroup:   entry.KlspluIsGroup,
+			},
+			FuncArea:  entry.FuncArea,
+			Allow:     entry.KlspluAllowmask,
+			Deny:      entry.KlspluDenymask,
+			MayWrite:  entry.KlspluMayWrite,
+			MayRemove: entry.KlspluMayRemove,
+		})
+	}
+	return acl
+}
+
+// Permissions returns ACL as permissions for the server.
+func (acl *ACL) Permissions() Permissions {
+	p := Permissions{
+		KlsplInherited:      acl.Inherited,
+		KlsplPermissions:    make([]KlsplPermission, 0, len(acl.Aces)),
+		KlsplPossibleRights: acl.PossibleRights,
+	}
+	for _, ace := range acl.Aces {
+		p.KlsplPermissions = append(p.KlsplPermissions, KlsplPermission{Type: "params", KlsplPermissionValue: KlsplPermissionValue{
+			KlspluSid:       ace.Trustee.SID,
+			KlspluName:      ace.Trustee.Name,
+			KlspluIsGroup:   ace.Trustee.IsGroup,
+			KlspluAkuserID:  ace.Trustee.KscUserID,
+			KlspluMayWrite:  ace.MayWrite,
+			KlspluMayRemove: ace.MayRemove,
+			TrusteeID:       ace.Trustee.ID,
+			FuncArea:        ace.FuncArea,
+			KlspluAllowmask: ace.Allow,
+			KlspluDenymask:  ace.Deny,
+		}})
+	}
+	return p
+}
+
+// Err returns the first error of ACL modifications.
+func (acl *ACL) Err() error {
+	return acl.err
+}
+
+// Find returns entry of trustee for functional area funcArea, nil if there is none.
+func (acl *ACL) Find(trustee AceTrustee, funcArea string) *Ace {
+	for i := range acl.Aces {
+		if acl.Aces[i].Trustee.is(trustee) && strings.EqualFold(acl.Aces[i].FuncArea, funcArea) {
+			return &acl.Aces[i]
+		}
+	}
+	return nil
+}
+
+// entry returns entry of trustee for functional area funcArea, adding one if there is none.
+func (acl *ACL) entry(trustee AceTrustee, funcArea string, mask int64) *Ace {
+	if acl.err != nil {
+		return nil
+	}
+	if !trustee.valid() {
+		acl.err = errors.New("ACL entry without trustee")
+		return nil
+	}
+	if mask == 0 {
+		acl.err = fmt.Errorf("%s: empty access mask", trustee)
+		return nil
+	}
+	if acl.PossibleRights != 0 && mask&^acl.PossibleRights != 0 {
+		acl.err = fmt.Errorf("%s: access rights %v may not be edited", trustee, AccessRightNames(mask&^acl.PossibleRights))
+		return nil
+	}
+
+	if ace := acl.Find(trustee, funcArea); ace != nil {
+		return ace
+	}
+	acl.Aces = append(acl.Aces, Ace{Trustee: trustee, FuncArea: funcArea, MayWrite: true, MayRemove: true})
+	return &acl.Aces[len(acl.Aces)-1]
+}
+
+// Grant allows access rights mask to trustee for functional area funcArea, the whole object if it is empty.
+// The rights are no longer denied to trustee.
+func (acl *ACL) Grant(trustee AceTrustee, funcArea string, mask int64) *ACL {
+	if ace := acl.entry(trustee, funcArea, mask); ace != nil {
+		ace.Allow |= mask
+		ace.Deny &^= mask
+	}
+	return acl
+}
+
+// Deny denies access rights mask to trustee for functional area funcArea, the whole object if it is empty.
+// The rights are no longer allowed to trustee.
+func (acl *ACL) Deny(trustee AceTrustee, funcArea string, mask int64) *ACL {
+	if ace := acl.entry(trustee, funcArea, mask); ace != nil {
+		ace.Deny |= mask
+		ace.Allow &^= mask
+	}
+	return acl
+}
+
+// Revoke neither allows nor denies access rights mask to trustee for functional area funcArea.
+// The entry is removed if it has no rights left.
+func (acl *ACL) Revoke(trustee AceTrustee, funcArea string, mask int64) *ACL {
+	if acl.err != nil || acl.Find(trustee, funcArea) == nil {
+		return acl
+	}
+
+	ace := acl.entry(trustee, funcArea, mask)
+	if ace == nil {
+		return acl
+	}
+	ace.Allow &^= mask
+	ace.Deny &^= mask
+	if ace.Allow == 0 && ace.Deny == 0 {
+		acl.remove(func(a *Ace) bool { return a == ace })
+	}
+	return acl
+}
+
+// Remove removes all entries of trustee.
+func (acl *ACL) Remove(trustee AceTrustee) *ACL {
+	if acl.err == nil {
+		acl.remove(func(a *Ace) bool { return a.Trustee.is(trustee) })
+	}
+	return acl
+}
+
+func (acl *ACL) remove(match func(*Ace) bool) {
+	aces := acl.Aces[:0]
+	for i := range acl.Aces {
+		if !match(&acl.Aces[i]) {
+			aces = append(aces, acl.Aces[i])
+		}
+	}
+	acl.Aces = aces
+}
+
+// SetInherited sets whether the object inherits ACL of its parent.
+func (acl *ACL) SetInherited(inherited bool) *ACL {
+	acl.Inherited = inherited
+	return acl
+}
+
+// equalPermissions reports whether a and b are the same ACL.
+func equalPermissions(a, b *Permissions) bool {
+	x, _ := json.Marshal(NewACLFromPermissions(a).Permissions())
+	y, _ := json.Marshal(NewACLFromPermissions(b).Permissions())
+	return bytes.Equal(x, y)
+}
+
+// updateACL reads ACL, lets fn modify it and writes it back unless it has not been changed.
+//
+// ACL is read again right before writing, ErrACLConflict is returned if it has been changed meanwhile.
+func updateACL(read func() (*Permissions, error), write func(Permissions) error, fn func(acl *ACL) error) error {
+	before, err := read()
+	if err != nil {
+		return err
+	}
+
+	acl := NewACLFromPermissions(before)
+	if err := fn(acl); err != nil {
+		return err
+	}
+	if err := acl.Err(); err != nil {
+		return err
+	}
+
+	after := acl.Permissions()
+	if equalPermissions(before, &after) {
+		return nil
+	}
+
+	current, err := read()
+	if err != nil {
+		return err
+	}
+	if !equalPermissions(before, current) {
+		return ErrACLConflict
+	}
+	return write(after)
+}
+
+// UpdateScObjectAcl Reads ACL of the object, calls fn to modify it and writes the ACL back by
+// HstAccessControl.SetScObjectAcl if it has been changed.
+//
+// ErrACLConflict is returned if the ACL has been changed by someone else meanwhile, the update may be retried then.
+// The server checks that the current user does not deny access to the object to itself.
+func (hac *HstAccessControl) UpdateScObjectAcl(ctx context.Context, nObjId, nObjType int64, fn func(acl *ACL) error) error {
+	return updateACL(func() (*Permissions, error) {
+		p, _, err := hac.GetScObjectAcl(ctx, nObjId, nObjType)
+		return p, err
+	}, func(p Permissions) error {
+		_, err := hac.SetScObjectAcl(ctx, ScObjectAclParams{
+			NObjID:               nObjId,
+			NObjType:             nObjType,
+			PAclParams:           p,
+			BCheckCurrentUserAce: true,
+		})
+		return err
+	}, fn)
+}
+
+// UpdateScVServerAcl Reads ACL of the virtual server, calls fn to modify it and writes the ACL back by
+// HstAccessControl.SetScVServerAcl if it has been changed.
+//
+// ErrACLConflict is returned if the ACL has been changed by someone else meanwhile, the update may be retried then.
+func (hac *HstAccessControl) UpdateScVServerAcl(ctx context.Context, nId int64, fn func(acl *ACL) error) error {
+	return updateACL(func() (*Permissions, error) {
+		p, _, err := hac.GetScVServerAcl(ctx, nId)
+		return p, err
+	}, func(p Permissions) error {
+		_, err := hac.SetScVServerAcl(ctx, ScVServerAclParams{NID: nId, PAclParams: p, BCheckCurrentUserAce: true})
+		return err
+	}, fn)
+}
+
+// UpdatePermissions Reads ACL of the virtual server, calls fn to modify it and writes the ACL back by
+// VServers.SetPermissions if it has been changed.
+//
+// ErrACLConflict is returned if the ACL has been changed by someone else meanwhile, the update may be retried then.
+func (vs *VServers) UpdatePermissions(ctx context.Context, lVServer int64, fn func(acl *ACL) error) error {
+	return updateACL(func() (*Permissions, error) {
+		p, err := vs.Permissions(ctx, lVServer)
+		if err != nil {
+			return nil, err
+		}
+		if p.Permissions == nil {
+			return &Permissions{}, nil
+		}
+		return p.Permissions, nil
+	}, func(p Permissions) error {
+		_, err := vs.SetPermissions(ctx, ACLParams{LVServer: lVServer, Permissions: p, Protection: true})
+		return err
+	}, fn)
+}
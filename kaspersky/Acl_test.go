@@ -0,0 +1,103 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestACLBuilder(t *testing.T) {
+	helpdesk := kaspersky.TrusteeByID(20)
+	admins := kaspersky.AccountTrustee("S-1-5-32-544", "BUILTIN\\Administrators", true)
+
+	acl := kaspersky.NewACL().
+		Grant(helpdesk, "", kaspersky.AccessRead|kaspersky.AccessExecute).
+		Deny(helpdesk, "", kaspersky.AccessExecute).
+		Grant(admins, "", kaspersky.AccessRead|kaspersky.AccessWrite).
+		Grant(kaspersky.AccountTrustee("", "builtin\\administrators", true), "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS", kaspersky.AccessExecute)
+	expectSucceeded(t, acl.Err())
+	expectEqual(t, 3, len(acl.Aces))
+	expectEqual(t, kaspersky.AccessRead, acl.Find(helpdesk, "").Allow)
+	expectEqual(t, kaspersky.AccessExecute, acl.Find(helpdesk, "").Deny)
+	expectEqual(t, kaspersky.AccessRead, acl.Find(helpdesk, "").Effective())
+
+	acl.Revoke(helpdesk, "", kaspersky.AccessRead|kaspersky.AccessExecute).Remove(kaspersky.AccountTrustee("", "BUILTIN\\ADMINISTRATORS", true))
+	expectSucceeded(t, acl.Err())
+	expectEqual(t, 0, len(acl.Aces))
+
+	acl = &kaspersky.ACL{PossibleRights: kaspersky.AccessRead}
+	if acl.Grant(helpdesk, "", kaspersky.AccessWrite).Err() == nil {
+		t.Fatal("expected error for access rights which may not be edited")
+	}
+	if kaspersky.NewACL().Grant(kaspersky.AceTrustee{}, "", kaspersky.AccessRead).Err() == nil {
+		t.Fatal("expected error for entry without trustee")
+	}
+}
+
+func TestUpdateScObjectAcl(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	var reads, writes int32
+	acls := []string{
+		`{"pAclParams": {"KLSPL_INHERITED": true, "KLSPL_PERMISSIONS": [
+			{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_ALLOWMASK": 11, "KLSPLU_MAY_WRITE": true}}]}}`,
+	}
+	handler.HandleFunc("/api/v1.0/HstAccessControl.GetScObjectAcl", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&reads, 1)
+		w.Write([]byte(acls[(int(n)-1)%len(acls)]))
+	})
+
+	var written struct {
+		ObjID int64                 `json:"nObjId"`
+		ACL   kaspersky.Permissions `json:"pAclParams"`
+		Check bool                  `json:"bCheckCurrentUserAce"`
+	}
+	handler.HandleFunc("/api/v1.0/HstAccessControl.SetScObjectAcl", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&writes, 1)
+		_ = json.NewDecoder(r.Body).Decode(&written)
+		w.Write([]byte(`{}`))
+	})
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+	grant := func(acl *kaspersky.ACL) error {
+		return acl.Grant(kaspersky.TrusteeByID(20), "", kaspersky.AccessRead|kaspersky.AccessExecute).Err()
+	}
+
+	err := client.HstAccessControl.UpdateScObjectAcl(ctx, 3, kaspersky.ScObjectTypeAdmGroup, grant)
+	expectSucceeded(t, err)
+	expectEqual(t, int32(2), atomic.LoadInt32(&reads))
+	expectEqual(t, int32(1), atomic.LoadInt32(&writes))
+	expectEqual(t, int64(3), written.ObjID)
+	expectEqual(t, true, written.Check)
+	expectEqual(t, true, written.ACL.KlsplInherited)
+
+	entries := written.ACL.Entries()
+	expectEqual(t, 2, len(entries))
+	expectEqual(t, int64(7), entries[0].KlspluAkuserID)
+	expectEqual(t, int64(20), entries[1].TrusteeID)
+	expectEqual(t, kaspersky.AccessRead|kaspersky.AccessExecute, entries[1].KlspluAllowmask)
+
+	// nothing to change
+	err = client.HstAccessControl.UpdateScObjectAcl(ctx, 3, kaspersky.ScObjectTypeAdmGroup, func(acl *kaspersky.ACL) error {
+		acl.Grant(kaspersky.KscUserTrustee(7), "", kaspersky.AccessRead)
+		return nil
+	})
+	expectSucceeded(t, err)
+	expectEqual(t, int32(1), atomic.LoadInt32(&writes))
+
+	// the ACL is changed between reads
+	acls = append(acls, `{"pAclParams": {"KLSPL_INHERITED": false, "KLSPL_PERMISSIONS": []}}`)
+	atomic.StoreInt32(&reads, 0)
+	err = client.HstAccessControl.UpdateScObjectAcl(ctx, 3, kaspersky.ScObjectTypeAdmGroup, grant)
+	if !errors.Is(err, kaspersky.ErrACLConflict) {
+		t.Fatalf("expected ErrACLConflict, got %v", err)
+	}
+	expectEqual(t, int32(1), atomic.LoadInt32(&writes))
+}
@@ -29,6 +29,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // AsyncActionStateChecker service to monitor state of async action
@@ -93,3 +94,42 @@ func (ac *AsyncActionStateChecker) CheckActionState(ctx context.Context, wstrAct
 	raw, err := ac.client.Do(ctx, request, &aSResult)
 	return aSResult, raw, err
 }
+
+// WaitActionState Waits until async action is finalized.
+//
+// Calls AsyncActionStateChecker.CheckActionState respecting lNextCheckDelay until it returns bFinalized.
+// Returns error if action is finalized unsuccessfully or ctx is done.
+func (ac *AsyncActionStateChecker) WaitActionState(ctx context.Context, wstrActionGuid string) (*ActionStateResult, error) {
+	for {
+		state, _, err := ac.CheckActionState(ctx, wstrActionGuid)
+		if err != nil {
+			return state, err
+		}
+
+		if state.BFinalized {
+			if !state.BSuccededFinalized {
+				return state, actionStateError(wstrActionGuid, state)
+			}
+			return state, nil
+		}
+
+		delay := time.Duration(state.LNextCheckDelay) * time.Millisecond
+		if delay <= 0 {
+			delay = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func actionStateError(wstrActionGuid string, state *ActionStateResult) error {
+	if state.PStateData != nil && state.PStateData.KlblagErrorMsg != "" {
+		return fmt.Errorf("action %s failed: %s (code %d)", wstrActionGuid, state.PStateData.KlblagErrorMsg,
+			state.PStateData.KlblagErrorCode)
+	}
+	return fmt.Errorf("action %s failed with state code %d", wstrActionGuid, state.LStateCode)
+}
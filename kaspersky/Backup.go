@@ -0,0 +1,169 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupManifestFile name of the manifest file in backup directories made by Policy.Backup and GroupTaskControlApi.Backup.
+//
+// The backup directory must not exist: backup is written into a temporary directory "<dir>.partial" and renamed
+// to the backup directory when finished, so an interrupted backup never looks complete. Failures of single objects
+// do not stop the backup, they are recorded in the manifest and reported by a returned error.
+const BackupManifestFile = "manifest.json"
+
+// BackupInfo origin of the backup, common part of PolicyBackupManifest and TaskBackupManifest
+type BackupInfo struct {
+	// Server Administration Server address the backup was made from
+	Server string `json:"server"`
+
+	// Created backup creation time
+	Created time.Time `json:"created"`
+}
+
+// BackupFailure policy or task that failed to be saved or restored
+type BackupFailure struct {
+	// ID policy or task id, empty if the whole group failed
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	GroupPath string `json:"groupPath,omitempty"`
+	Error     string `json:"error"`
+}
+
+func (c *Client) backupInfo() BackupInfo {
+	return BackupInfo{Server: c.Server, Created: time.Now().UTC()}
+}
+
+// writeBackup makes backup directory dir: fill saves objects into a temporary directory and records them
+// in manifest, which is then written as BackupManifestFile, and the temporary directory is renamed to dir.
+func writeBackup(dir string, manifest interface{}, fill func(tmp string) error) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("backup directory %q already exists", dir)
+	}
+
+	tmp := dir + ".partial"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, 0700); err != nil {
+		return err
+	}
+
+	if err := fill(tmp); err != nil {
+		return err
+	}
+
+	if err := writeJSONFile(filepath.Join(tmp, BackupManifestFile), manifest); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
+
+// readBackupManifest reads BackupManifestFile of backup directory dir into manifest.
+func readBackupManifest(dir string, manifest interface{}) error {
+	return readJSONFile(filepath.Join(dir, BackupManifestFile), manifest)
+}
+
+// remapGroupPath replaces the longest matching group path prefix from groupMap.
+func remapGroupPath(path string, groupMap map[string]string) string {
+	best := ""
+	for from := range groupMap {
+		lower, prefix := strings.ToLower(path), strings.ToLower(from)
+		if (lower == prefix || strings.HasPrefix(lower, prefix+GroupPathSeparator)) && len(from) > len(best) {
+			best = from
+		}
+	}
+
+	if best == "" {
+		return path
+	}
+	return groupMap[best] + path[len(best):]
+}
+
+// groupPathToDir converts group path to relative directory path.
+func groupPathToDir(path string) string {
+	names := SplitGroupPath(path)
+	for i, name := range names {
+		names[i] = sanitizeFileName(name)
+	}
+	return filepath.Join(names...)
+}
+
+// sanitizeFileName replaces characters not allowed in file names.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 32, strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readBackupFile reads file referenced by backup manifest, refusing paths outside of dir.
+func readBackupFile(dir, file string) ([]byte, error) {
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	if rel, err := filepath.Rel(dir, path); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, errors.New("backup file " + file + " is outside of backup directory")
+	}
+	return ioutil.ReadFile(path)
+}
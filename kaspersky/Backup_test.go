@@ -0,0 +1,63 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// newBackupTest starts test server with "Managed devices" group 1 and its subgroups named by id,
+// returns client, server handler and not yet existing backup directory.
+func newBackupTest(t *testing.T, subgroups map[int64]string) (*kaspersky.Client, *http.ServeMux, string) {
+	srv, handler := NewTestServer()
+	t.Cleanup(srv.Close)
+
+	handler.HandleFunc("/api/v1.0/HostGroup.GroupIdGroups", HandlerFuncOk(`{"PxgRetVal": 1}`))
+	handler.HandleFunc("/api/v1.0/HostGroup.GetGroupInfoEx", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NGroupID int64 `json:"nGroupId"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if name, ok := subgroups[in.NGroupID]; ok {
+			fmt.Fprintf(w, `{"PxgRetVal": {"name": %q, "parentId": 1}}`, name)
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`))
+	})
+
+	dir, err := ioutil.TempDir("", "backup")
+	expectSucceeded(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return kaspersky.New(kaspersky.Config{Server: srv.URL}), handler, filepath.Join(dir, "backup")
+}
+
+// expectBackupFile checks contents of the backup file.
+func expectBackupFile(t *testing.T, dir, file, expected string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join(dir, file))
+	expectSucceeded(t, err)
+	expectEqual(t, expected, string(data))
+}
+
+func TestBackupDirectoryExists(t *testing.T) {
+	client, _, backup := newBackupTest(t, nil)
+	expectSucceeded(t, os.Mkdir(backup, 0700))
+
+	_, err := client.Policy.Backup(context.Background(), backup)
+	if err == nil {
+		t.Fatal("expected existing backup directory error")
+	}
+
+	_, err = client.GroupTaskControlApi.Backup(context.Background(), backup)
+	if err == nil {
+		t.Fatal("expected existing backup directory error")
+	}
+}
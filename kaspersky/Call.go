@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var methodName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\.[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Call invokes KSC Open API method by its name "Service.Method", e.g. "HostGroup.GetDomains",
+// so that server methods without wrappers can be used.
+//
+// params are sent as the request body: []byte and json.RawMessage as is, nil as an empty body,
+// other values are encoded as JSON. If out is not nil the response is decoded into it.
+// PxgError of the response is returned as *Error, HTTP error status without PxgError as *StatusError.
+//
+// If the client uses X-KSC-Session authentication and the session has expired, a new session is started.
+// Read-only methods (Get*, Enum*, Find*, ...) are then retried once, other methods are not
+// because the server may have executed them, and the error is returned.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, out interface{}) ([]byte, error) {
+	if !methodName.MatchString(method) {
+		return nil, fmt.Errorf("invalid method name %q, Service.Method expected", method)
+	}
+
+	url := "/api/v1.0/" + method
+	token := c.SessionToken()
+	raw, err := c.PostInOut(ctx, url, params, out)
+
+	var status *StatusError
+	if c.XKscSession && token != "" && errors.As(err, &status) &&
+		(status.StatusCode == http.StatusUnauthorized || status.StatusCode == http.StatusForbidden) {
+		if err := c.restartSession(ctx, token); err != nil {
+			return nil, fmt.Errorf("restart session: %w", err)
+		}
+		if !readOnlyMethod(method) {
+			return raw, fmt.Errorf("session restarted, %s is not retried: %w", method, err)
+		}
+		raw, err = c.PostInOut(ctx, url, params, out)
+	}
+	return raw, err
+}
+
+// readOnlyMethodPrefixes prefixes of method names which do not change server state
+var readOnlyMethodPrefixes = []string{"Get", "Enum", "Find", "List", "Is", "Has", "Check", "Ping"}
+
+func readOnlyMethod(method string) bool {
+	name := method[strings.IndexByte(method, '.')+1:]
+	for _, prefix := range readOnlyMethodPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// restartSession starts a new X-KSC-Session session unless the expired token
+// has already been replaced by a concurrent call.
+func (c *Client) restartSession(ctx context.Context, expired string) error {
+	c.sessionRestart.Lock()
+	defer c.sessionRestart.Unlock()
+	if c.SessionToken() != expired {
+		return nil
+	}
+	c.setSessionToken("")
+	return c.xkscSession(ctx)
+}
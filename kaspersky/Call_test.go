@@ -0,0 +1,109 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestCall(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	handler.HandleFunc("/api/v1.0/HostGroup.GetHostInfo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		expectEqual(t, `{"strHostName":"host"}`, string(body))
+		w.Write([]byte(`{"PxgRetVal": {"KLHST_WKS_DN": "WKS-1"}}`))
+	})
+	handler.HandleFunc("/api/v1.0/HostGroup.RemoveHost", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"PxgError": {"code": 1183, "message": "Object not found"}}`))
+	})
+	handler.HandleFunc("/api/v1.0/HostGroup.Missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	out := new(struct {
+		Info kaspersky.Params `json:"PxgRetVal"`
+	})
+	_, err := client.Call(ctx, "HostGroup.GetHostInfo", json.RawMessage(`{"strHostName": "host"}`), out)
+	expectSucceeded(t, err)
+	expectEqual(t, "WKS-1", out.Info.String("KLHST_WKS_DN"))
+
+	_, err = client.Call(ctx, "HostGroup.RemoveHost", map[string]string{"strHostName": "host"}, nil)
+	var pxgError *kaspersky.Error
+	if !errors.As(err, &pxgError) {
+		t.Fatalf("expected PxgError, got %v", err)
+	}
+	expectEqual(t, "Code: 1183, Message: Object not found", err.Error())
+
+	_, err = client.Call(ctx, "HostGroup.Missing", nil, nil)
+	var status *kaspersky.StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 status error, got %v", err)
+	}
+
+	if _, err = client.Call(ctx, "/api/v1.0/HostGroup.GetDomains", nil, nil); err == nil {
+		t.Fatal("expected invalid method name error")
+	}
+}
+
+func TestCallRestartsSession(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL, XKscSession: true})
+
+	sessions := 0
+	handler.HandleFunc("/api/v1.0/Session.StartSession", func(w http.ResponseWriter, r *http.Request) {
+		sessions++
+		if sessions == 1 {
+			w.Write([]byte(`{"PxgRetVal": "expired"}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": "fresh"}`))
+	})
+	removed := 0
+	handler.HandleFunc("/api/v1.0/Session.Ping", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-KSC-Session") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/HostGroup.RemoveHost", func(w http.ResponseWriter, r *http.Request) {
+		removed++
+		if r.Header.Get("X-KSC-Session") != "fresh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	expectSucceeded(t, client.KSCAuth(ctx))
+	_, err := client.Call(ctx, "Session.Ping", nil, nil)
+	expectSucceeded(t, err)
+	expectEqual(t, 2, sessions)
+	expectEqual(t, "fresh", client.SessionToken())
+
+	// a method changing server state is not retried after the session is restarted
+	sessions = 0
+	expectSucceeded(t, client.KSCAuth(ctx))
+	_, err = client.Call(ctx, "HostGroup.RemoveHost", map[string]string{"strHostName": "host"}, nil)
+	var status *kaspersky.StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 status error, got %v", err)
+	}
+	expectEqual(t, 1, removed)
+	expectEqual(t, 2, sessions)
+	expectEqual(t, "fresh", client.SessionToken())
+}
@@ -86,3 +86,56 @@ func (ca *ChunkAccessor) GetItemsChunk(ctx context.Context, params ItemsChunkPar
 	raw, err := ca.client.Do(ctx, request, &result)
 	return raw, err
 }
+
+// ItemsChunk struct using in ChunkAccessor.GetItemsChunk
+type ItemsChunk struct {
+	PChunk *ItemsChunkArray `json:"pChunk,omitempty"`
+	// PxgRetVal actual number of returned elements (less or equal to nCount)
+	PxgRetVal int64 `json:"PxgRetVal"`
+}
+
+type ItemsChunkArray struct {
+	Items []ItemsChunkValue `json:"KLCSP_ITERATOR_ARRAY"`
+}
+
+type ItemsChunkValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// DefaultChunkSize number of result-set elements acquired by one ChunkAccessor.GetItemsChunk call in ChunkAccessor.ForEachItem.
+const DefaultChunkSize int64 = 500
+
+// ForEachItem Iterates over all result-set elements page by page and calls fn for raw value of each element.
+//
+// nChunkSize is number of elements acquired by one ChunkAccessor.GetItemsChunk call, DefaultChunkSize is used if it is not positive.
+// Iteration stops on the first error returned by fn. Result-set is not released.
+func (ca *ChunkAccessor) ForEachItem(ctx context.Context, accessor string, nChunkSize int64, fn func(item json.RawMessage) error) error {
+	if nChunkSize <= 0 {
+		nChunkSize = DefaultChunkSize
+	}
+
+	count, _, err := ca.GetItemsCount(ctx, accessor)
+	if err != nil {
+		return err
+	}
+
+	for start := int64(0); start < count.Int; start += nChunkSize {
+		chunk := new(ItemsChunk)
+		_, err := ca.GetItemsChunk(ctx, ItemsChunkParams{StrAccessor: accessor, NStart: start, NCount: nChunkSize}, chunk)
+		if err != nil {
+			return err
+		}
+
+		if chunk.PChunk == nil || len(chunk.PChunk.Items) == 0 {
+			break
+		}
+
+		for _, item := range chunk.PChunk.Items {
+			if err := fn(item.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -108,3 +108,47 @@ func (ep *EventProcessing) CancelDelete(ctx context.Context, params interface{})
 	raw, err := ep.client.Do(ctx, request, nil)
 	return raw, err
 }
+
+type eventRecords struct {
+	PParamsEvents struct {
+		Events []Params `json:"KLEVP_EVENT_RANGE_ARRAY"`
+	} `json:"pParamsEvents"`
+}
+
+// ForEachEvent Iterates over all events of the result-set page by page and calls fn for each event.
+//
+// nPageSize is number of events acquired by one EventProcessing.GetRecordRange call, DefaultChunkSize is used if it is not positive.
+// Iteration stops on the first error returned by fn. Result-set is not released.
+func (ep *EventProcessing) ForEachEvent(ctx context.Context, strIteratorId string, nPageSize int64, fn func(event Params) error) error {
+	if nPageSize <= 0 {
+		nPageSize = DefaultChunkSize
+	}
+
+	count, _, err := ep.GetRecordCount(ctx, strIteratorId)
+	if err != nil {
+		return err
+	}
+
+	for start := int64(0); start < count.Int; start += nPageSize {
+		raw, err := ep.GetRecordRange(ctx, strIteratorId, start, start+nPageSize)
+		if err != nil {
+			return err
+		}
+
+		records := new(eventRecords)
+		if err := json.Unmarshal(raw, records); err != nil {
+			return err
+		}
+
+		if len(records.PParamsEvents.Events) == 0 {
+			break
+		}
+
+		for _, event := range records.PParamsEvents.Events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
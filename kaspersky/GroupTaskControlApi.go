@@ -96,6 +96,18 @@ func (gtca *GroupTaskControlApi) ExportTask(ctx context.Context, wstrTaskId stri
 	return pxgValStr, raw, err
 }
 
+// ExportTaskBlob Gets specific task by its identifier and save data to memory chunk.
+// Unlike GroupTaskControlApi.ExportTask returns decoded blob.
+func (gtca *GroupTaskControlApi) ExportTaskBlob(ctx context.Context, wstrTaskId string) (*PxgValBinary, []byte, error) {
+	params := struct {
+		WstrTaskID string `json:"wstrTaskId"`
+	}{wstrTaskId}
+
+	pxgValBinary := new(PxgValBinary)
+	raw, err := gtca.client.PostInOut(ctx, "/api/v1.0/GroupTaskControlApi.ExportTask", params, pxgValBinary)
+	return pxgValBinary, raw, err
+}
+
 // TaskDescribe struct
 type TaskDescribe struct {
 	TaskValue *TaskValue `json:"PxgRetVal,omitempty"`
@@ -241,6 +253,24 @@ func (gtca *GroupTaskControlApi) RestoreTaskFromRevision(ctx context.Context, nO
 	return taskDescribe, raw, err
 }
 
+// ImportTaskParams struct using in GroupTaskControlApi.ImportTask
+type ImportTaskParams struct {
+	// PBlob task blob exported by GroupTaskControlApi.ExportTaskBlob
+	PBlob Binary `json:"pBlob"`
+
+	// PExtraData additional import data, e.g. "PRTS_TASK_GROUPID" - id of the group to import group task into
+	PExtraData Params `json:"pExtraData,omitempty"`
+}
+
+// ImportedTask result of GroupTaskControlApi.ImportTask
+type ImportedTask struct {
+	// WstrID import operation id to pass into GroupTaskControlApi.CommitImportedTask
+	WstrID string `json:"wstrId"`
+
+	// PCommitInfo import restrictions to be analyzed before committing the task
+	PCommitInfo Params `json:"pCommitInfo,omitempty"`
+}
+
 // ImportTask Prepares task import.
 //
 // Prepares task import operation. This method does not perform actual import of task: instead, it prepares import operation
@@ -59,7 +59,7 @@ type HWInvObject struct {
 	Created      DateTime `json:"Created,omitempty"`
 	LastVisible  DateTime `json:"LastVisible,omitempty"`
 	IsWrittenOff bool     `json:"IsWrittenOff,omitempty"`
-	WriteOffDate bool     `json:"WriteOffDate,omitempty"`
+	WriteOffDate DateTime `json:"WriteOffDate,omitempty"`
 	InvNum       string   `json:"InvNum,omitempty"`
 	UserName     string   `json:"UserName,omitempty"`
 	Placement    string   `json:"Placement,omitempty"`
@@ -309,6 +309,19 @@ func (hw *HWInvStorage) GetHWInvObject(ctx context.Context, nObjId int64) ([]byt
 	return raw, err
 }
 
+// HWInvObject Get hardware inventory object. Unlike HWInvStorage.GetHWInvObject returns decoded object.
+func (hw *HWInvStorage) HWInvObject(ctx context.Context, nObjId int64) (*HWInvObject, []byte, error) {
+	params := struct {
+		NObjID int64 `json:"nObjId"`
+	}{nObjId}
+
+	out := &struct {
+		Object HWInvObject `json:"PxgRetVal"`
+	}{}
+	raw, err := hw.client.PostInOut(ctx, "/api/v1.0/HWInvStorage.GetHWInvObject", params, out)
+	return &out.Object, raw, err
+}
+
 // HWInvStorageResponse struct
 type HWInvStorageResponse struct {
 	// PChunk Data chunk
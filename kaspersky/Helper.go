@@ -26,9 +26,11 @@ package kaspersky
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -67,6 +69,44 @@ type PxgValArrayOfString struct {
 	Array []string `json:"PxgRetVal"`
 }
 
+//	PxgValBinary struct
+type PxgValBinary struct {
+	Binary Binary `json:"PxgRetVal"`
+}
+
+// Binary KSC paramBinary value.
+//
+// Decodes both plain base64 string and {"type": "binary", "value": "<base64>"} forms,
+// encodes as {"type": "binary", "value": "<base64>"}.
+type Binary []byte
+
+func (b *Binary) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		var typed struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(data, &typed); err != nil {
+			return err
+		}
+		encoded = typed.Value
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func (b Binary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}{"binary", base64.StdEncoding.EncodeToString(b)})
+}
+
 //	RequestID struct
 type RequestID struct {
 	StrRequestID string `json:"strRequestId,omitempty"`
@@ -101,7 +141,26 @@ type Error struct {
 }
 
 func (e Error) Error() string {
-	return fmt.Sprintf(`Code: %d, File: %s, Line: %d, Module: %s, Message: %s`, *e.Code, *e.File, *e.Line, *e.Module, *e.Message)
+	var parts []string
+	if e.Code != nil {
+		parts = append(parts, fmt.Sprintf("Code: %d", *e.Code))
+	}
+	if e.File != nil {
+		parts = append(parts, "File: "+*e.File)
+	}
+	if e.Line != nil {
+		parts = append(parts, fmt.Sprintf("Line: %d", *e.Line))
+	}
+	if e.Module != nil {
+		parts = append(parts, "Module: "+*e.Module)
+	}
+	if e.Message != nil {
+		parts = append(parts, "Message: "+*e.Message)
+	}
+	if len(parts) == 0 {
+		return "PxgError"
+	}
+	return strings.Join(parts, ", ")
 }
 
 //	AsyncAccessor struct
@@ -219,6 +278,15 @@ type DateTime struct {
 	Value *string `json:"value"`
 }
 
+// Time returns datetime value or zero time if value is absent or malformed.
+func (d *DateTime) Time() time.Time {
+	if d == nil || d.Value == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339, *d.Value)
+	return t
+}
+
 type Size struct {
 	Type  *string `json:"type,omitempty"`
 	Value *int64  `json:"value,omitempty"`
@@ -0,0 +1,311 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BulkOptions struct using in HostGroup bulk operations
+type BulkOptions struct {
+	// BatchSize number of hosts passed to one server call, 100 by default
+	BatchSize int
+
+	// Concurrency max number of batches processed in parallel, 4 by default
+	Concurrency int
+
+	// DryRun only reports hosts that would be changed, nothing is modified on the server
+	DryRun bool
+
+	// MaxHosts refuses to run if filter matches more hosts than MaxHosts. 0 means no limit
+	MaxHosts int
+}
+
+func (o BulkOptions) withDefaults() BulkOptions {
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 4
+	}
+	return o
+}
+
+// BulkHost host attributes returned by HostGroup.FindBulkHosts
+type BulkHost struct {
+	// HostName host name (unique server-generated string)
+	HostName string `json:"KLHST_WKS_HOSTNAME"`
+
+	// DisplayName host display name
+	DisplayName string `json:"KLHST_WKS_DN"`
+
+	// GroupID id of administration group where host is located
+	GroupID int64 `json:"KLHST_WKS_GROUPID"`
+}
+
+// BulkHostFailure describes host which failed to be processed
+type BulkHostFailure struct {
+	Host BulkHost
+	Err  error
+}
+
+// BulkResult result of HostGroup bulk operation
+type BulkResult struct {
+	// DryRun true if nothing was modified on the server
+	DryRun bool
+
+	// Matched hosts that satisfy the filter
+	Matched []BulkHost
+
+	// Changed hosts that were changed (or would be changed in dry-run mode)
+	Changed []BulkHost
+
+	// Skipped hosts that need no change, e.g. already located in the destination group
+	Skipped []BulkHost
+
+	// Failed hosts that failed to be processed
+	Failed []BulkHostFailure
+}
+
+// Err returns error summarizing failed hosts or nil
+func (r *BulkResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d hosts failed, first error: %s: %v", len(r.Failed), len(r.Matched),
+		r.Failed[0].Host.HostName, r.Failed[0].Err)
+}
+
+// FindBulkHosts Finds all hosts that satisfy conditions from filter string wstrFilter.
+//
+// Result-set is paged out of ChunkAccessor and released.
+func (hg *HostGroup) FindBulkHosts(ctx context.Context, wstrFilter string) ([]BulkHost, error) {
+	accessor, _, err := hg.FindHosts(ctx, HGParams{
+		WstrFilter:        wstrFilter,
+		VecFieldsToReturn: []string{"KLHST_WKS_HOSTNAME", "KLHST_WKS_DN", "KLHST_WKS_GROUPID"},
+		PParams:           PParams{KlsrvhSlaveRecDepth: 0, KlgrpFindFromCurVsOnly: true},
+		LMaxLifeTime:      600,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer hg.client.ChunkAccessor.Release(ctx, accessor.StrAccessor)
+
+	var hosts []BulkHost
+	err = hg.client.ChunkAccessor.ForEachItem(ctx, accessor.StrAccessor, 0, func(item json.RawMessage) error {
+		var host BulkHost
+		if err := json.Unmarshal(item, &host); err != nil {
+			return err
+		}
+		hosts = append(hosts, host)
+		return nil
+	})
+	return hosts, err
+}
+
+// BulkMoveHostsToGroup Moves all hosts that satisfy conditions from filter string wstrFilter into group nGroup.
+//
+// Hosts are moved by HostGroup.MoveHostsToGroup in batches, hosts already located in nGroup are skipped.
+// If a batch fails, hosts of the batch which are not yet in nGroup are moved one by one to find out failed hosts.
+func (hg *HostGroup) BulkMoveHostsToGroup(ctx context.Context, wstrFilter string, nGroup int64, opts BulkOptions) (*BulkResult, error) {
+	return hg.bulk(ctx, wstrFilter, opts, false,
+		func(host BulkHost) bool { return host.GroupID != nGroup },
+		func(ctx context.Context, names []string) error {
+			_, err := hg.MoveHostsToGroup(ctx, HostsToGroupParams{NGroup: nGroup, PHostNames: names})
+			return err
+		})
+}
+
+// BulkMoveHostsFromGroupToGroup Moves hosts located in root of group nSrcGroupId into group nDstGroupId.
+//
+// Unlike HostGroup.MoveHostsFromGroupToGroup hosts are moved in batches with per-host failures and dry-run support.
+func (hg *HostGroup) BulkMoveHostsFromGroupToGroup(ctx context.Context, nSrcGroupId, nDstGroupId int64, opts BulkOptions) (*BulkResult, error) {
+	return hg.BulkMoveHostsToGroup(ctx, fmt.Sprintf("(KLHST_WKS_GROUPID = %d)", nSrcGroupId), nDstGroupId, opts)
+}
+
+// BulkRemoveHosts Removes all hosts that satisfy conditions from filter string wstrFilter.
+//
+// Hosts are removed by HostGroup.RemoveHosts in batches, see HostGroup.RemoveHosts for bForceDestroy meaning.
+// If a batch fails, hosts of the batch which still exist are removed one by one to find out failed hosts.
+func (hg *HostGroup) BulkRemoveHosts(ctx context.Context, wstrFilter string, bForceDestroy bool, opts BulkOptions) (*BulkResult, error) {
+	return hg.bulk(ctx, wstrFilter, opts, true,
+		func(BulkHost) bool { return true },
+		func(ctx context.Context, names []string) error {
+			_, err := hg.RemoveHosts(ctx, RemoveHostsParams{PHostNames: names, BForceDestroy: bForceDestroy})
+			return err
+		})
+}
+
+// bulk applies change to hosts matching wstrFilter which need it.
+// removing means that hosts disappear once the change is applied.
+func (hg *HostGroup) bulk(ctx context.Context, wstrFilter string, opts BulkOptions, removing bool, needed func(BulkHost) bool,
+	apply func(ctx context.Context, names []string) error) (*BulkResult, error) {
+	opts = opts.withDefaults()
+
+	hosts, err := hg.FindBulkHosts(ctx, wstrFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BulkResult{DryRun: opts.DryRun, Matched: hosts}
+	if opts.MaxHosts > 0 && len(hosts) > opts.MaxHosts {
+		return result, fmt.Errorf("filter matches %d hosts, more than allowed %d", len(hosts), opts.MaxHosts)
+	}
+
+	var pending []BulkHost
+	for _, host := range hosts {
+		if needed(host) {
+			pending = append(pending, host)
+		} else {
+			result.Skipped = append(result.Skipped, host)
+		}
+	}
+
+	if opts.DryRun {
+		result.Changed = pending
+		return result, nil
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, opts.Concurrency)
+	)
+
+	report := func(changed []BulkHost, failed []BulkHostFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Changed = append(result.Changed, changed...)
+		result.Failed = append(result.Failed, failed...)
+	}
+
+	for start := 0; start < len(pending); start += opts.BatchSize {
+		end := start + opts.BatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		select {
+		case <-ctx.Done():
+			failed := make([]BulkHostFailure, 0, len(pending)-start)
+			for _, host := range pending[start:] {
+				failed = append(failed, BulkHostFailure{Host: host, Err: ctx.Err()})
+			}
+			report(nil, failed)
+			wg.Wait()
+			return result, ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			report(hg.applyBatch(ctx, batch, removing, needed, apply))
+		}()
+	}
+	wg.Wait()
+
+	return result, nil
+}
+
+// applyBatch applies change to the whole batch and falls back to host by host processing if batch fails.
+//
+// A failed batch may be applied partially, so hosts are re-read first and only those still needing the change
+// are retried.
+func (hg *HostGroup) applyBatch(ctx context.Context, batch []BulkHost, removing bool, needed func(BulkHost) bool,
+	apply func(ctx context.Context, names []string) error) ([]BulkHost, []BulkHostFailure) {
+	names := make([]string, 0, len(batch))
+	for _, host := range batch {
+		names = append(names, host.HostName)
+	}
+
+	err := apply(ctx, names)
+	if err == nil {
+		return batch, nil
+	}
+
+	var changed []BulkHost
+	retry := batch
+	if current, rerr := hg.findBulkHostsByName(ctx, names); rerr == nil {
+		retry = nil
+		for _, host := range batch {
+			now, found := current[host.HostName]
+			if (!found && removing) || (found && !needed(now)) {
+				changed = append(changed, host)
+			} else {
+				retry = append(retry, host)
+			}
+		}
+	}
+
+	if len(batch) == 1 && len(retry) == 1 {
+		return nil, []BulkHostFailure{{Host: batch[0], Err: err}}
+	}
+
+	var failed []BulkHostFailure
+	for _, host := range retry {
+		if err := apply(ctx, []string{host.HostName}); err != nil {
+			failed = append(failed, BulkHostFailure{Host: host, Err: err})
+		} else {
+			changed = append(changed, host)
+		}
+	}
+	return changed, failed
+}
+
+var filterValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// QuoteFilterValue quotes string value of search filter escaping quotes and backslashes, see Search filter syntax,
+// e.g. "(KLHST_WKS_DN = " + QuoteFilterValue(name) + ")".
+func QuoteFilterValue(value string) string {
+	return `"` + filterValueEscaper.Replace(value) + `"`
+}
+
+// findBulkHostsByName returns current state of hosts by their names, missing hosts do not exist anymore.
+func (hg *HostGroup) findBulkHostsByName(ctx context.Context, names []string) (map[string]BulkHost, error) {
+	var filter strings.Builder
+	filter.WriteString("(|")
+	for _, name := range names {
+		filter.WriteString("(KLHST_WKS_HOSTNAME = " + QuoteFilterValue(name) + ")")
+	}
+	filter.WriteString(")")
+
+	hosts, err := hg.FindBulkHosts(ctx, filter.String())
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]BulkHost, len(hosts))
+	for _, host := range hosts {
+		current[host.HostName] = host
+	}
+	return current, nil
+}
@@ -0,0 +1,126 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestBulkMoveHostsToGroup(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	handler.HandleFunc("/api/v1.0/HostGroup.FindHosts", HandlerFuncOk(`{"strAccessor": "acc", "PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsCount", HandlerFuncOk(`{"PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.Release", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsChunk", HandlerFuncOk(`{
+		"pChunk": {"KLCSP_ITERATOR_ARRAY": [
+			{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "h1", "KLHST_WKS_DN": "PC-1", "KLHST_WKS_GROUPID": 1}},
+			{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "h2", "KLHST_WKS_DN": "PC-2", "KLHST_WKS_GROUPID": 5}},
+			{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "h3", "KLHST_WKS_DN": "PC-3", "KLHST_WKS_GROUPID": 1}}
+		]},
+		"PxgRetVal": 3}`))
+
+	var moved []string
+	handler.HandleFunc("/api/v1.0/HostGroup.MoveHostsToGroup", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.HostsToGroupParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		for _, name := range in.PHostNames {
+			if name == "h3" {
+				w.Write([]byte(`{"PxgError": {"code": 1183, "file": "", "line": 0, "module": "KLSTD", "message": "Access denied"}}`))
+				return
+			}
+		}
+		moved = append(moved, in.PHostNames...)
+		w.Write([]byte(`{}`))
+	})
+
+	result, err := client.HostGroup.BulkMoveHostsToGroup(ctx, "(KLHST_WKS_DN=\"PC-*\")", 5,
+		kaspersky.BulkOptions{DryRun: true})
+	expectSucceeded(t, err)
+	expectEqual(t, 3, len(result.Matched))
+	expectEqual(t, 2, len(result.Changed))
+	expectEqual(t, "h2", result.Skipped[0].HostName)
+	expectEqual(t, 0, len(moved))
+
+	_, err = client.HostGroup.BulkMoveHostsToGroup(ctx, "", 5, kaspersky.BulkOptions{MaxHosts: 2})
+	if err == nil {
+		t.Fatal("expected MaxHosts error")
+	}
+
+	result, err = client.HostGroup.BulkMoveHostsToGroup(ctx, "", 5, kaspersky.BulkOptions{BatchSize: 2, Concurrency: 1})
+	expectSucceeded(t, err)
+	sort.Strings(moved)
+	expectEqual(t, []string{"h1"}, moved)
+	expectEqual(t, 1, len(result.Failed))
+	expectEqual(t, "h3", result.Failed[0].Host.HostName)
+	if result.Err() == nil {
+		t.Fatal("expected summary error")
+	}
+}
+
+func TestBulkRemoveHostsPartialBatch(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	existing := map[string]bool{"h1": true, "h2": true, "h3": true}
+	var filter string
+	handler.HandleFunc("/api/v1.0/HostGroup.FindHosts", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.HGParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		filter = in.WstrFilter
+		w.Write([]byte(`{"strAccessor": "acc", "PxgRetVal": 3}`))
+	})
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsCount", HandlerFuncOk(`{"PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.Release", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/ChunkAccessor.GetItemsChunk", func(w http.ResponseWriter, r *http.Request) {
+		var items []string
+		for _, name := range []string{"h1", "h2", "h3"} {
+			if existing[name] && (filter == "" || strings.Contains(filter, `"`+name+`"`)) {
+				items = append(items, `{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "`+name+`", "KLHST_WKS_GROUPID": 1}}`)
+			}
+		}
+		w.Write([]byte(`{"pChunk": {"KLCSP_ITERATOR_ARRAY": [` + strings.Join(items, ",") + `]}}`))
+	})
+
+	// The batch removes h1 and h2 and fails on h3.
+	var calls [][]string
+	handler.HandleFunc("/api/v1.0/HostGroup.RemoveHosts", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.RemoveHostsParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		calls = append(calls, in.PHostNames)
+		for _, name := range in.PHostNames {
+			if name == "h3" {
+				w.Write([]byte(`{"PxgError": {"code": 1183, "file": "", "line": 0, "module": "KLSTD", "message": "Access denied"}}`))
+				return
+			}
+			delete(existing, name)
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	result, err := client.HostGroup.BulkRemoveHosts(ctx, "", false, kaspersky.BulkOptions{})
+	expectSucceeded(t, err)
+	expectEqual(t, [][]string{{"h1", "h2", "h3"}, {"h3"}}, calls)
+	expectEqual(t, 2, len(result.Changed))
+	expectEqual(t, 1, len(result.Failed))
+	expectEqual(t, "h3", result.Failed[0].Host.HostName)
+}
+
+func TestQuoteFilterValue(t *testing.T) {
+	expectEqual(t, `"PC-001"`, kaspersky.QuoteFilterValue("PC-001"))
+	expectEqual(t, `"a\"b\\c"`, kaspersky.QuoteFilterValue(`a"b\c`))
+	// unlike Go quoting non-ASCII and control characters are kept as is
+	expectEqual(t, "\"ПК-1\t\"", kaspersky.QuoteFilterValue("ПК-1\t"))
+}
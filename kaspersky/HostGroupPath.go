@@ -0,0 +1,381 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GroupPathSeparator separates group names in administration group paths,
+// e.g. "Managed devices/Branch/Kiosks".
+const GroupPathSeparator = "/"
+
+// ErrGroupNotFound is returned by HostGroup.ResolveGroupPath if some group of the path does not exist.
+var ErrGroupNotFound = errors.New("group not found")
+
+// groupPathCache keeps group path <-> id mappings for the client's lifetime.
+//
+// Paths are looked up case-insensitively, byID keeps them with group names as returned by the server.
+type groupPathCache struct {
+	mu     sync.RWMutex
+	byPath map[string]int64
+	byID   map[int64]string
+	rootID *int64
+
+	// mkdir serializes group creation so that concurrent EnsureGroupPath
+	// calls for the same path do not race on the server.
+	mkdir sync.Mutex
+}
+
+// lookupPath returns id of group path and the path as stored.
+func (gc *groupPathCache) lookupPath(path string) (int64, string, bool) {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	id, ok := gc.byPath[strings.ToLower(path)]
+	return id, gc.byID[id], ok
+}
+
+func (gc *groupPathCache) lookupID(id int64) (string, bool) {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+	path, ok := gc.byID[id]
+	return path, ok
+}
+
+func (gc *groupPathCache) store(path string, id int64) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if gc.byPath == nil {
+		gc.byPath = make(map[string]int64)
+		gc.byID = make(map[int64]string)
+	}
+	gc.byPath[strings.ToLower(path)] = id
+	gc.byID[id] = path
+}
+
+// forget evicts group id and all its cached subgroups.
+func (gc *groupPathCache) forget(id int64) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	path, ok := gc.byID[id]
+	if !ok {
+		return
+	}
+
+	prefix := strings.ToLower(path)
+	for p, childID := range gc.byPath {
+		if p == prefix || strings.HasPrefix(p, prefix+GroupPathSeparator) {
+			delete(gc.byPath, p)
+			delete(gc.byID, childID)
+		}
+	}
+	delete(gc.byID, id)
+}
+
+// SubgroupInfo is an element of the HostGroup.GetSubgroups result
+type SubgroupInfo struct {
+	ID     int64          `json:"id"`
+	Name   string         `json:"name"`
+	Groups []SubgroupItem `json:"groups,omitempty"`
+}
+
+type SubgroupItem struct {
+	Type  string       `json:"type"`
+	Value SubgroupInfo `json:"value"`
+}
+
+type subgroups struct {
+	Subgroups []SubgroupItem `json:"PxgRetVal"`
+}
+
+// GetSubgroupsList Acquire administration group subgroups tree as parsed SubgroupInfo list.
+func (hg *HostGroup) GetSubgroupsList(ctx context.Context, nGroupId int64, nDepth int64) ([]SubgroupInfo, []byte, error) {
+	postData := []byte(fmt.Sprintf(`{"nParent": %d, "nDepth": %d }`, nGroupId, nDepth))
+	out := new(subgroups)
+	raw, err := hg.client.PostInOut(ctx, "/api/v1.0/HostGroup.GetSubgroups", postData, out)
+	result := make([]SubgroupInfo, 0, len(out.Subgroups))
+	for _, item := range out.Subgroups {
+		result = append(result, item.Value)
+	}
+	return result, raw, err
+}
+
+// SplitGroupPath splits administration group path into non-empty group names.
+func SplitGroupPath(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, GroupPathSeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// groupsRoot returns id and name of the predefined "Managed devices" group.
+func (hg *HostGroup) groupsRoot(ctx context.Context) (int64, string, error) {
+	gc := &hg.client.groupPaths
+	gc.mu.RLock()
+	rootID := gc.rootID
+	gc.mu.RUnlock()
+
+	if rootID != nil {
+		if name, ok := gc.lookupID(*rootID); ok {
+			return *rootID, name, nil
+		}
+	}
+
+	root, _, err := hg.GroupIdGroups(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+
+	info, err := hg.groupNameAndParent(ctx, root.Int)
+	if err != nil {
+		return 0, "", err
+	}
+
+	gc.mu.Lock()
+	gc.rootID = Int64(root.Int)
+	gc.mu.Unlock()
+	gc.store(info.Name, root.Int)
+	return root.Int, info.Name, nil
+}
+
+type groupNameParent struct {
+	Name     string `json:"name"`
+	ParentID int64  `json:"parentId"`
+}
+
+func (hg *HostGroup) groupNameAndParent(ctx context.Context, nGroupId int64) (*groupNameParent, error) {
+	params := struct {
+		NGroupID       int64    `json:"nGroupId"`
+		PArrAttributes []string `json:"pArrAttributes"`
+	}{nGroupId, []string{"name", "parentId"}}
+
+	out := &struct {
+		Info groupNameParent `json:"PxgRetVal"`
+	}{}
+
+	_, err := hg.client.PostInOut(ctx, "/api/v1.0/HostGroup.GetGroupInfoEx", params, out)
+	if err != nil {
+		return nil, err
+	}
+	return &out.Info, nil
+}
+
+// ResolveGroupPath returns id of administration group by its full path, e.g. "Managed devices/Branch/Kiosks".
+//
+// The first path element is the name of the predefined "Managed devices" group.
+// Resolved ids are cached for the client's lifetime.
+func (hg *HostGroup) ResolveGroupPath(ctx context.Context, path string) (int64, error) {
+	return hg.resolveGroupPath(ctx, path, false)
+}
+
+// EnsureGroupPath returns id of administration group by its full path, creating missing intermediate groups
+// like "mkdir -p", e.g. EnsureGroupPath(ctx, "Managed devices/Branch/Kiosks").
+//
+// The first path element is the name of the predefined "Managed devices" group.
+// Resolved ids are cached for the client's lifetime.
+// Concurrent calls are safe: HostGroup.AddGroup returns id of existing group if it was created meanwhile.
+func (hg *HostGroup) EnsureGroupPath(ctx context.Context, path string) (int64, error) {
+	return hg.resolveGroupPath(ctx, path, true)
+}
+
+func (hg *HostGroup) resolveGroupPath(ctx context.Context, path string, create bool) (int64, error) {
+	names := SplitGroupPath(path)
+	if len(names) == 0 {
+		return 0, fmt.Errorf("empty group path %q", path)
+	}
+
+	full := strings.Join(names, GroupPathSeparator)
+	gc := &hg.client.groupPaths
+	if id, _, ok := gc.lookupPath(full); ok {
+		return id, nil
+	}
+
+	rootID, rootName, err := hg.groupsRoot(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if !strings.EqualFold(names[0], rootName) {
+		return 0, fmt.Errorf("group path %q must start with %q", path, rootName)
+	}
+
+	if create {
+		gc.mkdir.Lock()
+		defer gc.mkdir.Unlock()
+	}
+
+	// parentPath keeps names as returned by the server, names of the path may differ in case
+	parentID, parentPath := rootID, rootName
+	for _, name := range names[1:] {
+		current := parentPath + GroupPathSeparator + name
+
+		if id, path, ok := gc.lookupPath(current); ok {
+			parentID, parentPath = id, path
+			continue
+		}
+
+		id, childName, found, err := hg.findSubgroup(ctx, parentID, parentPath, name)
+		if err != nil {
+			return 0, err
+		}
+		current = parentPath + GroupPathSeparator + childName
+
+		if !found {
+			if !create {
+				return 0, fmt.Errorf("%q: %w", current, ErrGroupNotFound)
+			}
+
+			group, _, err := hg.AddGroup(ctx, AddGroupParams{PInfo: &GroupPInfo{
+				Name:     String(name),
+				ParentID: Int64(parentID),
+			}})
+			if err != nil {
+				return 0, fmt.Errorf("create group %q: %w", current, err)
+			}
+			id = group.Int
+			gc.store(current, id)
+		}
+		parentID, parentPath = id, current
+	}
+
+	return parentID, nil
+}
+
+// findSubgroup looks up direct subgroup by name case-insensitively and caches all direct subgroups of nParent.
+// The subgroup name is returned as stored on the server, name itself if the subgroup is not found.
+func (hg *HostGroup) findSubgroup(ctx context.Context, nParent int64, parentPath, name string) (int64, string, bool, error) {
+	children, _, err := hg.GetSubgroupsList(ctx, nParent, 1)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	gc := &hg.client.groupPaths
+	id, childName, found := int64(0), name, false
+	for _, child := range children {
+		gc.store(parentPath+GroupPathSeparator+child.Name, child.ID)
+		if !found && strings.EqualFold(child.Name, name) {
+			id, childName, found = child.ID, child.Name, true
+		}
+	}
+	return id, childName, found, nil
+}
+
+// GroupPath returns full path of administration group by its id, e.g. "Managed devices/Branch/Kiosks".
+//
+// Resolved paths are cached for the client's lifetime.
+func (hg *HostGroup) GroupPath(ctx context.Context, nGroupId int64) (string, error) {
+	gc := &hg.client.groupPaths
+	if path, ok := gc.lookupID(nGroupId); ok {
+		return path, nil
+	}
+
+	rootID, rootName, err := hg.groupsRoot(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	visited := map[int64]bool{}
+	id := nGroupId
+	for id != rootID {
+		if path, ok := gc.lookupID(id); ok {
+			names = append(names, path)
+			break
+		}
+
+		if visited[id] {
+			return "", fmt.Errorf("group %d: cycle in group hierarchy", nGroupId)
+		}
+		visited[id] = true
+
+		info, err := hg.groupNameAndParent(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		names = append(names, info.Name)
+
+		if info.ParentID <= 0 || info.ParentID == id {
+			break
+		}
+		id = info.ParentID
+	}
+
+	if id == rootID {
+		names = append(names, rootName)
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+
+	path := strings.Join(names, GroupPathSeparator)
+	gc.store(path, nGroupId)
+	return path, nil
+}
+
+// ForgetGroupPath removes administration group and all its subgroups from the client's path cache,
+// e.g. after the group has been renamed, moved or removed.
+func (hg *HostGroup) ForgetGroupPath(nGroupId int64) {
+	hg.client.groupPaths.forget(nGroupId)
+}
+
+// WalkGroups calls fn for administration group nGroupId and all its subgroups, parents before children.
+//
+// Group paths are resolved as in HostGroup.GroupPath and cached for the client's lifetime.
+// Walking stops on the first error returned by fn.
+func (hg *HostGroup) WalkGroups(ctx context.Context, nGroupId int64, fn func(nGroupId int64, path string) error) error {
+	path, err := hg.GroupPath(ctx, nGroupId)
+	if err != nil {
+		return err
+	}
+	return hg.walkGroups(ctx, nGroupId, path, fn)
+}
+
+func (hg *HostGroup) walkGroups(ctx context.Context, nGroupId int64, path string, fn func(int64, string) error) error {
+	if err := fn(nGroupId, path); err != nil {
+		return err
+	}
+
+	children, _, err := hg.GetSubgroupsList(ctx, nGroupId, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		childPath := path + GroupPathSeparator + child.Name
+		hg.client.groupPaths.store(childPath, child.ID)
+		if err := hg.walkGroups(ctx, child.ID, childPath, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestEnsureGroupPath(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var addGroupCalls int32
+	branchName := "Branch"
+	handler.HandleFunc("/api/v1.0/HostGroup.GroupIdGroups", HandlerFuncOk(`{"PxgRetVal": 1}`))
+	handler.HandleFunc("/api/v1.0/HostGroup.GetGroupInfoEx", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NGroupID int64 `json:"nGroupId"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch in.NGroupID {
+		case 1:
+			w.Write([]byte(`{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`))
+		case 2:
+			w.Write([]byte(`{"PxgRetVal": {"name": "` + branchName + `", "parentId": 1}}`))
+		case 3:
+			w.Write([]byte(`{"PxgRetVal": {"name": "Kiosks", "parentId": 2}}`))
+		}
+	})
+	handler.HandleFunc("/api/v1.0/HostGroup.GetSubgroups", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NParent int64 `json:"nParent"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NParent == 1 {
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Branch"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": []}`))
+	})
+	handler.HandleFunc("/api/v1.0/HostGroup.AddGroup", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&addGroupCalls, 1)
+		w.Write([]byte(`{"PxgRetVal": 3}`))
+	})
+
+	// Paths are resolved case-insensitively, group names are cached as returned by the server.
+	for _, path := range []string{"managed devices/BRANCH/Kiosks", "Managed devices/branch/kiosks"} {
+		id, err := client.HostGroup.EnsureGroupPath(ctx, path)
+		expectSucceeded(t, err)
+		expectEqual(t, int64(3), id)
+	}
+	expectEqual(t, int32(1), atomic.LoadInt32(&addGroupCalls))
+
+	path, err := client.HostGroup.GroupPath(ctx, 3)
+	expectSucceeded(t, err)
+	expectEqual(t, "Managed devices/Branch/Kiosks", path)
+
+	client.HostGroup.ForgetGroupPath(2)
+	path, err = client.HostGroup.GroupPath(ctx, 2)
+	expectSucceeded(t, err)
+	expectEqual(t, "Managed devices/Branch", path)
+
+	// Subgroups of the forgotten group are resolved again after the group is renamed.
+	branchName = "Office"
+	client.HostGroup.ForgetGroupPath(2)
+	path, err = client.HostGroup.GroupPath(ctx, 3)
+	expectSucceeded(t, err)
+	expectEqual(t, "Managed devices/Office/Kiosks", path)
+
+	_, err = client.HostGroup.ResolveGroupPath(ctx, "Managed devices/Missing")
+	if !errors.Is(err, kaspersky.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound, got %v", err)
+	}
+}
@@ -103,6 +103,7 @@ type HMoveRule struct {
 
 // HMRule struct
 type HMRule struct {
+	KlhstMrID         int64           `json:"KLHST_MR_ID,omitempty"`
 	KLHSTMRAutoDelete bool            `json:"KLHST_MR_AutoDelete,omitempty"`
 	KLHSTMRCustom     *KLHSTMRCustom  `json:"KLHST_MR_Custom,omitempty"`
 	KlhstMrDN         string          `json:"KLHST_MR_DN,omitempty"`
@@ -0,0 +1,94 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// HostsKeyIterator service for access to the forward iterator of hosts using a license key or of license keys,
+// see LicenseKeys.AcquireKeyHosts and LicenseKeys.EnumKeys.
+type HostsKeyIterator service
+
+// KeyHostsChunk result of HostsKeyIterator.GetNextItemsChunk
+type KeyHostsChunk struct {
+	PChunk *struct {
+		Items []ItemsChunkValue `json:"KLCSP_ITERATOR_ARRAY"`
+	} `json:"pChunk"`
+
+	// BEOF the end of the result-set is reached
+	BEOF bool `json:"bEOF"`
+}
+
+// GetNextItemsChunk Acquire up to nCount hosts from the current position of the iterator
+// and move the position forward.
+func (hki *HostsKeyIterator) GetNextItemsChunk(ctx context.Context, wstrIterator string, nCount int64) (*KeyHostsChunk, []byte, error) {
+	params := struct {
+		WstrIterator string `json:"wstrIterator"`
+		NCount       int64  `json:"nCount"`
+	}{wstrIterator, nCount}
+
+	chunk := new(KeyHostsChunk)
+	raw, err := hki.client.PostInOut(ctx, "/api/v1.0/HostsKeyIterator.GetNextItemsChunk", params, chunk)
+	return chunk, raw, err
+}
+
+// ReleaseIterator Release the iterator and free associated memory.
+func (hki *HostsKeyIterator) ReleaseIterator(ctx context.Context, wstrIterator string) ([]byte, error) {
+	params := struct {
+		WstrIterator string `json:"wstrIterator"`
+	}{wstrIterator}
+	return hki.client.PostInOut(ctx, "/api/v1.0/HostsKeyIterator.ReleaseIterator", params, nil)
+}
+
+// ForEachHost Iterates over all hosts of the iterator chunk by chunk and calls fn for raw attributes of each host.
+//
+// nChunkSize is number of hosts acquired by one HostsKeyIterator.GetNextItemsChunk call, DefaultChunkSize is used
+// if it is not positive. Iteration stops on the first error returned by fn. The iterator is not released.
+func (hki *HostsKeyIterator) ForEachHost(ctx context.Context, wstrIterator string, nChunkSize int64, fn func(host json.RawMessage) error) error {
+	if nChunkSize <= 0 {
+		nChunkSize = DefaultChunkSize
+	}
+
+	for {
+		chunk, _, err := hki.GetNextItemsChunk(ctx, wstrIterator, nChunkSize)
+		if err != nil {
+			return err
+		}
+
+		if chunk.PChunk != nil {
+			for _, item := range chunk.PChunk.Items {
+				if err := fn(item.Value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if chunk.BEOF || chunk.PChunk == nil || len(chunk.PChunk.Items) == 0 {
+			return nil
+		}
+	}
+}
@@ -52,19 +52,16 @@ func (hac *HstAccessControl) AccessCheckToAdmGroup(ctx context.Context,
 }
 
 // AddRole A role can be added only at a main server.
-func (hac *HstAccessControl) AddRole(ctx context.Context, params interface{}) ([]byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
-
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.AddRole", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
+//
+// Returns attributes of the created role including its id.
+func (hac *HstAccessControl) AddRole(ctx context.Context, pRoleData RoleData) (*RoleData, []byte, error) {
+	params := struct {
+		PRoleData RoleData `json:"pRoleData"`
+	}{pRoleData}
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	out := new(roleData)
+	raw, err := hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.AddRole", params, out)
+	return &out.Role, raw, err
 }
 
 // DeleteRole Delete user role.
@@ -203,78 +200,42 @@ type FuncAreas struct {
 	PFuncAreasArray []string `json:"pFuncAreasArray"`
 }
 
-// GetAccessibleFuncAreas Returns accessible functional areas.
+// GetAccessibleFuncAreas Returns functional areas accessible to the current user session for the administration group.
+//
+// If bInvert is true, functional areas which are not accessible are returned.
 func (hac *HstAccessControl) GetAccessibleFuncAreas(ctx context.Context, lGroupId, dwAccessMask int64, szwProduct,
-	szwVersion string, bInvert bool) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"lGroupId" : %d, "dwAccessMask": %d, "szwProduct": "%s", "szwVersion": "%s", 
-	"bInvert" : %v}`,
-		lGroupId, dwAccessMask, szwProduct, szwVersion, bInvert))
-
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetAccessibleFuncAreas",
-		bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
+	szwVersion string, bInvert bool) (*FuncAreas, []byte, error) {
+	params := struct {
+		LGroupID     int64  `json:"lGroupId"`
+		DwAccessMask int64  `json:"dwAccessMask"`
+		SzwProduct   string `json:"szwProduct"`
+		SzwVersion   string `json:"szwVersion"`
+		BInvert      bool   `json:"bInvert"`
+	}{lGroupId, dwAccessMask, szwProduct, szwVersion, bInvert}
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	funcAreas := new(FuncAreas)
+	raw, err := hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.GetAccessibleFuncAreas", params, funcAreas)
+	return funcAreas, raw, err
 }
 
 // GetMappingFuncAreaToPolicies Returns mapping functional area to policies.
-func (hac *HstAccessControl) GetMappingFuncAreaToPolicies(ctx context.Context, szwProduct, szwVersion string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"szwProduct": "%s","szwVersion": "%s"}`, szwProduct, szwVersion))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetMappingFuncAreaToPolicies",
-		bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+func (hac *HstAccessControl) GetMappingFuncAreaToPolicies(ctx context.Context, szwProduct, szwVersion string) (FuncAreaMapping, []byte, error) {
+	return hac.funcAreaMapping(ctx, "/api/v1.0/HstAccessControl.GetMappingFuncAreaToPolicies", szwProduct, szwVersion)
 }
 
 // GetMappingFuncAreaToReports Returns mapping functional area to reports.
-func (hac *HstAccessControl) GetMappingFuncAreaToReports(ctx context.Context, szwProduct, szwVersion string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"szwProduct": "%s","szwVersion": "%s"}`, szwProduct, szwVersion))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetMappingFuncAreaToReports",
-		bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+func (hac *HstAccessControl) GetMappingFuncAreaToReports(ctx context.Context, szwProduct, szwVersion string) (FuncAreaMapping, []byte, error) {
+	return hac.funcAreaMapping(ctx, "/api/v1.0/HstAccessControl.GetMappingFuncAreaToReports", szwProduct, szwVersion)
 }
 
-// GetMappingFuncAreaToSettings Returns mapping functional area to settings.
-func (hac *HstAccessControl) GetMappingFuncAreaToSettings(ctx context.Context, szwProduct, szwVersion string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"szwProduct": "%s","szwVersion": "%s"}`, szwProduct, szwVersion))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetMappingFuncAreaToSettings",
-		bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+// GetMappingFuncAreaToSettings Returns mapping functional area to settings sections.
+func (hac *HstAccessControl) GetMappingFuncAreaToSettings(ctx context.Context, szwProduct, szwVersion string) (FuncAreaMapping, []byte, error) {
+	return hac.funcAreaMapping(ctx, "/api/v1.0/HstAccessControl.GetMappingFuncAreaToSettings", szwProduct, szwVersion)
 }
 
-// GetMappingFuncAreaToTasks Returns mapping functional area to tasks.
-func (hac *HstAccessControl) GetMappingFuncAreaToTasks(ctx context.Context, szwProduct, szwVersion string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"szwProduct": "%s","szwVersion": "%s"}`, szwProduct, szwVersion))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetMappingFuncAreaToTasks",
-		bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+// GetMappingFuncAreaToTasks Returns mapping functional area to task types.
+func (hac *HstAccessControl) GetMappingFuncAreaToTasks(ctx context.Context, szwProduct, szwVersion string) (FuncAreaMapping, []byte, error) {
+	return hac.funcAreaMapping(ctx, "/api/v1.0/HstAccessControl.GetMappingFuncAreaToTasks", szwProduct, szwVersion)
 }
 
 // GetPolicyReadonlyNodes Returns array of paths for all nodes actually located in the specified policy section,
@@ -294,47 +255,34 @@ func (hac *HstAccessControl) GetPolicyReadonlyNodes(ctx context.Context, params
 	return raw, err
 }
 
-// GetRole Return parameters of a role.
-func (hac *HstAccessControl) GetRole(ctx context.Context, params TRParams) (*Trustee, []byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetRole", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, nil, err
-	}
-
-	trustee := new(Trustee)
-	raw, err := hac.client.Do(ctx, request, &trustee)
-	return trustee, raw, err
+// GetRole Return parameters of a role, see RoleDataAttributes for attributes to return.
+func (hac *HstAccessControl) GetRole(ctx context.Context, params TRParams) (*RoleData, []byte, error) {
+	out := new(roleData)
+	raw, err := hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.GetRole", params, out)
+	return &out.Role, raw, err
 }
 
 // GetScObjectAcl Returns ACL for the specified object.
-func (hac *HstAccessControl) GetScObjectAcl(ctx context.Context, nObjId, nObjType int64) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"nObjId":%d,"nObjType":%d}`, nObjId, nObjType))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetScObjectAcl", bytes.NewBuffer(postData))
-
-	if err != nil {
-		return nil, err
-	}
+func (hac *HstAccessControl) GetScObjectAcl(ctx context.Context, nObjId, nObjType int64) (*Permissions, []byte, error) {
+	params := struct {
+		NObjID   int64 `json:"nObjId"`
+		NObjType int64 `json:"nObjType"`
+	}{nObjId, nObjType}
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	out := new(aclParams)
+	raw, err := hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.GetScObjectAcl", params, out)
+	return &out.PAclParams, raw, err
 }
 
 // GetScVServerAcl Returns ACL for the server.
-func (hac *HstAccessControl) GetScVServerAcl(ctx context.Context, nId int64) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{"nId":%d}`, nId))
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.GetScVServerAcl", bytes.NewBuffer(postData))
+func (hac *HstAccessControl) GetScVServerAcl(ctx context.Context, nId int64) (*Permissions, []byte, error) {
+	params := struct {
+		NID int64 `json:"nId"`
+	}{nId}
 
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	out := new(aclParams)
+	raw, err := hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.GetScVServerAcl", params, out)
+	return &out.PAclParams, raw, err
 }
 
 // GetSettingsReadonlyNodes Returns array of paths for nodes from product's setting section, which are readonly for current user session.
@@ -416,67 +364,55 @@ func (hac *HstAccessControl) IsTaskTypeReadonly(ctx context.Context, lGroupId in
 	return pxgValBool, raw, err
 }
 
-// ModifyScObjectAcl Modify ACL for the specified object. Method updates only Accounts, permissions and roles which presented in pAclParams.
-// To delete Ace from Acl, it must be added to 'delete' list.
-func (hac *HstAccessControl) ModifyScObjectAcl(ctx context.Context, params interface{}) ([]byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
+// ScObjectAclParams params of HstAccessControl.SetScObjectAcl and HstAccessControl.ModifyScObjectAcl
+type ScObjectAclParams struct {
+	// NObjID, NObjType id and type of the object, e.g. ScObjectTypeAdmGroup
+	NObjID   int64 `json:"nObjId"`
+	NObjType int64 `json:"nObjType"`
 
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.ModifyScObjectAcl", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
+	// PAclParams ACL, see ACL to build one
+	PAclParams Permissions `json:"pAclParams"`
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	// BCheckCurrentUserAce if true checks that the current user does not deny access to the object to itself
+	BCheckCurrentUserAce bool `json:"bCheckCurrentUserAce"`
 }
 
-// SetScObjectAcl Sets ACL for the specified object.
-func (hac *HstAccessControl) SetScObjectAcl(ctx context.Context, params interface{}) ([]byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
-
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.SetScObjectAcl", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
+// ModifyScObjectAcl Modify ACL for the specified object. Method updates only Accounts, permissions and roles which presented in pAclParams.
+// To delete Ace from Acl, it must be added to 'delete' list.
+func (hac *HstAccessControl) ModifyScObjectAcl(ctx context.Context, params ScObjectAclParams) ([]byte, error) {
+	return hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.ModifyScObjectAcl", params, nil)
+}
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+// SetScObjectAcl Sets ACL for the specified object.
+func (hac *HstAccessControl) SetScObjectAcl(ctx context.Context, params ScObjectAclParams) ([]byte, error) {
+	return hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.SetScObjectAcl", params, nil)
 }
 
-// SetScVServerAcl Set ACL for virtual server.
-func (hac *HstAccessControl) SetScVServerAcl(ctx context.Context, params interface{}) ([]byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
+// ScVServerAclParams params of HstAccessControl.SetScVServerAcl
+type ScVServerAclParams struct {
+	// NID virtual server id
+	NID int64 `json:"nId"`
 
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.SetScVServerAcl", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
+	// PAclParams ACL, see ACL to build one
+	PAclParams Permissions `json:"pAclParams"`
 
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+	// BCheckCurrentUserAce if true checks that the current user does not deny access to the server to itself
+	BCheckCurrentUserAce bool `json:"bCheckCurrentUserAce"`
 }
 
-// UpdateRole Update user role.
-func (hac *HstAccessControl) UpdateRole(ctx context.Context, params interface{}) ([]byte, error) {
-	postData, err := json.Marshal(params)
-	if err != nil {
-		return nil, err
-	}
-
-	request, err := http.NewRequest("POST", hac.client.Server+"/api/v1.0/HstAccessControl.UpdateRole", bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
-
-	raw, err := hac.client.Do(ctx, request, nil)
-	return raw, err
+// SetScVServerAcl Set ACL for virtual server.
+func (hac *HstAccessControl) SetScVServerAcl(ctx context.Context, params ScVServerAclParams) ([]byte, error) {
+	return hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.SetScVServerAcl", params, nil)
+}
+
+// UpdateRole Update user role nId.
+//
+// If bProtection is true, checks that the current user does not lose access rights it has.
+func (hac *HstAccessControl) UpdateRole(ctx context.Context, nId int64, pRoleData RoleData, bProtection bool) ([]byte, error) {
+	params := struct {
+		NID         int64    `json:"nId"`
+		PRoleData   RoleData `json:"pRoleData"`
+		BProtection bool     `json:"bProtection"`
+	}{nId, pRoleData, bProtection}
+	return hac.client.PostInOut(ctx, "/api/v1.0/HstAccessControl.UpdateRole", params, nil)
 }
@@ -0,0 +1,191 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ScObjectTypeAdmGroup nObjType of administration groups in HstAccessControl.GetScObjectAcl
+// and HstAccessControl.SetScObjectAcl.
+const ScObjectTypeAdmGroup int64 = 1
+
+// ScVServerCurrent nId of HstAccessControl.GetScVServerAcl for the Administration Server the session is connected to.
+const ScVServerCurrent int64 = -1
+
+// Access rights, bits of KLSPLU_ALLOWMASK and KLSPLU_DENYMASK of ACL entries
+// and of dwAccessMask of HstAccessControl methods.
+const (
+	AccessRead               int64 = 0x0001
+	AccessWrite              int64 = 0x0002
+	AccessExecute            int64 = 0x0008
+	AccessModifyACL          int64 = 0x0010
+	AccessExecuteOnSelection int64 = 0x0020
+)
+
+// accessRights names of access rights in order of bits
+var accessRights = []struct {
+	name string
+	mask int64
+}{
+	{"read", AccessRead},
+	{"write", AccessWrite},
+	{"execute", AccessExecute},
+	{"modify_acl", AccessModifyACL},
+	{"execute_on_selection", AccessExecuteOnSelection},
+}
+
+// AccessRightNames returns names of access rights set in mask, e.g. ["read", "execute"].
+// Unknown bits are returned as hexadecimal numbers.
+func AccessRightNames(mask int64) []string {
+	var names []string
+	for _, right := range accessRights {
+		if mask&right.mask != 0 {
+			names = append(names, right.name)
+			mask &^= right.mask
+		}
+	}
+	for bit := int64(1); mask != 0 && bit > 0; bit <<= 1 {
+		if mask&bit != 0 {
+			names = append(names, fmt.Sprintf("0x%x", bit))
+			mask &^= bit
+		}
+	}
+	return names
+}
+
+// ParseAccessRights returns access mask by names of access rights as returned by AccessRightNames.
+func ParseAccessRights(names ...string) (int64, error) {
+	var mask int64
+next:
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for _, right := range accessRights {
+			if name == right.name {
+				mask |= right.mask
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("unknown access right %q", name)
+	}
+	return mask, nil
+}
+
+// aclParams result of HstAccessControl.GetScObjectAcl and HstAccessControl.GetScVServerAcl
+type aclParams struct {
+	PAclParams Permissions `json:"pAclParams"`
+}
+
+// Entries returns ACL entries.
+func (p *Permissions) Entries() []KlsplPermissionValue {
+	entries := make([]KlsplPermissionValue, 0, len(p.KlsplPermissions))
+	for _, permission := range p.KlsplPermissions {
+		entries = append(entries, permission.KlsplPermissionValue)
+	}
+	return entries
+}
+
+// Effective returns access rights allowed and not denied by the entry.
+func (v *KlsplPermissionValue) Effective() int64 {
+	return v.KlspluAllowmask &^ v.KlspluDenymask
+}
+
+// TrusteeAttributes attributes of TrusteeValue
+var TrusteeAttributes = []string{
+	"KLHST_ACL_TRUSTEE_ID",
+	"KLHST_ACL_TRUSTEE_SID",
+	"dn",
+	"objectGUID",
+	"userPrincipalName",
+	"kscInternalUserId",
+}
+
+// RoleAttributes attributes of RoleValue
+var RoleAttributes = []string{
+	"KLHST_ACL_ROLE_ID",
+	"KLHST_ACL_ROLE_NAME",
+	"KLHST_ACL_ROLE_DN",
+	"KLHST_ACL_ROLE_BUILT_IN",
+	"KLHST_ACL_ROLE_INHERITED",
+	"KLHST_ACL_TRUSTEE_ID",
+}
+
+// findLifetime lifetime of result-sets of HstAccessControl.FindTrusteesList and HstAccessControl.FindRolesList, in seconds
+const findLifetime = 600
+
+// FindTrusteesList Searches for trustees by filter string strFilter and returns all of them.
+//
+// An empty filter returns all trustees. The result-set is released.
+func (hac *HstAccessControl) FindTrusteesList(ctx context.Context, strFilter string) ([]TrusteeValue, error) {
+	accessor, _, err := hac.FindTrustees(ctx, PFindParams{
+		StrFilter:       strFilter,
+		PFieldsToReturn: TrusteeAttributes,
+		LMaxLifeTime:    findLifetime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer hac.client.ChunkAccessor.Release(context.Background(), accessor.StrAccessor)
+
+	var trustees []TrusteeValue
+	err = hac.client.ChunkAccessor.ForEachItem(ctx, accessor.StrAccessor, 0, func(item json.RawMessage) error {
+		var trustee TrusteeValue
+		if err := json.Unmarshal(item, &trustee); err != nil {
+			return err
+		}
+		trustees = append(trustees, trustee)
+		return nil
+	})
+	return trustees, err
+}
+
+// FindRolesList Searches for roles by filter string strFilter and returns all of them.
+//
+// An empty filter returns all roles. The result-set is released.
+func (hac *HstAccessControl) FindRolesList(ctx context.Context, strFilter string) ([]RoleValue, error) {
+	accessor, _, err := hac.FindRoles(ctx, PFindParams{
+		StrFilter:       strFilter,
+		PFieldsToReturn: RoleAttributes,
+		LMaxLifeTime:    findLifetime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer hac.client.ChunkAccessor.Release(context.Background(), accessor.StrAccessor)
+
+	var roles []RoleValue
+	err = hac.client.ChunkAccessor.ForEachItem(ctx, accessor.StrAccessor, 0, func(item json.RawMessage) error {
+		var role RoleValue
+		if err := json.Unmarshal(item, &role); err != nil {
+			return err
+		}
+		roles = append(roles, role)
+		return nil
+	})
+	return roles, err
+}
@@ -0,0 +1,38 @@
+package kaspersky_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestAccessRights(t *testing.T) {
+	expectEqual(t, []string{"read", "execute", "0x100"}, kaspersky.AccessRightNames(kaspersky.AccessRead|kaspersky.AccessExecute|0x100))
+
+	mask, err := kaspersky.ParseAccessRights("Read", "execute")
+	expectSucceeded(t, err)
+	expectEqual(t, kaspersky.AccessRead|kaspersky.AccessExecute, mask)
+
+	if _, err := kaspersky.ParseAccessRights("delete"); err == nil {
+		t.Fatal("expected error for unknown access right")
+	}
+}
+
+func TestGetScObjectAcl(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	handler.HandleFunc("/api/v1.0/HstAccessControl.GetScObjectAcl", HandlerFuncOk(`{"pAclParams": {"KLSPL_INHERITED": true,
+		"KLSPL_PERMISSIONS": [{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_ALLOWMASK": 11, "KLSPLU_DENYMASK": 2}}]}}`))
+
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+	acl, _, err := client.HstAccessControl.GetScObjectAcl(context.Background(), 1, kaspersky.ScObjectTypeAdmGroup)
+	expectSucceeded(t, err)
+	expectEqual(t, true, acl.KlsplInherited)
+
+	entries := acl.Entries()
+	expectEqual(t, 1, len(entries))
+	expectEqual(t, int64(7), entries[0].KlspluAkuserID)
+	expectEqual(t, kaspersky.AccessRead|kaspersky.AccessExecute, entries[0].Effective())
+}
@@ -0,0 +1,150 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"sort"
+)
+
+// RoleData attributes of user role, see HstAccessControl.AddRole, HstAccessControl.UpdateRole and HstAccessControl.GetRole
+type RoleData struct {
+	// ID role id (KLHST_ACL_ROLE_ID), read only
+	ID int64 `json:"KLHST_ACL_ROLE_ID,omitempty"`
+
+	// Name internal name of the role
+	Name string `json:"KLHST_ACL_ROLE_NAME,omitempty"`
+
+	// DisplayName display name of the role
+	DisplayName string `json:"KLHST_ACL_ROLE_DN,omitempty"`
+
+	// BuiltIn predefined role, read only
+	BuiltIn bool `json:"KLHST_ACL_ROLE_BUILT_IN,omitempty"`
+
+	// Products access rights of the role per product
+	Products []RoleProductItem `json:"KLHST_ACL_ROLE_PRODUCTS,omitempty"`
+
+	// Trustees trustee ids (KLHST_ACL_TRUSTEE_ID) of accounts the role is assigned to, read only
+	Trustees []int64 `json:"KLHST_ACL_ROLE_TRUSTEES,omitempty"`
+}
+
+// RoleProductItem container of RoleProduct
+type RoleProductItem struct {
+	Type  string      `json:"type"`
+	Value RoleProduct `json:"value"`
+}
+
+// RoleProduct access rights of a role to functional areas of a product
+type RoleProduct struct {
+	Product string `json:"KLHST_ACL_PRODUCT_NAME"`
+	Version string `json:"KLHST_ACL_PRODUCT_VERSION"`
+
+	// Allow, Deny access rights to all functional areas of the product, see AccessRead and others
+	Allow int64 `json:"KLHST_ACL_ALLOWMASK,omitempty"`
+	Deny  int64 `json:"KLHST_ACL_DENYMASK,omitempty"`
+
+	// FuncAreas access rights to functional areas of the product
+	FuncAreas []RoleFuncAreaItem `json:"KLHST_ACL_FUNC_AREAS,omitempty"`
+}
+
+// RoleFuncAreaItem container of RoleFuncArea
+type RoleFuncAreaItem struct {
+	Type  string       `json:"type"`
+	Value RoleFuncArea `json:"value"`
+}
+
+// RoleFuncArea access rights of a role to a functional area
+type RoleFuncArea struct {
+	FuncArea string `json:"KLHST_ACL_FUNC_AREA"`
+	Allow    int64  `json:"KLHST_ACL_ALLOWMASK,omitempty"`
+	Deny     int64  `json:"KLHST_ACL_DENYMASK,omitempty"`
+}
+
+// RoleDataAttributes attributes of RoleData
+var RoleDataAttributes = []string{
+	"KLHST_ACL_ROLE_ID",
+	"KLHST_ACL_ROLE_NAME",
+	"KLHST_ACL_ROLE_DN",
+	"KLHST_ACL_ROLE_BUILT_IN",
+	"KLHST_ACL_ROLE_PRODUCTS",
+}
+
+// roleData result of HstAccessControl.AddRole and HstAccessControl.GetRole
+type roleData struct {
+	Role RoleData `json:"PxgRetVal"`
+}
+
+// FuncAreaMapping names of tasks, policies, settings sections or reports by functional area
+type FuncAreaMapping map[string][]string
+
+// Areas returns functional areas of the mapping in sorted order.
+func (m FuncAreaMapping) Areas() []string {
+	areas := make([]string, 0, len(m))
+	for area := range m {
+		areas = append(areas, area)
+	}
+	sort.Strings(areas)
+	return areas
+}
+
+func (hac *HstAccessControl) funcAreaMapping(ctx context.Context, url, szwProduct, szwVersion string) (FuncAreaMapping, []byte, error) {
+	params := struct {
+		SzwProduct string `json:"szwProduct"`
+		SzwVersion string `json:"szwVersion"`
+	}{szwProduct, szwVersion}
+
+	out := &struct {
+		Mapping Params `json:"PxgRetVal"`
+	}{}
+	raw, err := hac.client.PostInOut(ctx, url, params, out)
+	if err != nil {
+		return nil, raw, err
+	}
+
+	mapping := FuncAreaMapping{}
+	for area, value := range out.Mapping {
+		mapping[area] = mappingNames(value)
+	}
+	return mapping, raw, nil
+}
+
+// mappingNames returns names of mapping value, which is a name, an array of names or params keyed by names.
+func mappingNames(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			names = append(names, mappingNames(item)...)
+		}
+		return names
+	case Params:
+		return v.Keys()
+	case TypedValue:
+		return mappingNames(v.Value)
+	}
+	return nil
+}
@@ -96,6 +96,113 @@ func (ia *InventoryApi) GetInvProductsList(ctx context.Context, params Null) ([]
 	return raw, err
 }
 
+// InvProduct software application of the software inventory
+type InvProduct struct {
+	// ProductID application id, unique within the Administration Server
+	ProductID       string    `json:"ProductID"`
+	DisplayName     string    `json:"DisplayName"`
+	DisplayVersion  string    `json:"DisplayVersion,omitempty"`
+	Publisher       string    `json:"Publisher,omitempty"`
+	InstallDate     *DateTime `json:"InstallDate,omitempty"`
+	InstallDir      string    `json:"InstallDir,omitempty"`
+	Comments        string    `json:"Comments,omitempty"`
+	HelpLink        string    `json:"HelpLink,omitempty"`
+	LangID          int64     `json:"LangId,omitempty"`
+	ARPRegKey       string    `json:"ARPRegKey,omitempty"`
+	UninstallString string    `json:"UninstallString,omitempty"`
+	PackageCode     string    `json:"PackageCode,omitempty"`
+	IsMsi           bool      `json:"bIsMsi,omitempty"`
+}
+
+// InvPatch software application update of the software inventory
+type InvPatch struct {
+	// PatchID update id, unique within the Administration Server
+	PatchID string `json:"PatchID"`
+
+	// ParentID id of the updated application, see InvProduct.ProductID
+	ParentID       string    `json:"ParentID,omitempty"`
+	DisplayName    string    `json:"DisplayName"`
+	DisplayVersion string    `json:"DisplayVersion,omitempty"`
+	Publisher      string    `json:"Publisher,omitempty"`
+	InstallDate    *DateTime `json:"InstallDate,omitempty"`
+	Classification string    `json:"Classification,omitempty"`
+	Comments       string    `json:"Comments,omitempty"`
+	HelpLink       string    `json:"HelpLink,omitempty"`
+	MoreInfoURL    string    `json:"MoreInfoURL,omitempty"`
+}
+
+type invProducts struct {
+	PxgRetVal struct {
+		Products []struct {
+			Value InvProduct `json:"value"`
+		} `json:"GNRL_EA_PARAM_1"`
+	} `json:"PxgRetVal"`
+}
+
+func (p *invProducts) list() []InvProduct {
+	result := make([]InvProduct, 0, len(p.PxgRetVal.Products))
+	for _, item := range p.PxgRetVal.Products {
+		result = append(result, item.Value)
+	}
+	return result
+}
+
+type invPatches struct {
+	PxgRetVal struct {
+		Patches []struct {
+			Value InvPatch `json:"value"`
+		} `json:"GNRL_EA_PARAM_1"`
+	} `json:"PxgRetVal"`
+}
+
+func (p *invPatches) list() []InvPatch {
+	result := make([]InvPatch, 0, len(p.PxgRetVal.Patches))
+	for _, item := range p.PxgRetVal.Patches {
+		result = append(result, item.Value)
+	}
+	return result
+}
+
+// HostInvProducts Acquire software applications which are installed on specified host.
+// Unlike InventoryApi.GetHostInvProducts returns decoded applications.
+func (ia *InventoryApi) HostInvProducts(ctx context.Context, szwHostId string) ([]InvProduct, []byte, error) {
+	params := struct {
+		SzwHostID string `json:"szwHostId"`
+	}{szwHostId}
+
+	out := new(invProducts)
+	raw, err := ia.client.PostInOut(ctx, "/api/v1.0/InventoryApi.GetHostInvProducts", params, out)
+	return out.list(), raw, err
+}
+
+// HostInvPatches Acquire software application updates which are installed on specified host.
+// Unlike InventoryApi.GetHostInvPatches returns decoded updates.
+func (ia *InventoryApi) HostInvPatches(ctx context.Context, szwHostId string) ([]InvPatch, []byte, error) {
+	params := struct {
+		SzwHostID string `json:"szwHostId"`
+	}{szwHostId}
+
+	out := new(invPatches)
+	raw, err := ia.client.PostInOut(ctx, "/api/v1.0/InventoryApi.GetHostInvPatches", params, out)
+	return out.list(), raw, err
+}
+
+// InvPatches Acquire all software application updates.
+// Unlike InventoryApi.GetInvPatchesList returns decoded updates.
+func (ia *InventoryApi) InvPatches(ctx context.Context) ([]InvPatch, []byte, error) {
+	out := new(invPatches)
+	raw, err := ia.client.PostInOut(ctx, "/api/v1.0/InventoryApi.GetInvPatchesList", Null{}, out)
+	return out.list(), raw, err
+}
+
+// InvProducts Acquire all software applications.
+// Unlike InventoryApi.GetInvProductsList returns decoded applications.
+func (ia *InventoryApi) InvProducts(ctx context.Context) ([]InvProduct, []byte, error) {
+	out := new(invProducts)
+	raw, err := ia.client.PostInOut(ctx, "/api/v1.0/InventoryApi.GetInvProductsList", Null{}, out)
+	return out.list(), raw, err
+}
+
 // DeleteUninstalledApps Remove from database info about software applications which aren't installed on any host.
 func (ia *InventoryApi) DeleteUninstalledApps(ctx context.Context) ([]byte, error) {
 	request, err := http.NewRequest("POST", ia.client.Server+"/api/v1.0/InventoryApi.DeleteUninstalledApps", nil)
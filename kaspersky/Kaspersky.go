@@ -35,6 +35,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 )
 
 type Config struct {
@@ -48,102 +49,110 @@ type Config struct {
 
 //-------------Client------------------
 type Client struct {
-	AdfsSso                                                   *AdfsSso
-	AdHosts                                                   *AdHosts
-	AdmServerSettings                                         *AdmServerSettings
-	AdSecManager                                              *AdSecManager
-	AppCtrlApi                                                *AppCtrlApi
-	AKPatches                                                 *AKPatches
-	AsyncActionStateChecker                                   *AsyncActionStateChecker
-	CertPoolCtrl                                              *CertPoolCtrl
-	CertPoolCtrl2                                             *CertPoolCtrl2
-	CgwHelper                                                 *CgwHelper
-	ChunkAccessor                                             *ChunkAccessor
-	CloudAccess                                               *CloudAccess
-	ConEvents                                                 *ConEvents
-	DatabaseInfo                                              *DatabaseInfo
-	DataProtectionApi                                         *DataProtectionApi
-	DpeKeyService                                             *DpeKeyService
-	EventNotificationProperties                               *EventNotificationProperties
-	EventNotificationsApi                                     *EventNotificationsApi
-	EventProcessing                                           *EventProcessing
-	EventProcessingFactory                                    *EventProcessingFactory
-	ExtAud                                                    *ExtAud
-	FileCategorizer2                                          *FileCategorizer2
-	FilesAcceptor                                             *FilesAcceptor
-	GatewayConnection                                         *GatewayConnection
-	GroupSync                                                 *GroupSync
-	HostGroup                                                 *HostGroup
-	HostMoveRules                                             *HostMoveRules
-	HostTagsApi                                               *HostTagsApi
-	HostTagsRulesApi                                          *HostTagsRulesApi
-	HostTasks                                                 *HostTasks
-	HstAccessControl                                          *HstAccessControl
-	HWInvStorage                                              *HWInvStorage
-	GroupSyncIterator                                         *GroupSyncIterator
-	GroupTaskControlApi                                       *GroupTaskControlApi
-	InventoryApi                                              *InventoryApi
-	InvLicenseProducts                                        *InvLicenseProducts
-	IWebSrvSettings                                           *IWebSrvSettings
-	IWebUsersSrv                                              *IWebUsersSrv
-	IWebUsersSrv2                                             *IWebUsersSrv2
-	KeyService                                                *KeyService
-	KeyService2                                               *KeyService2
-	KillChain                                                 *KillChain
-	KLEVerControl                                             *KLEVerControl
-	KsnInternal                                               *KsnInternal
-	LicenseInfoSync                                           *LicenseInfoSync
-	LicenseKeys                                               *LicenseKeys
-	LicensePolicy                                             *LicensePolicy
-	Limits                                                    *Limits
-	ListTags                                                  *ListTags
-	MigrationData                                             *MigrationData
-	Multitenancy                                              *Multitenancy
-	NagCgwHelper                                              *NagCgwHelper
-	NagGuiCalls                                               *NagGuiCalls
-	NagHstCtl                                                 *NagHstCtl
-	NagNetworkListApi                                         *NagNetworkListApi
-	NagRdu                                                    *NagRdu
-	NagRemoteScreen                                           *NagRemoteScreen
-	NetUtils                                                  *NetUtils
-	NlaDefinedNetworks                                        *NlaDefinedNetworks
-	OsVersion                                                 *OsVersion
-	PackagesApi                                               *PackagesApi
-	PatchParameters                                           *PatchParameters
-	PLCDevApi                                                 *PLCDevApi
-	Policy                                                    *Policy
-	PolicyProfiles                                            *PolicyProfiles
-	QueriesStorage                                            *QueriesStorage
-	QBTNetworkListApi                                         *QBTNetworkListApi
-	ReportManager                                             *ReportManager
-	RetrFiles                                                 *RetrFiles
-	ScanDiapasons                                             *ScanDiapasons
-	SecurityPolicy                                            *SecurityPolicy
-	SecurityPolicy3                                           *SecurityPolicy3
-	ServerHierarchy                                           *ServerHierarchy
-	ServerTransportSettings                                   *ServerTransportSettings
-	Session                                                   *Session
-	SmsQueue                                                  *SmsQueue
-	SmsSenders                                                *SmsSenders
-	SrvCloud                                                  *SrvCloud
-	SrvSsRevision                                             *SrvSsRevision
-	SrvView                                                   *SrvView
-	SsContents                                                *SsContents
-	SubnetMasks                                               *SubnetMasks
-	Tasks                                                     *Tasks
-	TrafficManager                                            *TrafficManager
-	UaControl                                                 *UaControl
-	Updates                                                   *Updates
-	UpdComps                                                  *UpdComps
-	UserDevicesApi                                            *UserDevicesApi
-	VapmControlApi                                            *VapmControlApi
-	UserName, Password, Server, VServerName, XKscSessionToken string
-	XKscSession, InsecureSkipVerify                           bool
-	VServers                                                  *VServers
-	VServers2                                                 *VServers2
-	WolSender                                                 *WolSender
-	client                                                    *http.Client
-	common                                                    service
+	AdfsSso                                 *AdfsSso
+	AdHosts                                 *AdHosts
+	AdmServerSettings                       *AdmServerSettings
+	AdSecManager                            *AdSecManager
+	AppCtrlApi                              *AppCtrlApi
+	AKPatches                               *AKPatches
+	AsyncActionStateChecker                 *AsyncActionStateChecker
+	CertPoolCtrl                            *CertPoolCtrl
+	CertPoolCtrl2                           *CertPoolCtrl2
+	CgwHelper                               *CgwHelper
+	ChunkAccessor                           *ChunkAccessor
+	CloudAccess                             *CloudAccess
+	ConEvents                               *ConEvents
+	DatabaseInfo                            *DatabaseInfo
+	DataProtectionApi                       *DataProtectionApi
+	DpeKeyService                           *DpeKeyService
+	EventNotificationProperties             *EventNotificationProperties
+	EventNotificationsApi                   *EventNotificationsApi
+	EventProcessing                         *EventProcessing
+	EventProcessingFactory                  *EventProcessingFactory
+	ExtAud                                  *ExtAud
+	FileCategorizer2                        *FileCategorizer2
+	FilesAcceptor                           *FilesAcceptor
+	GatewayConnection                       *GatewayConnection
+	GroupSync                               *GroupSync
+	HostGroup                               *HostGroup
+	HostMoveRules                           *HostMoveRules
+	HostsKeyIterator                        *HostsKeyIterator
+	HostTagsApi                             *HostTagsApi
+	HostTagsRulesApi                        *HostTagsRulesApi
+	HostTasks                               *HostTasks
+	HstAccessControl                        *HstAccessControl
+	HWInvStorage                            *HWInvStorage
+	GroupSyncIterator                       *GroupSyncIterator
+	GroupTaskControlApi                     *GroupTaskControlApi
+	InventoryApi                            *InventoryApi
+	InvLicenseProducts                      *InvLicenseProducts
+	IWebSrvSettings                         *IWebSrvSettings
+	IWebUsersSrv                            *IWebUsersSrv
+	IWebUsersSrv2                           *IWebUsersSrv2
+	KeyService                              *KeyService
+	KeyService2                             *KeyService2
+	KillChain                               *KillChain
+	KLEVerControl                           *KLEVerControl
+	KsnInternal                             *KsnInternal
+	LicenseInfoSync                         *LicenseInfoSync
+	LicenseKeys                             *LicenseKeys
+	LicensePolicy                           *LicensePolicy
+	Limits                                  *Limits
+	ListTags                                *ListTags
+	MigrationData                           *MigrationData
+	Multitenancy                            *Multitenancy
+	NagCgwHelper                            *NagCgwHelper
+	NagGuiCalls                             *NagGuiCalls
+	NagHstCtl                               *NagHstCtl
+	NagNetworkListApi                       *NagNetworkListApi
+	NagRdu                                  *NagRdu
+	NagRemoteScreen                         *NagRemoteScreen
+	NetUtils                                *NetUtils
+	NlaDefinedNetworks                      *NlaDefinedNetworks
+	OsVersion                               *OsVersion
+	PackagesApi                             *PackagesApi
+	PatchParameters                         *PatchParameters
+	PLCDevApi                               *PLCDevApi
+	Policy                                  *Policy
+	PolicyProfiles                          *PolicyProfiles
+	QueriesStorage                          *QueriesStorage
+	QBTNetworkListApi                       *QBTNetworkListApi
+	ReportManager                           *ReportManager
+	RetrFiles                               *RetrFiles
+	ScanDiapasons                           *ScanDiapasons
+	SecurityPolicy                          *SecurityPolicy
+	SecurityPolicy3                         *SecurityPolicy3
+	ServerHierarchy                         *ServerHierarchy
+	ServerTransportSettings                 *ServerTransportSettings
+	Session                                 *Session
+	SmsQueue                                *SmsQueue
+	SmsSenders                              *SmsSenders
+	SrvCloud                                *SrvCloud
+	SrvSsRevision                           *SrvSsRevision
+	SrvView                                 *SrvView
+	SsContents                              *SsContents
+	SubnetMasks                             *SubnetMasks
+	Tasks                                   *Tasks
+	TrafficManager                          *TrafficManager
+	UaControl                               *UaControl
+	Updates                                 *Updates
+	UpdComps                                *UpdComps
+	UserDevicesApi                          *UserDevicesApi
+	VapmControlApi                          *VapmControlApi
+	UserName, Password, Server, VServerName string
+	// XKscSessionToken X-KSC-Session token, guarded by sessionMu as the session may be restarted concurrently.
+	//
+	// Deprecated: use SessionToken, reading the field directly races with session restarts.
+	XKscSessionToken                string
+	XKscSession, InsecureSkipVerify bool
+	VServers                        *VServers
+	VServers2                       *VServers2
+	WolSender                       *WolSender
+	client                          *http.Client
+	common                          service
+	groupPaths                      groupPathCache
+	sessionMu                       sync.RWMutex
+	sessionRestart                  sync.Mutex
 }
 
 type service struct {
@@ -196,6 +205,7 @@ func New(cfg Config) *Client {
 	c.GroupSync = (*GroupSync)(&c.common)
 	c.HostGroup = (*HostGroup)(&c.common)
 	c.HostMoveRules = (*HostMoveRules)(&c.common)
+	c.HostsKeyIterator = (*HostsKeyIterator)(&c.common)
 	c.HostTagsApi = (*HostTagsApi)(&c.common)
 	c.HostTagsRulesApi = (*HostTagsRulesApi)(&c.common)
 	c.HostTasks = (*HostTasks)(&c.common)
@@ -282,12 +292,25 @@ func (c *Client) xkscSession(ctx context.Context) error {
 	s, _, e := c.Session.StartSession(ctx)
 
 	if s != nil {
-		c.XKscSessionToken = s.Str
+		c.setSessionToken(s.Str)
 	}
 
 	return e
 }
 
+// SessionToken returns X-KSC-Session token of the client, empty if the session is not started.
+func (c *Client) SessionToken() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	return c.XKscSessionToken
+}
+
+func (c *Client) setSessionToken(token string) {
+	c.sessionMu.Lock()
+	c.XKscSessionToken = token
+	c.sessionMu.Unlock()
+}
+
 func (c *Client) KSCAuth(ctx context.Context) error {
 
 	c.UserName = base64.StdEncoding.EncodeToString([]byte(c.UserName))
@@ -378,6 +401,16 @@ func (c *Client) Post(ctx context.Context, url string) ([]byte, error) {
 	return c.PostInOut(ctx, url, nil, nil)
 }
 
+// StatusError is returned by Client.Do if server responds with HTTP error status and no PxgError.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
 func (c *Client) Do(ctx context.Context, req *http.Request, out interface{}) ([]byte, error) {
 	if ctx == nil {
 		return nil, errors.New("context must be non-nil")
@@ -387,8 +420,8 @@ func (c *Client) Do(ctx context.Context, req *http.Request, out interface{}) ([]
 
 	var resp *http.Response
 
-	if c.XKscSession && c.XKscSessionToken != "" {
-		req.Header.Set("X-KSC-Session", c.XKscSessionToken)
+	if token := c.SessionToken(); c.XKscSession && token != "" {
+		req.Header.Set("X-KSC-Session", token)
 	}
 
 	req.Header.Set("User-Agent", "go-ksc")
@@ -407,10 +440,6 @@ func (c *Client) Do(ctx context.Context, req *http.Request, out interface{}) ([]
 		return nil, err
 	}
 
-	if resp.StatusCode >= http.StatusBadRequest {
-		return nil, errors.New(resp.Status)
-	}
-
 	defer resp.Body.Close()
 
 	var reader io.ReadCloser
@@ -424,6 +453,15 @@ func (c *Client) Do(ctx context.Context, req *http.Request, out interface{}) ([]
 
 	body, err := ioutil.ReadAll(reader)
 
+	if resp.StatusCode >= http.StatusBadRequest {
+		// KSC reports method failures as PxgError with HTTP error status
+		pre := new(PxgRetError)
+		if json.Unmarshal(body, pre) == nil && pre.Error != nil {
+			return body, pre.Error
+		}
+		return body, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
 	if err != nil {
 		return body, err
 	}
@@ -30,6 +30,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 )
 
 //	LicenseKeys service to operating with keys
@@ -82,13 +83,23 @@ type PInData struct {
 	KllicSerial string `json:"KLLIC_SERIAL"`
 }
 
-type HostsKeyIterator struct {
-	LKeyCount    int64  `json:"lKeyCount"`
+// KeyHostsIterator result of LicenseKeys.AcquireKeyHosts and LicenseKeys.EnumKeys.
+//
+// The type was named HostsKeyIterator before, the name now belongs to the HostsKeyIterator service
+// iterating over the result.
+type KeyHostsIterator struct {
+	// LKeyCount number of hosts using the key or number of keys
+	LKeyCount int64 `json:"lKeyCount"`
+
+	// WstrIterator forward iterator id, see HostsKeyIterator
 	WstrIterator string `json:"wstrIterator"`
 }
 
 // AcquireKeyHosts Get an array of hosts that are currently using the specified key.
-func (lk *LicenseKeys) AcquireKeyHosts(ctx context.Context, params AcquireKeyHostsParams) (*HostsKeyIterator,
+//
+// Hosts are acquired by HostsKeyIterator.GetNextItemsChunk, the iterator is to be released
+// by HostsKeyIterator.ReleaseIterator.
+func (lk *LicenseKeys) AcquireKeyHosts(ctx context.Context, params AcquireKeyHostsParams) (*KeyHostsIterator,
 	[]byte, error) {
 	postData, err := json.Marshal(params)
 	if err != nil {
@@ -101,9 +112,9 @@ func (lk *LicenseKeys) AcquireKeyHosts(ctx context.Context, params AcquireKeyHos
 		return nil, nil, err
 	}
 
-	hostsKeyIterator := new(HostsKeyIterator)
-	raw, err := lk.client.Do(ctx, request, &hostsKeyIterator)
-	return hostsKeyIterator, raw, err
+	keyHostsIterator := new(KeyHostsIterator)
+	raw, err := lk.client.Do(ctx, request, &keyHostsIterator)
+	return keyHostsIterator, raw, err
 }
 
 //	EnumKeysParams struct
@@ -129,6 +140,31 @@ func (lk *LicenseKeys) EnumKeys(ctx context.Context, params EnumKeysParams) ([]b
 	return raw, err
 }
 
+// EnumKeysList Enumerate keys and acquire LicenseKeyAttributes of each of them.
+//
+// Unlike LicenseKeys.EnumKeys returns decoded keys, which are read by HostsKeyIterator.ForEachHost.
+// The iterator is released.
+func (lk *LicenseKeys) EnumKeysList(ctx context.Context, lTimeoutSec int64) ([]LicenseKey, error) {
+	iterator := new(KeyHostsIterator)
+	_, err := lk.client.PostInOut(ctx, "/api/v1.0/LicenseKeys.EnumKeys",
+		EnumKeysParams{PFields: LicenseKeyAttributes, LTimeoutSEC: lTimeoutSec}, iterator)
+	if err != nil {
+		return nil, err
+	}
+	defer lk.client.HostsKeyIterator.ReleaseIterator(context.Background(), iterator.WstrIterator)
+
+	var keys []LicenseKey
+	err = lk.client.HostsKeyIterator.ForEachHost(ctx, iterator.WstrIterator, 0, func(item json.RawMessage) error {
+		var key LicenseKey
+		if err := json.Unmarshal(item, &key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
 // KeyDataParams struct
 type KeyDataParams struct {
 	//	PKeyInfo container which must contain "KLLIC_SERIAL" attribute to specify the interested license.
@@ -145,6 +181,81 @@ type PKeyInfo struct {
 	KllicsrvKeydata bool `json:"KLLICSRV_KEYDATA,omitempty"`
 }
 
+// Types of license keys, see LicenseKey.KeyType
+const (
+	KeyTypeCommercial   int64 = 1
+	KeyTypeBeta         int64 = 2
+	KeyTypeTrial        int64 = 3
+	KeyTypeTest         int64 = 4
+	KeyTypeOEM          int64 = 5
+	KeyTypeSubscription int64 = 6
+)
+
+// LicenseKey license key, see List of license key attributes
+type LicenseKey struct {
+	// Serial license key serial number
+	Serial string `json:"KLLIC_SERIAL"`
+
+	// ProductName name of the licensed application
+	ProductName string `json:"KLLIC_PROD_NAME,omitempty"`
+
+	// AppID id of the licensed application
+	AppID int64 `json:"KLLIC_APP_ID,omitempty"`
+
+	// MajorVersion major version of the licensed application
+	MajorVersion string `json:"KLLIC_MAJ_VER,omitempty"`
+
+	// KeyType key type, e.g. KeyTypeCommercial
+	KeyType int64 `json:"KLLIC_KEY_TYPE,omitempty"`
+
+	// LicenseCount number of hosts the key is licensed for, unlimited if zero
+	LicenseCount int64 `json:"KLLIC_LIC_COUNT,omitempty"`
+
+	// LicPeriod license period in days
+	LicPeriod int64 `json:"KLLIC_LICPERIOD,omitempty"`
+
+	CreationDate *DateTime `json:"KLLIC_CREATION_DATE,omitempty"`
+
+	// LimitDate key expiration date
+	LimitDate *DateTime `json:"KLLIC_LIMIT_DATE,omitempty"`
+
+	// CustomerInfo license owner
+	CustomerInfo string `json:"KLLIC_CUSTOMER_INFO,omitempty"`
+
+	// AutoKey key is deployed to hosts automatically
+	AutoKey bool `json:"KLLICSRV_AUTOKEY,omitempty"`
+
+	// Active, Reserve key is installed as the active or the reserve key of the application
+	Active  bool `json:"KLLICSRV_ACTIVE,omitempty"`
+	Reserve bool `json:"KLLICSRV_RESERVE,omitempty"`
+}
+
+// Expires returns key expiration time, zero time if the key does not expire.
+func (k *LicenseKey) Expires() time.Time {
+	return k.LimitDate.Time()
+}
+
+// LicenseKeyAttributes attributes of LicenseKey
+var LicenseKeyAttributes = []string{
+	"KLLIC_SERIAL",
+	"KLLIC_PROD_NAME",
+	"KLLIC_APP_ID",
+	"KLLIC_MAJ_VER",
+	"KLLIC_KEY_TYPE",
+	"KLLIC_LIC_COUNT",
+	"KLLIC_LICPERIOD",
+	"KLLIC_CREATION_DATE",
+	"KLLIC_LIMIT_DATE",
+	"KLLIC_CUSTOMER_INFO",
+	"KLLICSRV_AUTOKEY",
+	"KLLICSRV_ACTIVE",
+	"KLLICSRV_RESERVE",
+}
+
+type keyData struct {
+	PKeyData LicenseKey `json:"pKeyData"`
+}
+
 // GetKeyData Get data of a key. For any attribute to query you must put such attribute with any value into the container pKeyInfo.
 //
 // In particular, if you need the key body then put into pKeyInfo container the attribute with name "KLLICSRV_KEYDATA" of type (bool).
@@ -153,7 +264,8 @@ type PKeyInfo struct {
 // so that and Administration Server has license key body then it will be returned in "KLLICSRV_KEYDATA" attribute.
 //
 // Note that the additional "ExportLicense" access right must be set up to the user under which this call is made.
-
+//
+// See LicenseKeys.GetKey for decoded key data.
 func (lk *LicenseKeys) GetKeyData(ctx context.Context, params KeyDataParams) ([]byte, error) {
 	postData, err := json.Marshal(params)
 	if err != nil {
@@ -170,6 +282,19 @@ func (lk *LicenseKeys) GetKeyData(ctx context.Context, params KeyDataParams) ([]
 	return raw, err
 }
 
+// GetKey Acquire all LicenseKeyAttributes of the key by its serial number.
+func (lk *LicenseKeys) GetKey(ctx context.Context, serial string) (*LicenseKey, []byte, error) {
+	info := Params{}
+	for _, name := range LicenseKeyAttributes {
+		info[name] = true
+	}
+	info["KLLIC_SERIAL"] = serial
+
+	out := new(keyData)
+	raw, err := lk.client.PostInOut(ctx, "/api/v1.0/LicenseKeys.GetKeyData", Params{"pKeyInfo": info}, out)
+	return &out.PKeyData, raw, err
+}
+
 // SaasTryToUninstall Uninstall an adm. server's license.
 func (lk *LicenseKeys) SaasTryToUninstall(ctx context.Context, bCurrent bool) ([]byte, error) {
 	postData := []byte(fmt.Sprintf(`{"bCurrent": %v}`, bCurrent))
@@ -0,0 +1,49 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestLicenseKeysEnumKeysList(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var fields []string
+	handler.HandleFunc("/api/v1.0/LicenseKeys.EnumKeys", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.EnumKeysParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		fields = in.PFields
+		w.Write([]byte(`{"lKeyCount": 2, "wstrIterator": "keys"}`))
+	})
+	handler.HandleFunc("/api/v1.0/HostsKeyIterator.GetNextItemsChunk", HandlerFuncOk(`{
+		"pChunk": {"KLCSP_ITERATOR_ARRAY": [
+			{"type": "params", "value": {"KLLIC_SERIAL": "AAAA-0001"}},
+			{"type": "params", "value": {"KLLIC_SERIAL": "AAAA-0002"}}
+		]},
+		"bEOF": true
+	}`))
+	var released string
+	handler.HandleFunc("/api/v1.0/HostsKeyIterator.ReleaseIterator", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			WstrIterator string `json:"wstrIterator"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		released = in.WstrIterator
+		w.Write([]byte(`{}`))
+	})
+
+	keys, err := client.LicenseKeys.EnumKeysList(ctx, 0)
+	expectSucceeded(t, err)
+	expectEqual(t, kaspersky.LicenseKeyAttributes, fields)
+	expectEqual(t, 2, len(keys))
+	expectEqual(t, "AAAA-0002", keys[1].Serial)
+	expectEqual(t, "keys", released)
+}
@@ -0,0 +1,290 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Params KSC params container.
+//
+// Values are nil, bool, string, json.Number, []interface{}, nested Params for
+// {"type": "params", "value": {...}} containers and TypedValue for other typed values,
+// e.g. {"type": "datetime", "value": "2020-05-28T07:22:14Z"}.
+type Params map[string]interface{}
+
+// TypedValue KSC value which type can not be derived from JSON, e.g. datetime, long or binary
+type TypedValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+func (p *Params) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	if raw == nil {
+		*p = nil
+		return nil
+	}
+
+	value := normalizeParamValue(raw)
+	params, ok := value.(Params)
+	if !ok {
+		return fmt.Errorf("params expected, got %s", bytes.TrimSpace(data))
+	}
+	*p = params
+	return nil
+}
+
+// MarshalJSON encodes nested params as {"type": "params", "value": {...}} as KSC expects.
+func (p Params) MarshalJSON() ([]byte, error) {
+	plain := make(map[string]interface{}, len(p))
+	for k, v := range p {
+		plain[k] = denormalizeParamValue(v)
+	}
+	return json.Marshal(plain)
+}
+
+func (v TypedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	}{v.Type, denormalizeParamValue(v.Value)})
+}
+
+// denormalizeParamValue converts Params model value into KSC JSON representation.
+func denormalizeParamValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Params:
+		return TypedValue{Type: "params", Value: map[string]interface{}(v)}
+	case map[string]interface{}:
+		plain := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			plain[k] = denormalizeParamValue(item)
+		}
+		return plain
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = denormalizeParamValue(item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+// normalizeParamValue converts generic decoded JSON into Params model.
+func normalizeParamValue(raw interface{}) interface{} {
+	switch value := raw.(type) {
+	case map[string]interface{}:
+		if typ, ok := value["type"].(string); ok && len(value) == 2 {
+			if inner, ok := value["value"]; ok {
+				inner = normalizeParamValue(inner)
+				if typ == "params" {
+					if params, ok := inner.(Params); ok {
+						return params
+					}
+				}
+				return TypedValue{Type: typ, Value: inner}
+			}
+		}
+
+		params := make(Params, len(value))
+		for k, v := range value {
+			params[k] = normalizeParamValue(v)
+		}
+		return params
+	case []interface{}:
+		for i, v := range value {
+			value[i] = normalizeParamValue(v)
+		}
+		return value
+	default:
+		return raw
+	}
+}
+
+// Keys returns sorted keys of params.
+func (p Params) Keys() []string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Flatten returns leaf values of params with nested keys joined by ".", array items are indexed as "[N]".
+// Values are rendered as compact JSON.
+func (p Params) Flatten() map[string]string {
+	result := map[string]string{}
+	p.Leaves(func(key string, value interface{}) {
+		switch value.(type) {
+		case Params:
+			result[key] = "{}"
+			return
+		case []interface{}:
+			result[key] = "[]"
+			return
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			data = []byte(fmt.Sprint(value))
+		}
+		result[key] = string(data)
+	})
+	return result
+}
+
+// Leaves calls fn for every leaf value of params with nested keys joined by ".", array items are indexed as "[N]".
+// Empty nested params and arrays are passed to fn as leaves.
+func (p Params) Leaves(fn func(key string, value interface{})) {
+	walkParamValue("", p, fn)
+}
+
+func walkParamValue(prefix string, value interface{}, fn func(string, interface{})) {
+	switch v := value.(type) {
+	case Params:
+		if len(v) == 0 && prefix != "" {
+			fn(prefix, v)
+			return
+		}
+		for key, item := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			walkParamValue(name, item, fn)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fn(prefix, v)
+			return
+		}
+		for i, item := range v {
+			walkParamValue(prefix+"["+strconv.Itoa(i)+"]", item, fn)
+		}
+	default:
+		fn(prefix, v)
+	}
+}
+
+// String returns string value of key or empty string if key is absent or not a string.
+func (p Params) String(key string) string {
+	s, _ := p[key].(string)
+	return s
+}
+
+// Int returns integer value of key, including typed "long" values, or 0 if key is absent or not an integer.
+func (p Params) Int(key string) int64 {
+	value := p[key]
+	if typed, ok := value.(TypedValue); ok {
+		value = typed.Value
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
+
+// Bool returns bool value of key or false if key is absent or not a bool.
+func (p Params) Bool(key string) bool {
+	b, _ := p[key].(bool)
+	return b
+}
+
+// Params returns nested params of key or nil if key is absent or not params.
+func (p Params) Params(key string) Params {
+	nested, _ := p[key].(Params)
+	return nested
+}
+
+// Time returns datetime value of key or zero time if key is absent or not a datetime.
+func (p Params) Time(key string) time.Time {
+	typed, ok := p[key].(TypedValue)
+	if !ok {
+		return time.Time{}
+	}
+	s, _ := typed.Value.(string)
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// Has returns true if key is present in params.
+func (p Params) Has(key string) bool {
+	_, ok := p[key]
+	return ok
+}
+
+// Clone returns deep copy of params.
+func (p Params) Clone() Params {
+	if p == nil {
+		return nil
+	}
+	return cloneParamValue(p).(Params)
+}
+
+func cloneParamValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Params:
+		clone := make(Params, len(v))
+		for k, item := range v {
+			clone[k] = cloneParamValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneParamValue(item)
+		}
+		return clone
+	case TypedValue:
+		return TypedValue{Type: v.Type, Value: cloneParamValue(v.Value)}
+	default:
+		return value
+	}
+}
@@ -362,6 +362,17 @@ func (pl *Policy) ExportPolicy(ctx context.Context, lPolicy int64) (*PxgValStr,
 	return pxgValStr, err
 }
 
+// ExportPolicyBlob Export policy to a blob. Unlike Policy.ExportPolicy returns decoded blob.
+func (pl *Policy) ExportPolicyBlob(ctx context.Context, lPolicy int64) (*PxgValBinary, []byte, error) {
+	params := struct {
+		LPolicy int64 `json:"lPolicy"`
+	}{lPolicy}
+
+	pxgValBinary := new(PxgValBinary)
+	raw, err := pl.client.PostInOut(ctx, "/api/v1.0/Policy.ExportPolicy", params, pxgValBinary)
+	return pxgValBinary, raw, err
+}
+
 //PolicyBlob struct
 type PolicyBlob struct {
 	LGroup int64 `json:"lGroup,omitempty"`
@@ -385,3 +396,19 @@ func (pl *Policy) ImportPolicy(ctx context.Context, params PolicyBlob) (*PxgValS
 	raw, err := pl.client.Do(ctx, request, &pxgValStr)
 	return pxgValStr, raw, err
 }
+
+// PolicyBinaryBlob struct using in Policy.ImportPolicyBlob
+type PolicyBinaryBlob struct {
+	// LGroup id of the group to import policy into
+	LGroup int64 `json:"lGroup"`
+
+	// PData policy blob exported by Policy.ExportPolicyBlob
+	PData Binary `json:"pData"`
+}
+
+// ImportPolicyBlob Import policy from blob. Returns id of the new policy.
+func (pl *Policy) ImportPolicyBlob(ctx context.Context, params PolicyBinaryBlob) (*PxgValInt, []byte, error) {
+	pxgValInt := new(PxgValInt)
+	raw, err := pl.client.PostInOut(ctx, "/api/v1.0/Policy.ImportPolicy", params, pxgValInt)
+	return pxgValInt, raw, err
+}
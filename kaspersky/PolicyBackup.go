@@ -0,0 +1,352 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PolicyBackupManifest describes policies saved by Policy.Backup
+type PolicyBackupManifest struct {
+	BackupInfo
+
+	// Policies saved policies
+	Policies []PolicyBackupEntry `json:"policies"`
+
+	// Failures policies that failed to be saved
+	Failures []BackupFailure `json:"failures,omitempty"`
+}
+
+// PolicyBackupEntry saved policy
+type PolicyBackupEntry struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Product   string `json:"product"`
+	Version   string `json:"version"`
+	Active    bool   `json:"active"`
+	Roaming   bool   `json:"roaming,omitempty"`
+	GroupID   int64  `json:"groupId"`
+	GroupPath string `json:"groupPath"`
+
+	// File policy blob path relative to the backup directory
+	File string `json:"file"`
+
+	// Profiles policy profiles in priority order, the profile with lesser index has greater priority
+	Profiles []PolicyProfileBackupEntry `json:"profiles,omitempty"`
+}
+
+// PolicyProfileBackupEntry saved policy profile
+type PolicyProfileBackupEntry struct {
+	Name string `json:"name"`
+
+	// File profile blob path relative to the backup directory
+	File string `json:"file"`
+}
+
+// Backup Exports all policies of the server with their profiles into directory dir.
+//
+// Directory tree mirrors administration groups hierarchy, starting from the "Managed devices" group.
+// Every policy is saved as "<group path>/<policy name>-<policy id>.policy",
+// its profiles are saved into "<policy name>-<policy id>.profiles" directory next to it.
+// dir must not exist, see BackupManifestFile.
+func (pl *Policy) Backup(ctx context.Context, dir string) (*PolicyBackupManifest, error) {
+	root, _, err := pl.client.HostGroup.GroupIdGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &PolicyBackupManifest{BackupInfo: pl.client.backupInfo()}
+	err = writeBackup(dir, manifest, func(tmp string) error {
+		return pl.client.HostGroup.WalkGroups(ctx, root.Int, func(nGroupId int64, path string) error {
+			policies, err := pl.GetPoliciesForGroup(ctx, nGroupId)
+			if err != nil {
+				manifest.Failures = append(manifest.Failures, BackupFailure{GroupPath: path, Error: err.Error()})
+				return ctx.Err()
+			}
+
+			for _, policy := range policies.PList {
+				value := policy.PListValue
+				if value == nil || value.KlpolID == nil || (value.KlpolInherited != nil && *value.KlpolInherited) {
+					continue
+				}
+
+				entry := PolicyBackupEntry{
+					ID:        *value.KlpolID,
+					Name:      stringValue(value.KlpolDN),
+					Product:   stringValue(value.KlpolProduct),
+					Version:   stringValue(value.KlpolVersion),
+					Active:    value.KlpolActive != nil && *value.KlpolActive,
+					Roaming:   value.KlpolRoaming != nil && *value.KlpolRoaming,
+					GroupID:   nGroupId,
+					GroupPath: path,
+				}
+
+				if err := pl.backupPolicy(ctx, tmp, &entry); err != nil {
+					manifest.Failures = append(manifest.Failures, entry.failure(path, err))
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					continue
+				}
+				manifest.Policies = append(manifest.Policies, entry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	if len(manifest.Failures) != 0 {
+		return manifest, fmt.Errorf("%d policies failed to be saved, see %s", len(manifest.Failures), BackupManifestFile)
+	}
+	return manifest, nil
+}
+
+func (e PolicyBackupEntry) failure(groupPath string, err error) BackupFailure {
+	return BackupFailure{ID: strconv.FormatInt(e.ID, 10), Name: e.Name, GroupPath: groupPath, Error: err.Error()}
+}
+
+func (pl *Policy) backupPolicy(ctx context.Context, dir string, entry *PolicyBackupEntry) error {
+	blob, _, err := pl.ExportPolicyBlob(ctx, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	base := filepath.Join(groupPathToDir(entry.GroupPath), fmt.Sprintf("%s-%d", sanitizeFileName(entry.Name), entry.ID))
+	entry.File = filepath.ToSlash(base + ".policy")
+	if err := writeFile(filepath.Join(dir, entry.File), blob.Binary); err != nil {
+		return err
+	}
+
+	priorities, err := pl.client.PolicyProfiles.GetPriorities(ctx, entry.ID, 0)
+	if err != nil {
+		return err
+	}
+
+	names := new(PxgValArrayOfString)
+	if err := json.Unmarshal(priorities, names); err != nil {
+		return err
+	}
+
+	for i, name := range names.Array {
+		raw, err := pl.client.PolicyProfiles.ExportProfile(ctx, entry.ID, name)
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+
+		profile := new(PxgValBinary)
+		if err := json.Unmarshal(raw, profile); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+
+		file := filepath.ToSlash(filepath.Join(base+".profiles", fmt.Sprintf("%02d-%s.profile", i, sanitizeFileName(name))))
+		if err := writeFile(filepath.Join(dir, file), profile.Binary); err != nil {
+			return err
+		}
+		entry.Profiles = append(entry.Profiles, PolicyProfileBackupEntry{Name: name, File: file})
+	}
+	return nil
+}
+
+// PolicyRestoreOptions struct using in Policy.Restore
+type PolicyRestoreOptions struct {
+	// GroupMap remaps backup group paths to target group paths by prefix,
+	// e.g. {"Managed devices/Old branch": "Managed devices/New branch"}. The longest matching prefix wins.
+	GroupMap map[string]string
+
+	// CreateGroups creates missing target groups, otherwise policies of missing groups fail to be restored
+	CreateGroups bool
+
+	// Activate makes restored policies active if they were active at the backup time.
+	// Restored policies are inactive otherwise.
+	Activate bool
+
+	// Filter restores only policies for which Filter returns true. All policies are restored if nil
+	Filter func(entry PolicyBackupEntry) bool
+
+	// Replace deletes policies with the same name, product and version found in the target group before
+	// importing them. Such policies are skipped otherwise, so an interrupted restore may be re-run.
+	Replace bool
+}
+
+// PolicyRestoreResult restored policy
+type PolicyRestoreResult struct {
+	Entry PolicyBackupEntry
+
+	// NewID id of the imported policy
+	NewID int64
+
+	// GroupPath target group path
+	GroupPath string
+
+	// Skipped true if the policy already exists in the target group and was not imported,
+	// NewID is id of the existing policy then
+	Skipped bool
+}
+
+// Restore Imports policies saved by Policy.Backup from directory dir.
+//
+// Policies are imported into groups with the same paths or remapped by opts.GroupMap,
+// their profiles are imported and profile priorities are restored.
+// Policies already present in the target group are skipped or, with opts.Replace, replaced.
+// A policy is imported completely or not at all: if its profiles fail to be restored, the new policy is deleted.
+// A replaced policy is deleted only after its replacement is imported with all profiles.
+// Failures of single policies do not stop the restore, they are returned with an error summarizing them.
+func (pl *Policy) Restore(ctx context.Context, dir string, opts PolicyRestoreOptions) ([]PolicyRestoreResult, []BackupFailure, error) {
+	manifest := new(PolicyBackupManifest)
+	if err := readBackupManifest(dir, manifest); err != nil {
+		return nil, nil, err
+	}
+
+	var restored []PolicyRestoreResult
+	var failures []BackupFailure
+	for _, entry := range manifest.Policies {
+		if opts.Filter != nil && !opts.Filter(entry) {
+			continue
+		}
+
+		target := remapGroupPath(entry.GroupPath, opts.GroupMap)
+		id, skipped, err := pl.restorePolicy(ctx, dir, entry, target, opts)
+		if err != nil {
+			failures = append(failures, entry.failure(target, err))
+			if ctx.Err() != nil {
+				return restored, failures, ctx.Err()
+			}
+			continue
+		}
+		restored = append(restored, PolicyRestoreResult{Entry: entry, NewID: id, GroupPath: target, Skipped: skipped})
+	}
+
+	if len(failures) != 0 {
+		return restored, failures, fmt.Errorf("%d of %d policies failed to be restored", len(failures),
+			len(failures)+len(restored))
+	}
+	return restored, nil, nil
+}
+
+func (pl *Policy) restorePolicy(ctx context.Context, dir string, entry PolicyBackupEntry, groupPath string,
+	opts PolicyRestoreOptions) (int64, bool, error) {
+	groupID, err := pl.client.HostGroup.resolveGroupPath(ctx, groupPath, opts.CreateGroups)
+	if err != nil {
+		return 0, false, err
+	}
+
+	existing, found, err := pl.findGroupPolicy(ctx, groupID, entry)
+	if err != nil {
+		return 0, false, err
+	}
+	if found && !opts.Replace {
+		return existing, true, nil
+	}
+
+	blob, err := readBackupFile(dir, entry.File)
+	if err != nil {
+		return 0, false, err
+	}
+
+	policy, _, err := pl.ImportPolicyBlob(ctx, PolicyBinaryBlob{LGroup: groupID, PData: blob})
+	if err != nil {
+		return 0, false, err
+	}
+
+	// the existing policy is deleted only when its replacement is imported completely
+	err = pl.restoreProfiles(ctx, dir, entry, policy.Int, opts)
+	if err == nil && found {
+		if err = pl.DeletePolicy(ctx, existing); err != nil {
+			err = fmt.Errorf("delete existing policy %d: %w", existing, err)
+		}
+	}
+	if err != nil {
+		if derr := pl.DeletePolicy(ctx, policy.Int); derr != nil {
+			return 0, false, fmt.Errorf("%w (imported policy %d was not deleted: %v)", err, policy.Int, derr)
+		}
+		return 0, false, err
+	}
+	return policy.Int, false, nil
+}
+
+// restoreProfiles imports profiles of policy entry into imported policy lPolicy and activates it if needed.
+func (pl *Policy) restoreProfiles(ctx context.Context, dir string, entry PolicyBackupEntry, lPolicy int64,
+	opts PolicyRestoreOptions) error {
+	var names []string
+	for _, profile := range entry.Profiles {
+		blob, err := readBackupFile(dir, profile.File)
+		if err != nil {
+			return err
+		}
+
+		if _, err := pl.client.PolicyProfiles.ImportProfileBlob(ctx, lPolicy, blob); err != nil {
+			return fmt.Errorf("profile %q: %w", profile.Name, err)
+		}
+		names = append(names, profile.Name)
+	}
+
+	if len(names) > 1 {
+		_, err := pl.client.PolicyProfiles.PutPriorities(ctx, ProfilesPrioritiesParams{NPolicy: lPolicy, PArrayOfNames: names})
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Activate && entry.Active {
+		if _, err := pl.MakePolicyActive(ctx, lPolicy, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findGroupPolicy looks up own policy of group nGroupId with the same name, product and version as entry.
+func (pl *Policy) findGroupPolicy(ctx context.Context, nGroupId int64, entry PolicyBackupEntry) (int64, bool, error) {
+	policies, err := pl.GetPoliciesForGroup(ctx, nGroupId)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, policy := range policies.PList {
+		value := policy.PListValue
+		if value == nil || value.KlpolID == nil || (value.KlpolInherited != nil && *value.KlpolInherited) {
+			continue
+		}
+		if strings.EqualFold(stringValue(value.KlpolDN), entry.Name) && stringValue(value.KlpolProduct) == entry.Product &&
+			stringValue(value.KlpolVersion) == entry.Version {
+			return *value.KlpolID, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
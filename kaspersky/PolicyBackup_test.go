@@ -0,0 +1,123 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestPolicyBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	client, handler, backup := newBackupTest(t, nil)
+
+	var imported []kaspersky.PolicyBinaryBlob
+	var deleted []int64
+	handler.HandleFunc("/api/v1.0/HostGroup.GetSubgroups", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NParent int64 `json:"nParent"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NParent == 1 {
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Branch: A"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": []}`))
+	})
+	handler.HandleFunc("/api/v1.0/Policy.GetPoliciesForGroup", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NGroupID int64 `json:"nGroupId"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NGroupID == 2 || (in.NGroupID == 3 && len(imported) != 0 && !containsID(deleted, 10)) {
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {
+				"KLPOL_ID": 10, "KLPOL_DN": "KES policy", "KLPOL_PRODUCT": "KES", "KLPOL_VERSION": "11.0.0.0",
+				"KLPOL_ACTIVE": true, "KLPOL_INHERITED": false}}]}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": []}`))
+	})
+	handler.HandleFunc("/api/v1.0/Policy.ExportPolicy", HandlerFuncOk(`{"PxgRetVal": {"type": "binary", "value": "cG9saWN5"}}`))
+	handler.HandleFunc("/api/v1.0/PolicyProfiles.GetPriorities", HandlerFuncOk(`{"PxgRetVal": ["Laptops"]}`))
+	handler.HandleFunc("/api/v1.0/PolicyProfiles.ExportProfile", HandlerFuncOk(`{"PxgRetVal": "cHJvZmlsZQ=="}`))
+
+	manifest, err := client.Policy.Backup(ctx, backup)
+	expectSucceeded(t, err)
+	expectEqual(t, 1, len(manifest.Policies))
+	expectEqual(t, "Managed devices/Branch: A", manifest.Policies[0].GroupPath)
+	expectEqual(t, "Managed devices/Branch_ A/KES policy-10.policy", manifest.Policies[0].File)
+	expectBackupFile(t, backup, manifest.Policies[0].File, "policy")
+	expectBackupFile(t, backup, manifest.Policies[0].Profiles[0].File, "profile")
+
+	handler.HandleFunc("/api/v1.0/HostGroup.AddGroup", HandlerFuncOk(`{"PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/Policy.ImportPolicy", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.PolicyBinaryBlob
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		imported = append(imported, in)
+		w.Write([]byte(`{"PxgRetVal": 20}`))
+	})
+	profileFails := false
+	handler.HandleFunc("/api/v1.0/PolicyProfiles.ImportProfile", func(w http.ResponseWriter, r *http.Request) {
+		if profileFails {
+			w.Write([]byte(`{"PxgError": {"code": 1183, "file": "", "line": 0, "module": "KLSTD", "message": "Access denied"}}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/Policy.DeletePolicy", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NPolicy int64 `json:"nPolicy"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		deleted = append(deleted, in.NPolicy)
+		w.Write([]byte(`{}`))
+	})
+
+	opts := kaspersky.PolicyRestoreOptions{
+		GroupMap:     map[string]string{"Managed devices/Branch: A": "Managed devices/Branch B"},
+		CreateGroups: true,
+	}
+	restored, failures, err := client.Policy.Restore(ctx, backup, opts)
+	expectSucceeded(t, err)
+	expectEqual(t, 0, len(failures))
+	expectEqual(t, int64(20), restored[0].NewID)
+	expectEqual(t, "Managed devices/Branch B", restored[0].GroupPath)
+	expectEqual(t, []kaspersky.PolicyBinaryBlob{{LGroup: 3, PData: kaspersky.Binary("policy")}}, imported)
+
+	// Re-run skips the policy already restored into the target group.
+	restored, _, err = client.Policy.Restore(ctx, backup, opts)
+	expectSucceeded(t, err)
+	expectEqual(t, true, restored[0].Skipped)
+	expectEqual(t, int64(10), restored[0].NewID)
+	expectEqual(t, 1, len(imported))
+
+	// A failed profile import deletes the new policy and keeps the existing one.
+	profileFails = true
+	opts.Replace = true
+	_, failures, err = client.Policy.Restore(ctx, backup, opts)
+	if err == nil {
+		t.Fatal("expected profile import error")
+	}
+	expectEqual(t, 1, len(failures))
+	expectEqual(t, 2, len(imported))
+	expectEqual(t, []int64{20}, deleted)
+
+	// Replacing deletes the existing policy once the new one is imported.
+	profileFails = false
+	restored, _, err = client.Policy.Restore(ctx, backup, opts)
+	expectSucceeded(t, err)
+	expectEqual(t, int64(20), restored[0].NewID)
+	expectEqual(t, 3, len(imported))
+	expectEqual(t, []int64{20, 10}, deleted)
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
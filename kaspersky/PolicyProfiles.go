@@ -177,6 +177,16 @@ func (pp *PolicyProfiles) ImportProfile(ctx context.Context, lPolicy int64, pDat
 	return raw, err
 }
 
+// ImportProfileBlob Import profile from blob exported by PolicyProfiles.ExportProfile.
+// Unlike PolicyProfiles.ImportProfile takes decoded blob.
+func (pp *PolicyProfiles) ImportProfileBlob(ctx context.Context, lPolicy int64, pData Binary) ([]byte, error) {
+	params := struct {
+		LPolicy int64  `json:"lPolicy"`
+		PData   Binary `json:"pData"`
+	}{lPolicy, pData}
+	return pp.client.PostInOut(ctx, "/api/v1.0/PolicyProfiles.ImportProfile", params, nil)
+}
+
 // ProfilesPrioritiesParams struct
 type ProfilesPrioritiesParams struct {
 	// NPolicy policy id
@@ -0,0 +1,291 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SettingsSection settings storage section address
+type SettingsSection struct {
+	Product string `json:"product"`
+	Version string `json:"version"`
+	Section string `json:"section"`
+}
+
+// String returns section address as "product/version/section".
+func (s SettingsSection) String() string {
+	return s.Product + "/" + s.Version + "/" + s.Section
+}
+
+// SettingsSnapshot contents of all sections of settings storage
+type SettingsSnapshot map[SettingsSection]Params
+
+// Sections returns snapshot sections sorted by address.
+func (s SettingsSnapshot) Sections() []SettingsSection {
+	sections := make([]SettingsSection, 0, len(s))
+	for section := range s {
+		sections = append(sections, section)
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].String() < sections[j].String() })
+	return sections
+}
+
+// ListSectionNames Retrieves names of settings storage level, see SsContents.SSGetNames: products if wstrProduct is empty,
+// versions of wstrProduct if wstrVersion is empty and sections of wstrProduct and wstrVersion otherwise.
+func (sc *SsContents) ListSectionNames(ctx context.Context, wstrID, wstrProduct, wstrVersion string) ([]string, error) {
+	names, _, err := sc.SSGetNames(ctx, SsContentD{WstrID: wstrID, WstrProduct: wstrProduct, WstrVersion: wstrVersion})
+	if err != nil {
+		return nil, err
+	}
+	return names.Array, nil
+}
+
+// SsReadParams Reads saved data of the specified section of opened settings storage as Params.
+func (sc *SsContents) SsReadParams(ctx context.Context, wstrID string, section SettingsSection) (Params, error) {
+	out := &struct {
+		Params Params `json:"PxgRetVal"`
+	}{}
+	_, err := sc.SsRead(ctx, SsContentD{
+		WstrID:      wstrID,
+		WstrProduct: section.Product,
+		WstrVersion: section.Version,
+		WstrSection: section.Section,
+	}, out)
+	return out.Params, err
+}
+
+// SsReadAll Reads all sections of opened settings storage wstrID.
+func (sc *SsContents) SsReadAll(ctx context.Context, wstrID string) (SettingsSnapshot, error) {
+	snapshot := SettingsSnapshot{}
+	products, err := sc.ListSectionNames(ctx, wstrID, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, product := range products {
+		versions, err := sc.ListSectionNames(ctx, wstrID, product, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range versions {
+			sections, err := sc.ListSectionNames(ctx, wstrID, product, version)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, name := range sections {
+				section := SettingsSection{Product: product, Version: version, Section: name}
+				params, err := sc.SsReadParams(ctx, wstrID, section)
+				if err != nil {
+					return nil, fmt.Errorf("read section %s: %w", section, err)
+				}
+				snapshot[section] = params
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// policyContentsLifeTime lifetime in seconds of settings storages opened to read policy settings
+const policyContentsLifeTime = 600
+
+// GetPolicySettings Reads all sections of policy nPolicy settings.
+//
+// nRevisionId is the policy revision, 0 means the current policy settings.
+// Settings storage opened by Policy.GetPolicyContents is always released.
+func (pl *Policy) GetPolicySettings(ctx context.Context, nPolicy, nRevisionId int64) (SettingsSnapshot, error) {
+	contents, err := pl.GetPolicyContents(ctx, nPolicy, nRevisionId, policyContentsLifeTime)
+	if err != nil {
+		return nil, err
+	}
+	defer pl.client.SsContents.SsRelease(context.Background(), contents.Str)
+
+	return pl.client.SsContents.SsReadAll(ctx, contents.Str)
+}
+
+// DiffRevisions Compares settings of two revisions of policy nPolicy, 0 means the current policy settings.
+func (pl *Policy) DiffRevisions(ctx context.Context, nPolicy, nRevisionFrom, nRevisionTo int64) (*SettingsDiff, error) {
+	from, err := pl.GetPolicySettings(ctx, nPolicy, nRevisionFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := pl.GetPolicySettings(ctx, nPolicy, nRevisionTo)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSettings(from, to), nil
+}
+
+// DiffPolicies Compares current settings of two different policies.
+func (pl *Policy) DiffPolicies(ctx context.Context, nPolicyFrom, nPolicyTo int64) (*SettingsDiff, error) {
+	from, err := pl.GetPolicySettings(ctx, nPolicyFrom, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := pl.GetPolicySettings(ctx, nPolicyTo, 0)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSettings(from, to), nil
+}
+
+// DiffChange kind of settings change
+type DiffChange string
+
+const (
+	DiffAdded   DiffChange = "added"
+	DiffRemoved DiffChange = "removed"
+	DiffChanged DiffChange = "changed"
+)
+
+var diffSigns = map[DiffChange]string{DiffAdded: "+", DiffRemoved: "-", DiffChanged: "~"}
+
+// SettingsDiff structured difference of two settings snapshots
+type SettingsDiff struct {
+	Sections []SectionDiff `json:"sections"`
+}
+
+// SectionDiff difference of settings storage section
+type SectionDiff struct {
+	Section SettingsSection `json:"section"`
+	Change  DiffChange      `json:"change"`
+	Keys    []KeyDiff       `json:"keys"`
+}
+
+// KeyDiff difference of single value.
+//
+// Key is a path of the value inside the section as returned by Params.Flatten,
+// values are rendered as compact JSON.
+type KeyDiff struct {
+	Key    string     `json:"key"`
+	Change DiffChange `json:"change"`
+	From   *string    `json:"from,omitempty"`
+	To     *string    `json:"to,omitempty"`
+}
+
+// DiffSettings Compares two settings snapshots by section and key.
+func DiffSettings(from, to SettingsSnapshot) *SettingsDiff {
+	all := SettingsSnapshot{}
+	for section := range from {
+		all[section] = nil
+	}
+	for section := range to {
+		all[section] = nil
+	}
+
+	diff := &SettingsDiff{Sections: []SectionDiff{}}
+	for _, section := range all.Sections() {
+		before, inFrom := from[section]
+		after, inTo := to[section]
+
+		sectionDiff := SectionDiff{Section: section, Change: DiffChanged, Keys: diffParams(before, after)}
+		switch {
+		case !inFrom:
+			sectionDiff.Change = DiffAdded
+		case !inTo:
+			sectionDiff.Change = DiffRemoved
+		case len(sectionDiff.Keys) == 0:
+			continue
+		}
+		diff.Sections = append(diff.Sections, sectionDiff)
+	}
+	return diff
+}
+
+func diffParams(from, to Params) []KeyDiff {
+	before, after := from.Flatten(), to.Flatten()
+
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	diff := []KeyDiff{}
+	for _, key := range keys {
+		old, inFrom := before[key]
+		updated, inTo := after[key]
+		switch {
+		case !inFrom:
+			diff = append(diff, KeyDiff{Key: key, Change: DiffAdded, To: String(updated)})
+		case !inTo:
+			diff = append(diff, KeyDiff{Key: key, Change: DiffRemoved, From: String(old)})
+		case old != updated:
+			diff = append(diff, KeyDiff{Key: key, Change: DiffChanged, From: String(old), To: String(updated)})
+		}
+	}
+	return diff
+}
+
+// Empty returns true if snapshots are equal.
+func (d *SettingsDiff) Empty() bool {
+	return len(d.Sections) == 0
+}
+
+// WriteText writes human readable diff to w.
+func (d *SettingsDiff) WriteText(w io.Writer) error {
+	var b strings.Builder
+	if d.Empty() {
+		b.WriteString("No differences.\n")
+	}
+
+	for _, section := range d.Sections {
+		fmt.Fprintf(&b, "%s section %s\n", diffSigns[section.Change], section.Section)
+		for _, key := range section.Keys {
+			switch key.Change {
+			case DiffAdded:
+				fmt.Fprintf(&b, "    + %s = %s\n", key.Key, *key.To)
+			case DiffRemoved:
+				fmt.Fprintf(&b, "    - %s = %s\n", key.Key, *key.From)
+			default:
+				fmt.Fprintf(&b, "    ~ %s: %s -> %s\n", key.Key, *key.From, *key.To)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes diff as indented JSON to w.
+func (d *SettingsDiff) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
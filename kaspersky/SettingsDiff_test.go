@@ -0,0 +1,52 @@
+package kaspersky_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestDiffSettings(t *testing.T) {
+	decode := func(data string) kaspersky.Params {
+		var params kaspersky.Params
+		expectSucceeded(t, json.Unmarshal([]byte(data), &params))
+		return params
+	}
+
+	section := kaspersky.SettingsSection{Product: "KES", Version: "11.0.0.0", Section: "85"}
+	removed := kaspersky.SettingsSection{Product: "KES", Version: "11.0.0.0", Section: "86"}
+	from := kaspersky.SettingsSnapshot{
+		section: decode(`{"Enabled": true, "Level": 1, "Scope": {"type": "params", "value": {"Path": "C:\\"}}}`),
+		removed: decode(`{}`),
+	}
+	to := kaspersky.SettingsSnapshot{
+		section: decode(`{"Enabled": true, "Level": 2, "Scope": {"type": "params", "value": {"Path": "D:\\"}},
+			"Since": {"type": "datetime", "value": "2020-05-28T07:22:14Z"}}`),
+	}
+
+	diff := kaspersky.DiffSettings(from, to)
+	expectEqual(t, 2, len(diff.Sections))
+	expectEqual(t, kaspersky.DiffChanged, diff.Sections[0].Change)
+	expectEqual(t, kaspersky.DiffRemoved, diff.Sections[1].Change)
+
+	var keys []string
+	for _, key := range diff.Sections[0].Keys {
+		keys = append(keys, string(key.Change)+" "+key.Key)
+	}
+	expectEqual(t, []string{"changed Level", "changed Scope.Path", "added Since"}, keys)
+
+	var text bytes.Buffer
+	expectSucceeded(t, diff.WriteText(&text))
+	if !strings.Contains(text.String(), `~ Level: 1 -> 2`) {
+		t.Fatalf("unexpected diff text:\n%s", text.String())
+	}
+
+	data, err := json.Marshal(to[section])
+	expectSucceeded(t, err)
+	if !strings.Contains(string(data), `"Scope":{"type":"params","value":{"Path":"D:\\"}}`) {
+		t.Fatalf("nested params must be encoded as typed params: %s", data)
+	}
+}
@@ -0,0 +1,332 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SettingsOp settings storage change operation
+type SettingsOp string
+
+const (
+	SettingsOpUpdate        SettingsOp = "update"
+	SettingsOpReplace       SettingsOp = "replace"
+	SettingsOpAdd           SettingsOp = "add"
+	SettingsOpClear         SettingsOp = "clear"
+	SettingsOpDelete        SettingsOp = "delete"
+	SettingsOpCreateSection SettingsOp = "create section"
+	SettingsOpDeleteSection SettingsOp = "delete section"
+)
+
+// SettingsChange pending settings storage change
+type SettingsChange struct {
+	Op      SettingsOp
+	Section SettingsSection
+
+	// Data variables to write, or variables to delete for SettingsOpDelete
+	Data Params
+}
+
+// ErrSettingsStorageClosed is returned by SettingsStorage methods called after SettingsStorage.Close.
+var ErrSettingsStorageClosed = errors.New("settings storage is closed")
+
+// SettingsStorage opened settings storage editor.
+//
+// Changes are kept by the editor and sent to the server on Commit, which saves them at once with SsContents.SsApply,
+// so either all changes are saved or none. Read returns saved data with pending changes applied.
+//
+// Close must always be called to release server resources, e.g.:
+//
+//	ss := client.SsContents.Open(wstrID)
+//	defer ss.Close()
+type SettingsStorage struct {
+	sc      *SsContents
+	id      string
+	saved   map[SettingsSection]Params
+	pending []SettingsChange
+	broken  error
+	closed  bool
+}
+
+// Open returns editor of settings storage wstrID opened e.g. by Policy.GetPolicyContents.
+// The editor takes ownership of wstrID and releases it on SettingsStorage.Close.
+func (sc *SsContents) Open(wstrID string) *SettingsStorage {
+	return &SettingsStorage{sc: sc, id: wstrID, saved: map[SettingsSection]Params{}}
+}
+
+// ID returns settings storage id
+func (ss *SettingsStorage) ID() string {
+	return ss.id
+}
+
+// Names retrieves names of settings storage level, see SsContents.ListSectionNames. Pending changes are not reflected.
+func (ss *SettingsStorage) Names(ctx context.Context, product, version string) ([]string, error) {
+	if ss.closed {
+		return nil, ErrSettingsStorageClosed
+	}
+	return ss.sc.ListSectionNames(ctx, ss.id, product, version)
+}
+
+// Read returns copy of section data with pending changes applied.
+func (ss *SettingsStorage) Read(ctx context.Context, section SettingsSection) (Params, error) {
+	if ss.closed {
+		return nil, ErrSettingsStorageClosed
+	}
+
+	data, exists, err := ss.read(ctx, section)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range ss.pending {
+		if change.Section != section {
+			continue
+		}
+
+		switch change.Op {
+		case SettingsOpCreateSection:
+			if !exists {
+				data, exists = Params{}, true
+			}
+		case SettingsOpDeleteSection:
+			data, exists = nil, false
+		case SettingsOpClear:
+			data, exists = change.Data.Clone(), true
+		case SettingsOpDelete:
+			for key := range change.Data {
+				delete(data, key)
+			}
+		default:
+			if !exists {
+				// the server rejects changes of a missing section
+				continue
+			}
+			for key, value := range change.Data {
+				_, has := data[key]
+				if (change.Op == SettingsOpUpdate && !has) || (change.Op == SettingsOpAdd && has) {
+					continue
+				}
+				data[key] = cloneParamValue(value)
+			}
+		}
+	}
+
+	if !exists {
+		return nil, fmt.Errorf("settings section %s does not exist", section)
+	}
+	return data, nil
+}
+
+// read returns copy of saved section data, reading it once.
+func (ss *SettingsStorage) read(ctx context.Context, section SettingsSection) (Params, bool, error) {
+	if data, ok := ss.saved[section]; ok {
+		return data.Clone(), data != nil, nil
+	}
+
+	created := false
+	for _, change := range ss.pending {
+		if change.Section == section && (change.Op == SettingsOpCreateSection || change.Op == SettingsOpClear) {
+			created = true
+			break
+		}
+	}
+
+	data, err := ss.sc.SsReadParams(ctx, ss.id, section)
+	if err != nil {
+		if created {
+			// section is created by pending changes
+			return Params{}, false, nil
+		}
+		return nil, false, err
+	}
+
+	if data == nil {
+		data = Params{}
+	}
+	ss.saved[section] = data
+	return data.Clone(), true, nil
+}
+
+// Update updates existing variables of section
+func (ss *SettingsStorage) Update(section SettingsSection, data Params) {
+	ss.change(SettingsOpUpdate, section, data)
+}
+
+// Replace updates existing variables of section and adds missing ones
+func (ss *SettingsStorage) Replace(section SettingsSection, data Params) {
+	ss.change(SettingsOpReplace, section, data)
+}
+
+// Set sets variable key of section, see SettingsStorage.Replace
+func (ss *SettingsStorage) Set(section SettingsSection, key string, value interface{}) {
+	ss.change(SettingsOpReplace, section, Params{key: value})
+}
+
+// Add adds new variables to section
+func (ss *SettingsStorage) Add(section SettingsSection, data Params) {
+	ss.change(SettingsOpAdd, section, data)
+}
+
+// Clear replaces section contents with data
+func (ss *SettingsStorage) Clear(section SettingsSection, data Params) {
+	ss.change(SettingsOpClear, section, data)
+}
+
+// Delete deletes variables keys from section
+func (ss *SettingsStorage) Delete(section SettingsSection, keys ...string) {
+	data := make(Params, len(keys))
+	for _, key := range keys {
+		data[key] = nil
+	}
+	ss.change(SettingsOpDelete, section, data)
+}
+
+// CreateSection creates empty section
+func (ss *SettingsStorage) CreateSection(section SettingsSection) {
+	ss.change(SettingsOpCreateSection, section, nil)
+}
+
+// DeleteSection deletes section with all its contents
+func (ss *SettingsStorage) DeleteSection(section SettingsSection) {
+	ss.change(SettingsOpDeleteSection, section, nil)
+}
+
+func (ss *SettingsStorage) change(op SettingsOp, section SettingsSection, data Params) {
+	ss.pending = append(ss.pending, SettingsChange{Op: op, Section: section, Data: data.Clone()})
+}
+
+// Pending returns changes not committed yet
+func (ss *SettingsStorage) Pending() []SettingsChange {
+	return append([]SettingsChange(nil), ss.pending...)
+}
+
+// Discard drops changes not committed yet
+func (ss *SettingsStorage) Discard() {
+	ss.pending = nil
+}
+
+// Commit sends pending changes to the server and saves them with SsContents.SsApply.
+//
+// If some change fails none of the changes are saved, the server keeps them unsaved
+// so the storage can only be closed afterwards.
+func (ss *SettingsStorage) Commit(ctx context.Context) error {
+	if ss.closed {
+		return ErrSettingsStorageClosed
+	}
+	if ss.broken != nil {
+		return fmt.Errorf("settings storage has failed changes: %w", ss.broken)
+	}
+	if len(ss.pending) == 0 {
+		return nil
+	}
+
+	for _, change := range ss.pending {
+		if err := ss.send(ctx, change); err != nil {
+			ss.broken = fmt.Errorf("%s %s: %w", change.Op, change.Section, err)
+			return ss.broken
+		}
+	}
+
+	if _, err := ss.sc.SsApply(ctx, ss.id); err != nil {
+		ss.broken = err
+		return err
+	}
+
+	ss.pending = nil
+	ss.saved = map[SettingsSection]Params{}
+	return nil
+}
+
+func (ss *SettingsStorage) send(ctx context.Context, change SettingsChange) error {
+	content := SsContent{
+		WstrID:      ss.id,
+		WstrProduct: change.Section.Product,
+		WstrVersion: change.Section.Version,
+		WstrSection: change.Section.Section,
+		PNewData:    change.Data,
+	}
+	contentD := SsContentD{
+		WstrID:      ss.id,
+		WstrProduct: change.Section.Product,
+		WstrVersion: change.Section.Version,
+		WstrSection: change.Section.Section,
+	}
+
+	var err error
+	switch change.Op {
+	case SettingsOpUpdate:
+		_, err = ss.sc.SsUpdate(ctx, content)
+	case SettingsOpReplace:
+		_, err = ss.sc.SsReplace(ctx, content)
+	case SettingsOpAdd:
+		_, err = ss.sc.SsAdd(ctx, content)
+	case SettingsOpClear:
+		_, err = ss.sc.SsClear(ctx, content)
+	case SettingsOpDelete:
+		contentD.PData = change.Data
+		_, err = ss.sc.SsDelete(ctx, contentD)
+	case SettingsOpCreateSection:
+		_, err = ss.sc.SsCreateSection(ctx, contentD)
+	case SettingsOpDeleteSection:
+		_, err = ss.sc.SsDeleteSection(ctx, contentD)
+	default:
+		err = fmt.Errorf("unknown operation %q", change.Op)
+	}
+	return err
+}
+
+// Close releases settings storage with SsContents.SsRelease dropping changes not committed yet.
+// Close is safe to call more than once.
+func (ss *SettingsStorage) Close() error {
+	if ss.closed {
+		return nil
+	}
+	ss.closed = true
+	ss.pending = nil
+	_, err := ss.sc.SsRelease(context.Background(), ss.id)
+	return err
+}
+
+// EditContents Opens settings storage of policy nPolicy and passes it to fn.
+//
+// Changes made by fn are committed if fn returns nil and dropped otherwise.
+// The settings storage is always released.
+func (pl *Policy) EditContents(ctx context.Context, nPolicy int64, fn func(ss *SettingsStorage) error) error {
+	contents, err := pl.GetPolicyContents(ctx, nPolicy, 0, policyContentsLifeTime)
+	if err != nil {
+		return err
+	}
+
+	ss := pl.client.SsContents.Open(contents.Str)
+	defer ss.Close()
+
+	if err := fn(ss); err != nil {
+		return err
+	}
+	return ss.Commit(ctx)
+}
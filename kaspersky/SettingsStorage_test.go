@@ -0,0 +1,72 @@
+package kaspersky_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestPolicyEditContents(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(response string) func(http.ResponseWriter, *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls = append(calls, strings.TrimPrefix(r.URL.Path, "/api/v1.0/SsContents."))
+			mu.Unlock()
+			w.Write([]byte(response))
+		}
+	}
+
+	handler.HandleFunc("/api/v1.0/Policy.GetPolicyContents", HandlerFuncOk(`{"PxgRetVal": "ss-1"}`))
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Read", record(`{"PxgRetVal": {"Enabled": true, "Level": 1}}`))
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Replace", record(`{}`))
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Delete", record(`{}`))
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Apply", record(`{}`))
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Release", record(`{}`))
+
+	section := kaspersky.SettingsSection{Product: "KES", Version: "11.0.0.0", Section: "FileMonitor"}
+	err := client.Policy.EditContents(ctx, 10, func(ss *kaspersky.SettingsStorage) error {
+		ss.Set(section, "Level", 2)
+		ss.Delete(section, "Enabled")
+
+		data, err := ss.Read(ctx, section)
+		if err != nil {
+			return err
+		}
+		expectEqual(t, kaspersky.Params{"Level": 2}, data)
+		expectEqual(t, 2, len(ss.Pending()))
+		return nil
+	})
+	expectSucceeded(t, err)
+	expectEqual(t, []string{"Ss_Read", "Ss_Replace", "Ss_Delete", "Ss_Apply", "Ss_Release"}, calls)
+
+	calls = nil
+	failed := errors.New("failed")
+	err = client.Policy.EditContents(ctx, 10, func(ss *kaspersky.SettingsStorage) error {
+		ss.Set(section, "Level", 3)
+
+		// changes of a deleted section are not applied
+		ss.DeleteSection(section)
+		ss.Add(section, kaspersky.Params{"Level": 4})
+		if _, err := ss.Read(ctx, section); err == nil {
+			t.Error("expected error reading deleted section")
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("expected fn error, got %v", err)
+	}
+	expectEqual(t, []string{"Ss_Read", "Ss_Release"}, calls)
+}
@@ -27,7 +27,7 @@ package kaspersky
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"encoding/json"
 	"net/http"
 )
 
@@ -35,26 +35,38 @@ import (
 type SrvSsRevision service
 
 // SsRevisionOpen Open specified version of virtual server settings storage.
-func (ssr *SrvSsRevision) SsRevisionOpen(ctx context.Context, nVServer, nRevision int64, szwType string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{
-		"nVServer": %d, 
-		"nRevision": %d, 
-		"szwType": "%s"
-	}`, nVServer, nRevision, szwType))
+//
+// Returns id of the opened settings storage to be read with SsContents methods
+// and closed with SrvSsRevision.SsRevisionClose.
+func (ssr *SrvSsRevision) SsRevisionOpen(ctx context.Context, nVServer, nRevision int64, szwType string) (*PxgValStr, []byte, error) {
+	postData, err := json.Marshal(struct {
+		NVServer  int64  `json:"nVServer"`
+		NRevision int64  `json:"nRevision"`
+		SzwType   string `json:"szwType"`
+	}{nVServer, nRevision, szwType})
+	if err != nil {
+		return nil, nil, err
+	}
+
 	request, err := http.NewRequest("POST", ssr.client.Server+"/api/v1.0/SrvSsRevision.SsRevision_Open", bytes.NewBuffer(postData))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	raw, err := ssr.client.Do(ctx, request, nil)
-	return raw, err
+	pxgValStr := new(PxgValStr)
+	raw, err := ssr.client.Do(ctx, request, &pxgValStr)
+	return pxgValStr, raw, err
 }
 
-// SsRevisionClose Close settings storage opened by SrvSsRevision.SsRevisionOpen
-func (ssr *SrvSsRevision) SsRevisionClose(ctx context.Context, szwType string) ([]byte, error) {
-	postData := []byte(fmt.Sprintf(`{
-		"szwType": "%s"
-	}`, szwType))
+// SsRevisionClose Close settings storage szwId opened by SrvSsRevision.SsRevisionOpen
+func (ssr *SrvSsRevision) SsRevisionClose(ctx context.Context, szwId string) ([]byte, error) {
+	postData, err := json.Marshal(struct {
+		SzwID string `json:"szwId"`
+	}{szwId})
+	if err != nil {
+		return nil, err
+	}
+
 	request, err := http.NewRequest("POST", ssr.client.Server+"/api/v1.0/SrvSsRevision.SsRevision_Close", bytes.NewBuffer(postData))
 	if err != nil {
 		return nil, err
@@ -63,3 +75,107 @@ func (ssr *SrvSsRevision) SsRevisionClose(ctx context.Context, szwType string) (
 	raw, err := ssr.client.Do(ctx, request, nil)
 	return raw, err
 }
+
+// Virtual server settings storage types, szwType of SrvSsRevision.SsRevisionOpen
+const (
+	SsTypeSettings    = "SS_SETTINGS"
+	SsTypeProductInfo = "SS_PRODINFO"
+)
+
+// SettingsRevision read-only view of virtual server settings storage revision
+// opened by SrvSsRevision.OpenRevision.
+//
+// Close must always be called to release server resources.
+type SettingsRevision struct {
+	ssr *SrvSsRevision
+	id  string
+
+	VServer  int64
+	Revision int64
+	Type     string
+
+	closed bool
+}
+
+// OpenRevision Opens revision nRevision of virtual server nVServer settings storage of type szwType,
+// nRevision 0 means the current settings.
+func (ssr *SrvSsRevision) OpenRevision(ctx context.Context, nVServer, nRevision int64, szwType string) (*SettingsRevision, error) {
+	id, _, err := ssr.SsRevisionOpen(ctx, nVServer, nRevision, szwType)
+	if err != nil {
+		return nil, err
+	}
+	return &SettingsRevision{ssr: ssr, id: id.Str, VServer: nVServer, Revision: nRevision, Type: szwType}, nil
+}
+
+// Names retrieves names of revision settings storage level, see SsContents.ListSectionNames.
+func (r *SettingsRevision) Names(ctx context.Context, product, version string) ([]string, error) {
+	if r.closed {
+		return nil, ErrSettingsStorageClosed
+	}
+	return r.ssr.client.SsContents.ListSectionNames(ctx, r.id, product, version)
+}
+
+// Read reads section of the revision.
+func (r *SettingsRevision) Read(ctx context.Context, section SettingsSection) (Params, error) {
+	if r.closed {
+		return nil, ErrSettingsStorageClosed
+	}
+	return r.ssr.client.SsContents.SsReadParams(ctx, r.id, section)
+}
+
+// ReadAll reads all sections of the revision.
+func (r *SettingsRevision) ReadAll(ctx context.Context) (SettingsSnapshot, error) {
+	if r.closed {
+		return nil, ErrSettingsStorageClosed
+	}
+	return r.ssr.client.SsContents.SsReadAll(ctx, r.id)
+}
+
+// Close closes revision with SrvSsRevision.SsRevisionClose. Close is safe to call more than once.
+func (r *SettingsRevision) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	_, err := r.ssr.SsRevisionClose(context.Background(), r.id)
+	return err
+}
+
+// WithRevision Opens revision as SrvSsRevision.OpenRevision, passes it to fn and always closes it.
+func (ssr *SrvSsRevision) WithRevision(ctx context.Context, nVServer, nRevision int64, szwType string,
+	fn func(r *SettingsRevision) error) error {
+	r, err := ssr.OpenRevision(ctx, nVServer, nRevision, szwType)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return fn(r)
+}
+
+// ReadRevision Reads all sections of revision nRevision of virtual server nVServer settings storage of type szwType.
+func (ssr *SrvSsRevision) ReadRevision(ctx context.Context, nVServer, nRevision int64, szwType string) (SettingsSnapshot, error) {
+	var snapshot SettingsSnapshot
+	err := ssr.WithRevision(ctx, nVServer, nRevision, szwType, func(r *SettingsRevision) error {
+		var err error
+		snapshot, err = r.ReadAll(ctx)
+		return err
+	})
+	return snapshot, err
+}
+
+// DiffRevisions Compares two revisions of virtual server nVServer settings storage of type szwType,
+// revision 0 means the current settings, e.g. DiffRevisions(ctx, 0, 12, 0, SsTypeSettings)
+// shows what has changed since revision 12.
+func (ssr *SrvSsRevision) DiffRevisions(ctx context.Context, nVServer, nRevisionFrom, nRevisionTo int64,
+	szwType string) (*SettingsDiff, error) {
+	from, err := ssr.ReadRevision(ctx, nVServer, nRevisionFrom, szwType)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := ssr.ReadRevision(ctx, nVServer, nRevisionTo, szwType)
+	if err != nil {
+		return nil, err
+	}
+	return DiffSettings(from, to), nil
+}
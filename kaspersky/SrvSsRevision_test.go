@@ -0,0 +1,67 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestSrvSsRevisionDiff(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var open, closed int32
+	handler.HandleFunc("/api/v1.0/SrvSsRevision.SsRevision_Open", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&open, 1)
+		var in struct {
+			NRevision int64 `json:"nRevision"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NRevision == 0 {
+			w.Write([]byte(`{"PxgRetVal": "current"}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": "old"}`))
+	})
+	handler.HandleFunc("/api/v1.0/SrvSsRevision.SsRevision_Close", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&closed, 1)
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/SsContents.SS_GetNames", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.SsContentD
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		switch {
+		case in.WstrProduct == "":
+			w.Write([]byte(`{"PxgRetVal": ["1093"]}`))
+		case in.WstrVersion == "":
+			w.Write([]byte(`{"PxgRetVal": ["1.0.0.0"]}`))
+		default:
+			w.Write([]byte(`{"PxgRetVal": ["Events"]}`))
+		}
+	})
+	handler.HandleFunc("/api/v1.0/SsContents.Ss_Read", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.SsContentD
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.WstrID == "current" {
+			w.Write([]byte(`{"PxgRetVal": {"DaysToStore": 30}}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": {"DaysToStore": 7}}`))
+	})
+
+	diff, err := client.SrvSsRevision.DiffRevisions(ctx, 0, 12, 0, kaspersky.SsTypeSettings)
+	expectSucceeded(t, err)
+	expectEqual(t, 1, len(diff.Sections))
+	expectEqual(t, "DaysToStore", diff.Sections[0].Keys[0].Key)
+	expectEqual(t, "7", *diff.Sections[0].Keys[0].From)
+	expectEqual(t, "30", *diff.Sections[0].Keys[0].To)
+	expectEqual(t, int32(2), atomic.LoadInt32(&open))
+	expectEqual(t, int32(2), atomic.LoadInt32(&closed))
+}
@@ -0,0 +1,184 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+// Srvview names of the srvview catalogue, see List of supported srvviews.
+const (
+	SrvViewHWInventory     = "HWInvStorageSrvViewName"
+	SrvViewApplications    = "InvSrvViewName"
+	SrvViewVulnerabilities = "VulnerableAppsSrvViewName"
+	SrvViewUsers           = "GlobalUsersListSrvViewName"
+	SrvViewEvents          = "EventsSrvViewName"
+	SrvViewLicenseKeys     = "KLLIC_SRVVIEW_KEYS"
+	SrvViewHostTags        = "HostTagsSrvViewName"
+	SrvViewUpdates         = "UpdatesSrvViewName"
+)
+
+// HWInvRecord hardware inventory record
+type HWInvRecord struct {
+	ID           int64     `json:"Id"`
+	Type         int64     `json:"Type"`
+	SubType      int64     `json:"SubType,omitempty"`
+	Name         string    `json:"Name,omitempty"`
+	Description  string    `json:"Description,omitempty"`
+	Manufacturer string    `json:"Manufacturer,omitempty"`
+	SerialNumber string    `json:"SerialNumber,omitempty"`
+	InvNumber    string    `json:"InvNumber,omitempty"`
+	CPU          string    `json:"CPU,omitempty"`
+	MotherBoard  string    `json:"MotherBoard,omitempty"`
+	MemorySize   int64     `json:"MemorySize,omitempty"`
+	DiskSize     int64     `json:"DiskSize,omitempty"`
+	MAC          string    `json:"StrMac,omitempty"`
+	OS           string    `json:"OS,omitempty"`
+	LastVisible  *DateTime `json:"LastVisible,omitempty"`
+	PurchaseDate *DateTime `json:"PurchaseDate,omitempty"`
+}
+
+func (HWInvRecord) SrvViewName() string { return SrvViewHWInventory }
+
+// InvApplicationRecord installed application record of software inventory
+type InvApplicationRecord struct {
+	ProductID       string    `json:"ProductID"`
+	DisplayName     string    `json:"DisplayName"`
+	DisplayVersion  string    `json:"DisplayVersion,omitempty"`
+	Publisher       string    `json:"Publisher,omitempty"`
+	InstallDate     *DateTime `json:"InstallDate,omitempty"`
+	InstallDir      string    `json:"InstallDir,omitempty"`
+	Comments        string    `json:"Comments,omitempty"`
+	UninstallString string    `json:"UninstallString,omitempty"`
+	ARPRegKey       string    `json:"ARPRegKey,omitempty"`
+	HostName        string    `json:"KLHST_WKS_HOSTNAME,omitempty"`
+	HostDN          string    `json:"KLHST_WKS_DN,omitempty"`
+}
+
+func (InvApplicationRecord) SrvViewName() string { return SrvViewApplications }
+
+// VulnerabilityRecord software vulnerability found on a host
+type VulnerabilityRecord struct {
+	VulnerabilityID string    `json:"KLVULNR_VULN_ID"`
+	Name            string    `json:"KLVULNR_VULN_NAME,omitempty"`
+	Severity        int64     `json:"KLVULNR_SEVERITY"`
+	ProductName     string    `json:"KLVULNR_PRODUCT_NAME,omitempty"`
+	ProductVersion  string    `json:"KLVULNR_PRODUCT_VERSION,omitempty"`
+	CVE             []string  `json:"KLVULNR_CVE,omitempty"`
+	FixAvailable    bool      `json:"KLVULNR_FIX_AVAILABLE"`
+	DetectTime      *DateTime `json:"KLVULNR_DETECT_TIME,omitempty"`
+	HostName        string    `json:"KLHST_WKS_HOSTNAME,omitempty"`
+	HostDN          string    `json:"KLHST_WKS_DN,omitempty"`
+}
+
+func (VulnerabilityRecord) SrvViewName() string { return SrvViewVulnerabilities }
+
+// UserRecord user or group of the global users list
+type UserRecord struct {
+	TrusteeID      int64  `json:"ul_llTrusteeId"`
+	DisplayName    string `json:"ul_wstrDisplayName,omitempty"`
+	SamAccountName string `json:"ul_wstrSamAccountName,omitempty"`
+	Mail           string `json:"ul_wstrMail,omitempty"`
+	Description    string `json:"ul_wstrDescription,omitempty"`
+	IsGroup        bool   `json:"ul_bIsGroup"`
+	Disabled       bool   `json:"ul_bDisabled"`
+}
+
+func (UserRecord) SrvViewName() string { return SrvViewUsers }
+
+// EventRecord event registered on the Administration Server
+type EventRecord struct {
+	ID              int64     `json:"event_db_id"`
+	Type            string    `json:"event_type"`
+	TypeDisplayName string    `json:"event_type_display_name,omitempty"`
+	Description     string    `json:"descr,omitempty"`
+	Severity        int64     `json:"severity"`
+	RiseTime        *DateTime `json:"rise_time,omitempty"`
+	TaskDisplayName string    `json:"task_display_name,omitempty"`
+	ProductName     string    `json:"product_name,omitempty"`
+	ProductVersion  string    `json:"product_version,omitempty"`
+	HostName        string    `json:"hostname,omitempty"`
+	HostDisplayName string    `json:"hostdn,omitempty"`
+}
+
+func (EventRecord) SrvViewName() string { return SrvViewEvents }
+
+// LicenseKeyRecord license key installed on the Administration Server or hosts
+type LicenseKeyRecord struct {
+	Serial       string    `json:"KLLIC_SERIAL"`
+	ProductName  string    `json:"KLLIC_PROD_NAME,omitempty"`
+	AppID        int64     `json:"KLLIC_APP_ID"`
+	KeyType      int64     `json:"KLLIC_KEY_TYPE"`
+	LicenseCount int64     `json:"KLLIC_LIC_COUNT"`
+	CreationDate *DateTime `json:"KLLIC_CREATION_DATE,omitempty"`
+	LimitDate    *DateTime `json:"KLLIC_LIMIT_DATE,omitempty"`
+	LicPeriod    int64     `json:"KLLIC_LICPERIOD"`
+}
+
+func (LicenseKeyRecord) SrvViewName() string { return SrvViewLicenseKeys }
+
+// HostTagRecord tag assigned to a host
+type HostTagRecord struct {
+	Tag       string `json:"KLHST_WKS_TAG"`
+	HostName  string `json:"KLHST_WKS_HOSTNAME"`
+	HostDN    string `json:"KLHST_WKS_DN,omitempty"`
+	GroupID   int64  `json:"KLHST_WKS_GROUPID"`
+	GroupName string `json:"name,omitempty"`
+}
+
+func (HostTagRecord) SrvViewName() string { return SrvViewHostTags }
+
+// UpdateRecord software update or patch known to the Administration Server
+type UpdateRecord struct {
+	// Source type of update, see VapmControlApi.ChangeApproval
+	Source int64 `json:"nSource"`
+
+	// KlUpdateDbID, RevisionID, PatchDbID update db id, only one of them is set depending on Source
+	KlUpdateDbID int64 `json:"nKlUpdateDbId,omitempty"`
+	RevisionID   int64 `json:"nRevisionID,omitempty"`
+	PatchDbID    int64 `json:"nPatchDbId,omitempty"`
+
+	// PatchGlobalID VAPM patch global identity
+	PatchGlobalID int64 `json:"nPatchGlbId,omitempty"`
+
+	DisplayName string `json:"wstrDisplayName"`
+	Vendor      string `json:"wstrVendor,omitempty"`
+	KB          string `json:"wstrKBId,omitempty"`
+	Severity    int64  `json:"nSeverity"`
+
+	// ApprovalState approval state of the update, e.g. ApprovementStateApproved
+	ApprovalState int64 `json:"nApprovementState"`
+
+	Lcid int64 `json:"nLcid,omitempty"`
+}
+
+func (UpdateRecord) SrvViewName() string { return SrvViewUpdates }
+
+// DbID returns update db id to be passed as PUpdateValue.NPatchDBID.
+func (r UpdateRecord) DbID() int64 {
+	switch {
+	case r.PatchDbID != 0:
+		return r.PatchDbID
+	case r.RevisionID != 0:
+		return r.RevisionID
+	}
+	return r.KlUpdateDbID
+}
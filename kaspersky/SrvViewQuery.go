@@ -0,0 +1,308 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SrvViewRecord record of srvview, implemented by structs of the srvview catalogue, e.g. HWInvRecord.
+//
+// Record fields are srvview columns named by their json tags.
+type SrvViewRecord interface {
+	// SrvViewName returns name of the srvview, see List of supported srvviews.
+	SrvViewName() string
+}
+
+// SrvViewQuery query of SrvView.Query
+type SrvViewQuery struct {
+	// Filter filter string over srvview columns, see also Search filter syntax.
+	Filter string
+
+	// Fields columns to return, all columns of the record are returned if empty.
+	Fields []string
+
+	// Order columns to sort by
+	Order []OrderValue
+
+	// TopN acquire only first TopN records if positive
+	TopN int64
+
+	// PageSize number of records acquired by one SrvView.GetRecordRange call, DefaultChunkSize by default
+	PageSize int64
+
+	// Lifetime result-set lifetime, 10 minutes by default
+	Lifetime time.Duration
+
+	// ExtraColumns columns allowed in Filter and Order in addition to record columns
+	ExtraColumns []string
+}
+
+// ErrUnknownSrvViewColumn is returned by SrvView.Query if query refers to a column the srvview record does not have.
+var ErrUnknownSrvViewColumn = errors.New("unknown srvview column")
+
+// SrvViewColumns returns srvview column names of record fields.
+func SrvViewColumns(record SrvViewRecord) []string {
+	t := reflect.TypeOf(record)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return structColumns(t)
+}
+
+func structColumns(t reflect.Type) []string {
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// Params returns SrvView.ResetIterator params of the query over srvview of record,
+// validating column names against record columns.
+func (q SrvViewQuery) Params(record SrvViewRecord) (*SrvViewParams, error) {
+	columns := SrvViewColumns(record)
+	known := make(map[string]bool, len(columns)+len(q.ExtraColumns))
+	for _, column := range append(columns, q.ExtraColumns...) {
+		known[column] = true
+	}
+
+	var unknown []string
+	check := func(names ...string) {
+		for _, name := range names {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+
+	fields := columns
+	if len(q.Fields) != 0 {
+		fields = q.Fields
+		check(fields...)
+	}
+
+	order := make([]FieldsToOrder, len(q.Order))
+	for i, o := range q.Order {
+		check(o.Name)
+		order[i] = FieldsToOrder{Type: "params", OrderValue: o}
+	}
+
+	filterColumns, err := FilterColumns(q.Filter)
+	if err != nil {
+		return nil, err
+	}
+	check(filterColumns...)
+
+	if len(unknown) != 0 {
+		return nil, fmt.Errorf("%s: %w: %s", record.SrvViewName(), ErrUnknownSrvViewColumn, strings.Join(unknown, ", "))
+	}
+
+	lifetime := q.Lifetime
+	if lifetime <= 0 {
+		lifetime = 10 * time.Minute
+	}
+
+	params := &SrvViewParams{
+		WstrViewName:      record.SrvViewName(),
+		WstrFilter:        q.Filter,
+		VecFieldsToReturn: fields,
+		VecFieldsToOrder:  order,
+		LifetimeSEC:       int64(lifetime / time.Second),
+	}
+	if q.TopN > 0 {
+		params.PParams = &ESrvViewParams{TopN: q.TopN}
+	}
+	return params, nil
+}
+
+// FilterColumns returns sorted unique column names used in filter string, see Search filter syntax,
+// e.g. `(&(KLHST_WKS_DN="host*")(!(KLHST_WKS_STATUS_ID=1)))` refers to KLHST_WKS_DN and KLHST_WKS_STATUS_ID.
+func FilterColumns(filter string) ([]string, error) {
+	seen := map[string]bool{}
+	var columns []string
+	for i := 0; i < len(filter); i++ {
+		switch filter[i] {
+		case '"':
+			// skip quoted value honoring escaped quotes
+			for i++; i < len(filter) && filter[i] != '"'; i++ {
+				if filter[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(filter) {
+				return nil, fmt.Errorf("unterminated string in filter %q", filter)
+			}
+		case '(':
+			j := i + 1
+			for j < len(filter) && filter[j] == ' ' {
+				j++
+			}
+			start := j
+			for j < len(filter) && isFilterNameChar(filter[j]) {
+				j++
+			}
+			name := filter[start:j]
+			for j < len(filter) && filter[j] == ' ' {
+				j++
+			}
+			if name != "" && j < len(filter) && strings.ContainsRune("=<>~", rune(filter[j])) && !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+func isFilterNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+type srvViewRecords struct {
+	PRecords *ItemsChunkArray `json:"pRecords"`
+}
+
+// ForEachRecord Finds srvview records by params and calls fn for raw value of each record, page by page.
+//
+// nPageSize is number of records acquired by one SrvView.GetRecordRange call, DefaultChunkSize is used if it is not positive.
+// Iteration stops on the first error returned by fn. Result-set is always released.
+func (sv *SrvView) ForEachRecord(ctx context.Context, params *SrvViewParams, nPageSize int64,
+	fn func(record json.RawMessage) error) error {
+	if nPageSize <= 0 {
+		nPageSize = DefaultChunkSize
+	}
+
+	iterator, _, err := sv.ResetIterator(ctx, params)
+	if err != nil {
+		return err
+	}
+	defer sv.ReleaseIterator(context.Background(), iterator.WstrIteratorID)
+
+	count, _, err := sv.GetRecordCount(ctx, iterator.WstrIteratorID)
+	if err != nil {
+		return err
+	}
+
+	for start := int64(0); start < count.Int; start += nPageSize {
+		records := new(srvViewRecords)
+		_, err := sv.GetRecordRange(ctx, &RecordRangeParams{
+			WstrIteratorID: iterator.WstrIteratorID,
+			NStart:         start,
+			NEnd:           start + nPageSize,
+		}, records)
+		if err != nil {
+			return err
+		}
+
+		if records.PRecords == nil || len(records.PRecords.Items) == 0 {
+			break
+		}
+
+		for _, item := range records.PRecords.Items {
+			if err := fn(item.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// QueryEach Runs query over srvview of record and calls fn for each found record.
+//
+// record is a pointer to srvview record struct, e.g. &HWInvRecord{}, fn receives new record of the same type
+// for every row. Column names of the query are validated before the request is sent.
+func (sv *SrvView) QueryEach(ctx context.Context, q SrvViewQuery, record SrvViewRecord, fn func(record SrvViewRecord) error) error {
+	t := reflect.TypeOf(record)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("record must be a pointer to struct, got %T", record)
+	}
+
+	params, err := q.Params(record)
+	if err != nil {
+		return err
+	}
+
+	return sv.ForEachRecord(ctx, params, q.PageSize, func(raw json.RawMessage) error {
+		row := reflect.New(t.Elem()).Interface().(SrvViewRecord)
+		if err := json.Unmarshal(raw, row); err != nil {
+			return err
+		}
+		return fn(row)
+	})
+}
+
+// Query Runs query over srvview of records and appends found records to slice out.
+//
+// out is a pointer to slice of srvview record structs or pointers to them, e.g.:
+//
+//	var apps []kaspersky.InvApplicationRecord
+//	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{Filter: `(Publisher="Microsoft*")`}, &apps)
+func (sv *SrvView) Query(ctx context.Context, q SrvViewQuery, out interface{}) error {
+	slice := reflect.ValueOf(out)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("out must be a pointer to slice, got %T", out)
+	}
+
+	elem := slice.Elem().Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+
+	record, ok := reflect.New(elem).Interface().(SrvViewRecord)
+	if !ok {
+		return fmt.Errorf("%s does not implement SrvViewRecord", elem)
+	}
+
+	return sv.QueryEach(ctx, q, record, func(row SrvViewRecord) error {
+		value := reflect.ValueOf(row)
+		if !isPtr {
+			value = value.Elem()
+		}
+		slice.Elem().Set(reflect.Append(slice.Elem(), value))
+		return nil
+	})
+}
@@ -0,0 +1,79 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestFilterColumns(t *testing.T) {
+	columns, err := kaspersky.FilterColumns(`(&(KLHST_WKS_DN="a(b=c)\"")(!(KLHST_WKS_STATUS_ID = 1))(KLHST_WKS_DN<>"x"))`)
+	expectSucceeded(t, err)
+	expectEqual(t, []string{"KLHST_WKS_DN", "KLHST_WKS_STATUS_ID"}, columns)
+
+	_, err = kaspersky.FilterColumns(`(Name="open`)
+	if err == nil {
+		t.Fatal("expected unterminated string error")
+	}
+}
+
+func TestSrvViewQuery(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var resets, released int32
+	var params kaspersky.SrvViewParams
+	handler.HandleFunc("/api/v1.0/SrvView.ResetIterator", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&resets, 1)
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		w.Write([]byte(`{"wstrIteratorId": "it"}`))
+	})
+	handler.HandleFunc("/api/v1.0/SrvView.ReleaseIterator", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&released, 1)
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/SrvView.GetRecordCount", HandlerFuncOk(`{"PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/SrvView.GetRecordRange", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.RecordRangeParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.NStart == 0 {
+			w.Write([]byte(`{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+				{"type": "params", "value": {"ProductID": "1", "DisplayName": "7-Zip",
+					"InstallDate": {"type": "datetime", "value": "2020-05-28T07:22:14Z"}}},
+				{"type": "params", "value": {"ProductID": "2", "DisplayName": "Firefox"}}]}}`))
+			return
+		}
+		w.Write([]byte(`{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+			{"type": "params", "value": {"ProductID": "3", "DisplayName": "Chrome"}}]}}`))
+	})
+
+	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{Filter: `(DisplayNam="7*")`}, &[]kaspersky.InvApplicationRecord{})
+	if !errors.Is(err, kaspersky.ErrUnknownSrvViewColumn) {
+		t.Fatalf("expected ErrUnknownSrvViewColumn, got %v", err)
+	}
+	expectEqual(t, int32(0), atomic.LoadInt32(&resets))
+
+	var apps []kaspersky.InvApplicationRecord
+	err = client.SrvView.Query(ctx, kaspersky.SrvViewQuery{
+		Filter:   `(DisplayName="*")`,
+		Fields:   []string{"ProductID", "DisplayName", "InstallDate"},
+		Order:    []kaspersky.OrderValue{{Name: "DisplayName", Asc: true}},
+		TopN:     10,
+		PageSize: 2,
+	}, &apps)
+	expectSucceeded(t, err)
+	expectEqual(t, 3, len(apps))
+	expectEqual(t, "Chrome", apps[2].DisplayName)
+	expectEqual(t, 2020, apps[0].InstallDate.Time().Year())
+	expectEqual(t, kaspersky.SrvViewApplications, params.WstrViewName)
+	expectEqual(t, int64(10), params.PParams.TopN)
+	expectEqual(t, int32(1), atomic.LoadInt32(&released))
+}
@@ -0,0 +1,305 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// TaskBackupGlobalDir directory of the task backup holding tasks which do not belong to any group,
+// e.g. tasks for specified hosts
+const TaskBackupGlobalDir = "_global"
+
+// TaskBackupManifest describes tasks saved by GroupTaskControlApi.Backup
+type TaskBackupManifest struct {
+	BackupInfo
+
+	// Tasks saved tasks
+	Tasks []TaskBackupEntry `json:"tasks"`
+
+	// Failures tasks that failed to be saved
+	Failures []BackupFailure `json:"failures,omitempty"`
+}
+
+// TaskBackupEntry saved task
+type TaskBackupEntry struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+
+	// TaskType task type name, e.g. "KLNAG_TASK_REMOTE_INSTALL"
+	TaskType string `json:"taskType"`
+	Product  string `json:"product"`
+	Version  string `json:"version"`
+
+	// GroupID and GroupPath group of the group task, GroupPath is empty for tasks which do not belong to any group
+	GroupID   int64  `json:"groupId"`
+	GroupPath string `json:"groupPath,omitempty"`
+
+	// File task blob path relative to the backup directory
+	File string `json:"file"`
+}
+
+// Global returns true if task does not belong to any group
+func (e TaskBackupEntry) Global() bool {
+	return e.GroupPath == ""
+}
+
+func (e TaskBackupEntry) failure(groupPath string, err error) BackupFailure {
+	return BackupFailure{ID: e.ID, Name: e.DisplayName, GroupPath: groupPath, Error: err.Error()}
+}
+
+type taskIterator struct {
+	StrTaskIteratorID string `json:"strTaskIteratorId"`
+}
+
+type nextTask struct {
+	PTaskData Params `json:"pTaskData"`
+	PxgRetVal bool   `json:"PxgRetVal"`
+}
+
+// ForEachTask calls fn for every task matching params, task data is acquired by Tasks.GetNextTask.
+//
+// Iteration stops on the first error returned by fn. Task iterator is always released.
+func (ts *Tasks) ForEachTask(ctx context.Context, params TasksIteratorParams, fn func(task Params) error) error {
+	raw, err := ts.ResetTasksIterator(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	iterator := new(taskIterator)
+	if err := json.Unmarshal(raw, iterator); err != nil {
+		return err
+	}
+	defer ts.ReleaseTasksIterator(context.Background(), iterator.StrTaskIteratorID)
+
+	for {
+		raw, err := ts.GetNextTask(ctx, iterator.StrTaskIteratorID)
+		if err != nil {
+			return err
+		}
+
+		next := new(nextTask)
+		if err := json.Unmarshal(raw, next); err != nil {
+			return err
+		}
+
+		if !next.PxgRetVal || len(next.PTaskData) == 0 {
+			return nil
+		}
+
+		if err := fn(next.PTaskData); err != nil {
+			return err
+		}
+	}
+}
+
+// Backup Exports all group tasks and tasks for specified hosts of the server into directory dir.
+//
+// Every task is saved as "<group path>/<task name>-<task id>.task",
+// tasks which do not belong to any group are saved into TaskBackupGlobalDir.
+// dir must not exist, see BackupManifestFile.
+func (gtca *GroupTaskControlApi) Backup(ctx context.Context, dir string) (*TaskBackupManifest, error) {
+	manifest := &TaskBackupManifest{BackupInfo: gtca.client.backupInfo()}
+	err := writeBackup(dir, manifest, func(tmp string) error {
+		return gtca.client.Tasks.ForEachTask(ctx, TasksIteratorParams{}, func(task Params) error {
+			info := task.Params("TASK_INFO_PARAMS")
+			entry := TaskBackupEntry{
+				ID:          task.String("TASK_UNIQUE_ID"),
+				DisplayName: info.String("DisplayName"),
+				TaskType:    task.String("TASK_NAME"),
+				Product:     task.String("TASKID_PRODUCT_NAME"),
+				Version:     task.String("TASKID_VERSION"),
+				GroupID:     -1,
+			}
+			if info.Has("PRTS_TASK_GROUPID") {
+				entry.GroupID = info.Int("PRTS_TASK_GROUPID")
+			}
+
+			if err := gtca.backupTask(ctx, tmp, &entry); err != nil {
+				manifest.Failures = append(manifest.Failures, entry.failure(entry.GroupPath, err))
+				return ctx.Err()
+			}
+			manifest.Tasks = append(manifest.Tasks, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	if len(manifest.Failures) != 0 {
+		return manifest, fmt.Errorf("%d tasks failed to be saved, see %s", len(manifest.Failures), BackupManifestFile)
+	}
+	return manifest, nil
+}
+
+func (gtca *GroupTaskControlApi) backupTask(ctx context.Context, dir string, entry *TaskBackupEntry) error {
+	if entry.ID == "" {
+		return errors.New("task has no TASK_UNIQUE_ID")
+	}
+
+	groupDir := TaskBackupGlobalDir
+	if entry.GroupID >= 0 {
+		path, err := gtca.client.HostGroup.GroupPath(ctx, entry.GroupID)
+		if err != nil {
+			return err
+		}
+		entry.GroupPath = path
+		groupDir = groupPathToDir(path)
+	}
+
+	blob, _, err := gtca.ExportTaskBlob(ctx, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	name := entry.DisplayName
+	if name == "" {
+		name = entry.TaskType
+	}
+
+	entry.File = filepath.ToSlash(filepath.Join(groupDir, fmt.Sprintf("%s-%s.task", sanitizeFileName(name),
+		sanitizeFileName(entry.ID))))
+	return writeFile(filepath.Join(dir, entry.File), blob.Binary)
+}
+
+// TaskRestoreOptions struct using in GroupTaskControlApi.Restore
+type TaskRestoreOptions struct {
+	// GroupMap remaps backup group paths to target group paths by prefix,
+	// e.g. {"Managed devices/Old branch": "Managed devices/New branch"}. The longest matching prefix wins.
+	GroupMap map[string]string
+
+	// CreateGroups creates missing target groups, otherwise tasks of missing groups fail to be restored
+	CreateGroups bool
+
+	// Filter restores only tasks for which Filter returns true. All tasks are restored if nil
+	Filter func(entry TaskBackupEntry) bool
+
+	// Confirm decides whether to commit the imported task, given import restrictions
+	// returned by GroupTaskControlApi.ImportTask. Declined tasks are reported as failures.
+	// All tasks are committed if nil
+	Confirm func(entry TaskBackupEntry, commitInfo Params) bool
+}
+
+// TaskRestoreResult restored task
+type TaskRestoreResult struct {
+	Entry TaskBackupEntry
+
+	// NewID id of the imported task
+	NewID string
+
+	// GroupPath target group path, empty for tasks which do not belong to any group
+	GroupPath string
+}
+
+// Restore Imports tasks saved by GroupTaskControlApi.Backup from directory dir, possibly made on another server.
+//
+// Group tasks are imported into groups with the same paths or remapped by opts.GroupMap,
+// group ids are resolved on the target server by path.
+// Every task is prepared by GroupTaskControlApi.ImportTask and committed by GroupTaskControlApi.CommitImportedTask.
+// Failures of single tasks do not stop the restore, they are returned with an error summarizing them.
+func (gtca *GroupTaskControlApi) Restore(ctx context.Context, dir string, opts TaskRestoreOptions) ([]TaskRestoreResult,
+	[]BackupFailure, error) {
+	manifest := new(TaskBackupManifest)
+	if err := readBackupManifest(dir, manifest); err != nil {
+		return nil, nil, err
+	}
+
+	var restored []TaskRestoreResult
+	var failures []BackupFailure
+	for _, entry := range manifest.Tasks {
+		if opts.Filter != nil && !opts.Filter(entry) {
+			continue
+		}
+
+		target := ""
+		if !entry.Global() {
+			target = remapGroupPath(entry.GroupPath, opts.GroupMap)
+		}
+
+		id, err := gtca.restoreTask(ctx, dir, entry, target, opts)
+		if err != nil {
+			failures = append(failures, entry.failure(target, err))
+			if ctx.Err() != nil {
+				return restored, failures, ctx.Err()
+			}
+			continue
+		}
+		restored = append(restored, TaskRestoreResult{Entry: entry, NewID: id, GroupPath: target})
+	}
+
+	if len(failures) != 0 {
+		return restored, failures, fmt.Errorf("%d of %d tasks failed to be restored", len(failures),
+			len(failures)+len(restored))
+	}
+	return restored, nil, nil
+}
+
+func (gtca *GroupTaskControlApi) restoreTask(ctx context.Context, dir string, entry TaskBackupEntry, groupPath string,
+	opts TaskRestoreOptions) (string, error) {
+	params := ImportTaskParams{}
+	if groupPath != "" {
+		groupID, err := gtca.client.HostGroup.resolveGroupPath(ctx, groupPath, opts.CreateGroups)
+		if err != nil {
+			return "", err
+		}
+		params.PExtraData = Params{"PRTS_TASK_GROUPID": groupID}
+	}
+
+	blob, err := readBackupFile(dir, entry.File)
+	if err != nil {
+		return "", err
+	}
+	params.PBlob = blob
+
+	raw, err := gtca.ImportTask(ctx, params)
+	if err != nil {
+		return "", err
+	}
+
+	imported := new(ImportedTask)
+	if err := json.Unmarshal(raw, imported); err != nil {
+		return "", err
+	}
+
+	commit := opts.Confirm == nil || opts.Confirm(entry, imported.PCommitInfo)
+	task, _, err := gtca.CommitImportedTask(ctx, imported.WstrID, commit)
+	if err != nil {
+		return "", err
+	}
+
+	if !commit {
+		return "", errors.New("import declined")
+	}
+
+	if task.TaskValue == nil || task.TaskValue.TaskUniqueID == "" {
+		return "", errors.New("imported task has no TASK_UNIQUE_ID")
+	}
+	return task.TaskValue.TaskUniqueID, nil
+}
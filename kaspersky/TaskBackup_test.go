@@ -0,0 +1,87 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestTaskBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	client, handler, backup := newBackupTest(t, map[int64]string{2: "Branch"})
+
+	var next, released int32
+	handler.HandleFunc("/api/v1.0/Tasks.ResetTasksIterator", HandlerFuncOk(`{"strTaskIteratorId": "it"}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ReleaseTasksIterator", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&released, 1)
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/Tasks.GetNextTask", func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&next, 1) {
+		case 1:
+			w.Write([]byte(`{"PxgRetVal": true, "pTaskData": {"TASK_UNIQUE_ID": "_LOCAL_1", "TASK_NAME": "KLNAG_TASK_REMOTE_INSTALL",
+				"TASKID_PRODUCT_NAME": "1093", "TASKID_VERSION": "1.0.0.0",
+				"TASK_INFO_PARAMS": {"type": "params", "value": {"DisplayName": "Install KES", "PRTS_TASK_GROUPID": 2}}}}`))
+		case 2:
+			w.Write([]byte(`{"PxgRetVal": true, "pTaskData": {"TASK_UNIQUE_ID": "_LOCAL_2", "TASK_NAME": "Wake-on-LAN",
+				"TASK_INFO_PARAMS": {"type": "params", "value": {"DisplayName": "Wake up"}}}}`))
+		default:
+			w.Write([]byte(`{"PxgRetVal": false, "pTaskData": {}}`))
+		}
+	})
+	handler.HandleFunc("/api/v1.0/GroupTaskControlApi.ExportTask", HandlerFuncOk(`{"PxgRetVal": {"type": "binary", "value": "dGFzaw=="}}`))
+
+	manifest, err := client.GroupTaskControlApi.Backup(ctx, backup)
+	expectSucceeded(t, err)
+	expectEqual(t, int32(1), atomic.LoadInt32(&released))
+	expectEqual(t, 2, len(manifest.Tasks))
+	expectEqual(t, "Managed devices/Branch", manifest.Tasks[0].GroupPath)
+	expectEqual(t, "Managed devices/Branch/Install KES-_LOCAL_1.task", manifest.Tasks[0].File)
+	expectEqual(t, true, manifest.Tasks[1].Global())
+	expectEqual(t, "_global/Wake up-_LOCAL_2.task", manifest.Tasks[1].File)
+
+	expectBackupFile(t, backup, manifest.Tasks[0].File, "task")
+
+	var imported []kaspersky.ImportTaskParams
+	handler.HandleFunc("/api/v1.0/HostGroup.GetSubgroups", HandlerFuncOk(`{"PxgRetVal": []}`))
+	handler.HandleFunc("/api/v1.0/HostGroup.AddGroup", HandlerFuncOk(`{"PxgRetVal": 3}`))
+	handler.HandleFunc("/api/v1.0/GroupTaskControlApi.ImportTask", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.ImportTaskParams
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		imported = append(imported, in)
+		w.Write([]byte(`{"wstrId": "import-1", "pCommitInfo": {}}`))
+	})
+	handler.HandleFunc("/api/v1.0/GroupTaskControlApi.CommitImportedTask", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			BCommit bool `json:"bCommit"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		w.Write([]byte(`{"PxgRetVal": {"TASK_UNIQUE_ID": "_LOCAL_10"}}`))
+	})
+
+	restored, failures, err := client.GroupTaskControlApi.Restore(ctx, backup, kaspersky.TaskRestoreOptions{
+		GroupMap:     map[string]string{"Managed devices/Branch": "Managed devices/New branch"},
+		CreateGroups: true,
+		Confirm: func(entry kaspersky.TaskBackupEntry, _ kaspersky.Params) bool {
+			return !entry.Global()
+		},
+	})
+	if err == nil {
+		t.Fatal("expected declined task to be reported")
+	}
+	expectEqual(t, 1, len(restored))
+	expectEqual(t, "_LOCAL_10", restored[0].NewID)
+	expectEqual(t, "Managed devices/New branch", restored[0].GroupPath)
+	expectEqual(t, 1, len(failures))
+	expectEqual(t, "_LOCAL_2", failures[0].ID)
+	expectEqual(t, "import declined", failures[0].Error)
+
+	expectEqual(t, 2, len(imported))
+	expectEqual(t, "task", string(imported[0].PBlob))
+	expectEqual(t, json.Number("3"), imported[0].PExtraData["PRTS_TASK_GROUPID"])
+	expectEqual(t, 0, len(imported[1].PExtraData))
+}
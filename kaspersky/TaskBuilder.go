@@ -0,0 +1,390 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Products and task types used by task builders, as reported by Tasks.GetTask in
+// TASKID_PRODUCT_NAME, TASKID_VERSION and TASK_NAME.
+const (
+	ProductAdmServer        = "1093"
+	ProductNetworkAgent     = "1103"
+	ProductVersionAdmServer = "1.0.0.0"
+
+	// ProductKES and ProductVersionKES Kaspersky Endpoint Security for Windows 11
+	ProductKES        = "KES"
+	ProductVersionKES = "11.0.0.0"
+
+	TaskTypeUpdateBases          = "Updater"
+	TaskTypeVirusScan            = "ODS"
+	TaskTypeRemoteInstall        = "KLNAG_TASK_REMOTE_INSTALL"
+	TaskTypeUninstallApplication = "KLNAG_TASK_REMOTE_UNINSTALL"
+	TaskTypeWakeOnLAN            = "KLNAG_TASK_WOL"
+	TaskTypeInstallUpdates       = "KLNAG_TASK_INSTALL_REQUIRED_UPDATES"
+)
+
+// Task schedule types, "TASKSCH_TYPE" task attribute
+const (
+	TaskScheduleTypeNone   int64 = 0
+	TaskScheduleTypePeriod int64 = 1
+	TaskScheduleTypeDaily  int64 = 2
+	TaskScheduleTypeWeekly int64 = 3
+)
+
+// TaskSchedule task start schedule.
+//
+// Use ManualSchedule, PeriodSchedule, DailySchedule, WeeklySchedule or EventSchedule to make one.
+type TaskSchedule struct {
+	typ       int64
+	first     time.Time
+	period    time.Duration
+	weekday   time.Weekday
+	at        time.Duration
+	runMissed bool
+	event     *TaskScheduleEvent
+}
+
+// TaskScheduleEvent event which starts the task
+type TaskScheduleEvent struct {
+	// EventType e.g. "KLPRCI_TaskState"
+	EventType string
+
+	// Product, Version, Component and Instance filter events by publisher, empty values match any
+	Product   string
+	Version   string
+	Component string
+	Instance  string
+
+	// Body filter of the event body, e.g. Params{"KLPRCI_newState": 2}
+	Body Params
+}
+
+// ManualSchedule task is started manually, e.g. by Tasks.RunTask
+func ManualSchedule() TaskSchedule {
+	return TaskSchedule{typ: TaskScheduleTypeNone}
+}
+
+// PeriodSchedule task is started every period starting at first
+func PeriodSchedule(first time.Time, period time.Duration) TaskSchedule {
+	return TaskSchedule{typ: TaskScheduleTypePeriod, first: first, period: period}
+}
+
+// DailySchedule task is started every day at hour:minute of the host local time
+func DailySchedule(hour, minute int) TaskSchedule {
+	return TaskSchedule{
+		typ: TaskScheduleTypeDaily,
+		at:  time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute,
+	}
+}
+
+// WeeklySchedule task is started every week on day at hour:minute
+func WeeklySchedule(day time.Weekday, hour, minute int) TaskSchedule {
+	return TaskSchedule{
+		typ:     TaskScheduleTypeWeekly,
+		weekday: day,
+		at:      time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute,
+	}
+}
+
+// EventSchedule task is started when event occurs
+func EventSchedule(event TaskScheduleEvent) TaskSchedule {
+	return TaskSchedule{typ: TaskScheduleTypeNone, event: &event}
+}
+
+// RunMissed returns schedule which starts missed task runs as soon as the host becomes available
+func (s TaskSchedule) RunMissed() TaskSchedule {
+	s.runMissed = true
+	return s
+}
+
+func (s TaskSchedule) params() Params {
+	p := Params{
+		"TASKSCH_TYPE":            s.typ,
+		"TASKSCH_RUN_MISSED_FLAG": s.runMissed,
+	}
+
+	switch s.typ {
+	case TaskScheduleTypePeriod:
+		p["TASKSCH_MS_PERIOD"] = s.period.Milliseconds()
+		p["TASKSCH_FIRST_EXECUTION_TIME"] = datetime(s.first)
+	case TaskScheduleTypeDaily:
+		p["TASKSCH_ED_HOURS"] = int64(s.at / time.Hour)
+		p["TASKSCH_ED_MINS"] = int64(s.at % time.Hour / time.Minute)
+		p["TASKSCH_ED_SECS"] = int64(0)
+	case TaskScheduleTypeWeekly:
+		p["TASKSCH_EW_DAY"] = int64(s.weekday)
+		p["TASKSCH_EW_HOURS"] = int64(s.at / time.Hour)
+		p["TASKSCH_EW_MINS"] = int64(s.at % time.Hour / time.Minute)
+		p["TASKSCH_EW_SECS"] = int64(0)
+	}
+
+	if s.event != nil {
+		p["EVENT_TYPE"] = s.event.EventType
+		p["FILTER_EVENTS_PRODUCT_NAME"] = s.event.Product
+		p["FILTER_EVENTS_VERSION"] = s.event.Version
+		p["FILTER_EVENTS_COMPONENT_NAME"] = s.event.Component
+		p["FILTER_EVENTS_INSTANCE_ID"] = s.event.Instance
+		if s.event.Body != nil {
+			p["KLEVP_ND_BODY_FILTER"] = s.event.Body
+		}
+	}
+	return p
+}
+
+func datetime(t time.Time) *TaskschFirstExecutionTime {
+	return &TaskschFirstExecutionTime{Type: "datetime", Value: t.UTC().Format(time.RFC3339)}
+}
+
+// TaskBuilder builds task data for Tasks.AddTask.
+//
+// Task is created for the target set by ForGroup, ForHosts or ForQuery.
+// Task type specific settings are set by Set, any other task attribute can be set by SetAttribute.
+//
+// Example:
+//
+//	id, err := client.Tasks.CreateTask(ctx, kaspersky.UpdateBasesTask("Update").
+//		ForGroup(groupID).
+//		WithSchedule(kaspersky.DailySchedule(3, 0).RunMissed()))
+type TaskBuilder struct {
+	product, version, taskName string
+	displayName                string
+
+	schedule TaskSchedule
+	groupID  *int64
+	hosts    []string
+	query    string
+	enabled  bool
+	accounts []taskAccount
+
+	attributes Params
+	info       Params
+	settings   Params
+}
+
+type taskAccount struct {
+	user, password string
+}
+
+// NewTaskBuilder makes builder of the task of type taskName of the product.
+// Task is created enabled and started manually.
+func NewTaskBuilder(product, version, taskName, displayName string) *TaskBuilder {
+	return &TaskBuilder{
+		product:     product,
+		version:     version,
+		taskName:    taskName,
+		displayName: displayName,
+		schedule:    ManualSchedule(),
+		enabled:     true,
+		attributes:  Params{},
+		info:        Params{},
+		settings:    Params{},
+	}
+}
+
+// UpdateBasesTask makes builder of Kaspersky Endpoint Security databases update task
+func UpdateBasesTask(displayName string) *TaskBuilder {
+	return NewTaskBuilder(ProductKES, ProductVersionKES, TaskTypeUpdateBases, displayName)
+}
+
+// VirusScanTask makes builder of Kaspersky Endpoint Security virus scan task
+func VirusScanTask(displayName string) *TaskBuilder {
+	return NewTaskBuilder(ProductKES, ProductVersionKES, TaskTypeVirusScan, displayName)
+}
+
+// RemoteInstallTask makes builder of installation package remote install task,
+// see PackagesApi for installation packages ids
+func RemoteInstallTask(displayName string, packageIDs ...int64) *TaskBuilder {
+	return NewTaskBuilder(ProductAdmServer, ProductVersionAdmServer, TaskTypeRemoteInstall, displayName).
+		Set("KLTSK_RI_PACKAGES_IDS", int64sToArray(packageIDs))
+}
+
+// UninstallApplicationTask makes builder of remote uninstall task of the application product/version
+func UninstallApplicationTask(displayName, product, version string) *TaskBuilder {
+	return NewTaskBuilder(ProductAdmServer, ProductVersionAdmServer, TaskTypeUninstallApplication, displayName).
+		Set("KLTSK_RU_PRODUCT_NAME", product).
+		Set("KLTSK_RU_PRODUCT_VERSION", version)
+}
+
+// WakeOnLANTask makes builder of hosts wake-on-LAN task
+func WakeOnLANTask(displayName string) *TaskBuilder {
+	return NewTaskBuilder(ProductAdmServer, ProductVersionAdmServer, TaskTypeWakeOnLAN, displayName)
+}
+
+// InstallUpdatesTask makes builder of required updates and vulnerability fixes install task
+func InstallUpdatesTask(displayName string) *TaskBuilder {
+	return NewTaskBuilder(ProductNetworkAgent, ProductVersionAdmServer, TaskTypeInstallUpdates, displayName)
+}
+
+// WithSchedule sets task start schedule
+func (b *TaskBuilder) WithSchedule(schedule TaskSchedule) *TaskBuilder {
+	b.schedule = schedule
+	return b
+}
+
+// ForGroup makes group task of administration group nGroupId
+func (b *TaskBuilder) ForGroup(nGroupId int64) *TaskBuilder {
+	b.groupID, b.hosts, b.query = Int64(nGroupId), nil, ""
+	return b
+}
+
+// ForHosts makes task for specified hosts, hosts are host names (KLHST_WKS_HOSTNAME)
+func (b *TaskBuilder) ForHosts(hosts ...string) *TaskBuilder {
+	b.groupID, b.hosts, b.query = nil, hosts, ""
+	return b
+}
+
+// ForQuery makes task for hosts matching selection query, see "Search filter syntax"
+func (b *TaskBuilder) ForQuery(query string) *TaskBuilder {
+	b.groupID, b.hosts, b.query = nil, nil, query
+	return b
+}
+
+// Enabled sets whether the scheduled task is enabled
+func (b *TaskBuilder) Enabled(enabled bool) *TaskBuilder {
+	b.enabled = enabled
+	return b
+}
+
+// WithAccount runs the task under account user, password is protected by Tasks.ProtectPasswordBinary on Tasks.CreateTask
+func (b *TaskBuilder) WithAccount(user, password string) *TaskBuilder {
+	b.accounts = append(b.accounts, taskAccount{user: user, password: password})
+	return b
+}
+
+// Set sets task type specific setting, "TASK_ADDITIONAL_PARAMS" task attribute
+func (b *TaskBuilder) Set(name string, value interface{}) *TaskBuilder {
+	b.settings[name] = value
+	return b
+}
+
+// SetInfo sets "TASK_INFO_PARAMS" task attribute
+func (b *TaskBuilder) SetInfo(name string, value interface{}) *TaskBuilder {
+	b.info[name] = value
+	return b
+}
+
+// SetAttribute sets task attribute overriding attributes set by the builder
+func (b *TaskBuilder) SetAttribute(name string, value interface{}) *TaskBuilder {
+	b.attributes[name] = value
+	return b
+}
+
+// Build returns task data for Tasks.AddTask, account passwords are protected by ts.
+func (b *TaskBuilder) Build(ctx context.Context, ts *Tasks) (Params, error) {
+	if b.groupID == nil && len(b.hosts) == 0 && b.query == "" {
+		return nil, errors.New("task target is not set, use ForGroup, ForHosts or ForQuery")
+	}
+
+	info := Params{
+		"DisplayName":          b.displayName,
+		"PRTS_TASK_ENABLED":    b.enabled,
+		"PRTS_TASK_GROUPID":    int64(-1),
+		"PRTS_EXCEPT_GROUPIDS": []interface{}{},
+	}
+	switch {
+	case b.groupID != nil:
+		info["PRTS_TASK_GROUPID"] = *b.groupID
+	case len(b.hosts) != 0:
+		hosts := make([]interface{}, len(b.hosts))
+		for i, host := range b.hosts {
+			hosts[i] = Params{"objId": host}
+		}
+		info["PRTS_TASK_TARGET_COMPUTERS"] = hosts
+	default:
+		info["PRTS_TASK_QUERY"] = b.query
+	}
+
+	if len(b.accounts) != 0 {
+		accounts := make([]interface{}, len(b.accounts))
+		for i, account := range b.accounts {
+			protected, _, err := ts.ProtectPasswordBinary(ctx, account.password)
+			if err != nil {
+				return nil, fmt.Errorf("protect password of %q: %w", account.user, err)
+			}
+			accounts[i] = Params{
+				"klprts-TaskAccountUser":     account.user,
+				"klprts-TaskAccountPassword": protected.Binary,
+			}
+		}
+		info["klprts-TaskAccounts"] = accounts
+	}
+
+	for k, v := range b.info {
+		info[k] = v
+	}
+
+	data := b.schedule.params()
+	data["TASKID_PRODUCT_NAME"] = b.product
+	data["TASKID_VERSION"] = b.version
+	data["TASK_NAME"] = b.taskName
+	data["TASK_INFO_PARAMS"] = info
+	data["TASK_ADDITIONAL_PARAMS"] = b.settings
+	for k, v := range b.attributes {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// CreateTask Creates task built by b with Tasks.AddTask and returns its id.
+func (ts *Tasks) CreateTask(ctx context.Context, b *TaskBuilder) (string, error) {
+	data, err := b.Build(ctx, ts)
+	if err != nil {
+		return "", err
+	}
+
+	out := &struct {
+		ID json.RawMessage `json:"PxgRetVal"`
+	}{}
+	if _, err := ts.client.PostInOut(ctx, "/api/v1.0/Tasks.AddTask", struct {
+		PData Params `json:"pData"`
+	}{data}, out); err != nil {
+		return "", err
+	}
+
+	// task id is a string, but some server versions return it as a number
+	var id string
+	if err := json.Unmarshal(out.ID, &id); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(out.ID, &n); err != nil {
+			return "", fmt.Errorf("unexpected task id %s", out.ID)
+		}
+		id = n.String()
+	}
+	return id, nil
+}
+
+func int64sToArray(values []int64) []interface{} {
+	array := make([]interface{}, len(values))
+	for i, v := range values {
+		array[i] = v
+	}
+	return array
+}
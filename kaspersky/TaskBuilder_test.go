@@ -0,0 +1,140 @@
+package kaspersky_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestCreateTask(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var password string
+	handler.HandleFunc("/api/v1.0/Tasks.ProtectPassword", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			StrPassword string `json:"strPassword"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		password = in.StrPassword
+		w.Write([]byte(`{"PxgRetVal": {"type": "binary", "value": "c2VjcmV0"}}`))
+	})
+
+	var data kaspersky.Params
+	handler.HandleFunc("/api/v1.0/Tasks.AddTask", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			PData kaspersky.Params `json:"pData"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		data = in.PData
+		w.Write([]byte(`{"PxgRetVal": 195}`))
+	})
+
+	_, err := client.Tasks.CreateTask(ctx, kaspersky.WakeOnLANTask("Wake up"))
+	if err == nil {
+		t.Fatal("expected error for task without target")
+	}
+
+	id, err := client.Tasks.CreateTask(ctx, kaspersky.RemoteInstallTask("Install KES", 12).
+		ForGroup(5).
+		WithSchedule(kaspersky.WeeklySchedule(time.Monday, 3, 30).RunMissed()).
+		WithAccount(`DOMAIN\admin`, `p"ss`))
+	expectSucceeded(t, err)
+	expectEqual(t, "195", id)
+	expectEqual(t, `p"ss`, password)
+
+	flat := data.Flatten()
+	expectEqual(t, `"KLNAG_TASK_REMOTE_INSTALL"`, flat["TASK_NAME"])
+	expectEqual(t, `3`, flat["TASKSCH_TYPE"])
+	expectEqual(t, `1`, flat["TASKSCH_EW_DAY"])
+	expectEqual(t, `30`, flat["TASKSCH_EW_MINS"])
+	expectEqual(t, `true`, flat["TASKSCH_RUN_MISSED_FLAG"])
+	expectEqual(t, `5`, flat["TASK_INFO_PARAMS.PRTS_TASK_GROUPID"])
+	expectEqual(t, `"DOMAIN\\admin"`, flat["TASK_INFO_PARAMS.klprts-TaskAccounts[0].klprts-TaskAccountUser"])
+	expectEqual(t, `{"type":"binary","value":"c2VjcmV0"}`, flat["TASK_INFO_PARAMS.klprts-TaskAccounts[0].klprts-TaskAccountPassword"])
+	expectEqual(t, `12`, flat["TASK_ADDITIONAL_PARAMS.KLTSK_RI_PACKAGES_IDS[0]"])
+}
+
+func TestTaskBuilderBuild(t *testing.T) {
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: "http://127.0.0.1:0"})
+
+	tests := []struct {
+		name     string
+		builder  *kaspersky.TaskBuilder
+		expected map[string]string
+		absent   []string
+	}{
+		{
+			name:    "daily for hosts",
+			builder: kaspersky.UpdateBasesTask("Update").ForHosts("h1", "h2").WithSchedule(kaspersky.DailySchedule(3, 15)),
+			expected: map[string]string{
+				"TASKSCH_TYPE":                       `2`,
+				"TASKSCH_ED_HOURS":                   `3`,
+				"TASKSCH_ED_MINS":                    `15`,
+				"TASK_INFO_PARAMS.PRTS_TASK_GROUPID": `-1`,
+				"TASK_INFO_PARAMS.PRTS_TASK_TARGET_COMPUTERS[0].objId": `"h1"`,
+				"TASK_INFO_PARAMS.PRTS_TASK_TARGET_COMPUTERS[1].objId": `"h2"`,
+			},
+			absent: []string{"TASKSCH_FIRST_EXECUTION_TIME", "TASKSCH_MS_PERIOD", "TASK_INFO_PARAMS.PRTS_TASK_QUERY"},
+		},
+		{
+			name: "event for query",
+			builder: kaspersky.VirusScanTask("Scan").ForQuery(`(KLHST_WKS_OS_NAME = "*Server*")`).
+				WithSchedule(kaspersky.EventSchedule(kaspersky.TaskScheduleEvent{
+					EventType: "KLPRCI_TaskState",
+					Product:   kaspersky.ProductKES,
+					Body:      kaspersky.Params{"KLPRCI_newState": int64(2)},
+				})),
+			expected: map[string]string{
+				"TASKSCH_TYPE":                         `0`,
+				"EVENT_TYPE":                           `"KLPRCI_TaskState"`,
+				"FILTER_EVENTS_PRODUCT_NAME":           `"KES"`,
+				"KLEVP_ND_BODY_FILTER.KLPRCI_newState": `2`,
+				"TASK_INFO_PARAMS.PRTS_TASK_GROUPID":   `-1`,
+				"TASK_INFO_PARAMS.PRTS_TASK_QUERY":     `"(KLHST_WKS_OS_NAME = \"*Server*\")"`,
+			},
+			absent: []string{"TASK_INFO_PARAMS.PRTS_TASK_TARGET_COMPUTERS"},
+		},
+		{
+			name:    "target replaced",
+			builder: kaspersky.WakeOnLANTask("Wake up").ForGroup(5).ForHosts("h1"),
+			expected: map[string]string{
+				"TASK_INFO_PARAMS.PRTS_TASK_GROUPID":                   `-1`,
+				"TASK_INFO_PARAMS.PRTS_TASK_TARGET_COMPUTERS[0].objId": `"h1"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		data, err := test.builder.Build(ctx, client.Tasks)
+		expectSucceeded(t, err)
+
+		flat := data.Flatten()
+		for key, value := range test.expected {
+			if flat[key] != value {
+				t.Errorf("%s: %s = %s, expected %s", test.name, key, flat[key], value)
+			}
+		}
+		for _, key := range test.absent {
+			if _, ok := flat[key]; ok {
+				t.Errorf("%s: unexpected %s", test.name, key)
+			}
+		}
+	}
+
+	// A daily schedule does not depend on the time the builder was made.
+	builder := kaspersky.UpdateBasesTask("Update").ForGroup(1).WithSchedule(kaspersky.DailySchedule(3, 0))
+	first, err := builder.Build(ctx, client.Tasks)
+	expectSucceeded(t, err)
+	second, err := builder.Build(ctx, client.Tasks)
+	expectSucceeded(t, err)
+	expectEqual(t, first.Flatten(), second.Flatten())
+}
@@ -0,0 +1,393 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// TaskState state of the task on a host, as reported by Tasks.GetTaskStatistics and
+// host task state attribute "state_code"
+type TaskState int64
+
+const (
+	TaskStatePending   TaskState = 0x01
+	TaskStateRunning   TaskState = 0x02
+	TaskStateCompleted TaskState = 0x04
+	TaskStateWarning   TaskState = 0x08
+	TaskStateFailed    TaskState = 0x10
+	TaskStateScheduled TaskState = 0x20
+	TaskStatePaused    TaskState = 0x40
+
+	// TaskStateAll mask of all task states
+	TaskStateAll TaskState = 0x7F
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case TaskStatePending:
+		return "pending"
+	case TaskStateRunning:
+		return "running"
+	case TaskStateCompleted:
+		return "completed"
+	case TaskStateWarning:
+		return "completed with warnings"
+	case TaskStateFailed:
+		return "failed"
+	case TaskStateScheduled:
+		return "scheduled"
+	case TaskStatePaused:
+		return "paused"
+	}
+	return "unknown"
+}
+
+// Active returns true if task is still to be completed on a host
+func (s TaskState) Active() bool {
+	return s == TaskStatePending || s == TaskStateRunning || s == TaskStateScheduled
+}
+
+// taskCancelTimeout bounds Tasks.CancelTask call made by Tasks.RunTaskAndWait after ctx is done
+const taskCancelTimeout = 30 * time.Second
+
+// DefaultTaskWaitTimeout timeout of Tasks.RunTaskAndWait if TaskRunOptions.Timeout is zero
+const DefaultTaskWaitTimeout = time.Hour
+
+// ErrTaskWaitTimeout is returned by Tasks.RunTaskAndWait if task did not complete within TaskRunOptions.Timeout.
+var ErrTaskWaitTimeout = errors.New("timeout waiting for task completion")
+
+// HostTaskState state of the task on a host
+type HostTaskState struct {
+	HostName    string    `json:"hostName"`
+	DisplayName string    `json:"displayName"`
+	State       TaskState `json:"state"`
+
+	// Description state description, e.g. failure text
+	Description string `json:"description,omitempty"`
+
+	// Time time of the last state change, zero if unknown
+	Time time.Time `json:"time,omitempty"`
+}
+
+// HostTaskStateChange change of the task state on a host
+type HostTaskStateChange struct {
+	HostTaskState
+
+	// Previous state, zero if the host had no state before the task was started
+	Previous TaskState `json:"previous,omitempty"`
+}
+
+// TaskRunOptions struct using in Tasks.RunTaskAndWait
+type TaskRunOptions struct {
+	// PollInterval interval between task state checks, 5 seconds by default
+	PollInterval time.Duration
+
+	// Timeout stops waiting after the given duration, the task keeps running on the server.
+	// DefaultTaskWaitTimeout if zero, no timeout if negative
+	Timeout time.Duration
+
+	// PageSize number of host states requested at once, 500 by default
+	PageSize int64
+
+	// Updates receives per-host state changes if not nil. The channel is closed when Tasks.RunTaskAndWait returns
+	Updates chan<- HostTaskStateChange
+}
+
+// TaskRunSummary final state of the task run by Tasks.RunTaskAndWait
+type TaskRunSummary struct {
+	TaskID string `json:"taskId"`
+
+	// Statistics number of hosts by task state
+	Statistics map[TaskState]int64 `json:"statistics"`
+
+	// CompletedPercent task completion percentage
+	CompletedPercent int64 `json:"completedPercent"`
+
+	// Hosts last known task states on hosts ordered by host name
+	Hosts []HostTaskState `json:"hosts"`
+
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+
+	TimedOut bool `json:"timedOut,omitempty"`
+	Canceled bool `json:"canceled,omitempty"`
+}
+
+// Failed returns hosts the task failed on
+func (s *TaskRunSummary) Failed() []HostTaskState {
+	var failed []HostTaskState
+	for _, host := range s.Hosts {
+		if host.State == TaskStateFailed {
+			failed = append(failed, host)
+		}
+	}
+	return failed
+}
+
+// RunTaskAndWait Starts the task by Tasks.RunTask and waits until it is completed on all hosts.
+//
+// Task statistics and host states are saved before the task is started and then polled every opts.PollInterval,
+// host state changes are sent to opts.Updates.
+// The task is considered completed when statistics or host states have changed since the task was started,
+// so results of the previous run are not mistaken for the new one, and no hosts are pending, running or scheduled.
+// Host states include the time of the last change, so a rerun ending in the same states is detected too.
+// A task without hosts is completed on the first poll.
+//
+// Returns the summary with ErrTaskWaitTimeout if opts.Timeout hits.
+// If ctx is canceled the task is canceled by Tasks.CancelTask and the summary is returned with ctx error.
+func (ts *Tasks) RunTaskAndWait(ctx context.Context, strTask string, opts TaskRunOptions) (*TaskRunSummary, error) {
+	if opts.Updates != nil {
+		defer close(opts.Updates)
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultChunkSize
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTaskWaitTimeout
+	}
+
+	summary := &TaskRunSummary{TaskID: strTask, Started: time.Now()}
+	baseline, err := ts.taskRunSnapshot(ctx, strTask, opts.PageSize)
+	if err != nil {
+		return summary, err
+	}
+
+	if _, err := ts.RunTask(ctx, strTask); err != nil {
+		return summary, err
+	}
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	hosts := make(map[string]HostTaskState, len(baseline.hosts))
+	for name, host := range baseline.hosts {
+		hosts[name] = host
+	}
+	started := false
+
+	finish := func(err error) (*TaskRunSummary, error) {
+		summary.Duration = time.Since(summary.Started)
+		summary.Hosts = make([]HostTaskState, 0, len(hosts))
+		for _, host := range hosts {
+			summary.Hosts = append(summary.Hosts, host)
+		}
+		sort.Slice(summary.Hosts, func(i, j int) bool { return summary.Hosts[i].HostName < summary.Hosts[j].HostName })
+		return summary, err
+	}
+
+	cancel := func() (*TaskRunSummary, error) {
+		summary.Canceled = true
+		cctx, cancel := context.WithTimeout(context.Background(), taskCancelTimeout)
+		defer cancel()
+		_, err := ts.CancelTask(cctx, strTask)
+		if err == nil {
+			err = ctx.Err()
+		}
+		return finish(err)
+	}
+
+	for {
+		done, err := ts.pollTaskRun(ctx, summary, baseline, hosts, &started, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return cancel()
+			}
+			return finish(err)
+		}
+		if done {
+			return finish(nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return cancel()
+		case <-timeout:
+			summary.TimedOut = true
+			return finish(ErrTaskWaitTimeout)
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+// taskRunSnapshot task statistics and host states at some moment
+type taskRunSnapshot struct {
+	statistics map[TaskState]int64
+	percent    int64
+	hosts      map[string]HostTaskState
+}
+
+// taskRunSnapshot acquires task statistics and host states.
+func (ts *Tasks) taskRunSnapshot(ctx context.Context, strTask string, pageSize int64) (*taskRunSnapshot, error) {
+	stats, _, err := ts.GetTaskStatistics(ctx, strTask)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &taskRunSnapshot{
+		statistics: taskStatistics(stats.TaskStatistic),
+		percent:    stats.TaskStatistic.GnrlCompletedPercent,
+		hosts:      map[string]HostTaskState{},
+	}
+	err = ts.forEachHostTaskState(ctx, strTask, pageSize, func(state HostTaskState) error {
+		snapshot.hosts[state.HostName] = state
+		return nil
+	})
+	return snapshot, err
+}
+
+func taskStatistics(s TaskStatistic) map[TaskState]int64 {
+	return map[TaskState]int64{
+		TaskStatePending:   s.The1,
+		TaskStateRunning:   s.The2,
+		TaskStateCompleted: s.The4,
+		TaskStateWarning:   s.The8,
+		TaskStateFailed:    s.The16,
+		TaskStateScheduled: s.The32,
+		TaskStatePaused:    s.The64,
+	}
+}
+
+// pollTaskRun refreshes task statistics and host states, returns true if the task is completed on all hosts.
+//
+// started is set once statistics or host states differ from baseline taken before the task was started.
+func (ts *Tasks) pollTaskRun(ctx context.Context, summary *TaskRunSummary, baseline *taskRunSnapshot,
+	hosts map[string]HostTaskState, started *bool, opts TaskRunOptions) (bool, error) {
+	stats, _, err := ts.GetTaskStatistics(ctx, summary.TaskID)
+	if err != nil {
+		return false, err
+	}
+
+	s := stats.TaskStatistic
+	summary.Statistics = taskStatistics(s)
+	summary.CompletedPercent = s.GnrlCompletedPercent
+	if summary.CompletedPercent != baseline.percent || !reflect.DeepEqual(summary.Statistics, baseline.statistics) {
+		*started = true
+	}
+
+	if err := ts.forEachHostTaskState(ctx, summary.TaskID, opts.PageSize, func(state HostTaskState) error {
+		previous, seen := hosts[state.HostName]
+		if seen && previous == state {
+			return nil
+		}
+		hosts[state.HostName] = state
+		if before, ok := baseline.hosts[state.HostName]; !ok || before != state {
+			*started = true
+		}
+
+		if opts.Updates == nil {
+			return nil
+		}
+		change := HostTaskStateChange{HostTaskState: state}
+		if seen {
+			change.Previous = previous.State
+		}
+		select {
+		case opts.Updates <- change:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}); err != nil {
+		return false, err
+	}
+
+	var total int64
+	for _, count := range summary.Statistics {
+		total += count
+	}
+	if total == 0 && len(hosts) == 0 {
+		// nothing to run the task on
+		return true, nil
+	}
+
+	active := summary.Statistics[TaskStatePending] + summary.Statistics[TaskStateRunning] +
+		summary.Statistics[TaskStateScheduled]
+	return *started && active == 0, nil
+}
+
+type hostStatusRecords struct {
+	PParHostStatus struct {
+		Statuses []Params `json:"statuses"`
+	} `json:"pParHostStatus"`
+}
+
+// forEachHostTaskState calls fn for task state of every host, pages are acquired by Tasks.GetHostStatusRecordRange.
+func (ts *Tasks) forEachHostTaskState(ctx context.Context, strTask string, pageSize int64,
+	fn func(state HostTaskState) error) error {
+	iterator, _, err := ts.ResetHostIteratorForTaskStatusEx(ctx, HostIteratorForTaskParamsEx{
+		StrTask:        strTask,
+		NHostStateMask: int64(TaskStateAll),
+		PFields2Return: []string{"hostname", "hostdn", "state_code", "state_descr", "time"},
+		PFields2Order:  []FieldsToOrder{},
+		NLifetime:      600,
+	})
+	if err != nil {
+		return err
+	}
+	defer ts.ReleaseHostStatusIterator(context.Background(), iterator.StrHostIteratorId)
+
+	count, _, err := ts.GetHostStatusRecordsCount(ctx, iterator.StrHostIteratorId)
+	if err != nil {
+		return err
+	}
+
+	for start := int64(0); start < count.Int; start += pageSize {
+		raw, err := ts.GetHostStatusRecordRange(ctx, iterator.StrHostIteratorId, start, start+pageSize)
+		if err != nil {
+			return err
+		}
+
+		records := new(hostStatusRecords)
+		if err := json.Unmarshal(raw, records); err != nil {
+			return err
+		}
+
+		for _, status := range records.PParHostStatus.Statuses {
+			if err := fn(HostTaskState{
+				HostName:    status.String("hostname"),
+				DisplayName: status.String("hostdn"),
+				State:       TaskState(status.Int("state_code")),
+				Description: status.String("state_descr"),
+				Time:        status.Time("time"),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
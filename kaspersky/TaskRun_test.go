@@ -0,0 +1,141 @@
+package kaspersky_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+func TestRunTaskAndWait(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	// The first statistics request is the baseline taken before RunTask: results of the previous run.
+	// The task is not started yet on the next poll, then it runs and completes again with the same statistics.
+	var polls, runAt int32
+	handler.HandleFunc("/api/v1.0/Tasks.RunTask", func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&runAt, atomic.LoadInt32(&polls))
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/Tasks.GetTaskStatistics", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) == 3 {
+			w.Write([]byte(`{"PxgRetVal": {"2": 1, "32": 1, "GNRL_COMPLETED_PERCENT": 0}}`))
+			return
+		}
+		w.Write([]byte(`{"PxgRetVal": {"4": 1, "16": 1, "GNRL_COMPLETED_PERCENT": 100}}`))
+	})
+	handler.HandleFunc("/api/v1.0/Tasks.ResetHostIteratorForTaskStatusEx", HandlerFuncOk(`{"strHostIteratorId": "it"}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ReleaseHostStatusIterator", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/Tasks.GetHostStatusRecordsCount", HandlerFuncOk(`{"PxgRetVal": 2}`))
+	handler.HandleFunc("/api/v1.0/Tasks.GetHostStatusRecordRange", func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.LoadInt32(&polls) {
+		case 1, 2:
+			w.Write([]byte(`{"PxgRetVal": 2, "pParHostStatus": {"statuses": [
+				{"type": "params", "value": {"hostname": "a", "hostdn": "A", "state_code": 4,
+					"time": {"type": "datetime", "value": "2020-05-28T07:00:00Z"}}},
+				{"type": "params", "value": {"hostname": "b", "hostdn": "B", "state_code": 16, "state_descr": "Access denied",
+					"time": {"type": "datetime", "value": "2020-05-28T07:00:00Z"}}}]}}`))
+		case 3:
+			w.Write([]byte(`{"PxgRetVal": 2, "pParHostStatus": {"statuses": [
+				{"type": "params", "value": {"hostname": "a", "hostdn": "A", "state_code": 32,
+					"time": {"type": "datetime", "value": "2020-05-29T07:00:00Z"}}},
+				{"type": "params", "value": {"hostname": "b", "hostdn": "B", "state_code": 2,
+					"time": {"type": "datetime", "value": "2020-05-29T07:00:00Z"}}}]}}`))
+		default:
+			w.Write([]byte(`{"PxgRetVal": 2, "pParHostStatus": {"statuses": [
+				{"type": "params", "value": {"hostname": "a", "hostdn": "A", "state_code": 4,
+					"time": {"type": "datetime", "value": "2020-05-29T07:01:00Z"}}},
+				{"type": "params", "value": {"hostname": "b", "hostdn": "B", "state_code": 16, "state_descr": "Access denied",
+					"time": {"type": "datetime", "value": "2020-05-29T07:01:00Z"}}}]}}`))
+		}
+	})
+
+	updates := make(chan kaspersky.HostTaskStateChange)
+	var changes []kaspersky.HostTaskStateChange
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for change := range updates {
+			changes = append(changes, change)
+		}
+	}()
+
+	summary, err := client.Tasks.RunTaskAndWait(ctx, "42", kaspersky.TaskRunOptions{
+		PollInterval: time.Millisecond,
+		Updates:      updates,
+	})
+	<-done
+	expectSucceeded(t, err)
+	expectEqual(t, int32(1), atomic.LoadInt32(&runAt))
+	expectEqual(t, int32(4), atomic.LoadInt32(&polls))
+	expectEqual(t, 4, len(changes))
+	expectEqual(t, kaspersky.TaskStateCompleted, changes[0].Previous)
+	expectEqual(t, kaspersky.TaskStateScheduled, changes[2].Previous)
+	expectEqual(t, kaspersky.TaskStateRunning, changes[3].Previous)
+	expectEqual(t, int64(100), summary.CompletedPercent)
+	expectEqual(t, int64(1), summary.Statistics[kaspersky.TaskStateFailed])
+	expectEqual(t, []kaspersky.HostTaskState{{HostName: "b", DisplayName: "B", State: kaspersky.TaskStateFailed,
+		Description: "Access denied", Time: time.Date(2020, 5, 29, 7, 1, 0, 0, time.UTC)}}, summary.Failed())
+}
+
+func TestRunTaskAndWaitCancel(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	var canceled int32
+	handler.HandleFunc("/api/v1.0/Tasks.RunTask", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/Tasks.CancelTask", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&canceled, 1)
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/Tasks.GetTaskStatistics", HandlerFuncOk(`{"PxgRetVal": {"2": 1}}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ResetHostIteratorForTaskStatusEx", HandlerFuncOk(`{"strHostIteratorId": "it"}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ReleaseHostStatusIterator", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/Tasks.GetHostStatusRecordsCount", HandlerFuncOk(`{"PxgRetVal": 0}`))
+
+	summary, err := client.Tasks.RunTaskAndWait(context.Background(), "42", kaspersky.TaskRunOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+	})
+	if err != kaspersky.ErrTaskWaitTimeout {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+	expectEqual(t, true, summary.TimedOut)
+	expectEqual(t, int32(0), atomic.LoadInt32(&canceled))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	summary, err = client.Tasks.RunTaskAndWait(ctx, "42", kaspersky.TaskRunOptions{PollInterval: time.Millisecond})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context error, got %v", err)
+	}
+	expectEqual(t, true, summary.Canceled)
+	expectEqual(t, int32(1), atomic.LoadInt32(&canceled))
+}
+
+func TestRunTaskAndWaitNoHosts(t *testing.T) {
+	srv, handler := NewTestServer()
+	defer srv.Close()
+
+	client := kaspersky.New(kaspersky.Config{Server: srv.URL})
+
+	handler.HandleFunc("/api/v1.0/Tasks.RunTask", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/Tasks.GetTaskStatistics", HandlerFuncOk(`{"PxgRetVal": {"GNRL_COMPLETED_PERCENT": 0}}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ResetHostIteratorForTaskStatusEx", HandlerFuncOk(`{"strHostIteratorId": "it"}`))
+	handler.HandleFunc("/api/v1.0/Tasks.ReleaseHostStatusIterator", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/Tasks.GetHostStatusRecordsCount", HandlerFuncOk(`{"PxgRetVal": 0}`))
+
+	summary, err := client.Tasks.RunTaskAndWait(context.Background(), "42", kaspersky.TaskRunOptions{PollInterval: time.Hour})
+	expectSucceeded(t, err)
+	expectEqual(t, false, summary.TimedOut)
+	expectEqual(t, 0, len(summary.Hosts))
+}
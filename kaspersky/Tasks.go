@@ -276,6 +276,19 @@ func (ts *Tasks) ProtectPassword(ctx context.Context, strPassword string) ([]byt
 	return raw, err
 }
 
+// ProtectPasswordBinary Encrypt an account password. Unlike Tasks.ProtectPassword returns decoded protected password.
+//
+// Protected password is used as "klprts-TaskAccountPassword" of the task account.
+func (ts *Tasks) ProtectPasswordBinary(ctx context.Context, strPassword string) (*PxgValBinary, []byte, error) {
+	params := struct {
+		StrPassword string `json:"strPassword"`
+	}{strPassword}
+
+	pxgValBinary := new(PxgValBinary)
+	raw, err := ts.client.PostInOut(ctx, "/api/v1.0/Tasks.ProtectPassword", params, pxgValBinary)
+	return pxgValBinary, raw, err
+}
+
 //TasksIteratorParams struct
 type TasksIteratorParams struct {
 	NGroupID            int64  `json:"nGroupId"`
@@ -302,6 +302,12 @@ type KlsplPermissionValue struct {
 	// KlspluMayRemove The entry may be removed.
 	KlspluMayRemove bool `json:"KLSPLU_MAY_REMOVE,omitempty"`
 
+	// TrusteeID id of trustee or role (if not OS account), see HstAccessControl.FindTrustees and HstAccessControl.FindRoles.
+	TrusteeID int64 `json:"KLHST_ACL_TRUSTEE_ID,omitempty"`
+
+	// FuncArea functional area "<product>|<version>|<functional area>" the entry applies to, the whole object if empty.
+	FuncArea string `json:"KLSPLU_FUNC_AREA,omitempty"`
+
 	// KlspluAllowmask Allow access mask
 	KlspluAllowmask int64 `json:"KLSPLU_ALLOWMASK,omitempty"`
 
@@ -100,9 +100,25 @@ type EulasIDSValue struct {
 	NPatchDBID int64 `json:"nPatchDbId,omitempty"`
 }
 
+// Update approval states, see ChangeApprovalParams.NApprovementState
+const (
+	ApprovementStateUndefined int64 = 0
+	ApprovementStateApproved  int64 = 1
+	ApprovementStateDeclined  int64 = 2
+)
+
+// ChangeApprovalParams struct using in VapmControlApi.ChangeApproval
+type ChangeApprovalParams struct {
+	// PUpdates updates to be approved/declined
+	PUpdates []PUpdate `json:"pUpdates"`
+
+	// NApprovementState new approval state, e.g. ApprovementStateApproved
+	NApprovementState int64 `json:"nApprovementState"`
+}
+
 // ChangeApproval
 // Changes updates approval.
-func (vca *VapmControlApi) ChangeApproval(ctx context.Context, params EulasIDSForUpdates) ([]byte, error) {
+func (vca *VapmControlApi) ChangeApproval(ctx context.Context, params ChangeApprovalParams) ([]byte, error) {
 	postData, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
@@ -210,30 +226,41 @@ func (vca *VapmControlApi) GetAttributesSetVersionNum(ctx context.Context) (*Pxg
 
 // GetDownloadPatchDataChunk
 // Get the downloaded patch body chunk.
-func (vca *VapmControlApi) GetDownloadPatchDataChunk(ctx context.Context, wstrRequestId string, nStartPos, nSizeMax int64) ([]byte, error) {
+func (vca *VapmControlApi) GetDownloadPatchDataChunk(ctx context.Context, wstrRequestId string, nStartPos, nSizeMax int64) (*PxgValBinary, []byte, error) {
 	postData := []byte(fmt.Sprintf(`{"wstrRequestId": "%s", "nStartPos": %d, "nSizeMax": %d}`, wstrRequestId, nStartPos, nSizeMax))
 
 	request, err := http.NewRequest("POST", vca.client.Server+"/api/v1.0/VapmControlApi.GetDownloadPatchDataChunk", bytes.NewBuffer(postData))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	raw, err := vca.client.Do(ctx, request, nil)
-	return raw, err
+	pxgValBinary := new(PxgValBinary)
+	raw, err := vca.client.Do(ctx, request, pxgValBinary)
+	return pxgValBinary, raw, err
+}
+
+// DownloadPatchResult result of the patch downloading
+type DownloadPatchResult struct {
+	// WstrFileName file name of the patch
+	WstrFileName string `json:"wstrFileName"`
+
+	// NSize size of the patch file in bytes
+	NSize int64 `json:"nSize"`
 }
 
 // GetDownloadPatchResult
 // Get the information on the patch download result.
-func (vca *VapmControlApi) GetDownloadPatchResult(ctx context.Context, wstrRequestId string) ([]byte, error) {
+func (vca *VapmControlApi) GetDownloadPatchResult(ctx context.Context, wstrRequestId string) (*DownloadPatchResult, []byte, error) {
 	postData := []byte(fmt.Sprintf(`{"wstrRequestId": "%s"}`, wstrRequestId))
 
 	request, err := http.NewRequest("POST", vca.client.Server+"/api/v1.0/VapmControlApi.GetDownloadPatchResult", bytes.NewBuffer(postData))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	raw, err := vca.client.Do(ctx, request, nil)
-	return raw, err
+	result := new(DownloadPatchResult)
+	raw, err := vca.client.Do(ctx, request, result)
+	return result, raw, err
 }
 
 // PEULAParams struct using in VapmControlApi.GetEulaParams
@@ -0,0 +1,213 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package kaspersky
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultPatchChunkSize number of bytes acquired by one VapmControlApi.GetDownloadPatchDataChunk call in
+// VapmControlApi.DownloadPatch.
+const DefaultPatchChunkSize int64 = 1 << 20
+
+// ErrPatchSizeMismatch is returned by VapmControlApi.DownloadPatch if the number of downloaded bytes
+// differs from the patch size reported by the Administration Server.
+var ErrPatchSizeMismatch = errors.New("patch size mismatch")
+
+// DownloadPatchOptions struct using in VapmControlApi.DownloadPatch
+type DownloadPatchOptions struct {
+	// ChunkSize number of bytes acquired at once, DefaultPatchChunkSize by default
+	ChunkSize int64
+
+	// Offset number of the patch bytes already downloaded, e.g. size of a partial file.
+	// Downloading resumes from this position.
+	Offset int64
+
+	// Retries number of retries of a failed chunk, 3 by default, no retries if negative
+	Retries int
+
+	// RetryDelay delay before the first retry of a chunk, doubled by every next retry, 1 second by default
+	RetryDelay time.Duration
+
+	// RequestID id of the download request, random by default
+	RequestID string
+}
+
+// PatchDownload result of VapmControlApi.DownloadPatch
+type PatchDownload struct {
+	RequestID string `json:"requestId"`
+
+	// FileName file name of the patch
+	FileName string `json:"fileName"`
+
+	// Size size of the patch in bytes
+	Size int64 `json:"size"`
+
+	// Written number of bytes written by this download, Size - DownloadPatchOptions.Offset on success
+	Written int64 `json:"written"`
+}
+
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// DownloadPatch Downloads 3-party patch llPatchGlbId in language nLcid and writes its body to w.
+//
+// The download is started by VapmControlApi.DownloadPatchAsync, its completion is awaited by
+// AsyncActionStateChecker.WaitActionState and the patch body is acquired chunk by chunk starting from opts.Offset,
+// failed chunks are retried. If the download fails or ctx is done, it is canceled by VapmControlApi.CancelDownloadPatch.
+//
+// Returns ErrPatchSizeMismatch if the number of bytes downloaded does not match the patch size.
+func (vca *VapmControlApi) DownloadPatch(ctx context.Context, llPatchGlbId, nLcid int64, w io.Writer, opts DownloadPatchOptions) (*PatchDownload, error) {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultPatchChunkSize
+	}
+	retries := opts.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	download := &PatchDownload{RequestID: opts.RequestID}
+	if download.RequestID == "" {
+		id, err := newRequestID()
+		if err != nil {
+			return nil, err
+		}
+		download.RequestID = id
+	}
+
+	if _, err := vca.DownloadPatchAsync(ctx, llPatchGlbId, nLcid, download.RequestID); err != nil {
+		return download, err
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			_, _ = vca.CancelDownloadPatch(context.Background(), download.RequestID)
+		}
+	}()
+
+	if _, err := vca.client.AsyncActionStateChecker.WaitActionState(ctx, download.RequestID); err != nil {
+		return download, err
+	}
+
+	result, _, err := vca.GetDownloadPatchResult(ctx, download.RequestID)
+	if err != nil {
+		return download, err
+	}
+	download.FileName, download.Size = result.WstrFileName, result.NSize
+
+	if opts.Offset > download.Size {
+		return download, fmt.Errorf("offset %d is beyond patch size %d: %w", opts.Offset, download.Size, ErrPatchSizeMismatch)
+	}
+
+	for pos := opts.Offset; pos < download.Size; {
+		size := chunkSize
+		if rest := download.Size - pos; rest < size {
+			size = rest
+		}
+
+		chunk, err := vca.patchChunk(ctx, download.RequestID, pos, size, retries, retryDelay)
+		if err != nil {
+			return download, fmt.Errorf("chunk at %d: %w", pos, err)
+		}
+		if len(chunk) == 0 {
+			return download, fmt.Errorf("empty chunk at %d of %d bytes: %w", pos, download.Size, ErrPatchSizeMismatch)
+		}
+		if int64(len(chunk)) > size {
+			return download, fmt.Errorf("chunk at %d is %d bytes, requested %d: %w", pos, len(chunk), size, ErrPatchSizeMismatch)
+		}
+
+		n, err := w.Write(chunk)
+		download.Written += int64(n)
+		if err != nil {
+			return download, err
+		}
+		pos += int64(n)
+	}
+
+	completed = true
+	return download, nil
+}
+
+// patchChunk acquires patch chunk retrying failures.
+func (vca *VapmControlApi) patchChunk(ctx context.Context, wstrRequestId string, nStartPos, nSizeMax int64, retries int, delay time.Duration) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		chunk, _, err := vca.GetDownloadPatchDataChunk(ctx, wstrRequestId, nStartPos, nSizeMax)
+		if err == nil {
+			return chunk.Binary, nil
+		}
+		if attempt >= retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// DownloadPatchFile Downloads 3-party patch by VapmControlApi.DownloadPatch into file path.
+//
+// If the file exists, it is treated as a partial download of the patch and downloading resumes from its size,
+// opts.Offset is ignored.
+func (vca *VapmControlApi) DownloadPatchFile(ctx context.Context, llPatchGlbId, nLcid int64, path string, opts DownloadPatchOptions) (*PatchDownload, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	opts.Offset = info.Size()
+
+	download, err := vca.DownloadPatch(ctx, llPatchGlbId, nLcid, f, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return download, err
+}
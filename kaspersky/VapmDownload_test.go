@@ -0,0 +1,113 @@
+package kaspersky_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+const patchBody = "0123456789abcdefghij"
+
+func newPatchServer(t *testing.T, failures int32) (*kaspersky.Client, *[]int64, *int32) {
+	srv, handler := NewTestServer()
+	t.Cleanup(srv.Close)
+
+	var starts []int64
+	var canceled int32
+	handler.HandleFunc("/api/v1.0/VapmControlApi.DownloadPatchAsync", HandlerFuncOk(`{}`))
+	handler.HandleFunc("/api/v1.0/AsyncActionStateChecker.CheckActionState",
+		HandlerFuncOk(`{"bFinalized": true, "bSuccededFinalized": true}`))
+	handler.HandleFunc("/api/v1.0/VapmControlApi.GetDownloadPatchResult",
+		HandlerFuncOk(`{"wstrFileName": "patch.msi", "nSize": 20}`))
+	handler.HandleFunc("/api/v1.0/VapmControlApi.CancelDownloadPatch", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&canceled, 1)
+		w.Write([]byte(`{}`))
+	})
+	handler.HandleFunc("/api/v1.0/VapmControlApi.GetDownloadPatchDataChunk", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			NStartPos int64 `json:"nStartPos"`
+			NSizeMax  int64 `json:"nSizeMax"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		starts = append(starts, in.NStartPos)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		end := in.NStartPos + in.NSizeMax
+		if end > int64(len(patchBody)) {
+			end = int64(len(patchBody))
+		}
+		chunk := base64.StdEncoding.EncodeToString([]byte(patchBody[in.NStartPos:end]))
+		w.Write([]byte(`{"PxgRetVal": "` + chunk + `"}`))
+	})
+
+	return kaspersky.New(kaspersky.Config{Server: srv.URL}), &starts, &canceled
+}
+
+func TestDownloadPatch(t *testing.T) {
+	client, starts, canceled := newPatchServer(t, 1)
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	download, err := client.VapmControlApi.DownloadPatch(ctx, 42, 1033, &buf, kaspersky.DownloadPatchOptions{
+		ChunkSize:  8,
+		RetryDelay: time.Millisecond,
+	})
+	expectSucceeded(t, err)
+	expectEqual(t, patchBody, buf.String())
+	expectEqual(t, "patch.msi", download.FileName)
+	expectEqual(t, int64(20), download.Written)
+	expectEqual(t, []int64{0, 0, 8, 16}, *starts)
+	expectEqual(t, int32(0), atomic.LoadInt32(canceled))
+}
+
+func TestDownloadPatchResume(t *testing.T) {
+	client, starts, _ := newPatchServer(t, 0)
+
+	dir, err := ioutil.TempDir("", "patch")
+	expectSucceeded(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "patch.msi")
+	expectSucceeded(t, ioutil.WriteFile(path, []byte(patchBody[:12]), 0644))
+
+	download, err := client.VapmControlApi.DownloadPatchFile(context.Background(), 42, 1033, path,
+		kaspersky.DownloadPatchOptions{ChunkSize: 8})
+	expectSucceeded(t, err)
+	expectEqual(t, int64(8), download.Written)
+	expectEqual(t, []int64{12}, *starts)
+
+	data, err := ioutil.ReadFile(path)
+	expectSucceeded(t, err)
+	expectEqual(t, patchBody, string(data))
+
+	// the file is longer than the patch
+	expectSucceeded(t, ioutil.WriteFile(path, []byte(patchBody+"x"), 0644))
+	_, err = client.VapmControlApi.DownloadPatchFile(context.Background(), 42, 1033, path, kaspersky.DownloadPatchOptions{})
+	if !errors.Is(err, kaspersky.ErrPatchSizeMismatch) {
+		t.Fatalf("expected ErrPatchSizeMismatch, got %v", err)
+	}
+}
+
+func TestDownloadPatchCancel(t *testing.T) {
+	client, starts, canceled := newPatchServer(t, 100)
+
+	_, err := client.VapmControlApi.DownloadPatch(context.Background(), 42, 1033, ioutil.Discard,
+		kaspersky.DownloadPatchOptions{Retries: 2, RetryDelay: time.Millisecond})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	expectEqual(t, 3, len(*starts))
+	expectEqual(t, int32(1), atomic.LoadInt32(canceled))
+}
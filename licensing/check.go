@@ -0,0 +1,118 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package licensing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of alerts
+const (
+	AlertExpiring = "expiring"
+	AlertExpired  = "expired"
+	AlertOverused = "overused"
+)
+
+// Alert problem of a license key
+type Alert struct {
+	// Kind alert kind, e.g. AlertExpiring
+	Kind string `json:"kind"`
+
+	Serial  string `json:"serial"`
+	Product string `json:"product"`
+
+	// Expires, DaysLeft expiration time of the key and whole days left until it, for AlertExpiring and AlertExpired
+	Expires  time.Time `json:"expires,omitempty"`
+	DaysLeft int       `json:"daysLeft,omitempty"`
+
+	// Used, Limit number of hosts using the key and its license count, for AlertOverused
+	Used  int64 `json:"used,omitempty"`
+	Limit int64 `json:"limit,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// CheckExpiry returns AlertExpiring for keys expiring within days after now and AlertExpired for expired keys
+// which are still used by hosts.
+func CheckExpiry(keys []Key, now time.Time, days int) []Alert {
+	var alerts []Alert
+	deadline := now.AddDate(0, 0, days)
+	for _, key := range keys {
+		expires := key.Expires()
+		if expires.IsZero() || expires.After(deadline) {
+			continue
+		}
+
+		alert := Alert{Serial: key.Serial, Product: key.ProductName, Expires: expires}
+		if !expires.After(now) {
+			if key.Used == 0 {
+				continue
+			}
+			alert.Kind = AlertExpired
+			alert.Message = fmt.Sprintf("license key %s (%s) expired on %s and is used by %d hosts",
+				key.Serial, key.ProductName, expires.Format("2006-01-02"), key.Used)
+		} else {
+			alert.Kind = AlertExpiring
+			alert.DaysLeft = int(expires.Sub(now) / (24 * time.Hour))
+			alert.Message = fmt.Sprintf("license key %s (%s) expires on %s, in %d days",
+				key.Serial, key.ProductName, expires.Format("2006-01-02"), alert.DaysLeft)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// Reconcile returns AlertOverused for keys used by more hosts than they are licensed for.
+func Reconcile(keys []Key) []Alert {
+	var alerts []Alert
+	for _, key := range keys {
+		if key.LicenseCount > 0 && key.Used > key.LicenseCount {
+			alerts = append(alerts, Alert{
+				Kind:    AlertOverused,
+				Serial:  key.Serial,
+				Product: key.ProductName,
+				Used:    key.Used,
+				Limit:   key.LicenseCount,
+				Message: fmt.Sprintf("license key %s (%s) is used by %d hosts, licensed for %d",
+					key.Serial, key.ProductName, key.Used, key.LicenseCount),
+			})
+		}
+	}
+	return alerts
+}
+
+// Check returns alerts of CheckExpiry and Reconcile ordered by serial number and kind.
+func Check(keys []Key, now time.Time, days int) []Alert {
+	alerts := append(CheckExpiry(keys, now, days), Reconcile(keys)...)
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if alerts[i].Serial != alerts[j].Serial {
+			return alerts[i].Serial < alerts[j].Serial
+		}
+		return alerts[i].Kind < alerts[j].Kind
+	})
+	return alerts
+}
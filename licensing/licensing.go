@@ -0,0 +1,132 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package licensing keeps track of license keys: hosts using them, expiration and usage above the licensed limit.
+//
+// Keys returns typed license keys with the number of hosts using each key, KeyHosts lists the hosts.
+// Check reports keys which expire within the given number of days, expired keys and keys used by more hosts
+// than they are licensed for.
+package licensing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Key license key and its usage
+type Key struct {
+	kaspersky.LicenseKey
+
+	// Used number of hosts using the key
+	Used int64 `json:"used"`
+}
+
+// Free returns number of hosts the key may still be deployed to, -1 if the key is not limited.
+func (k *Key) Free() int64 {
+	if k.LicenseCount <= 0 {
+		return -1
+	}
+	return k.LicenseCount - k.Used
+}
+
+// Host host using a license key
+type Host struct {
+	// ID host id (KLHST_WKS_HOSTNAME)
+	ID string `json:"KLHST_WKS_HOSTNAME"`
+
+	// Name host display name (KLHST_WKS_DN)
+	Name string `json:"KLHST_WKS_DN"`
+
+	GroupID int64 `json:"KLHST_WKS_GROUPID"`
+}
+
+var hostAttributes = []string{"KLHST_WKS_HOSTNAME", "KLHST_WKS_DN", "KLHST_WKS_GROUPID"}
+
+// keyHostsTimeout lifetime of the hosts iterator, in seconds
+const keyHostsTimeout = 300
+
+// Keys returns license keys known to the Administration Server ordered by serial number,
+// with the number of hosts using each key.
+func Keys(ctx context.Context, client *kaspersky.Client) ([]Key, error) {
+	var records []kaspersky.LicenseKeyRecord
+	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{
+		Fields: []string{"KLLIC_SERIAL"},
+		Order:  []kaspersky.OrderValue{{Name: "KLLIC_SERIAL", Asc: true}},
+	}, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(records))
+	for _, record := range records {
+		key, _, err := client.LicenseKeys.GetKey(ctx, record.Serial)
+		if err != nil {
+			return nil, fmt.Errorf("license key %s: %w", record.Serial, err)
+		}
+		if key.Serial == "" {
+			key.Serial = record.Serial
+		}
+
+		hosts, _, err := client.LicenseKeys.AcquireKeyHosts(ctx, kaspersky.AcquireKeyHostsParams{
+			PInData:     kaspersky.PInData{KllicSerial: record.Serial},
+			PFields:     []string{"KLHST_WKS_HOSTNAME"},
+			LTimeoutSEC: keyHostsTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("license key %s: %w", record.Serial, err)
+		}
+		_, _ = client.HostsKeyIterator.ReleaseIterator(ctx, hosts.WstrIterator)
+
+		keys = append(keys, Key{LicenseKey: *key, Used: hosts.LKeyCount})
+	}
+	return keys, nil
+}
+
+// KeyHosts returns hosts using license key serial.
+func KeyHosts(ctx context.Context, client *kaspersky.Client, serial string) ([]Host, error) {
+	iterator, _, err := client.LicenseKeys.AcquireKeyHosts(ctx, kaspersky.AcquireKeyHostsParams{
+		PInData:        kaspersky.PInData{KllicSerial: serial},
+		PFields:        hostAttributes,
+		PFieldsToOrder: []string{"KLHST_WKS_DN"},
+		LTimeoutSEC:    keyHostsTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.HostsKeyIterator.ReleaseIterator(context.Background(), iterator.WstrIterator)
+
+	hosts := make([]Host, 0, iterator.LKeyCount)
+	err = client.HostsKeyIterator.ForEachHost(ctx, iterator.WstrIterator, 0, func(item json.RawMessage) error {
+		var host Host
+		if err := json.Unmarshal(item, &host); err != nil {
+			return err
+		}
+		hosts = append(hosts, host)
+		return nil
+	})
+	return hosts, err
+}
@@ -0,0 +1,119 @@
+package licensing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/licensing"
+)
+
+var keyData = map[string]string{
+	"AAAA-0001": `{"KLLIC_SERIAL": "AAAA-0001", "KLLIC_PROD_NAME": "Endpoint Security", "KLLIC_KEY_TYPE": 1,
+		"KLLIC_LIC_COUNT": 2, "KLLIC_LIMIT_DATE": {"type": "datetime", "value": "2020-06-20T00:00:00Z"},
+		"KLLICSRV_ACTIVE": true}`,
+	"BBBB-0002": `{"KLLIC_SERIAL": "BBBB-0002", "KLLIC_PROD_NAME": "Endpoint Security", "KLLIC_KEY_TYPE": 1,
+		"KLLIC_LIC_COUNT": 10, "KLLIC_LIMIT_DATE": {"type": "datetime", "value": "2021-06-01T00:00:00Z"},
+		"KLLICSRV_RESERVE": true}`,
+	"CCCC-0003": `{"KLLIC_SERIAL": "CCCC-0003", "KLLIC_PROD_NAME": "Trial", "KLLIC_KEY_TYPE": 3,
+		"KLLIC_LIC_COUNT": 5, "KLLIC_LIMIT_DATE": {"type": "datetime", "value": "2020-05-01T00:00:00Z"}}`,
+}
+
+var keyHosts = map[string]int{"AAAA-0001": 3, "BBBB-0002": 0, "CCCC-0003": 1}
+
+// fakeKSC returns fake Administration Server with license keys.
+func fakeKSC(t *testing.T) *ksctest.Server {
+	srv := ksctest.NewServer(t)
+	var serial string
+	srv.Reply("SrvView.ResetIterator", `{"wstrIteratorId": "it"}`)
+	srv.Reply("SrvView.GetRecordCount", `{"PxgRetVal": 3}`)
+	srv.Reply("SrvView.GetRecordRange", `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+		{"type": "params", "value": {"KLLIC_SERIAL": "AAAA-0001"}},
+		{"type": "params", "value": {"KLLIC_SERIAL": "BBBB-0002"}},
+		{"type": "params", "value": {"KLLIC_SERIAL": "CCCC-0003"}}
+	]}}`)
+	srv.Handle("LicenseKeys.GetKeyData", func(w http.ResponseWriter, r *http.Request) {
+		body := ksctest.Params(r, nil)
+		for serial, data := range keyData {
+			if bytes.Contains(body, []byte(`"KLLIC_SERIAL":"`+serial+`"`)) {
+				w.Write([]byte(`{"pKeyData": ` + data + `}`))
+				return
+			}
+		}
+		t.Errorf("unexpected key: %s", body)
+	})
+	srv.Handle("LicenseKeys.AcquireKeyHosts", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Data struct {
+				Serial string `json:"KLLIC_SERIAL"`
+			} `json:"pInData"`
+		}
+		ksctest.Params(r, &in)
+		serial = in.Data.Serial
+		count, _ := json.Marshal(keyHosts[serial])
+		w.Write([]byte(`{"lKeyCount": ` + string(count) + `, "wstrIterator": "hosts-` + serial + `"}`))
+	})
+	srv.Handle("HostsKeyIterator.GetNextItemsChunk", func(w http.ResponseWriter, r *http.Request) {
+		if body := ksctest.Params(r, nil); !bytes.Contains(body, []byte(`"hosts-`+serial+`"`)) {
+			t.Errorf("unexpected iterator: %s", body)
+		}
+		var items []string
+		for i := 0; i < keyHosts[serial]; i++ {
+			items = append(items, `{"type": "params", "value": {"KLHST_WKS_HOSTNAME": "h`+string(rune('1'+i))+
+				`", "KLHST_WKS_DN": "WKS-`+string(rune('1'+i))+`", "KLHST_WKS_GROUPID": 1}}`)
+		}
+		w.Write([]byte(`{"pChunk": {"KLCSP_ITERATOR_ARRAY": [` + strings.Join(items, ",") + `]}, "bEOF": true}`))
+	})
+	return srv
+}
+
+func TestKeys(t *testing.T) {
+	srv := fakeKSC(t)
+	client := srv.NewClient()
+
+	keys, err := licensing.Keys(context.Background(), client)
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, 3, len(keys))
+	ksctest.ExpectEqual(t, "AAAA-0001", keys[0].Serial)
+	ksctest.ExpectEqual(t, true, keys[0].Active)
+	ksctest.ExpectEqual(t, int64(3), keys[0].Used)
+	ksctest.ExpectEqual(t, true, keys[1].Reserve)
+	ksctest.ExpectEqual(t, int64(10), keys[1].Free())
+	ksctest.ExpectEqual(t, int64(4), keys[2].Free())
+	ksctest.ExpectEqual(t, 3, srv.Calls("HostsKeyIterator.ReleaseIterator"))
+
+	hosts, err := licensing.KeyHosts(context.Background(), client, "AAAA-0001")
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, []licensing.Host{{ID: "h1", Name: "WKS-1", GroupID: 1}, {ID: "h2", Name: "WKS-2", GroupID: 1},
+		{ID: "h3", Name: "WKS-3", GroupID: 1}}, hosts)
+	ksctest.ExpectEqual(t, 4, srv.Calls("HostsKeyIterator.ReleaseIterator"))
+}
+
+func TestCheck(t *testing.T) {
+	client := fakeKSC(t).NewClient()
+
+	keys, err := licensing.Keys(context.Background(), client)
+	ksctest.ExpectSucceeded(t, err)
+
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	alerts := licensing.Check(keys, now, 30)
+	ksctest.ExpectEqual(t, 3, len(alerts))
+
+	ksctest.ExpectEqual(t, licensing.AlertExpiring, alerts[0].Kind)
+	ksctest.ExpectEqual(t, "AAAA-0001", alerts[0].Serial)
+	ksctest.ExpectEqual(t, 19, alerts[0].DaysLeft)
+	ksctest.ExpectEqual(t, licensing.AlertOverused, alerts[1].Kind)
+	ksctest.ExpectEqual(t, int64(3), alerts[1].Used)
+	ksctest.ExpectEqual(t, int64(2), alerts[1].Limit)
+	ksctest.ExpectEqual(t, licensing.AlertExpired, alerts[2].Kind)
+	ksctest.ExpectEqual(t, "CCCC-0003", alerts[2].Serial)
+
+	alerts = licensing.CheckExpiry(keys, now, 10)
+	ksctest.ExpectEqual(t, 1, len(alerts))
+	ksctest.ExpectEqual(t, licensing.AlertExpired, alerts[0].Kind)
+}
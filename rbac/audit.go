@@ -0,0 +1,371 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Types of audited objects
+const (
+	ObjectGroup   = "group"
+	ObjectVServer = "vserver"
+)
+
+// SourceServer Source of rights granted on the Administration Server and inherited by administration groups
+const SourceServer = "Administration Server"
+
+// Object administration group or virtual server
+type Object struct {
+	Type string `json:"type"`
+	ID   int64  `json:"id"`
+
+	// Path full path of administration group, e.g. "Managed devices/Servers", or name of virtual server
+	Path string `json:"path"`
+}
+
+// Permission effective access rights of a trustee to an object
+type Permission struct {
+	Object Object `json:"object"`
+
+	// Trustee name of the trustee, TrusteeID and TrusteeKind are empty if the trustee is not found
+	// among trustees of the report
+	Trustee     string `json:"trustee"`
+	TrusteeID   int64  `json:"trusteeId,omitempty"`
+	TrusteeKind string `json:"trusteeKind,omitempty"`
+
+	// FuncArea functional area "<product>|<version>|<functional area>" the rights apply to, the whole object if empty
+	FuncArea string `json:"funcArea,omitempty"`
+
+	// Allow, Deny access rights allowed and denied to the trustee, Effective allowed and not denied ones,
+	// see kaspersky.AccessRead and others
+	Allow     int64 `json:"allow"`
+	Deny      int64 `json:"deny"`
+	Effective int64 `json:"effective"`
+
+	// Inherited all rights are inherited from the parent group
+	Inherited bool `json:"inherited"`
+
+	// Source path of the object whose ACL defines the rights, SourceServer for rights of the Administration Server
+	Source string `json:"source"`
+
+	// Roles names of roles held by the account whose rights are included
+	Roles []string `json:"roles,omitempty"`
+}
+
+// FuncAreas functional areas accessible to the current session on administration group with Right
+type FuncAreas struct {
+	Group Object   `json:"group"`
+	Right int64    `json:"right"`
+	Areas []string `json:"areas"`
+}
+
+// Options of Audit
+type Options struct {
+	// GroupID root of audited administration groups, "Managed devices" if 0
+	GroupID int64
+
+	// VServers audit ACLs of virtual servers too
+	VServers bool
+
+	// FuncAreaRights access rights to collect functional areas accessible to the current session for,
+	// functional areas are not collected if it is 0
+	FuncAreaRights int64
+
+	// Product, Version product of functional areas, Administration Server if empty
+	Product string
+	Version string
+}
+
+// Report result of Audit
+type Report struct {
+	Trustees []Trustee `json:"trustees"`
+	Objects  []Object  `json:"objects"`
+
+	// Permissions effective permissions ordered by object as in Objects, by trustee name and functional area
+	Permissions []Permission `json:"permissions"`
+
+	FuncAreas []FuncAreas `json:"funcAreas,omitempty"`
+}
+
+// Who returns permissions with all rights of mask effective on objects whose path matches pattern,
+// e.g. Who(kaspersky.AccessExecute, "Managed devices/Servers"). Patterns are matched case-insensitively
+// by path.Match, an empty pattern matches any object.
+func (r *Report) Who(mask int64, pattern string) []Permission {
+	var result []Permission
+	for _, p := range r.Permissions {
+		if p.Effective&mask != mask {
+			continue
+		}
+		if pattern != "" {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(p.Object.Path)); !ok {
+				continue
+			}
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// ace access rights of a trustee for a functional area resolved from ACL entries
+type ace struct {
+	key       string
+	name      string
+	funcArea  string
+	trustee   *Trustee
+	allow     int64
+	deny      int64
+	inherited bool
+	source    string
+	roles     []string
+}
+
+// resolveACL merges ACL entries of the same trustee and functional area and, if the ACL is inherited,
+// entries of the parent.
+// Rights granted by the object itself and by the parent to the same trustee are combined.
+func resolveACL(index *trusteeIndex, acl *kaspersky.Permissions, source string, parent []ace) []ace {
+	var aces []ace
+	byKey := map[string]int{}
+	for _, entry := range acl.Entries() {
+		key, name, trustee := index.resolve(entry)
+		key += "|" + strings.ToLower(entry.FuncArea)
+		if i, ok := byKey[key]; ok {
+			aces[i].allow |= entry.KlspluAllowmask
+			aces[i].deny |= entry.KlspluDenymask
+			continue
+		}
+		byKey[key] = len(aces)
+		aces = append(aces, ace{key: key, name: name, funcArea: entry.FuncArea, trustee: trustee,
+			allow: entry.KlspluAllowmask, deny: entry.KlspluDenymask, source: source})
+	}
+
+	if !acl.KlsplInherited {
+		return aces
+	}
+
+	for _, p := range parent {
+		if i, ok := byKey[p.key]; ok {
+			aces[i].allow |= p.allow
+			aces[i].deny |= p.deny
+			continue
+		}
+		p.inherited = true
+		aces = append(aces, p)
+	}
+	return aces
+}
+
+// expandRoles adds rights of roles to accounts holding them.
+// Rights of a role are combined with rights granted to the account directly for the same functional area.
+func expandRoles(index *trusteeIndex, aces []ace) []ace {
+	result := append([]ace(nil), aces...)
+	byKey := map[string]int{}
+	for i, a := range result {
+		byKey[a.key] = i
+	}
+
+	for _, role := range aces {
+		if role.trustee == nil || role.trustee.Kind != TrusteeRole {
+			continue
+		}
+		for _, id := range role.trustee.Members {
+			member := index.byID[id]
+			if member == nil {
+				continue
+			}
+
+			key := member.key() + "|" + strings.ToLower(role.funcArea)
+			if i, ok := byKey[key]; ok {
+				result[i].allow |= role.allow
+				result[i].deny |= role.deny
+				result[i].inherited = result[i].inherited && role.inherited
+				result[i].roles = append(result[i].roles, role.name)
+				continue
+			}
+			byKey[key] = len(result)
+			result = append(result, ace{key: key, name: member.Name, funcArea: role.funcArea, trustee: member,
+				allow: role.allow, deny: role.deny, inherited: role.inherited, source: role.source,
+				roles: []string{role.name}})
+		}
+	}
+	return result
+}
+
+func (r *Report) add(object Object, aces []ace) {
+	r.Objects = append(r.Objects, object)
+
+	permissions := make([]Permission, 0, len(aces))
+	for _, a := range aces {
+		p := Permission{
+			Object:    object,
+			Trustee:   a.name,
+			FuncArea:  a.funcArea,
+			Allow:     a.allow,
+			Deny:      a.deny,
+			Effective: a.allow &^ a.deny,
+			Inherited: a.inherited,
+			Source:    a.source,
+			Roles:     a.roles,
+		}
+		if a.trustee != nil {
+			p.TrusteeID, p.TrusteeKind = a.trustee.ID, a.trustee.Kind
+		}
+		permissions = append(permissions, p)
+	}
+	sort.SliceStable(permissions, func(i, j int) bool {
+		a, b := strings.ToLower(permissions[i].Trustee), strings.ToLower(permissions[j].Trustee)
+		if a != b {
+			return a < b
+		}
+		return permissions[i].FuncArea < permissions[j].FuncArea
+	})
+	r.Permissions = append(r.Permissions, permissions...)
+}
+
+// Audit collects trustees, roles and ACLs of administration groups and virtual servers and returns
+// effective permissions of each trustee on each object.
+//
+// Groups are audited from opts.GroupID down, parents before children. An inherited ACL of a group
+// gets rights of its parent group, the top administration group gets rights granted on the Administration Server.
+// Accounts holding roles get rights of their roles.
+func Audit(ctx context.Context, client *kaspersky.Client, opts Options) (*Report, error) {
+	trustees, err := Trustees(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	index := newTrusteeIndex(trustees)
+	report := &Report{Trustees: trustees}
+
+	if opts.Product == "" {
+		opts.Product, opts.Version = kaspersky.ProductAdmServer, kaspersky.ProductVersionAdmServer
+	}
+
+	root := opts.GroupID
+	if root == 0 {
+		groups, _, err := client.HostGroup.GroupIdGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		root = groups.Int
+	}
+
+	serverACL, _, err := client.HstAccessControl.GetScVServerAcl(ctx, kaspersky.ScVServerCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("administration server: %w", err)
+	}
+	server := resolveACL(index, serverACL, SourceServer, nil)
+
+	rootPath, err := client.HostGroup.GroupPath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := map[string][]ace{}
+	parentACEs := func(groupPath string) []ace {
+		if i := strings.LastIndex(groupPath, kaspersky.GroupPathSeparator); i >= 0 {
+			return effective[groupPath[:i]]
+		}
+		return server
+	}
+
+	// parents of the audited root group are not reported but pass their rights down
+	names := kaspersky.SplitGroupPath(rootPath)
+	for n := 1; n < len(names); n++ {
+		groupPath := strings.Join(names[:n], kaspersky.GroupPathSeparator)
+		id, err := client.HostGroup.ResolveGroupPath(ctx, groupPath)
+		if err != nil {
+			return nil, err
+		}
+		acl, _, err := client.HstAccessControl.GetScObjectAcl(ctx, id, kaspersky.ScObjectTypeAdmGroup)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %w", groupPath, err)
+		}
+		effective[groupPath] = resolveACL(index, acl, groupPath, parentACEs(groupPath))
+	}
+
+	err = client.HostGroup.WalkGroups(ctx, root, func(id int64, groupPath string) error {
+		acl, _, err := client.HstAccessControl.GetScObjectAcl(ctx, id, kaspersky.ScObjectTypeAdmGroup)
+		if err != nil {
+			return fmt.Errorf("group %q: %w", groupPath, err)
+		}
+
+		aces := resolveACL(index, acl, groupPath, parentACEs(groupPath))
+		effective[groupPath] = aces
+
+		group := Object{Type: ObjectGroup, ID: id, Path: groupPath}
+		report.add(group, expandRoles(index, aces))
+		return report.collectFuncAreas(ctx, client, group, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.VServers {
+		vservers, err := client.VServers.VServers(ctx, -1)
+		if err != nil {
+			return nil, err
+		}
+		if vservers.VServersInfo != nil {
+			for _, info := range *vservers.VServersInfo {
+				if info.VServer == nil || info.VServer.KlvsrvID == nil {
+					continue
+				}
+				object := Object{Type: ObjectVServer, ID: *info.VServer.KlvsrvID}
+				if info.VServer.KlvsrvDN != nil {
+					object.Path = *info.VServer.KlvsrvDN
+				}
+
+				acl, _, err := client.HstAccessControl.GetScVServerAcl(ctx, object.ID)
+				if err != nil {
+					return nil, fmt.Errorf("virtual server %q: %w", object.Path, err)
+				}
+				report.add(object, expandRoles(index, resolveACL(index, acl, object.Path, nil)))
+			}
+		}
+	}
+	return report, nil
+}
+
+// collectFuncAreas appends functional areas accessible to the current session on group for each of opts.FuncAreaRights.
+func (r *Report) collectFuncAreas(ctx context.Context, client *kaspersky.Client, group Object, opts Options) error {
+	for bit := int64(1); bit > 0 && bit <= opts.FuncAreaRights; bit <<= 1 {
+		if opts.FuncAreaRights&bit == 0 {
+			continue
+		}
+
+		areas, _, err := client.HstAccessControl.GetAccessibleFuncAreas(ctx, group.ID, bit, opts.Product, opts.Version, false)
+		if err != nil {
+			return fmt.Errorf("group %q: functional areas: %w", group.Path, err)
+		}
+		r.FuncAreas = append(r.FuncAreas, FuncAreas{Group: group, Right: bit, Areas: areas.PFuncAreasArray})
+	}
+	return nil
+}
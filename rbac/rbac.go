@@ -0,0 +1,255 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package rbac audits access rights of trustees to administration groups and virtual servers.
+//
+// Audit collects trustees and roles, reads ACLs of the administration group tree and of virtual servers
+// and resolves inheritance down the group tree into a matrix of effective permissions of each trustee
+// on each object, e.g. to answer who may run tasks on hosts of a group.
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Kinds of trustees
+const (
+	TrusteeAccount = "account"
+	TrusteeRole    = "role"
+)
+
+// Trustee user, group of users or role access rights are granted to
+type Trustee struct {
+	// ID trustee id (KLHST_ACL_TRUSTEE_ID)
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+
+	// SID security id of OS account
+	SID string `json:"sid,omitempty"`
+
+	// KscUserID id of internal Administration Server user
+	KscUserID int64 `json:"kscUserId,omitempty"`
+
+	// BuiltIn predefined role
+	BuiltIn bool `json:"builtIn,omitempty"`
+
+	// RoleID id of role (KLHST_ACL_ROLE_ID)
+	RoleID int64 `json:"roleId,omitempty"`
+
+	// Members trustee ids of accounts the role is assigned to
+	Members []int64 `json:"members,omitempty"`
+}
+
+// roleMemberAttributes attributes of HstAccessControl.GetRole to resolve role members
+var roleMemberAttributes = []string{"KLHST_ACL_ROLE_ID", "KLHST_ACL_ROLE_TRUSTEES"}
+
+// Trustees returns accounts and roles known to the Administration Server ordered by kind and name.
+//
+// Members of roles are read by HstAccessControl.GetRole, members which are not found among accounts
+// are read by HstAccessControl.GetTrustee.
+func Trustees(ctx context.Context, client *kaspersky.Client) ([]Trustee, error) {
+	accounts, err := client.HstAccessControl.FindTrusteesList(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := client.HstAccessControl.FindRolesList(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	trustees := make([]Trustee, 0, len(accounts)+len(roles))
+	known := map[int64]bool{}
+	for _, account := range accounts {
+		trustee := accountTrustee(account)
+		known[trustee.ID] = true
+		trustees = append(trustees, trustee)
+	}
+
+	for _, role := range roles {
+		trustee := Trustee{Kind: TrusteeRole}
+		if role.KlhstACLTrusteeID != nil {
+			trustee.ID = *role.KlhstACLTrusteeID
+		}
+		if role.KlhstACLRoleID != nil {
+			trustee.RoleID = *role.KlhstACLRoleID
+		}
+		switch {
+		case role.KlhstACLRoleDN != nil && *role.KlhstACLRoleDN != "":
+			trustee.Name = *role.KlhstACLRoleDN
+		case role.KlhstACLRoleName != nil:
+			trustee.Name = *role.KlhstACLRoleName
+		}
+		if role.KlhstACLRoleBuiltIn != nil {
+			trustee.BuiltIn = *role.KlhstACLRoleBuiltIn
+		}
+
+		if trustee.RoleID != 0 {
+			data, _, err := client.HstAccessControl.GetRole(ctx, kaspersky.TRParams{
+				NID: trustee.RoleID, PFieldsToReturn: roleMemberAttributes,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("role %q: %w", trustee.Name, err)
+			}
+			trustee.Members = data.Trustees
+		}
+		trustees = append(trustees, trustee)
+	}
+
+	// accounts holding roles which are not returned by FindTrustees
+	for i := range trustees {
+		if trustees[i].Kind != TrusteeRole {
+			continue
+		}
+		for _, id := range trustees[i].Members {
+			if known[id] {
+				continue
+			}
+			known[id] = true
+
+			member, _, err := client.HstAccessControl.GetTrustee(ctx, kaspersky.TRParams{
+				NID: id, PFieldsToReturn: kaspersky.TrusteeAttributes,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("role %q: member %d: %w", trustees[i].Name, id, err)
+			}
+			if member.Trustee == nil {
+				continue
+			}
+			trustee := accountTrustee(*member.Trustee)
+			if trustee.ID == 0 {
+				trustee.ID = id
+			}
+			trustees = append(trustees, trustee)
+		}
+	}
+
+	sort.SliceStable(trustees, func(i, j int) bool {
+		if trustees[i].Kind != trustees[j].Kind {
+			return trustees[i].Kind < trustees[j].Kind
+		}
+		return strings.ToLower(trustees[i].Name) < strings.ToLower(trustees[j].Name)
+	})
+	return trustees, nil
+}
+
+// accountTrustee returns trustee of account attributes as returned by HstAccessControl.FindTrustees.
+func accountTrustee(account kaspersky.TrusteeValue) Trustee {
+	trustee := Trustee{Kind: TrusteeAccount}
+	if account.KlhstACLTrusteeID != nil {
+		trustee.ID = *account.KlhstACLTrusteeID
+	}
+	if account.KlhstACLTrusteeSid != nil && account.KlhstACLTrusteeSid.Value != nil {
+		trustee.SID = *account.KlhstACLTrusteeSid.Value
+	}
+	if account.KscInternalUserID != nil {
+		trustee.KscUserID = *account.KscInternalUserID
+	}
+	switch {
+	case account.UserPrincipalName != nil && *account.UserPrincipalName != "":
+		trustee.Name = *account.UserPrincipalName
+	case account.DN != nil:
+		trustee.Name = *account.DN
+	}
+	return trustee
+}
+
+// key identifies the trustee in resolved ACLs
+func (t *Trustee) key() string {
+	return t.Kind + ":" + strconv.FormatInt(t.ID, 10) + ":" + strings.ToLower(t.Name)
+}
+
+// trusteeIndex resolves trustees of ACL entries
+type trusteeIndex struct {
+	byID      map[int64]*Trustee
+	byKscUser map[int64]*Trustee
+	bySID     map[string]*Trustee
+	byName    map[string]*Trustee
+}
+
+func newTrusteeIndex(trustees []Trustee) *trusteeIndex {
+	index := &trusteeIndex{
+		byID:      map[int64]*Trustee{},
+		byKscUser: map[int64]*Trustee{},
+		bySID:     map[string]*Trustee{},
+		byName:    map[string]*Trustee{},
+	}
+	for i := range trustees {
+		t := &trustees[i]
+		if t.ID != 0 {
+			index.byID[t.ID] = t
+		}
+		if t.KscUserID != 0 {
+			index.byKscUser[t.KscUserID] = t
+		}
+		if t.SID != "" {
+			index.bySID[t.SID] = t
+		}
+		if t.Name != "" {
+			index.byName[strings.ToLower(t.Name)] = t
+		}
+	}
+	return index
+}
+
+// resolve returns key, name and trustee of ACL entry, the trustee is nil if it is unknown.
+func (index *trusteeIndex) resolve(entry kaspersky.KlsplPermissionValue) (string, string, *Trustee) {
+	var trustee *Trustee
+	switch {
+	case entry.TrusteeID != 0:
+		trustee = index.byID[entry.TrusteeID]
+	case entry.KlspluAkuserID != 0:
+		trustee = index.byKscUser[entry.KlspluAkuserID]
+	case entry.KlspluSid != "":
+		trustee = index.bySID[entry.KlspluSid]
+	}
+	if trustee == nil && entry.KlspluName != "" {
+		trustee = index.byName[strings.ToLower(entry.KlspluName)]
+	}
+
+	name := entry.KlspluName
+	if trustee != nil && trustee.Name != "" {
+		name = trustee.Name
+	}
+
+	switch {
+	case trustee != nil:
+		return trustee.key(), name, trustee
+	case entry.TrusteeID != 0:
+		return "id:" + strconv.FormatInt(entry.TrusteeID, 10), name, nil
+	case entry.KlspluAkuserID != 0:
+		return "ksc:" + strconv.FormatInt(entry.KlspluAkuserID, 10), name, nil
+	case entry.KlspluSid != "":
+		return "sid:" + entry.KlspluSid, name, nil
+	default:
+		return "name:" + strings.ToLower(name), name, nil
+	}
+}
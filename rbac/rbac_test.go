@@ -0,0 +1,197 @@
+package rbac_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/rbac"
+)
+
+var chunks = map[string]string{
+	"trustees": `[
+		{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 10, "dn": "CORP\\helpdesk", "KLHST_ACL_TRUSTEE_SID": {"type": "binary", "value": "S-1-5-21-1"}}},
+		{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 11, "userPrincipalName": "admin@corp", "kscInternalUserId": 7}}
+	]`,
+	"roles": `[
+		{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 20, "KLHST_ACL_ROLE_ID": 2, "KLHST_ACL_ROLE_NAME": "Auditor", "KLHST_ACL_ROLE_BUILT_IN": true}},
+		{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 21, "KLHST_ACL_ROLE_ID": 3, "KLHST_ACL_ROLE_DN": "Operators"}}
+	]`,
+}
+
+var acls = map[int64]string{
+	1: `{"KLSPL_INHERITED": true, "KLSPL_PERMISSIONS": [
+		{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_ALLOWMASK": 11}},
+		{"type": "params", "value": {"KLSPLU_NAME": "Auditor", "KLSPLU_ALLOWMASK": 1}}
+	]}`,
+	2: `{"KLSPL_INHERITED": true, "KLSPL_PERMISSIONS": [
+		{"type": "params", "value": {"KLSPLU_SID": "S-1-5-21-1", "KLSPLU_NAME": "helpdesk", "KLSPLU_ALLOWMASK": 9}},
+		{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_DENYMASK": 2}}
+	]}`,
+	3: `{"KLSPL_PERMISSIONS": [{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_ALLOWMASK": 1}},
+		{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 20, "KLSPLU_FUNC_AREA": "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS",
+			"KLSPLU_ALLOWMASK": 8}}]}`,
+	4: `{"KLSPL_INHERITED": true, "KLSPL_PERMISSIONS": []}`,
+}
+
+var members = map[int64]string{
+	2: `[11]`,
+	3: `[10, 12]`,
+}
+
+func fakeKSC(t *testing.T) *ksctest.Server {
+	srv := ksctest.NewServer(t)
+	srv.Reply("HstAccessControl.FindTrustees", `{"strAccessor": "trustees", "PxgRetVal": 2}`)
+	srv.Reply("HstAccessControl.FindRoles", `{"strAccessor": "roles", "PxgRetVal": 2}`)
+	srv.Handle("HstAccessControl.GetRole", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.TRParams
+		ksctest.Params(r, &in)
+		w.Write([]byte(`{"PxgRetVal": {"KLHST_ACL_ROLE_TRUSTEES": ` + members[in.NID] + `}}`))
+	})
+	srv.Handle("HstAccessControl.GetTrustee", func(w http.ResponseWriter, r *http.Request) {
+		var in kaspersky.TRParams
+		if ksctest.Params(r, &in); in.NID != 12 {
+			t.Errorf("unexpected trustee %d", in.NID)
+		}
+		w.Write([]byte(`{"PxgRetVal": {"KLHST_ACL_TRUSTEE_ID": 12, "userPrincipalName": "ops@corp"}}`))
+	})
+	srv.Handle("ChunkAccessor.GetItemsCount", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Accessor string `json:"strAccessor"`
+		}
+		ksctest.Params(r, &in)
+		w.Write([]byte(`{"PxgRetVal": ` + map[string]string{"trustees": "2", "roles": "2"}[in.Accessor] + `}`))
+	})
+	srv.Handle("ChunkAccessor.GetItemsChunk", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Accessor string `json:"strAccessor"`
+		}
+		ksctest.Params(r, &in)
+		w.Write([]byte(`{"pChunk": {"KLCSP_ITERATOR_ARRAY": ` + chunks[in.Accessor] + `}}`))
+	})
+	srv.Reply("HostGroup.GroupIdGroups", `{"PxgRetVal": 1}`)
+	srv.Reply("HostGroup.GetGroupInfoEx", `{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`)
+	srv.Handle("HostGroup.GetSubgroups", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Parent int64 `json:"nParent"`
+		}
+		ksctest.Params(r, &in)
+		switch in.Parent {
+		case 1:
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Servers"}}]}`))
+		case 2:
+			w.Write([]byte(`{"PxgRetVal": [{"type": "params", "value": {"id": 3, "name": "DB"}},
+				{"type": "params", "value": {"id": 4, "name": "Web"}}]}`))
+		default:
+			w.Write([]byte(`{"PxgRetVal": []}`))
+		}
+	})
+	srv.Handle("HstAccessControl.GetScObjectAcl", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ObjID int64 `json:"nObjId"`
+		}
+		ksctest.Params(r, &in)
+		w.Write([]byte(`{"pAclParams": ` + acls[in.ObjID] + `}`))
+	})
+	srv.Reply("VServers.GetVServers", `{"PxgRetVal": [{"type": "params", "value": {"KLVSRV_ID": 5, "KLVSRV_DN": "Branch"}}]}`)
+	srv.Handle("HstAccessControl.GetScVServerAcl", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			ID int64 `json:"nId"`
+		}
+		ksctest.Params(r, &in)
+		if in.ID == kaspersky.ScVServerCurrent {
+			w.Write([]byte(`{"pAclParams": {"KLSPL_PERMISSIONS": [
+				{"type": "params", "value": {"KLHST_ACL_TRUSTEE_ID": 21, "KLSPLU_ALLOWMASK": 8}}]}}`))
+			return
+		}
+		w.Write([]byte(`{"pAclParams": {"KLSPL_PERMISSIONS": [
+			{"type": "params", "value": {"KLSPLU_AKUSER_ID": 7, "KLSPLU_ALLOWMASK": 3}}]}}`))
+	})
+	srv.Handle("HstAccessControl.GetAccessibleFuncAreas", func(w http.ResponseWriter, r *http.Request) {
+		if body := ksctest.Params(r, nil); !bytes.Contains(body, []byte(`"szwProduct":"1093"`)) {
+			t.Errorf("unexpected product: %s", body)
+		}
+		w.Write([]byte(`{"pFuncAreasArray": ["1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS"]}`))
+	})
+	return srv
+}
+
+type permission struct {
+	Object    string
+	Trustee   string
+	FuncArea  string
+	Effective int64
+	Inherited bool
+	Source    string
+	Roles     []string
+}
+
+func summary(permissions []rbac.Permission) []permission {
+	var result []permission
+	for _, p := range permissions {
+		result = append(result, permission{p.Object.Path, p.Trustee, p.FuncArea, p.Effective, p.Inherited, p.Source, p.Roles})
+	}
+	return result
+}
+
+func TestAudit(t *testing.T) {
+	client := fakeKSC(t).NewClient()
+
+	report, err := rbac.Audit(context.Background(), client, rbac.Options{VServers: true, FuncAreaRights: kaspersky.AccessExecute})
+	ksctest.ExpectSucceeded(t, err)
+
+	ksctest.ExpectEqual(t, []rbac.Trustee{
+		{ID: 11, Kind: rbac.TrusteeAccount, Name: "admin@corp", KscUserID: 7},
+		{ID: 10, Kind: rbac.TrusteeAccount, Name: "CORP\\helpdesk", SID: "S-1-5-21-1"},
+		{ID: 12, Kind: rbac.TrusteeAccount, Name: "ops@corp"},
+		{ID: 20, Kind: rbac.TrusteeRole, Name: "Auditor", BuiltIn: true, RoleID: 2, Members: []int64{11}},
+		{ID: 21, Kind: rbac.TrusteeRole, Name: "Operators", RoleID: 3, Members: []int64{10, 12}},
+	}, report.Trustees)
+
+	auditor, operators := []string{"Auditor"}, []string{"Operators"}
+	servers := []permission{
+		{"Managed devices/Servers", "admin@corp", "", 9, false, "Managed devices/Servers", auditor},
+		{"Managed devices/Servers", "Auditor", "", 1, true, "Managed devices", nil},
+		{"Managed devices/Servers", "CORP\\helpdesk", "", 9, false, "Managed devices/Servers", operators},
+		{"Managed devices/Servers", "Operators", "", 8, true, rbac.SourceServer, nil},
+		{"Managed devices/Servers", "ops@corp", "", 8, true, rbac.SourceServer, operators},
+		{"Managed devices/Servers/DB", "admin@corp", "", 1, false, "Managed devices/Servers/DB", nil},
+		{"Managed devices/Servers/DB", "admin@corp", "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS", 8, false, "Managed devices/Servers/DB", auditor},
+		{"Managed devices/Servers/DB", "Auditor", "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS", 8, false, "Managed devices/Servers/DB", nil},
+		{"Managed devices/Servers/Web", "admin@corp", "", 9, true, "Managed devices/Servers", auditor},
+		{"Managed devices/Servers/Web", "Auditor", "", 1, true, "Managed devices", nil},
+		{"Managed devices/Servers/Web", "CORP\\helpdesk", "", 9, true, "Managed devices/Servers", operators},
+		{"Managed devices/Servers/Web", "Operators", "", 8, true, rbac.SourceServer, nil},
+		{"Managed devices/Servers/Web", "ops@corp", "", 8, true, rbac.SourceServer, operators},
+	}
+	expected := append([]permission{
+		{"Managed devices", "admin@corp", "", 11, false, "Managed devices", auditor},
+		{"Managed devices", "Auditor", "", 1, false, "Managed devices", nil},
+		{"Managed devices", "CORP\\helpdesk", "", 8, true, rbac.SourceServer, operators},
+		{"Managed devices", "Operators", "", 8, true, rbac.SourceServer, nil},
+		{"Managed devices", "ops@corp", "", 8, true, rbac.SourceServer, operators},
+	}, servers...)
+	expected = append(expected, permission{"Branch", "admin@corp", "", 3, false, "Branch", nil})
+	ksctest.ExpectEqual(t, expected, summary(report.Permissions))
+
+	who := report.Who(kaspersky.AccessExecute, "managed devices/servers/*")
+	ksctest.ExpectEqual(t, []permission{
+		{"Managed devices/Servers/DB", "admin@corp", "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS", 8, false, "Managed devices/Servers/DB", auditor},
+		{"Managed devices/Servers/DB", "Auditor", "1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS", 8, false, "Managed devices/Servers/DB", nil},
+		{"Managed devices/Servers/Web", "admin@corp", "", 9, true, "Managed devices/Servers", auditor},
+		{"Managed devices/Servers/Web", "CORP\\helpdesk", "", 9, true, "Managed devices/Servers", operators},
+		{"Managed devices/Servers/Web", "Operators", "", 8, true, rbac.SourceServer, nil},
+		{"Managed devices/Servers/Web", "ops@corp", "", 8, true, rbac.SourceServer, operators},
+	}, summary(who))
+
+	ksctest.ExpectEqual(t, 4, len(report.FuncAreas))
+	ksctest.ExpectEqual(t, []string{"1093|1.0.0.0|KLAUTH_AREA_GROUP_TASKS"}, report.FuncAreas[0].Areas)
+
+	// rights of parents of the audited group are inherited as when the whole tree is audited
+	report, err = rbac.Audit(context.Background(), client, rbac.Options{GroupID: 2})
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, servers, summary(report.Permissions))
+}
@@ -0,0 +1,349 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package reconcile keeps KSC structure in line with a declarative document.
+//
+// The document lists administration groups hierarchy, host moving rules and their order,
+// host tags, host automatic tagging rules and custom user roles. Engine.Plan diffs the document against the live state
+// of the Administration Server and Engine.Apply converges the server to the document.
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Document desired state of the Administration Server
+type Document struct {
+	// Groups administration groups tree. Top level group is the predefined "Managed devices" group.
+	Groups []Group `json:"groups,omitempty" yaml:"groups,omitempty"`
+
+	// MoveRules host moving rules in the desired order of execution
+	MoveRules []MoveRule `json:"moveRules,omitempty" yaml:"moveRules,omitempty"`
+
+	// Tags host tags
+	Tags []Tag `json:"tags,omitempty" yaml:"tags,omitempty"`
+
+	// TagRules host automatic tagging rules
+	TagRules []TagRule `json:"tagRules,omitempty" yaml:"tagRules,omitempty"`
+
+	// Roles custom user roles. Predefined roles are never changed.
+	Roles []Role `json:"roles,omitempty" yaml:"roles,omitempty"`
+
+	// Prune which objects missing from the document are removed from the server.
+	// Administration groups and predefined roles are never removed.
+	Prune Prune `json:"prune,omitempty" yaml:"prune,omitempty"`
+}
+
+// Group administration group and its subgroups
+type Group struct {
+	Name   string  `json:"name" yaml:"name"`
+	Groups []Group `json:"groups,omitempty" yaml:"groups,omitempty"`
+}
+
+// MoveRule host moving rule, identified by its display name
+type MoveRule struct {
+	// Name rule display name (KLHST_MR_DN)
+	Name string `json:"name" yaml:"name"`
+
+	// Group full path of the destination group, e.g. "Managed devices/Branch/Kiosks" (KLHST_MR_Group)
+	Group string `json:"group" yaml:"group"`
+
+	// Query host filtering expression (KLHST_MR_Query)
+	Query string `json:"query" yaml:"query"`
+
+	// Enabled whether rule is turned on, true by default (KLHST_MR_Enabled)
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+
+	// Options rule execution options bit mask (KLHST_MR_Options), 1 (execute once for each host) by default
+	Options int64 `json:"options,omitempty" yaml:"options,omitempty"`
+
+	// AutoDelete whether rule is deleted automatically (KLHST_MR_AutoDelete)
+	AutoDelete bool `json:"autoDelete,omitempty" yaml:"autoDelete,omitempty"`
+}
+
+// Tag host tag
+type Tag struct {
+	Name string `json:"name" yaml:"name"`
+
+	// RenamedFrom previous tag value. If it exists on the server, it is renamed instead of adding a new tag,
+	// so hosts keep the tag.
+	RenamedFrom string `json:"renamedFrom,omitempty" yaml:"renamedFrom,omitempty"`
+}
+
+// TagRule host automatic tagging rule, identified by the tag value it sets
+type TagRule struct {
+	// Tag tag value that will be set by rule (KLHST_HTR_TagValue)
+	Tag string `json:"tag" yaml:"tag"`
+
+	// Name rule display name (KLHST_HTR_DN)
+	Name string `json:"name" yaml:"name"`
+
+	// Query host filtering expression (KLHST_HTR_Query)
+	Query string `json:"query" yaml:"query"`
+
+	// Enabled whether rule is turned on, true by default (KLHST_HTR_Enabled)
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// Role custom user role, identified by its name (KLHST_ACL_ROLE_NAME or KLHST_ACL_ROLE_DN)
+type Role struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Products access rights of the role per product
+	Products []RoleProduct `json:"products,omitempty" yaml:"products,omitempty"`
+}
+
+// RoleProduct access rights of a role to a product and its functional areas
+type RoleProduct struct {
+	// Product, Version e.g. "1093" and "1.0.0.0" for the Administration Server
+	Product string `json:"product" yaml:"product"`
+	Version string `json:"version" yaml:"version"`
+
+	// Allow, Deny names of access rights to all functional areas of the product, e.g. [read, execute],
+	// see kaspersky.AccessRightNames
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+
+	// Areas access rights to functional areas of the product
+	Areas []RoleArea `json:"areas,omitempty" yaml:"areas,omitempty"`
+}
+
+// RoleArea access rights of a role to a functional area.
+//
+// Area is the name of a functional area as reported by the server, or "task:", "policy:", "settings:" or "report:"
+// followed by the name of a task type, policy, settings section or report of the functional area,
+// e.g. "task:KLNAG_TASK_REMOTE_INSTALL".
+type RoleArea struct {
+	Area  string   `json:"area" yaml:"area"`
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// Prune struct
+type Prune struct {
+	MoveRules bool `json:"moveRules,omitempty" yaml:"moveRules,omitempty"`
+	Tags      bool `json:"tags,omitempty" yaml:"tags,omitempty"`
+	TagRules  bool `json:"tagRules,omitempty" yaml:"tagRules,omitempty"`
+	Roles     bool `json:"roles,omitempty" yaml:"roles,omitempty"`
+}
+
+const defaultMoveRuleOptions int64 = 1
+
+func (r MoveRule) enabled() bool { return r.Enabled == nil || *r.Enabled }
+
+func (r MoveRule) options() int64 {
+	if r.Options == 0 {
+		return defaultMoveRuleOptions
+	}
+	return r.Options
+}
+
+func (r TagRule) enabled() bool { return r.Enabled == nil || *r.Enabled }
+
+// LoadFile reads document from JSON (.json) or YAML (.yaml, .yml) file.
+func LoadFile(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return Parse(data, "json")
+	default:
+		return Parse(data, "yaml")
+	}
+}
+
+// Parse decodes document in "json" or "yaml" format and validates it.
+func Parse(data []byte, format string) (*Document, error) {
+	doc := new(Document)
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, doc)
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, doc)
+	default:
+		err = fmt.Errorf("unknown document format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, doc.Validate()
+}
+
+// Validate checks document for empty and duplicate names.
+func (d *Document) Validate() error {
+	if err := validateGroups("", d.Groups); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for i, rule := range d.MoveRules {
+		key := strings.ToLower(rule.Name)
+		switch {
+		case rule.Name == "":
+			return fmt.Errorf("moveRules[%d]: empty name", i)
+		case rule.Group == "":
+			return fmt.Errorf("move rule %q: empty group", rule.Name)
+		case seen[key]:
+			return fmt.Errorf("move rule %q: duplicate name", rule.Name)
+		}
+		seen[key] = true
+	}
+
+	seen = map[string]bool{}
+	for i, tag := range d.Tags {
+		switch {
+		case tag.Name == "":
+			return fmt.Errorf("tags[%d]: empty name", i)
+		case seen[tag.Name]:
+			return fmt.Errorf("tag %q: duplicate name", tag.Name)
+		}
+		seen[tag.Name] = true
+	}
+
+	seen = map[string]bool{}
+	for i, rule := range d.TagRules {
+		switch {
+		case rule.Tag == "":
+			return fmt.Errorf("tagRules[%d]: empty tag", i)
+		case seen[rule.Tag]:
+			return fmt.Errorf("tag rule %q: duplicate tag", rule.Tag)
+		}
+		seen[rule.Tag] = true
+	}
+
+	seen = map[string]bool{}
+	for i, role := range d.Roles {
+		key := strings.ToLower(role.Name)
+		switch {
+		case role.Name == "":
+			return fmt.Errorf("roles[%d]: empty name", i)
+		case seen[key]:
+			return fmt.Errorf("role %q: duplicate name", role.Name)
+		}
+		seen[key] = true
+
+		if err := role.validate(); err != nil {
+			return fmt.Errorf("role %q: %w", role.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r Role) validate() error {
+	products := map[string]bool{}
+	for i, product := range r.Products {
+		key := strings.ToLower(product.Product + "/" + product.Version)
+		switch {
+		case product.Product == "" || product.Version == "":
+			return fmt.Errorf("products[%d]: empty product or version", i)
+		case products[key]:
+			return fmt.Errorf("product %s/%s: duplicate product", product.Product, product.Version)
+		}
+		products[key] = true
+
+		if _, _, err := rights(product.Allow, product.Deny); err != nil {
+			return fmt.Errorf("product %s/%s: %w", product.Product, product.Version, err)
+		}
+
+		areas := map[string]bool{}
+		for j, area := range product.Areas {
+			switch {
+			case area.Area == "":
+				return fmt.Errorf("product %s/%s: areas[%d]: empty area", product.Product, product.Version, j)
+			case areas[strings.ToLower(area.Area)]:
+				return fmt.Errorf("product %s/%s: area %q: duplicate area", product.Product, product.Version, area.Area)
+			}
+			areas[strings.ToLower(area.Area)] = true
+
+			if _, _, err := rights(area.Allow, area.Deny); err != nil {
+				return fmt.Errorf("product %s/%s: area %q: %w", product.Product, product.Version, area.Area, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rights returns access masks of allowed and denied access rights names.
+func rights(allow, deny []string) (int64, int64, error) {
+	allowMask, err := kaspersky.ParseAccessRights(allow...)
+	if err != nil {
+		return 0, 0, err
+	}
+	denyMask, err := kaspersky.ParseAccessRights(deny...)
+	if err != nil {
+		return 0, 0, err
+	}
+	return allowMask, denyMask, nil
+}
+
+func validateGroups(parent string, groups []Group) error {
+	seen := map[string]bool{}
+	for _, group := range groups {
+		name := strings.TrimSpace(group.Name)
+		switch {
+		case name == "":
+			return fmt.Errorf("group %q: empty subgroup name", parent)
+		case strings.Contains(name, kaspersky.GroupPathSeparator):
+			return fmt.Errorf("group %q: name must not contain %q", name, kaspersky.GroupPathSeparator)
+		case seen[strings.ToLower(name)]:
+			return fmt.Errorf("group %q: duplicate subgroup %q", parent, name)
+		}
+		seen[strings.ToLower(name)] = true
+
+		if err := validateGroups(parent+kaspersky.GroupPathSeparator+name, group.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GroupPaths returns full paths of all document groups, parents before children.
+func (d *Document) GroupPaths() []string {
+	var paths []string
+	var walk func(prefix string, groups []Group)
+	walk = func(prefix string, groups []Group) {
+		for _, group := range groups {
+			path := group.Name
+			if prefix != "" {
+				path = prefix + kaspersky.GroupPathSeparator + group.Name
+			}
+			paths = append(paths, path)
+			walk(path, group.Groups)
+		}
+	}
+	walk("", d.Groups)
+	return paths
+}
@@ -0,0 +1,512 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Kind kind of reconciled object
+type Kind string
+
+const (
+	KindGroup     Kind = "group"
+	KindMoveRule  Kind = "move-rule"
+	KindTag       Kind = "tag"
+	KindTagRule   Kind = "tag-rule"
+	KindRuleOrder Kind = "move-rule-order"
+	KindRole      Kind = "role"
+)
+
+// Action change applied to reconciled object
+type Action string
+
+const (
+	Create  Action = "create"
+	Update  Action = "update"
+	Delete  Action = "delete"
+	Rename  Action = "rename"
+	Reorder Action = "reorder"
+)
+
+var actionSigns = map[Action]string{Create: "+", Update: "~", Delete: "-", Rename: ">", Reorder: "~"}
+
+// FieldDiff changed object attribute
+type FieldDiff struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Change single change of the plan
+type Change struct {
+	Kind   Kind        `json:"kind"`
+	Action Action      `json:"action"`
+	Name   string      `json:"name"`
+	Diff   []FieldDiff `json:"diff,omitempty"`
+
+	apply func(ctx context.Context, e *Engine) error
+}
+
+// String returns one line description of the change.
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s %s %q", actionSigns[c.Action], c.Action, c.Kind, c.Name)
+}
+
+// Plan ordered list of changes converging the server to the document
+type Plan struct {
+	Changes []Change `json:"changes"`
+}
+
+// Empty returns true if the server already matches the document.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// WriteText writes human readable plan to w.
+func (p *Plan) WriteText(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "No changes. Server matches the document.")
+		return err
+	}
+
+	for _, change := range p.Changes {
+		if _, err := fmt.Fprintln(w, change.String()); err != nil {
+			return err
+		}
+		for _, diff := range change.Diff {
+			if _, err := fmt.Fprintf(w, "    %s: %q -> %q\n", diff.Field, diff.From, diff.To); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "Plan: %d change(s).\n", len(p.Changes))
+	return err
+}
+
+// Engine plans and applies document to the Administration Server.
+type Engine struct {
+	client *kaspersky.Client
+
+	// createdRules ids of host moving rules created by Apply, by lower-cased name
+	createdRules map[string]int64
+
+	// funcAreas functional areas of products, by "product/version"
+	funcAreas map[string]*funcAreaCatalog
+}
+
+// New returns Engine working with client. Client must be authenticated.
+func New(client *kaspersky.Client) *Engine {
+	return &Engine{client: client, createdRules: map[string]int64{}, funcAreas: map[string]*funcAreaCatalog{}}
+}
+
+// Plan diffs document against the live state of the server.
+//
+// Changes are ordered so that they can be applied one by one: groups are created before
+// move rules referring to them, tags are renamed before tagging rules are updated. Functional areas of roles
+// are resolved when the plan is made.
+func (e *Engine) Plan(ctx context.Context, doc *Document) (*Plan, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := new(Plan)
+	for _, step := range []func(context.Context, *Document, *Plan) error{
+		e.planGroups, e.planTags, e.planTagRules, e.planMoveRules, e.planRoles,
+	} {
+		if err := step(ctx, doc, plan); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// Apply applies plan changes in order and stops on the first failed change.
+//
+// Returns changes applied successfully.
+func (e *Engine) Apply(ctx context.Context, plan *Plan) ([]Change, error) {
+	var applied []Change
+	for _, change := range plan.Changes {
+		if change.apply == nil {
+			return applied, fmt.Errorf("%s: change is not bound to an engine, plan must be created by Engine.Plan", change)
+		}
+
+		if err := change.apply(ctx, e); err != nil {
+			return applied, fmt.Errorf("%s: %w", change, err)
+		}
+		applied = append(applied, change)
+	}
+	return applied, nil
+}
+
+// planGroups plans creation of missing groups of the document and target groups of move rules,
+// so every group created by Apply is shown in the plan.
+func (e *Engine) planGroups(ctx context.Context, doc *Document, plan *Plan) error {
+	paths := doc.GroupPaths()
+	for _, rule := range doc.MoveRules {
+		names := kaspersky.SplitGroupPath(rule.Group)
+		for n := 1; n <= len(names); n++ {
+			paths = append(paths, strings.Join(names[:n], kaspersky.GroupPathSeparator))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if seen[strings.ToLower(path)] {
+			continue
+		}
+		seen[strings.ToLower(path)] = true
+
+		_, err := e.client.HostGroup.ResolveGroupPath(ctx, path)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, kaspersky.ErrGroupNotFound) {
+			return err
+		}
+
+		path := path
+		plan.Changes = append(plan.Changes, Change{
+			Kind: KindGroup, Action: Create, Name: path,
+			apply: func(ctx context.Context, e *Engine) error {
+				_, err := e.client.HostGroup.EnsureGroupPath(ctx, path)
+				return err
+			},
+		})
+	}
+	return nil
+}
+
+func (e *Engine) planTags(ctx context.Context, doc *Document, plan *Plan) error {
+	live, err := e.tags(ctx)
+	if err != nil {
+		return err
+	}
+
+	exists := map[string]bool{}
+	for _, tag := range live {
+		exists[tag] = true
+	}
+
+	wanted := map[string]bool{}
+	for _, tag := range doc.Tags {
+		wanted[tag.Name] = true
+		if tag.RenamedFrom != "" {
+			wanted[tag.RenamedFrom] = true
+		}
+	}
+
+	for _, tag := range doc.Tags {
+		tag := tag
+		switch {
+		case exists[tag.Name]:
+		case tag.RenamedFrom != "" && exists[tag.RenamedFrom]:
+			exists[tag.RenamedFrom] = false
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindTag, Action: Rename, Name: tag.Name,
+				Diff: []FieldDiff{{Field: "name", From: tag.RenamedFrom, To: tag.Name}},
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.ListTags.RenameTag(ctx, tagParams{
+						SzwOldTagValue: tag.RenamedFrom, SzwNewTagValue: tag.Name, PParams: &kaspersky.Null{},
+					})
+					return err
+				},
+			})
+		default:
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindTag, Action: Create, Name: tag.Name,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.ListTags.AddTag(ctx, kaspersky.NewTagParams{SzwTagValue: tag.Name})
+					return err
+				},
+			})
+		}
+	}
+
+	if doc.Prune.Tags {
+		var stale []string
+		for _, tag := range live {
+			if !wanted[tag] {
+				stale = append(stale, tag)
+			}
+		}
+
+		for _, tag := range stale {
+			tag := tag
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindTag, Action: Delete, Name: tag,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.ListTags.DeleteTags2(ctx, tagParams{PTagValue: []string{tag}, PParams: &kaspersky.Null{}})
+					return err
+				},
+			})
+		}
+	}
+	return nil
+}
+
+func (e *Engine) planTagRules(ctx context.Context, doc *Document, plan *Plan) error {
+	live, err := e.tagRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	byTag := map[string]liveTagRule{}
+	for _, rule := range live {
+		byTag[rule.Tag] = rule
+	}
+
+	wanted := map[string]bool{}
+	for _, rule := range doc.TagRules {
+		rule := rule
+		wanted[rule.Tag] = true
+
+		change := Change{Kind: KindTagRule, Action: Create, Name: rule.Tag}
+		if current, ok := byTag[rule.Tag]; ok {
+			change.Action = Update
+			change.Diff = diffFields(
+				field("name", current.Name, rule.Name),
+				field("query", current.Query, rule.Query),
+				field("enabled", strconv.FormatBool(current.Enabled), strconv.FormatBool(rule.enabled())),
+			)
+			if len(change.Diff) == 0 {
+				continue
+			}
+		}
+
+		change.apply = func(ctx context.Context, e *Engine) error {
+			_, err := e.client.HostTagsRulesApi.UpdateRule(ctx, kaspersky.UpdateRuleParams{
+				SzwTagValue: rule.Tag,
+				PRuleInfo: kaspersky.PRuleInfo{
+					KlhstHtrDN:       rule.Name,
+					KLHSTHTREnabled:  rule.enabled(),
+					KLHSTHTRTagValue: rule.Tag,
+					KLHSTHTRQuery:    rule.Query,
+					KLHSTHTRCustom:   kaspersky.KLHSTHTRCustom{Type: "params"},
+				},
+			})
+			return err
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if doc.Prune.TagRules {
+		for _, rule := range live {
+			if wanted[rule.Tag] {
+				continue
+			}
+
+			tag := rule.Tag
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindTagRule, Action: Delete, Name: tag,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.HostTagsRulesApi.DeleteRule(ctx, tag)
+					return err
+				},
+			})
+		}
+	}
+	return nil
+}
+
+func (e *Engine) planMoveRules(ctx context.Context, doc *Document, plan *Plan) error {
+	live, err := e.moveRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]liveMoveRule{}
+	for _, rule := range live {
+		byName[strings.ToLower(rule.Name)] = rule
+	}
+
+	wanted := map[string]bool{}
+	for _, rule := range doc.MoveRules {
+		rule := rule
+		wanted[strings.ToLower(rule.Name)] = true
+
+		current, exists := byName[strings.ToLower(rule.Name)]
+		change := Change{Kind: KindMoveRule, Action: Create, Name: rule.Name}
+		if exists {
+			change.Action = Update
+			change.Diff = diffFields(
+				groupField(current.Group, rule.Group),
+				field("query", current.Query, rule.Query),
+				field("enabled", strconv.FormatBool(current.enabled()), strconv.FormatBool(rule.enabled())),
+				field("options", strconv.FormatInt(current.Options, 10), strconv.FormatInt(rule.options(), 10)),
+				field("autoDelete", strconv.FormatBool(current.AutoDelete), strconv.FormatBool(rule.AutoDelete)),
+			)
+			if len(change.Diff) == 0 {
+				continue
+			}
+		}
+
+		change.apply = func(ctx context.Context, e *Engine) error {
+			// missing groups are created by group changes planned before
+			group, err := e.client.HostGroup.ResolveGroupPath(ctx, rule.Group)
+			if err != nil {
+				return err
+			}
+
+			info := moveRuleInfo{
+				Name:       rule.Name,
+				Group:      group,
+				Query:      rule.Query,
+				Enabled:    rule.enabled(),
+				Options:    rule.options(),
+				AutoDelete: rule.AutoDelete,
+			}
+
+			if exists {
+				_, err = e.client.HostMoveRules.UpdateRule(ctx, updateMoveRuleParams{NRule: current.ID, PRule: info})
+				return err
+			}
+
+			raw, err := e.client.HostMoveRules.AddRule(ctx, addMoveRuleParams{PRule: info})
+			if err != nil {
+				return err
+			}
+
+			id := new(kaspersky.PxgValInt)
+			if err := json.Unmarshal(raw, id); err != nil {
+				return err
+			}
+			e.createdRules[strings.ToLower(rule.Name)] = id.Int
+			return nil
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	if doc.Prune.MoveRules {
+		for _, rule := range live {
+			if wanted[strings.ToLower(rule.Name)] {
+				continue
+			}
+
+			id := rule.ID
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindMoveRule, Action: Delete, Name: rule.Name,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.HostMoveRules.DeleteRule(ctx, id)
+					return err
+				},
+			})
+		}
+	}
+
+	var currentOrder []string
+	for _, rule := range live {
+		if wanted[strings.ToLower(rule.Name)] {
+			currentOrder = append(currentOrder, rule.Name)
+		}
+	}
+
+	var desiredOrder []string
+	for _, rule := range doc.MoveRules {
+		desiredOrder = append(desiredOrder, rule.Name)
+	}
+
+	if len(desiredOrder) > 1 && !strings.EqualFold(strings.Join(currentOrder, "\n"), strings.Join(desiredOrder, "\n")) {
+		plan.Changes = append(plan.Changes, Change{
+			Kind: KindRuleOrder, Action: Reorder, Name: "move rules",
+			Diff: []FieldDiff{{
+				Field: "order",
+				From:  strings.Join(currentOrder, ", "),
+				To:    strings.Join(desiredOrder, ", "),
+			}},
+			apply: func(ctx context.Context, e *Engine) error {
+				return e.reorderMoveRules(ctx, desiredOrder)
+			},
+		})
+	}
+	return nil
+}
+
+// reorderMoveRules puts document rules first in the document order, other rules keep their relative order.
+func (e *Engine) reorderMoveRules(ctx context.Context, desiredOrder []string) error {
+	live, err := e.moveRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]int64{}
+	for name, id := range e.createdRules {
+		byName[name] = id
+	}
+	for _, rule := range live {
+		byName[strings.ToLower(rule.Name)] = rule.ID
+	}
+
+	var order []int64
+	ordered := map[int64]bool{}
+	for _, name := range desiredOrder {
+		id, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("move rule %q not found", name)
+		}
+		order = append(order, id)
+		ordered[id] = true
+	}
+
+	for _, rule := range live {
+		if !ordered[rule.ID] {
+			order = append(order, rule.ID)
+		}
+	}
+
+	_, _, err = e.client.HostMoveRules.SetRulesOrder(ctx, kaspersky.RulesOrderParams{PRules: order})
+	return err
+}
+
+// groupField compares group paths case-insensitively as KSC does for group names.
+func groupField(from, to string) FieldDiff {
+	if strings.EqualFold(from, to) {
+		from = to
+	}
+	return field("group", from, to)
+}
+
+func field(name, from, to string) FieldDiff {
+	return FieldDiff{Field: name, From: from, To: to}
+}
+
+// diffFields returns only changed fields.
+func diffFields(fields ...FieldDiff) []FieldDiff {
+	var diff []FieldDiff
+	for _, f := range fields {
+		if f.From != f.To {
+			diff = append(diff, f)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,262 @@
+package reconcile_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/reconcile"
+)
+
+const document = `
+groups:
+  - name: Managed devices
+    groups:
+      - name: Branch
+        groups:
+          - name: Kiosks
+moveRules:
+  - name: Kiosks
+    group: Managed devices/Branch/Kiosks
+    query: (KLHST_WKS_DN="KIOSK-*")
+  - name: Servers
+    group: Managed devices
+    query: (KLHST_WKS_OS_NAME="*Server*")
+    enabled: false
+  - name: Lab
+    group: Managed devices/Lab
+    query: (KLHST_WKS_DN="LAB-*")
+tags:
+  - name: kiosk
+    renamedFrom: kiosks
+  - name: server
+tagRules:
+  - tag: server
+    name: Servers
+    query: (KLHST_WKS_OS_NAME="*Server*")
+prune:
+  tags: true
+`
+
+func newServer(t *testing.T) (*httptest.Server, *[]string) {
+	var calls []string
+	responses := map[string]string{
+		"HostGroup.GroupIdGroups":  `{"PxgRetVal": 1}`,
+		"HostGroup.GetGroupInfoEx": `{"PxgRetVal": {"name": "Managed devices", "parentId": 0}}`,
+		"HostGroup.GetSubgroups":   `{"PxgRetVal": [{"type": "params", "value": {"id": 2, "name": "Branch"}}]}`,
+		"HostMoveRules.GetRules": `{"PxgRetVal": [{"type": "params", "value": {
+			"KLHST_MR_ID": 7, "KLHST_MR_DN": "Servers", "KLHST_MR_Group": 1,
+			"KLHST_MR_Query": "(KLHST_WKS_OS_NAME=\"*Server*\")", "KLHST_MR_Enabled": true, "KLHST_MR_Options": 1}}]}`,
+		"ListTags.GetAllTags":       `{"PxgRetVal": ["kiosks", "obsolete"]}`,
+		"HostTagsRulesApi.GetRules": `{"PxgRetVal": {"KLHST_HTR_Rules": []}}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/api/v1.0/")
+		calls = append(calls, method)
+		if response, ok := responses[method]; ok {
+			w.Write([]byte(response))
+			return
+		}
+		if method == "HostGroup.AddGroup" || method == "HostMoveRules.AddRule" {
+			w.Write([]byte(`{"PxgRetVal": 3}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return srv, &calls
+}
+
+func TestPlan(t *testing.T) {
+	srv, calls := newServer(t)
+	defer srv.Close()
+
+	doc, err := reconcile.Parse([]byte(document), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	engine := reconcile.New(kaspersky.New(kaspersky.Config{Server: srv.URL}))
+	plan, err := engine.Plan(ctx, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, change := range plan.Changes {
+		actual = append(actual, change.String())
+	}
+
+	expected := []string{
+		`+ create group "Managed devices/Branch/Kiosks"`,
+		`+ create group "Managed devices/Lab"`,
+		`> rename tag "kiosk"`,
+		`+ create tag "server"`,
+		`- delete tag "obsolete"`,
+		`+ create tag-rule "server"`,
+		`+ create move-rule "Kiosks"`,
+		`~ update move-rule "Servers"`,
+		`+ create move-rule "Lab"`,
+		`~ reorder move-rule-order "move rules"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("\n expected: \n %s \n actual: \n %s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	var text bytes.Buffer
+	if err := plan.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), `enabled: "true" -> "false"`) {
+		t.Fatalf("unexpected plan text:\n%s", text.String())
+	}
+
+	if _, err := json.Marshal(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	*calls = nil
+	applied, err := engine.Apply(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(plan.Changes) {
+		t.Fatalf("expected %d applied changes, got %d", len(plan.Changes), len(applied))
+	}
+
+	for _, method := range []string{"HostGroup.AddGroup", "ListTags.RenameTag", "ListTags.AddTag", "ListTags.DeleteTags2",
+		"HostTagsRulesApi.UpdateRule", "HostMoveRules.AddRule", "HostMoveRules.UpdateRule", "HostMoveRules.SetRulesOrder"} {
+		if !strings.Contains(strings.Join(*calls, " "), method) {
+			t.Fatalf("%s was not called", method)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	_, err := reconcile.Parse([]byte(`{"moveRules": [{"name": "a", "group": "g"}, {"name": "A", "group": "g"}]}`), "json")
+	if err == nil {
+		t.Fatal("expected duplicate rule error")
+	}
+
+	_, err = reconcile.Parse([]byte(`{"roles": [{"name": "a", "products": [{"product": "1093", "version": "1.0.0.0", "allow": ["fly"]}]}]}`), "json")
+	if err == nil {
+		t.Fatal("expected unknown access right error")
+	}
+}
+
+const rolesDocument = `
+roles:
+  - name: Helpdesk
+    products:
+      - product: "1093"
+        version: 1.0.0.0
+        allow: [read]
+        areas:
+          - area: task:KLNAG_TASK_REMOTE_INSTALL
+            allow: [read, execute]
+  - name: Auditors
+    products:
+      - product: "1093"
+        version: 1.0.0.0
+        allow: [read]
+prune:
+  roles: true
+`
+
+func newRolesServer(t *testing.T) (*httptest.Server, *[]string) {
+	var calls []string
+	responses := map[string]string{
+		"HstAccessControl.FindRoles":  `{"strAccessor": "roles"}`,
+		"ChunkAccessor.GetItemsCount": `{"PxgRetVal": 3}`,
+		"HstAccessControl.AddRole":    `{"PxgRetVal": {"KLHST_ACL_ROLE_ID": 7}}`,
+		"HstAccessControl.GetRole": `{"PxgRetVal": {"KLHST_ACL_ROLE_ID": 5, "KLHST_ACL_ROLE_DN": "Helpdesk",
+			"KLHST_ACL_ROLE_PRODUCTS": [{"type": "params", "value": {"KLHST_ACL_PRODUCT_NAME": "1093",
+			"KLHST_ACL_PRODUCT_VERSION": "1.0.0.0", "KLHST_ACL_ALLOWMASK": 1}}]}}`,
+		"HstAccessControl.GetMappingFuncAreaToTasks":    `{"PxgRetVal": {"KLAUTH_AREA_GROUP_TASKS": ["KLNAG_TASK_REMOTE_INSTALL"]}}`,
+		"HstAccessControl.GetMappingFuncAreaToPolicies": `{"PxgRetVal": {}}`,
+		"HstAccessControl.GetMappingFuncAreaToReports":  `{"PxgRetVal": {}}`,
+		"HstAccessControl.GetMappingFuncAreaToSettings": `{"PxgRetVal": {}}`,
+		"HostMoveRules.GetRules":                        `{"PxgRetVal": []}`,
+		"ListTags.GetAllTags":                           `{"PxgRetVal": []}`,
+		"HostTagsRulesApi.GetRules":                     `{"PxgRetVal": {"KLHST_HTR_Rules": []}}`,
+		"ChunkAccessor.GetItemsChunk": `{"pChunk": {"KLCSP_ITERATOR_ARRAY": [
+			{"type": "params", "value": {"KLHST_ACL_ROLE_ID": 1, "KLHST_ACL_ROLE_DN": "Main Administrator", "KLHST_ACL_ROLE_BUILT_IN": true}},
+			{"type": "params", "value": {"KLHST_ACL_ROLE_ID": 5, "KLHST_ACL_ROLE_DN": "Helpdesk"}},
+			{"type": "params", "value": {"KLHST_ACL_ROLE_ID": 6, "KLHST_ACL_ROLE_DN": "Old"}}
+		]}}`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/api/v1.0/")
+		var body bytes.Buffer
+		_, _ = body.ReadFrom(r.Body)
+		calls = append(calls, method+" "+body.String())
+		if response, ok := responses[method]; ok {
+			w.Write([]byte(response))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	return srv, &calls
+}
+
+func TestPlanRoles(t *testing.T) {
+	srv, calls := newRolesServer(t)
+	defer srv.Close()
+
+	doc, err := reconcile.Parse([]byte(rolesDocument), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	engine := reconcile.New(kaspersky.New(kaspersky.Config{Server: srv.URL}))
+	plan, err := engine.Plan(ctx, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, change := range plan.Changes {
+		actual = append(actual, change.String())
+		for _, diff := range change.Diff {
+			actual = append(actual, "    "+diff.Field+": "+diff.From+" -> "+diff.To)
+		}
+	}
+	expected := []string{
+		`~ update role "Helpdesk"`,
+		`    1093/1.0.0.0 KLAUTH_AREA_GROUP_TASKS:  -> read,execute`,
+		`+ create role "Auditors"`,
+		`    1093/1.0.0.0:  -> read`,
+		`- delete role "Old"`,
+	}
+	if strings.Join(actual, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("\n expected: \n %s \n actual: \n %s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	*calls = nil
+	if _, err := engine.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+	applied := strings.Join(*calls, "\n")
+	for _, call := range []string{`HstAccessControl.UpdateRole {"nId":5,`, `HstAccessControl.AddRole {"pRoleData":{"KLHST_ACL_ROLE_NAME":"Auditors"`,
+		`HstAccessControl.DeleteRole {"nId":6,"bProtection":true}`, `"KLHST_ACL_FUNC_AREA":"KLAUTH_AREA_GROUP_TASKS"`} {
+		if !strings.Contains(applied, call) {
+			t.Fatalf("%s was not called:\n%s", call, applied)
+		}
+	}
+	if strings.Contains(applied, `"nId":1`) {
+		t.Fatalf("predefined role was changed:\n%s", applied)
+	}
+
+	doc.Roles = append(doc.Roles, reconcile.Role{Name: "main administrator"})
+	if _, err := engine.Plan(ctx, doc); err == nil {
+		t.Fatal("expected error for predefined role")
+	}
+}
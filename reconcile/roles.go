@@ -0,0 +1,270 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// funcAreaCatalog functional areas of a product and names of objects mapped to them
+type funcAreaCatalog struct {
+	// areas functional areas by lower-cased name
+	areas map[string]string
+
+	// objects functional areas by "<kind>:<lower-cased object name>", e.g. "task:klnag_task_remote_install"
+	objects map[string]string
+}
+
+// funcAreaCatalog returns functional areas of product, see HstAccessControl.GetMappingFuncAreaToTasks and others.
+func (e *Engine) funcAreaCatalog(ctx context.Context, product, version string) (*funcAreaCatalog, error) {
+	key := strings.ToLower(product + "/" + version)
+	if catalog, ok := e.funcAreas[key]; ok {
+		return catalog, nil
+	}
+
+	hac := e.client.HstAccessControl
+	mappings := []struct {
+		kind string
+		get  func(context.Context, string, string) (kaspersky.FuncAreaMapping, []byte, error)
+	}{
+		{"task", hac.GetMappingFuncAreaToTasks},
+		{"policy", hac.GetMappingFuncAreaToPolicies},
+		{"settings", hac.GetMappingFuncAreaToSettings},
+		{"report", hac.GetMappingFuncAreaToReports},
+	}
+
+	catalog := &funcAreaCatalog{areas: map[string]string{}, objects: map[string]string{}}
+	for _, m := range mappings {
+		mapping, _, err := m.get(ctx, product, version)
+		if err != nil {
+			return nil, fmt.Errorf("functional areas of %s/%s: %w", product, version, err)
+		}
+		for _, area := range mapping.Areas() {
+			catalog.areas[strings.ToLower(area)] = area
+			for _, name := range mapping[area] {
+				catalog.objects[m.kind+":"+strings.ToLower(name)] = area
+			}
+		}
+	}
+	e.funcAreas[key] = catalog
+	return catalog, nil
+}
+
+// resolve returns functional area by its name or by name of an object mapped to it, see RoleArea.
+func (c *funcAreaCatalog) resolve(name string) (string, error) {
+	if i := strings.Index(name, ":"); i > 0 {
+		switch kind := strings.ToLower(name[:i]); kind {
+		case "task", "policy", "settings", "report":
+			if area, ok := c.objects[kind+":"+strings.ToLower(strings.TrimSpace(name[i+1:]))]; ok {
+				return area, nil
+			}
+			return "", fmt.Errorf("no functional area for %s %q", kind, name[i+1:])
+		}
+	}
+
+	if area, ok := c.areas[strings.ToLower(name)]; ok {
+		return area, nil
+	}
+	return "", fmt.Errorf("unknown functional area %q", name)
+}
+
+// roleData returns role attributes of the document role with functional areas resolved.
+func (e *Engine) roleData(ctx context.Context, role Role) (kaspersky.RoleData, error) {
+	data := kaspersky.RoleData{Name: role.Name, DisplayName: role.Name}
+	for _, product := range role.Products {
+		allow, deny, err := rights(product.Allow, product.Deny)
+		if err != nil {
+			return data, err
+		}
+		p := kaspersky.RoleProduct{Product: product.Product, Version: product.Version, Allow: allow, Deny: deny}
+
+		if len(product.Areas) != 0 {
+			catalog, err := e.funcAreaCatalog(ctx, product.Product, product.Version)
+			if err != nil {
+				return data, err
+			}
+
+			for _, area := range product.Areas {
+				name, err := catalog.resolve(area.Area)
+				if err != nil {
+					return data, fmt.Errorf("product %s/%s: %w", product.Product, product.Version, err)
+				}
+				allow, deny, err := rights(area.Allow, area.Deny)
+				if err != nil {
+					return data, err
+				}
+				p.FuncAreas = append(p.FuncAreas, kaspersky.RoleFuncAreaItem{
+					Type:  "params",
+					Value: kaspersky.RoleFuncArea{FuncArea: name, Allow: allow, Deny: deny},
+				})
+			}
+		}
+		data.Products = append(data.Products, kaspersky.RoleProductItem{Type: "params", Value: p})
+	}
+	return data, nil
+}
+
+// roleRights returns access rights of the role as text by "product/version" and "product/version area".
+func roleRights(role *kaspersky.RoleData) map[string]string {
+	text := func(allow, deny int64) string {
+		s := strings.Join(kaspersky.AccessRightNames(allow), ",")
+		if deny != 0 {
+			s += " deny " + strings.Join(kaspersky.AccessRightNames(deny), ",")
+		}
+		return strings.TrimSpace(s)
+	}
+
+	result := map[string]string{}
+	for _, item := range role.Products {
+		p := item.Value
+		product := p.Product + "/" + p.Version
+		if p.Allow != 0 || p.Deny != 0 {
+			result[product] = text(p.Allow, p.Deny)
+		}
+		for _, area := range p.FuncAreas {
+			if area.Value.Allow != 0 || area.Value.Deny != 0 {
+				result[product+" "+area.Value.FuncArea] = text(area.Value.Allow, area.Value.Deny)
+			}
+		}
+	}
+	return result
+}
+
+// diffRoles returns access rights which differ between live and desired roles.
+func diffRoles(live, desired *kaspersky.RoleData) []FieldDiff {
+	from, to := roleRights(live), roleRights(desired)
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var fields []FieldDiff
+	for _, key := range keys {
+		fields = append(fields, field(key, from[key], to[key]))
+	}
+	return diffFields(fields...)
+}
+
+func (e *Engine) planRoles(ctx context.Context, doc *Document, plan *Plan) error {
+	if len(doc.Roles) == 0 && !doc.Prune.Roles {
+		return nil
+	}
+
+	live, err := e.client.HstAccessControl.FindRolesList(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]kaspersky.RoleValue{}
+	for _, role := range live {
+		for _, name := range []*string{role.KlhstACLRoleName, role.KlhstACLRoleDN} {
+			if name != nil && *name != "" {
+				byName[strings.ToLower(*name)] = role
+			}
+		}
+	}
+
+	wanted := map[int64]bool{}
+	for _, role := range doc.Roles {
+		desired, err := e.roleData(ctx, role)
+		if err != nil {
+			return fmt.Errorf("role %q: %w", role.Name, err)
+		}
+
+		existing, ok := byName[strings.ToLower(role.Name)]
+		if !ok || existing.KlhstACLRoleID == nil {
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindRole, Action: Create, Name: role.Name,
+				Diff: diffRoles(&kaspersky.RoleData{}, &desired),
+				apply: func(ctx context.Context, e *Engine) error {
+					_, _, err := e.client.HstAccessControl.AddRole(ctx, desired)
+					return err
+				},
+			})
+			continue
+		}
+
+		id := *existing.KlhstACLRoleID
+		if existing.KlhstACLRoleBuiltIn != nil && *existing.KlhstACLRoleBuiltIn {
+			return fmt.Errorf("role %q: predefined roles are not managed", role.Name)
+		}
+		wanted[id] = true
+
+		current, _, err := e.client.HstAccessControl.GetRole(ctx, kaspersky.TRParams{
+			NID: id, PFieldsToReturn: kaspersky.RoleDataAttributes,
+		})
+		if err != nil {
+			return fmt.Errorf("role %q: %w", role.Name, err)
+		}
+
+		if diff := diffRoles(current, &desired); len(diff) != 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindRole, Action: Update, Name: role.Name, Diff: diff,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.HstAccessControl.UpdateRole(ctx, id, desired, true)
+					return err
+				},
+			})
+		}
+	}
+
+	if doc.Prune.Roles {
+		for _, role := range live {
+			if role.KlhstACLRoleID == nil || wanted[*role.KlhstACLRoleID] {
+				continue
+			}
+			if role.KlhstACLRoleBuiltIn != nil && *role.KlhstACLRoleBuiltIn {
+				continue
+			}
+
+			id := *role.KlhstACLRoleID
+			name := fmt.Sprint(id)
+			if role.KlhstACLRoleDN != nil && *role.KlhstACLRoleDN != "" {
+				name = *role.KlhstACLRoleDN
+			} else if role.KlhstACLRoleName != nil && *role.KlhstACLRoleName != "" {
+				name = *role.KlhstACLRoleName
+			}
+			plan.Changes = append(plan.Changes, Change{
+				Kind: KindRole, Action: Delete, Name: name,
+				apply: func(ctx context.Context, e *Engine) error {
+					_, err := e.client.HstAccessControl.DeleteRole(ctx, id, true)
+					return err
+				},
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// liveMoveRule host moving rule as it exists on the server
+type liveMoveRule struct {
+	ID int64
+	MoveRule
+}
+
+// liveTagRule host automatic tagging rule as it exists on the server
+type liveTagRule struct {
+	Name    string `json:"KLHST_HTR_DN"`
+	Enabled bool   `json:"KLHST_HTR_Enabled"`
+	Tag     string `json:"KLHST_HTR_TagValue"`
+	Query   string `json:"KLHST_HTR_Query"`
+}
+
+type tagRules struct {
+	PxgRetVal struct {
+		Rules []struct {
+			Value liveTagRule `json:"value"`
+		} `json:"KLHST_HTR_Rules"`
+	} `json:"PxgRetVal"`
+}
+
+// moveRules returns host moving rules in the order of execution.
+func (e *Engine) moveRules(ctx context.Context) ([]liveMoveRule, error) {
+	rules, _, err := e.client.HostMoveRules.GetRules(ctx, kaspersky.Rules{PFields: []string{
+		"KLHST_MR_ID", "KLHST_MR_DN", "KLHST_MR_Group", "KLHST_MR_Query",
+		"KLHST_MR_Enabled", "KLHST_MR_Options", "KLHST_MR_AutoDelete",
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []liveMoveRule
+	for _, rule := range rules.HMRules {
+		if rule.HMRule == nil {
+			continue
+		}
+
+		group, err := e.client.HostGroup.GroupPath(ctx, rule.HMRule.KLHSTMRGroup)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, liveMoveRule{
+			ID: rule.HMRule.KlhstMrID,
+			MoveRule: MoveRule{
+				Name:       rule.HMRule.KlhstMrDN,
+				Group:      group,
+				Query:      rule.HMRule.KLHSTMRQuery,
+				Enabled:    kaspersky.Bool(rule.HMRule.KLHSTMREnabled),
+				Options:    rule.HMRule.KLHSTMROptions,
+				AutoDelete: rule.HMRule.KLHSTMRAutoDelete,
+			},
+		})
+	}
+	return result, nil
+}
+
+// tags returns all host tags.
+func (e *Engine) tags(ctx context.Context) ([]string, error) {
+	raw, err := e.client.ListTags.GetAllTags(ctx, tagParams{PParams: &kaspersky.Null{}})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := new(kaspersky.PxgValArrayOfString)
+	if err := json.Unmarshal(raw, tags); err != nil {
+		return nil, err
+	}
+	return tags.Array, nil
+}
+
+// tagRules returns all host automatic tagging rules.
+func (e *Engine) tagRules(ctx context.Context) ([]liveTagRule, error) {
+	raw, err := e.client.HostTagsRulesApi.GetRules(ctx, kaspersky.HostTagsRulesParams{
+		PFields2ReturnArray: []string{"KLHST_HTR_DN", "KLHST_HTR_Enabled", "KLHST_HTR_TagValue", "KLHST_HTR_Query"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := new(tagRules)
+	if err := json.Unmarshal(raw, rules); err != nil {
+		return nil, err
+	}
+
+	result := make([]liveTagRule, 0, len(rules.PxgRetVal.Rules))
+	for _, rule := range rules.PxgRetVal.Rules {
+		result = append(result, rule.Value)
+	}
+	return result, nil
+}
+
+// tagParams params of ListTags methods
+type tagParams struct {
+	SzwTagValue    string          `json:"szwTagValue,omitempty"`
+	SzwOldTagValue string          `json:"szwOldTagValue,omitempty"`
+	SzwNewTagValue string          `json:"szwNewTagValue,omitempty"`
+	PTagValue      []string        `json:"pTagValue,omitempty"`
+	PParams        *kaspersky.Null `json:"pParams,omitempty"`
+}
+
+// moveRuleInfo host moving rule attributes for HostMoveRules.AddRule and HostMoveRules.UpdateRule
+type moveRuleInfo struct {
+	Name       string `json:"KLHST_MR_DN"`
+	Group      int64  `json:"KLHST_MR_Group"`
+	Query      string `json:"KLHST_MR_Query"`
+	Enabled    bool   `json:"KLHST_MR_Enabled"`
+	Options    int64  `json:"KLHST_MR_Options"`
+	AutoDelete bool   `json:"KLHST_MR_AutoDelete"`
+}
+
+type addMoveRuleParams struct {
+	PRule moveRuleInfo `json:"pRule"`
+}
+
+type updateMoveRuleParams struct {
+	NRule int64        `json:"nRule"`
+	PRule moveRuleInfo `json:"pRule"`
+}
@@ -0,0 +1,344 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package vapm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Decisions recorded in the audit log
+const (
+	DecisionApprove      = "approve"
+	DecisionDecline      = "decline"
+	DecisionAcceptEulas  = "accept_eulas"
+	DecisionEulaRequired = "eula_required"
+)
+
+// Decision decision of Apply
+type Decision struct {
+	Time time.Time `json:"time"`
+
+	// Decision e.g. DecisionApprove
+	Decision string `json:"decision"`
+
+	// Rule name of the policy rule the decision is made by
+	Rule string `json:"rule,omitempty"`
+
+	// DryRun decision was not applied to the server
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Update the update decided on, empty for DecisionAcceptEulas
+	Update *UpdateRef `json:"update,omitempty"`
+
+	// EulaIDs EULAs required by the update or accepted
+	EulaIDs []int64 `json:"eulaIds,omitempty"`
+
+	// Error error of applying the decision to the server
+	Error string `json:"error,omitempty"`
+}
+
+// UpdateRef identity of update recorded in the audit log
+type UpdateRef struct {
+	Source        int64  `json:"source"`
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Vendor        string `json:"vendor,omitempty"`
+	KB            string `json:"kb,omitempty"`
+	Severity      int64  `json:"severity"`
+	PreviousState int64  `json:"previousState"`
+}
+
+func updateRef(update kaspersky.UpdateRecord) *UpdateRef {
+	return &UpdateRef{
+		Source:        update.Source,
+		ID:            update.DbID(),
+		Name:          update.DisplayName,
+		Vendor:        update.Vendor,
+		KB:            update.KB,
+		Severity:      update.Severity,
+		PreviousState: update.ApprovalState,
+	}
+}
+
+// AuditLog records decisions of Apply
+type AuditLog interface {
+	Record(ctx context.Context, decision Decision) error
+}
+
+// AuditFunc function implementing AuditLog
+type AuditFunc func(ctx context.Context, decision Decision) error
+
+// Record calls f(ctx, decision).
+func (f AuditFunc) Record(ctx context.Context, decision Decision) error {
+	return f(ctx, decision)
+}
+
+type jsonAudit struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAudit returns audit log writing decisions to w as JSON Lines, e.g. to a file opened for appending.
+func NewJSONAudit(w io.Writer) AuditLog {
+	return &jsonAudit{enc: json.NewEncoder(w)}
+}
+
+func (a *jsonAudit) Record(ctx context.Context, decision Decision) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enc.Encode(decision)
+}
+
+// Options options of Apply
+type Options struct {
+	// Filter srvview filter of updates to decide on, all updates by default
+	Filter string
+
+	// DryRun record decisions without applying them
+	DryRun bool
+
+	// BatchSize number of updates approved or declined by one VapmControlApi.ChangeApproval call
+	// and EULAs are requested for by one VapmControlApi.GetEulasIdsForUpdates call, 100 by default
+	BatchSize int
+
+	// Lcid preferred LCID of EULAs
+	Lcid int64
+}
+
+// Result result of Apply
+type Result struct {
+	Approved      int `json:"approved"`
+	Declined      int `json:"declined"`
+	Unchanged     int `json:"unchanged"`
+	Unmatched     int `json:"unmatched"`
+	EulaRequired  int `json:"eulaRequired"`
+	EulasAccepted int `json:"eulasAccepted"`
+}
+
+type decided struct {
+	update kaspersky.UpdateRecord
+	rule   *Rule
+	eulas  []int64
+}
+
+// Apply approves or declines updates matching opts.Filter by the first matching rule of policy.
+//
+// Updates already in the state required by their rule are left as is. EULAs required by updates to be approved
+// are accepted if policy.AcceptEulas is set, otherwise such updates are not approved and recorded as
+// DecisionEulaRequired. Every decision is recorded in audit.
+func Apply(ctx context.Context, client *kaspersky.Client, policy *Policy, audit AuditLog, opts Options) (*Result, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	updates, err := Updates(ctx, client, opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	record := func(d Decision) error {
+		d.Time = time.Now().UTC()
+		d.DryRun = opts.DryRun
+		return audit.Record(ctx, d)
+	}
+
+	var pending, approve, decline []decided
+	var eulas []int64
+	seenEulas := map[int64]bool{}
+	for _, update := range updates {
+		rule := policy.Rule(update)
+		if rule == nil {
+			result.Unmatched++
+			continue
+		}
+
+		state := kaspersky.ApprovementStateApproved
+		if rule.Action == ActionDecline {
+			state = kaspersky.ApprovementStateDeclined
+		}
+		if update.ApprovalState == state {
+			result.Unchanged++
+			continue
+		}
+
+		if state == kaspersky.ApprovementStateDeclined {
+			decline = append(decline, decided{update: update, rule: rule})
+			continue
+		}
+
+		pending = append(pending, decided{update: update, rule: rule})
+	}
+
+	if err := requestEulas(ctx, client, pending, opts.Lcid, batchSize); err != nil {
+		return result, err
+	}
+	for _, d := range pending {
+		if len(d.eulas) != 0 && !policy.AcceptEulas {
+			result.EulaRequired++
+			err := record(Decision{Decision: DecisionEulaRequired, Rule: d.rule.Name, Update: updateRef(d.update), EulaIDs: d.eulas})
+			if err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		for _, id := range d.eulas {
+			if !seenEulas[id] {
+				seenEulas[id] = true
+				eulas = append(eulas, id)
+			}
+		}
+		approve = append(approve, d)
+	}
+
+	if len(eulas) != 0 {
+		var applyErr error
+		if !opts.DryRun {
+			_, applyErr = client.VapmControlApi.AcceptEulas(ctx, kaspersky.PEulaIDParams{PEulaIDs: eulas})
+		}
+		if err := record(Decision{Decision: DecisionAcceptEulas, EulaIDs: eulas, Error: errorString(applyErr)}); err != nil {
+			return result, err
+		}
+		if applyErr != nil {
+			return result, applyErr
+		}
+		result.EulasAccepted = len(eulas)
+	}
+
+	n, err := changeApproval(ctx, client, approve, kaspersky.ApprovementStateApproved, DecisionApprove, batchSize, opts.DryRun, record)
+	result.Approved = n
+	if err != nil {
+		return result, err
+	}
+	result.Declined, err = changeApproval(ctx, client, decline, kaspersky.ApprovementStateDeclined, DecisionDecline, batchSize, opts.DryRun, record)
+	return result, err
+}
+
+// requestEulas sets EULAs required by updates. EULAs are requested in batches, updates of a batch
+// requiring EULAs are then requested one by one to know the EULAs of each update.
+func requestEulas(ctx context.Context, client *kaspersky.Client, updates []decided, lcid int64, batchSize int) error {
+	for start := 0; start < len(updates); start += batchSize {
+		end := start + batchSize
+		if end > len(updates) {
+			end = len(updates)
+		}
+		batch := updates[start:end]
+
+		ids, err := eulaIDs(ctx, client, batch, lcid)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		if len(batch) == 1 {
+			batch[0].eulas = ids
+			continue
+		}
+		for i := range batch {
+			if batch[i].eulas, err = eulaIDs(ctx, client, batch[i:i+1], lcid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func eulaIDs(ctx context.Context, client *kaspersky.Client, updates []decided, lcid int64) ([]int64, error) {
+	params := kaspersky.EulasIdsForUpdatesParams{NLcid: lcid}
+	for _, d := range updates {
+		params.PUpdates = append(params.PUpdates, kaspersky.EulasIdsPUpdate{
+			Type:  "params",
+			Value: kaspersky.ApprovalValue{NSource: d.update.Source, NPatchDbId: d.update.DbID()},
+		})
+	}
+	ids, err := client.VapmControlApi.GetEulasIdsForUpdates(ctx, params)
+	if err != nil {
+		if len(updates) == 1 {
+			return nil, fmt.Errorf("update %s: %w", updates[0].update.DisplayName, err)
+		}
+		return nil, fmt.Errorf("EULAs of %d updates: %w", len(updates), err)
+	}
+	return ids.PEulasID, nil
+}
+
+// changeApproval sets approval state of updates in batches and records decisions. It returns number of updates changed.
+func changeApproval(ctx context.Context, client *kaspersky.Client, updates []decided, state int64, decision string,
+	batchSize int, dryRun bool, record func(Decision) error) (int, error) {
+	changed := 0
+	for start := 0; start < len(updates); start += batchSize {
+		end := start + batchSize
+		if end > len(updates) {
+			end = len(updates)
+		}
+		batch := updates[start:end]
+
+		var applyErr error
+		if !dryRun {
+			params := kaspersky.ChangeApprovalParams{NApprovementState: state}
+			for _, d := range batch {
+				params.PUpdates = append(params.PUpdates, kaspersky.PUpdate{
+					Type:  "params",
+					Value: kaspersky.PUpdateValue{NSource: d.update.Source, NPatchDBID: d.update.DbID()},
+				})
+			}
+			_, applyErr = client.VapmControlApi.ChangeApproval(ctx, params)
+		}
+
+		for _, d := range batch {
+			err := record(Decision{
+				Decision: decision,
+				Rule:     d.rule.Name,
+				Update:   updateRef(d.update),
+				EulaIDs:  d.eulas,
+				Error:    errorString(applyErr),
+			})
+			if err != nil {
+				return changed, err
+			}
+		}
+		if applyErr != nil {
+			return changed, applyErr
+		}
+		changed += len(batch)
+	}
+	return changed, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,147 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package vapm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/pixfid/go-ksc/internal/pattern"
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Rule actions
+const (
+	ActionApprove = "approve"
+	ActionDecline = "decline"
+)
+
+// Rule approval rule of updates, patterns are matched by pattern.MatchAny.
+// Update matches the rule if it matches all set conditions.
+type Rule struct {
+	// Name rule name recorded in the audit log
+	Name string `json:"name" yaml:"name"`
+
+	// Action ActionApprove or ActionDecline
+	Action string `json:"action" yaml:"action"`
+
+	// Vendors patterns of update vendor, e.g. "Microsoft*"
+	Vendors []string `json:"vendors,omitempty" yaml:"vendors,omitempty"`
+
+	// Names patterns of update display name
+	Names []string `json:"names,omitempty" yaml:"names,omitempty"`
+
+	// KB patterns of update KB id, e.g. "KB45*"
+	KB []string `json:"kb,omitempty" yaml:"kb,omitempty"`
+
+	// MinSeverity, MaxSeverity severity range of updates, names or numbers, e.g. "high"
+	MinSeverity string `json:"minSeverity,omitempty" yaml:"minSeverity,omitempty"`
+	MaxSeverity string `json:"maxSeverity,omitempty" yaml:"maxSeverity,omitempty"`
+}
+
+// Policy approval policy
+type Policy struct {
+	// Rules approval rules, the first rule matching an update decides
+	Rules []Rule `json:"rules" yaml:"rules"`
+
+	// AcceptEulas accept EULAs required by approved updates. Otherwise updates requiring EULAs which are not
+	// accepted yet are left as is.
+	AcceptEulas bool `json:"acceptEulas,omitempty" yaml:"acceptEulas,omitempty"`
+}
+
+// LoadPolicy reads policy from JSON (.json) or YAML (.yaml, .yml) file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(Policy)
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		err = json.Unmarshal(data, policy)
+	} else {
+		err = yaml.Unmarshal(data, policy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return policy, policy.Validate()
+}
+
+// Validate checks rules for empty names, unknown actions and severities.
+func (p *Policy) Validate() error {
+	seen := map[string]bool{}
+	for i, rule := range p.Rules {
+		switch {
+		case rule.Name == "":
+			return fmt.Errorf("rules[%d]: empty name", i)
+		case seen[rule.Name]:
+			return fmt.Errorf("rule %q: duplicate name", rule.Name)
+		case rule.Action != ActionApprove && rule.Action != ActionDecline:
+			return fmt.Errorf("rule %q: unknown action %q, expected %s or %s", rule.Name, rule.Action, ActionApprove, ActionDecline)
+		}
+		seen[rule.Name] = true
+
+		for _, severity := range []string{rule.MinSeverity, rule.MaxSeverity} {
+			if _, ok := ParseSeverity(severity); severity != "" && !ok {
+				return fmt.Errorf("rule %q: unknown severity %q", rule.Name, severity)
+			}
+		}
+		for _, expr := range append(append(append([]string{}, rule.Vendors...), rule.Names...), rule.KB...) {
+			if _, err := path.Match(expr, ""); err != nil {
+				return fmt.Errorf("rule %q: pattern %q: %w", rule.Name, expr, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports whether update matches the rule.
+func (r Rule) Match(update kaspersky.UpdateRecord) bool {
+	if min, ok := ParseSeverity(r.MinSeverity); ok && update.Severity < min {
+		return false
+	}
+	if max, ok := ParseSeverity(r.MaxSeverity); ok && update.Severity > max {
+		return false
+	}
+	return pattern.MatchAny(r.Vendors, update.Vendor) && pattern.MatchAny(r.Names, update.DisplayName) &&
+		pattern.MatchAny(r.KB, update.KB)
+}
+
+// Rule returns the first rule matching update, nil if there is none.
+func (p *Policy) Rule(update kaspersky.UpdateRecord) *Rule {
+	for i := range p.Rules {
+		if p.Rules[i].Match(update) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
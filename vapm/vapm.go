@@ -0,0 +1,142 @@
+/*
+ * MIT License
+ *
+ * Copyright (c) [2020] [Semchenko Aleksandr]
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package vapm implements vulnerability and patch approval workflow on top of VapmControlApi.
+//
+// Vulnerabilities lists vulnerabilities found on managed hosts with host counts. Apply approves or declines
+// updates in bulk by rules of a Policy, accepts EULAs required by approved updates if the policy allows it
+// and records every decision in an audit log.
+package vapm
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pixfid/go-ksc/kaspersky"
+)
+
+// Severities of vulnerabilities and updates
+const (
+	SeverityNone     int64 = 0
+	SeverityLow      int64 = 1
+	SeverityMedium   int64 = 2
+	SeverityHigh     int64 = 3
+	SeverityCritical int64 = 4
+)
+
+var severityNames = []string{"none", "low", "medium", "high", "critical"}
+
+// SeverityName returns name of severity, e.g. "critical".
+func SeverityName(severity int64) string {
+	if severity >= 0 && severity < int64(len(severityNames)) {
+		return severityNames[severity]
+	}
+	return "unknown"
+}
+
+// ParseSeverity returns severity by its name or number.
+func ParseSeverity(name string) (int64, bool) {
+	for i, s := range severityNames {
+		if strings.EqualFold(name, s) || name == strconv.Itoa(i) {
+			return int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// Vulnerability software vulnerability found on managed hosts
+type Vulnerability struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Severity       int64    `json:"severity"`
+	ProductName    string   `json:"productName,omitempty"`
+	ProductVersion string   `json:"productVersion,omitempty"`
+	CVE            []string `json:"cve,omitempty"`
+	FixAvailable   bool     `json:"fixAvailable"`
+
+	// Hosts ids of hosts the vulnerability is found on
+	Hosts []string `json:"hosts"`
+}
+
+// Vulnerabilities returns vulnerabilities matching srvview filter, e.g. (KLVULNR_SEVERITY >= 3),
+// ordered by severity and number of hosts, most severe and widespread first.
+func Vulnerabilities(ctx context.Context, client *kaspersky.Client, filter string) ([]Vulnerability, error) {
+	var records []kaspersky.VulnerabilityRecord
+	if err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{Filter: filter}, &records); err != nil {
+		return nil, err
+	}
+
+	index := map[string]*Vulnerability{}
+	hosts := map[string]map[string]bool{}
+	var result []*Vulnerability
+	for _, record := range records {
+		v, ok := index[record.VulnerabilityID]
+		if !ok {
+			v = &Vulnerability{
+				ID:             record.VulnerabilityID,
+				Name:           record.Name,
+				Severity:       record.Severity,
+				ProductName:    record.ProductName,
+				ProductVersion: record.ProductVersion,
+				CVE:            record.CVE,
+				FixAvailable:   record.FixAvailable,
+				Hosts:          []string{},
+			}
+			index[record.VulnerabilityID] = v
+			hosts[record.VulnerabilityID] = map[string]bool{}
+			result = append(result, v)
+		}
+		if record.HostName != "" && !hosts[v.ID][record.HostName] {
+			hosts[v.ID][record.HostName] = true
+			v.Hosts = append(v.Hosts, record.HostName)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if len(a.Hosts) != len(b.Hosts) {
+			return len(a.Hosts) > len(b.Hosts)
+		}
+		return a.ID < b.ID
+	})
+
+	list := make([]Vulnerability, len(result))
+	for i, v := range result {
+		sort.Strings(v.Hosts)
+		list[i] = *v
+	}
+	return list, nil
+}
+
+// Updates returns software updates matching srvview filter, e.g. (nApprovementState = 0).
+func Updates(ctx context.Context, client *kaspersky.Client, filter string) ([]kaspersky.UpdateRecord, error) {
+	var updates []kaspersky.UpdateRecord
+	err := client.SrvView.Query(ctx, kaspersky.SrvViewQuery{Filter: filter}, &updates)
+	return updates, err
+}
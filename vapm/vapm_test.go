@@ -0,0 +1,186 @@
+package vapm_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pixfid/go-ksc/internal/ksctest"
+	"github.com/pixfid/go-ksc/kaspersky"
+	"github.com/pixfid/go-ksc/vapm"
+)
+
+const updates = `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+	{"type": "params", "value": {"nSource": 2, "nRevisionID": 11, "wstrDisplayName": "Security Update for Windows",
+		"wstrVendor": "Microsoft", "wstrKBId": "KB4556799", "nSeverity": 4, "nApprovementState": 0}},
+	{"type": "params", "value": {"nSource": 2, "nRevisionID": 12, "wstrDisplayName": "Preview of Monthly Rollup",
+		"wstrVendor": "Microsoft", "wstrKBId": "KB4556800", "nSeverity": 1, "nApprovementState": 0}},
+	{"type": "params", "value": {"nSource": 3, "nPatchDbId": 21, "wstrDisplayName": "Java 8 Update 251",
+		"wstrVendor": "Oracle", "nSeverity": 3, "nApprovementState": 0}},
+	{"type": "params", "value": {"nSource": 3, "nPatchDbId": 22, "wstrDisplayName": "Firefox 77",
+		"wstrVendor": "Mozilla", "nSeverity": 3, "nApprovementState": 1}},
+	{"type": "params", "value": {"nSource": 3, "nPatchDbId": 23, "wstrDisplayName": "Toolbar",
+		"wstrVendor": "Unknown", "nSeverity": 2, "nApprovementState": 0}}
+]}}`
+
+const vulnerabilities = `{"pRecords": {"KLCSP_ITERATOR_ARRAY": [
+	{"type": "params", "value": {"KLVULNR_VULN_ID": "v1", "KLVULNR_VULN_NAME": "Java RCE", "KLVULNR_SEVERITY": 3,
+		"KLVULNR_FIX_AVAILABLE": true, "KLHST_WKS_HOSTNAME": "h1"}},
+	{"type": "params", "value": {"KLVULNR_VULN_ID": "v2", "KLVULNR_VULN_NAME": "Windows RCE", "KLVULNR_SEVERITY": 4,
+		"KLVULNR_FIX_AVAILABLE": true, "KLHST_WKS_HOSTNAME": "h1"}},
+	{"type": "params", "value": {"KLVULNR_VULN_ID": "v1", "KLVULNR_VULN_NAME": "Java RCE", "KLVULNR_SEVERITY": 3,
+		"KLVULNR_FIX_AVAILABLE": true, "KLHST_WKS_HOSTNAME": "h2"}},
+	{"type": "params", "value": {"KLVULNR_VULN_ID": "v1", "KLVULNR_VULN_NAME": "Java RCE", "KLVULNR_SEVERITY": 3,
+		"KLVULNR_FIX_AVAILABLE": true, "KLHST_WKS_HOSTNAME": "h2"}}
+]}}`
+
+// fakeKSC returns fake Administration Server and bodies of requests changing its state.
+func fakeKSC(t *testing.T) (*ksctest.Server, *[]string) {
+	srv := ksctest.NewServer(t)
+	var view string
+	var changes []string
+	srv.Handle("SrvView.ResetIterator", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			View string `json:"wstrViewName"`
+		}
+		ksctest.Params(r, &in)
+		view = in.View
+		w.Write([]byte(`{"wstrIteratorId": "it"}`))
+	})
+	srv.Reply("SrvView.GetRecordCount", `{"PxgRetVal": 5}`)
+	srv.Handle("SrvView.GetRecordRange", func(w http.ResponseWriter, r *http.Request) {
+		if view == kaspersky.SrvViewVulnerabilities {
+			w.Write([]byte(vulnerabilities))
+			return
+		}
+		w.Write([]byte(updates))
+	})
+	srv.Handle("VapmControlApi.GetEulasIdsForUpdates", func(w http.ResponseWriter, r *http.Request) {
+		if bytes.Contains(ksctest.Params(r, nil), []byte(`"nPatchDbId":21`)) {
+			w.Write([]byte(`{"pEulaIds": [501]}`))
+			return
+		}
+		w.Write([]byte(`{"pEulaIds": []}`))
+	})
+	change := func(w http.ResponseWriter, r *http.Request) {
+		changes = append(changes, strings.TrimPrefix(r.URL.Path, "/api/v1.0/")+" "+string(ksctest.Params(r, nil)))
+		w.Write([]byte(`{}`))
+	}
+	srv.Handle("VapmControlApi.AcceptEulas", change)
+	srv.Handle("VapmControlApi.ChangeApproval", change)
+	return srv, &changes
+}
+
+func TestVulnerabilities(t *testing.T) {
+	srv, _ := fakeKSC(t)
+	client := srv.NewClient()
+
+	list, err := vapm.Vulnerabilities(context.Background(), client, "")
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, 2, len(list))
+	ksctest.ExpectEqual(t, "v2", list[0].ID)
+	ksctest.ExpectEqual(t, []string{"h1"}, list[0].Hosts)
+	ksctest.ExpectEqual(t, "v1", list[1].ID)
+	ksctest.ExpectEqual(t, []string{"h1", "h2"}, list[1].Hosts)
+}
+
+const policy = `
+rules:
+  - name: critical Microsoft
+    action: approve
+    vendors: [Microsoft*]
+    minSeverity: high
+  - name: no previews
+    action: decline
+    names: ["Preview*"]
+  - name: third-party
+    action: approve
+    vendors: [Oracle, Mozilla]
+`
+
+func loadPolicy(t *testing.T, acceptEulas bool) *vapm.Policy {
+	f, err := ioutil.TempFile("", "policy*.yaml")
+	ksctest.ExpectSucceeded(t, err)
+	defer f.Close()
+	t.Cleanup(func() { _ = os.Remove(f.Name()) })
+
+	_, err = f.WriteString(policy)
+	ksctest.ExpectSucceeded(t, err)
+	p, err := vapm.LoadPolicy(f.Name())
+	ksctest.ExpectSucceeded(t, err)
+	p.AcceptEulas = acceptEulas
+	return p
+}
+
+func decisions(log *bytes.Buffer) []string {
+	var result []string
+	dec := json.NewDecoder(log)
+	for dec.More() {
+		var d vapm.Decision
+		if err := dec.Decode(&d); err != nil {
+			panic(err)
+		}
+		s := d.Decision + " " + d.Rule
+		if d.Update != nil {
+			s += " " + d.Update.Name
+		}
+		result = append(result, strings.TrimSpace(s))
+	}
+	return result
+}
+
+func TestApply(t *testing.T) {
+	srv, changes := fakeKSC(t)
+	client := srv.NewClient()
+	ctx := context.Background()
+
+	// EULAs are not allowed: Java is left as is
+	var log bytes.Buffer
+	result, err := vapm.Apply(ctx, client, loadPolicy(t, false), vapm.NewJSONAudit(&log), vapm.Options{DryRun: true})
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, &vapm.Result{Approved: 1, Declined: 1, Unchanged: 1, Unmatched: 1, EulaRequired: 1}, result)
+	ksctest.ExpectEqual(t, []string{
+		"eula_required third-party Java 8 Update 251",
+		"approve critical Microsoft Security Update for Windows",
+		"decline no previews Preview of Monthly Rollup",
+	}, decisions(&log))
+	ksctest.ExpectEqual(t, 0, len(*changes))
+	// EULAs of both updates are requested at once, then one by one since Java requires one
+	ksctest.ExpectEqual(t, 3, srv.Calls("VapmControlApi.GetEulasIdsForUpdates"))
+
+	log.Reset()
+	result, err = vapm.Apply(ctx, client, loadPolicy(t, true), vapm.NewJSONAudit(&log), vapm.Options{})
+	ksctest.ExpectSucceeded(t, err)
+	ksctest.ExpectEqual(t, &vapm.Result{Approved: 2, Declined: 1, Unchanged: 1, Unmatched: 1, EulasAccepted: 1}, result)
+	ksctest.ExpectEqual(t, []string{
+		"accept_eulas",
+		"approve critical Microsoft Security Update for Windows",
+		"approve third-party Java 8 Update 251",
+		"decline no previews Preview of Monthly Rollup",
+	}, decisions(&log))
+	ksctest.ExpectEqual(t, []string{
+		`VapmControlApi.AcceptEulas {"pEulaIDs":[501]}`,
+		`VapmControlApi.ChangeApproval {"pUpdates":[{"type":"params","value":{"nSource":2,"nPatchDbId":11}},` +
+			`{"type":"params","value":{"nSource":3,"nPatchDbId":21}}],"nApprovementState":1}`,
+		`VapmControlApi.ChangeApproval {"pUpdates":[{"type":"params","value":{"nSource":2,"nPatchDbId":12}}],` +
+			`"nApprovementState":2}`,
+	}, *changes)
+}
+
+func TestPolicyValidate(t *testing.T) {
+	for _, p := range []vapm.Policy{
+		{Rules: []vapm.Rule{{Action: vapm.ActionApprove}}},
+		{Rules: []vapm.Rule{{Name: "a", Action: "install"}}},
+		{Rules: []vapm.Rule{{Name: "a", Action: vapm.ActionApprove, MinSeverity: "urgent"}}},
+		{Rules: []vapm.Rule{{Name: "a", Action: vapm.ActionApprove}, {Name: "a", Action: vapm.ActionDecline}}},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", p)
+		}
+	}
+}